@@ -0,0 +1,45 @@
+// File contains Abandon functionality
+//
+// https://tools.ietf.org/html/rfc4511
+//
+// AbandonRequest ::= [APPLICATION 16] MessageID
+//
+
+package ldap
+
+import (
+	"github.com/gostores/encoding/asn1"
+)
+
+// Abandon asks the server to stop processing the request with the given
+// message ID, as reported by the MessageID of the events of PersistentSearch
+// and Syncrepl. The operation waiting for the request returns an error, the
+// server sending no response to abandoned requests.
+func (l *Conn) Abandon(messageID int64) error {
+	if err := l.abandon(messageID); err != nil {
+		return err
+	}
+	l.sendProcessMessage(&messagePacket{
+		Op:        MessageAbandon,
+		MessageID: messageID,
+	})
+	return nil
+}
+
+// abandon asks the server to stop processing the request with the given
+// message ID. The server sends no response to an abandon request, so the
+// message is finished as soon as it has been handed to the connection.
+func (l *Conn) abandon(messageID int64) error {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
+	packet.AppendChild(asn1.NewInteger(asn1.ClassApplication, asn1.TypePrimitive, ApplicationAbandonRequest, messageID, "Abandon Request"))
+
+	l.Debug.PrintPacket(packet)
+
+	msgCtx, err := l.sendMessage(packet)
+	if err != nil {
+		return err
+	}
+	l.finishMessage(msgCtx)
+	return nil
+}
@@ -0,0 +1,210 @@
+// File contains Active Directory codecs and password management
+//
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/f992ad60-0fe4-4b87-9fed-beb478836861
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-dtyp/49e490b8-f972-45d6-a3a4-99f924998d97
+// https://docs.microsoft.com/en-us/troubleshoot/windows-server/identity/set-user-password-with-ldifde
+
+package ldap
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DecodeSID returns the string form, such as
+// "S-1-5-21-3623811015-3361044348-30300820-1013", of a binary security
+// identifier, the value of the objectSid attribute
+func DecodeSID(sid []byte) (string, error) {
+	if len(sid) < 8 || len(sid) != 8+4*int(sid[1]) {
+		return "", fmt.Errorf("ldap: invalid SID of %d bytes", len(sid))
+	}
+	var authority uint64
+	for _, b := range sid[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+	s := fmt.Sprintf("S-%d-%d", sid[0], authority)
+	if authority >= 1<<32 {
+		s = fmt.Sprintf("S-%d-0x%012X", sid[0], authority)
+	}
+	for i := 8; i < len(sid); i += 4 {
+		s += "-" + strconv.FormatUint(uint64(binary.LittleEndian.Uint32(sid[i:])), 10)
+	}
+	return s, nil
+}
+
+// EncodeSID returns the binary form of a security identifier in the string
+// form returned by DecodeSID, e.g. to search for an objectSid
+func EncodeSID(s string) ([]byte, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") || len(parts)-3 > 15 {
+		return nil, fmt.Errorf("ldap: invalid SID %q", s)
+	}
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid SID %q", s)
+	}
+	var authority uint64
+	if strings.HasPrefix(parts[2], "0x") || strings.HasPrefix(parts[2], "0X") {
+		authority, err = strconv.ParseUint(parts[2][2:], 16, 48)
+	} else {
+		authority, err = strconv.ParseUint(parts[2], 10, 48)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid SID %q", s)
+	}
+
+	sid := make([]byte, 8, 8+4*(len(parts)-3))
+	sid[0] = byte(revision)
+	sid[1] = byte(len(parts) - 3)
+	for i := 7; i >= 2; i-- {
+		sid[i] = byte(authority)
+		authority >>= 8
+	}
+	for _, part := range parts[3:] {
+		subAuthority, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid SID %q", s)
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(subAuthority))
+		sid = append(sid, b[:]...)
+	}
+	return sid, nil
+}
+
+// DecodeGUID returns the canonical text form, such as
+// "6f9619ff-8b86-d011-b42d-00c04fc964ff", of a binary GUID, the value of the
+// objectGUID attribute. The first three fields of a GUID are stored in little
+// endian order.
+func DecodeGUID(guid []byte) (string, error) {
+	if len(guid) != 16 {
+		return "", fmt.Errorf("ldap: invalid GUID of %d bytes", len(guid))
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(guid[0:4]),
+		binary.LittleEndian.Uint16(guid[4:6]),
+		binary.LittleEndian.Uint16(guid[6:8]),
+		guid[8:10],
+		guid[10:16]), nil
+}
+
+// EncodeGUID returns the binary form of a GUID in the text form returned by
+// DecodeGUID, with or without braces, e.g. to search for an objectGUID
+func EncodeGUID(s string) ([]byte, error) {
+	text := strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if len(text) != 36 || text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+		return nil, fmt.Errorf("ldap: invalid GUID %q", s)
+	}
+	digits := strings.Replace(text, "-", "", -1)
+	if len(digits) != 32 {
+		return nil, fmt.Errorf("ldap: invalid GUID %q", s)
+	}
+	b := make([]byte, 16)
+	for i := range b {
+		n, err := strconv.ParseUint(digits[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid GUID %q", s)
+		}
+		b[i] = byte(n)
+	}
+	guid := []byte{
+		b[3], b[2], b[1], b[0],
+		b[5], b[4],
+		b[7], b[6],
+	}
+	return append(guid, b[8:]...), nil
+}
+
+// FileTimeNever is the value of FILETIME attributes, such as accountExpires,
+// standing for never. Attributes such as pwdLastSet and lastLogonTimestamp
+// hold 0 instead.
+const FileTimeNever int64 = math.MaxInt64
+
+// fileTimeUnixEpoch is the FILETIME of January 1, 1970 UTC
+const fileTimeUnixEpoch = 116444736000000000
+
+// FileTimeToTime returns the time of a FILETIME, the number of 100ns intervals
+// since January 1, 1601 UTC, as held by Active Directory attributes such as
+// pwdLastSet, lastLogonTimestamp and accountExpires. The zero time is
+// returned for 0 and FileTimeNever.
+func FileTimeToTime(fileTime int64) time.Time {
+	if fileTime <= 0 || fileTime == FileTimeNever {
+		return time.Time{}
+	}
+	ticks := fileTime - fileTimeUnixEpoch
+	seconds, remainder := ticks/1e7, ticks%1e7
+	if remainder < 0 {
+		seconds, remainder = seconds-1, remainder+1e7
+	}
+	return time.Unix(seconds, remainder*100).UTC()
+}
+
+// TimeToFileTime returns the FILETIME of the time, 0 for the zero time
+func TimeToFileTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()*1e7 + int64(t.Nanosecond()/100) + fileTimeUnixEpoch
+}
+
+// ParseFileTime parses the value of a FILETIME attribute, such as
+// "132537600000000000", returning the zero time for 0 and FileTimeNever
+func ParseFileTime(value string) (time.Time, error) {
+	fileTime, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ldap: invalid FILETIME %q", value)
+	}
+	return FileTimeToTime(fileTime), nil
+}
+
+// FormatFileTime returns the value of a FILETIME attribute for the time, "0"
+// for the zero time
+func FormatFileTime(t time.Time) string {
+	return strconv.FormatInt(TimeToFileTime(t), 10)
+}
+
+// unicodePwd is the attribute holding the password of Active Directory users
+const unicodePwd = "unicodePwd"
+
+// EncodeADPassword returns the value of the unicodePwd attribute for the
+// password: the password enclosed in quotation marks, encoded in UTF-16LE
+func EncodeADPassword(password string) []byte {
+	return encodeUTF16(`"` + password + `"`)
+}
+
+// SetADPassword sets the password of the Active Directory user, as an
+// administrator. The server only accepts the change over an encrypted
+// connection, e.g. after StartTLS.
+func SetADPassword(conn Client, dn, newPassword string) error {
+	return SetADPasswordContext(context.Background(), conn, dn, newPassword)
+}
+
+// SetADPasswordContext is like SetADPassword, but abandons the request and
+// returns ctx.Err() if ctx is done before the server responds.
+func SetADPasswordContext(ctx context.Context, conn Client, dn, newPassword string) error {
+	modifyRequest := NewModifyRequest(dn)
+	modifyRequest.ReplaceBinary(unicodePwd, [][]byte{EncodeADPassword(newPassword)})
+	return conn.ModifyContext(ctx, modifyRequest)
+}
+
+// ChangeADPassword changes the password of the Active Directory user, as the
+// user: Active Directory requires deleting the old password and adding the
+// new one in a single modify request. The server only accepts the change over
+// an encrypted connection, e.g. after StartTLS.
+func ChangeADPassword(conn Client, dn, oldPassword, newPassword string) error {
+	return ChangeADPasswordContext(context.Background(), conn, dn, oldPassword, newPassword)
+}
+
+// ChangeADPasswordContext is like ChangeADPassword, but abandons the request
+// and returns ctx.Err() if ctx is done before the server responds.
+func ChangeADPasswordContext(ctx context.Context, conn Client, dn, oldPassword, newPassword string) error {
+	modifyRequest := NewModifyRequest(dn)
+	modifyRequest.DeleteBinary(unicodePwd, [][]byte{EncodeADPassword(oldPassword)})
+	modifyRequest.AddBinary(unicodePwd, [][]byte{EncodeADPassword(newPassword)})
+	return conn.ModifyContext(ctx, modifyRequest)
+}
@@ -0,0 +1,137 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestSID(t *testing.T) {
+	for s, sid := range map[string][]byte{
+		"S-1-5-21-3623811015-3361044348-30300820-1013": {
+			0x01, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+			0x15, 0x00, 0x00, 0x00,
+			0xc7, 0xf7, 0xfe, 0xd7,
+			0x7c, 0x77, 0x55, 0xc8,
+			0x94, 0x5a, 0xce, 0x01,
+			0xf5, 0x03, 0x00, 0x00,
+		},
+		"S-1-1-0":              {0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		"S-1-0x0102030405FF-1": {0x01, 0x01, 0x01, 0x02, 0x03, 0x04, 0x05, 0xff, 0x01, 0x00, 0x00, 0x00},
+		"S-1-5":                {0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05},
+	} {
+		decoded, err := DecodeSID(sid)
+		if err != nil || decoded != s {
+			t.Errorf("DecodeSID(%x): got %q, %v, expected %q", sid, decoded, err, s)
+		}
+		encoded, err := EncodeSID(s)
+		if err != nil || !bytes.Equal(encoded, sid) {
+			t.Errorf("EncodeSID(%q): got %x, %v, expected %x", s, encoded, err, sid)
+		}
+	}
+	if _, err := DecodeSID([]byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00}); err == nil {
+		t.Error("expected an error decoding a truncated SID")
+	}
+	for _, s := range []string{"", "S-1", "X-1-5", "S-1-5-x", "S-1-5-4294967296", "S-256-5"} {
+		if _, err := EncodeSID(s); err == nil {
+			t.Errorf("EncodeSID(%q): expected an error", s)
+		}
+	}
+}
+
+func TestGUID(t *testing.T) {
+	guid := []byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff}
+	s := "6f9619ff-8b86-d011-b42d-00c04fc964ff"
+	decoded, err := DecodeGUID(guid)
+	if err != nil || decoded != s {
+		t.Errorf("DecodeGUID: got %q, %v, expected %q", decoded, err, s)
+	}
+	for _, text := range []string{s, "{6F9619FF-8B86-D011-B42D-00C04FC964FF}"} {
+		encoded, err := EncodeGUID(text)
+		if err != nil || !bytes.Equal(encoded, guid) {
+			t.Errorf("EncodeGUID(%q): got %x, %v, expected %x", text, encoded, err, guid)
+		}
+	}
+	if _, err := DecodeGUID(guid[1:]); err == nil {
+		t.Error("expected an error decoding a truncated GUID")
+	}
+	if _, err := EncodeGUID("6f9619ff8b86d011b42d00c04fc964ff"); err == nil {
+		t.Error("expected an error encoding a GUID without dashes")
+	}
+}
+
+func TestFileTime(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		expected time.Time
+	}{
+		{"116444736000000000", time.Unix(0, 0).UTC()},
+		{"132537600000000000", time.Date(2020, 12, 30, 0, 0, 0, 0, time.UTC)},
+		{"116444735999999999", time.Date(1969, 12, 31, 23, 59, 59, 999999900, time.UTC)},
+		{"9223372036854775806", time.Date(30828, 9, 14, 2, 48, 5, 477580600, time.UTC)},
+		{"0", time.Time{}},
+		{"9223372036854775807", time.Time{}},
+	} {
+		parsed, err := ParseFileTime(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+		} else if !parsed.Equal(test.expected) {
+			t.Errorf("%s: expected %s, got %s", test.value, test.expected, parsed)
+		}
+		if !test.expected.IsZero() {
+			if formatted := FormatFileTime(test.expected); formatted != test.value {
+				t.Errorf("%s: expected %s to be formatted as the value, got %s", test.value, test.expected, formatted)
+			}
+		}
+	}
+	if formatted := FormatFileTime(time.Time{}); formatted != "0" {
+		t.Errorf("expected the zero time to be formatted as 0, got %s", formatted)
+	}
+	if _, err := ParseFileTime("never"); err == nil {
+		t.Error("expected an error parsing an invalid FILETIME")
+	}
+}
+
+func TestChangeADPassword(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var request *asn1.Packet
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		request = p
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationModifyResponse, LDAPResultSuccess, "")}
+	})
+
+	if err := ChangeADPassword(conn, "cn=jdoe,cn=users,dc=example,dc=com", "old", "néw"); err != nil {
+		t.Fatal(err)
+	}
+	changes := request.Children[1].Children[1].Children
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, expected 2", len(changes))
+	}
+	expected := []struct {
+		operation int64
+		value     []byte
+	}{
+		{DeleteAttribute, []byte{'"', 0, 'o', 0, 'l', 0, 'd', 0, '"', 0}},
+		{AddAttribute, []byte{'"', 0, 'n', 0, 0xe9, 0, 'w', 0, '"', 0}},
+	}
+	for i, change := range changes {
+		attribute := change.Children[1]
+		if operation := change.Children[0].Value.(int64); operation != expected[i].operation {
+			t.Errorf("change %d: got operation %d, expected %d", i, operation, expected[i].operation)
+		}
+		if name := attribute.Children[0].Value.(string); name != "unicodePwd" {
+			t.Errorf("change %d: got attribute %q", i, name)
+		}
+		if value := attribute.Children[1].Children[0].ByteValue; !bytes.Equal(value, expected[i].value) {
+			t.Errorf("change %d: got value %x, expected %x", i, value, expected[i].value)
+		}
+	}
+}
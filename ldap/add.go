@@ -10,8 +10,7 @@
 package ldap
 
 import (
-	"errors"
-	"log"
+	"context"
 
 	"github.com/gostores/encoding/asn1"
 )
@@ -22,17 +21,51 @@ type Attribute struct {
 	Type string
 	// Vals are the LDAP attribute values
 	Vals []string
+	// ByteVals are binary LDAP attribute values, such as certificates or
+	// photos, sent after Vals
+	ByteVals [][]byte
 }
 
 func (a *Attribute) encode() *asn1.Packet {
 	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attribute")
 	seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, a.Type, "Type"))
+	seq.AppendChild(encodeAttributeValues(a.Vals, a.ByteVals))
+	return seq
+}
+
+func (a *Attribute) encodeBuffer(b *berBuffer) {
+	b.begin(berSequence)
+	b.appendString(berOctetString, a.Type)
+	encodeAttributeValuesBuffer(b, a.Vals, a.ByteVals)
+	b.end()
+}
+
+// encodeAttributeValuesBuffer encodes the set of values as
+// encodeAttributeValues does
+func encodeAttributeValuesBuffer(b *berBuffer, vals []string, byteVals [][]byte) {
+	b.begin(berSet)
+	for _, value := range vals {
+		b.appendString(berOctetString, value)
+	}
+	for _, value := range byteVals {
+		b.appendBytes(berOctetString, value)
+	}
+	b.end()
+}
+
+// encodeAttributeValues returns the set of string and binary values of an
+// attribute
+func encodeAttributeValues(vals []string, byteVals [][]byte) *asn1.Packet {
 	set := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSet, nil, "AttributeValue")
-	for _, value := range a.Vals {
+	for _, value := range vals {
 		set.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, value, "Vals"))
 	}
-	seq.AppendChild(set)
-	return seq
+	for _, value := range byteVals {
+		packet := asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(value), "Vals")
+		packet.ByteValue = value
+		set.AppendChild(packet)
+	}
+	return set
 }
 
 // AddRequest represents an LDAP AddRequest operation
@@ -41,6 +74,8 @@ type AddRequest struct {
 	DN string
 	// Attributes list the attributes of the new entry
 	Attributes []Attribute
+	// Controls hold optional controls to send with the request
+	Controls []Control
 }
 
 func (a AddRequest) encode() *asn1.Packet {
@@ -54,60 +89,96 @@ func (a AddRequest) encode() *asn1.Packet {
 	return request
 }
 
+func (a AddRequest) encodeBuffer(b *berBuffer) {
+	b.begin(berApplication(ApplicationAddRequest))
+	b.appendString(berOctetString, a.DN)
+	b.begin(berSequence)
+	for _, attribute := range a.Attributes {
+		attribute.encodeBuffer(b)
+	}
+	b.end()
+	b.end()
+}
+
+func (a AddRequest) controls() []Control {
+	return a.Controls
+}
+
+func (a AddRequest) responseTag() asn1.Tag {
+	return ApplicationAddResponse
+}
+
 // Attribute adds an attribute with the given type and values
 func (a *AddRequest) Attribute(attrType string, attrVals []string) {
 	a.Attributes = append(a.Attributes, Attribute{Type: attrType, Vals: attrVals})
 }
 
+// BinaryAttribute adds an attribute with the given type and binary values
+func (a *AddRequest) BinaryAttribute(attrType string, attrVals [][]byte) {
+	a.Attributes = append(a.Attributes, Attribute{Type: attrType, ByteVals: attrVals})
+}
+
 // NewAddRequest returns an AddRequest for the given DN, with no attributes
-func NewAddRequest(dn string) *AddRequest {
+func NewAddRequest(dn string, controls ...Control) *AddRequest {
 	return &AddRequest{
-		DN: dn,
+		DN:       dn,
+		Controls: controls,
 	}
 
 }
 
-// Add performs the given AddRequest
-func (l *Conn) Add(addRequest *AddRequest) error {
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
-	packet.AppendChild(addRequest.encode())
-
-	l.Debug.PrintPacket(packet)
-
-	msgCtx, err := l.sendMessage(packet)
-	if err != nil {
-		return err
+// decodeAddRequest decodes the protocol operation of an add request received
+// by a Server
+func decodeAddRequest(packet *asn1.Packet) *AddRequest {
+	addRequest := &AddRequest{DN: decodeOctetString(packet.Children[0])}
+	for _, child := range packet.Children[1].Children {
+		attributeType, vals := decodeAttribute(child)
+		addRequest.Attributes = append(addRequest.Attributes, Attribute{Type: attributeType, Vals: vals})
 	}
-	defer l.finishMessage(msgCtx)
+	return addRequest
+}
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
-	if err != nil {
-		return err
+// decodeAttribute decodes the type and the values of an attribute
+func decodeAttribute(packet *asn1.Packet) (string, []string) {
+	var vals []string
+	for _, value := range packet.Children[1].Children {
+		vals = append(vals, decodeOctetString(value))
 	}
+	return decodeOctetString(packet.Children[0]), vals
+}
 
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return err
-		}
-		asn1.PrintPacket(packet)
-	}
+// decodeOctetString returns the raw value of a string packet, which is not
+// always decoded for the context class
+func decodeOctetString(packet *asn1.Packet) string {
+	return string(packet.Data.Bytes())
+}
+
+// Add performs the given AddRequest
+func (l *Conn) Add(addRequest *AddRequest) error {
+	return l.AddContext(context.Background(), addRequest)
+}
+
+// AddContext performs the given AddRequest. If ctx is done before the server
+// responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) AddContext(ctx context.Context, addRequest *AddRequest) error {
+	_, err := l.AddWithResultContext(ctx, addRequest)
+	return err
+}
+
+// AddWithResult performs the AddRequest and returns the controls sent back by the
+// server, such as the entry requested with a pre-read or post-read control.
+func (l *Conn) AddWithResult(addRequest *AddRequest) (*UpdateResult, error) {
+	return l.AddWithResultContext(context.Background(), addRequest)
+}
 
-	if packet.Children[1].Tag == ApplicationAddResponse {
-		resultCode, resultDescription := getLDAPResultCode(packet)
-		if resultCode != 0 {
-			return NewError(resultCode, errors.New(resultDescription))
+// AddWithResultContext is like AddWithResult, but abandons the request and
+// returns ctx.Err() if ctx is done before the server responds.
+func (l *Conn) AddWithResultContext(ctx context.Context, addRequest *AddRequest) (*UpdateResult, error) {
+	if schema := l.loadSchema(); schema != nil {
+		if err := schema.ValidateAddRequest(addRequest); err != nil {
+			return nil, err
 		}
-	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
 	}
 
-	l.Debug.Printf("%d: returning", msgCtx.id)
-	return nil
+	return newUpdateResult(l.DoContext(ctx, addRequest))
 }
@@ -0,0 +1,128 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestAddWithControls(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var request *asn1.Packet
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		request = p
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationAddResponse, LDAPResultSuccess, "")}
+	})
+
+	addRequest := NewAddRequest("uid=jdoe,ou=people,dc=example,dc=com", NewControlProxiedAuthorization("u:admin"))
+	addRequest.Attribute("objectClass", []string{"inetOrgPerson"})
+	addRequest.Attribute("cn", []string{"John Doe"})
+	if err := conn.Add(addRequest); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(request.Children) != 3 {
+		t.Fatalf("expected request to carry controls, got %d children", len(request.Children))
+	}
+	control := DecodeControl(request.Children[2].Children[0])
+	if proxied, ok := control.(*ControlProxiedAuthorization); !ok || proxied.AuthzID != "u:admin" {
+		t.Errorf("unexpected control %s", control)
+	}
+}
+
+func TestAddWithResultPostRead(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		requested, ok := DecodeControl(p.Children[2].Children[0]).(*ControlPostRead)
+		if !ok || len(requested.Attributes) != 1 || requested.Attributes[0] != "entryUUID" {
+			t.Errorf("unexpected request control %s", DecodeControl(p.Children[2].Children[0]))
+		}
+		response := newResultPacket(p.Children[0].Value.(int64), ApplicationAddResponse, LDAPResultSuccess, "")
+		response.AppendChild(encodeControls([]Control{&ControlPostRead{Entry: &Entry{
+			DN:         "uid=jdoe,ou=people,dc=example,dc=com",
+			Attributes: []*EntryAttribute{{Name: "entryUUID", Values: []string{"597ae2f6-16a6-1027-98f4-d28b5365dc14"}}},
+		}}}))
+		return []*asn1.Packet{response}
+	})
+
+	addRequest := NewAddRequest("uid=jdoe,ou=people,dc=example,dc=com", NewControlPostRead("entryUUID"))
+	addRequest.Attribute("objectClass", []string{"inetOrgPerson"})
+	result, err := conn.AddWithResult(addRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	postRead, ok := FindControl(result.Controls, ControlTypePostRead).(*ControlPostRead)
+	if !ok || postRead.Entry == nil {
+		t.Fatalf("expected post-read entry in result controls, got %v", result.Controls)
+	}
+	if postRead.Entry.DN != "uid=jdoe,ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected entry DN %q", postRead.Entry.DN)
+	}
+	if uuid := postRead.Entry.GetAttributeValue("entryUUID"); uuid != "597ae2f6-16a6-1027-98f4-d28b5365dc14" {
+		t.Errorf("unexpected entryUUID %q", uuid)
+	}
+}
+
+func TestAddWithResultInvalidPostRead(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		response := newResultPacket(p.Children[0].Value.(int64), ApplicationAddResponse, LDAPResultSuccess, "")
+		controls := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+		controls.AppendChild(newControlPacket(ControlTypePostRead, nil))
+		response.AppendChild(controls)
+		return []*asn1.Packet{response}
+	})
+
+	addRequest := NewAddRequest("uid=jdoe,ou=people,dc=example,dc=com", NewControlPostRead("entryUUID"))
+	addRequest.Attribute("objectClass", []string{"inetOrgPerson"})
+	if _, err := conn.AddWithResult(addRequest); !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+		t.Errorf("expected unexpected response error, got %v", err)
+	}
+}
+
+func TestAddBinaryAttribute(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var request *asn1.Packet
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		request = p
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationAddResponse, LDAPResultSuccess, "")}
+	})
+
+	photo := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10}
+	addRequest := NewAddRequest("uid=jdoe,ou=people,dc=example,dc=com")
+	addRequest.BinaryAttribute("jpegPhoto", [][]byte{photo})
+	if err := conn.Add(addRequest); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	attribute := request.Children[1].Children[1].Children[0]
+	if values := attribute.Children[1].Children; len(values) != 1 || !bytes.Equal(values[0].ByteValue, photo) {
+		t.Errorf("unexpected values %v", values)
+	}
+}
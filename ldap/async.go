@@ -0,0 +1,85 @@
+// File contains the asynchronous search
+
+package ldap
+
+import (
+	"context"
+)
+
+// AsyncSearch is a search running in the background, started with
+// SearchAsync. Its entries are received from Results, which is closed once
+// the search completes. If the entries are not all read, Cancel must be
+// called to release the search.
+type AsyncSearch struct {
+	// Results receives the entries of the search
+	Results <-chan *Entry
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *SearchResult
+	err    error
+}
+
+// SearchAsync starts the given search and returns without waiting for its
+// entries, which are buffered up to bufferSize in Results. Many searches can
+// be started this way on the same connection and read with a select. As
+// described for SearchCursor, the entries not received from Results yet are
+// held in memory. Referrals are not followed.
+func (l *Conn) SearchAsync(searchRequest *SearchRequest, bufferSize int) *AsyncSearch {
+	return l.SearchAsyncContext(context.Background(), searchRequest, bufferSize)
+}
+
+// SearchAsyncContext is like SearchAsync, but the search is abandoned once ctx
+// is done, Wait then returning ctx.Err().
+func (l *Conn) SearchAsyncContext(ctx context.Context, searchRequest *SearchRequest, bufferSize int) *AsyncSearch {
+	ctx, cancel := context.WithCancel(ctx)
+	results := make(chan *Entry, bufferSize)
+	search := &AsyncSearch{
+		Results: results,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	cursor := l.SearchCursorContext(ctx, searchRequest, 0)
+	go search.run(ctx, cursor, results)
+	return search
+}
+
+func (a *AsyncSearch) run(ctx context.Context, cursor *SearchCursor, results chan<- *Entry) {
+	defer close(a.done)
+	defer close(results)
+	defer a.cancel()
+	defer cursor.Close()
+
+	for cursor.Next() {
+		select {
+		case results <- cursor.Entry():
+		case <-ctx.Done():
+			a.err = ctx.Err()
+			return
+		}
+	}
+	a.result = &SearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: cursor.Referrals(),
+		Controls:  cursor.Controls(),
+	}
+	a.err = cursor.Err()
+}
+
+// Cancel abandons the search if it has not completed
+func (a *AsyncSearch) Cancel() {
+	a.cancel()
+}
+
+// Done returns a channel closed once the search completed or was canceled
+func (a *AsyncSearch) Done() <-chan struct{} {
+	return a.done
+}
+
+// Wait waits for the search to complete and returns its referrals and
+// controls, without entries, or the error which stopped it. The entries must
+// be read from Results or the search canceled for Wait to return.
+func (a *AsyncSearch) Wait() (*SearchResult, error) {
+	<-a.done
+	return a.result, a.err
+}
@@ -0,0 +1,108 @@
+package ldap
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestSearchAsync(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+				messageID := p.Children[0].Value.(int64)
+				baseDN := p.Children[1].Children[0].Value.(string)
+				return []*asn1.Packet{
+					newEntryPacket(messageID, "cn=a,"+baseDN),
+					newEntryPacket(messageID, "cn=b,"+baseDN),
+					newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+				}
+			})
+		}
+	}()
+
+	people := conn.SearchAsync(NewSearchRequest("ou=people,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), 1)
+	groups := conn.SearchAsync(NewSearchRequest("ou=groups,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), 1)
+
+	var dns []string
+	runWithTimeout(t, 5*time.Second, func() {
+		peopleResults, groupsResults := people.Results, groups.Results
+		for peopleResults != nil || groupsResults != nil {
+			select {
+			case entry, ok := <-peopleResults:
+				if !ok {
+					peopleResults = nil
+					continue
+				}
+				dns = append(dns, entry.DN)
+			case entry, ok := <-groupsResults:
+				if !ok {
+					groupsResults = nil
+					continue
+				}
+				dns = append(dns, entry.DN)
+			}
+		}
+		for _, search := range []*AsyncSearch{people, groups} {
+			if _, err := search.Wait(); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+	})
+	sort.Strings(dns)
+	expected := []string{"cn=a,ou=groups,dc=example,dc=com", "cn=a,ou=people,dc=example,dc=com", "cn=b,ou=groups,dc=example,dc=com", "cn=b,ou=people,dc=example,dc=com"}
+	if !reflect.DeepEqual(dns, expected) {
+		t.Errorf("got entries %v, expected %v", dns, expected)
+	}
+}
+
+func TestSearchAsyncCancel(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	abandoned := make(chan struct{})
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+				newEntryPacket(messageID, "cn=b,dc=example,dc=com"),
+				newEntryPacket(messageID, "cn=c,dc=example,dc=com"),
+			}
+		})
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Errorf("unable to receive request packet: %s", err)
+			return
+		}
+		if request.Children[1].Tag == ApplicationAbandonRequest {
+			close(abandoned)
+		}
+	}()
+
+	search := conn.SearchAsync(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), 0)
+	runWithTimeout(t, 5*time.Second, func() {
+		if entry := <-search.Results; entry == nil || entry.DN != "cn=a,dc=example,dc=com" {
+			t.Fatalf("unexpected entry %v", entry)
+		}
+		search.Cancel()
+		if _, err := search.Wait(); err == nil {
+			t.Error("expected the search to be canceled")
+		}
+		<-abandoned
+	})
+}
@@ -0,0 +1,293 @@
+// File contains the backends holding the entries served by a Server
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// Backend holds the entries served by a Server created with
+// NewBackendServer, such as rows of a SQL database or resources of a REST API
+// exposed as an LDAP tree. MemoryBackend is a reference implementation.
+type Backend interface {
+	// Lookup returns the entry with the given DN, or nil if there is none
+	Lookup(ctx context.Context, dn string) (*Entry, error)
+	// SearchSubtree calls fn with the entry with the given DN, if there is
+	// one, and each entry below it, stopping at the first error of fn
+	SearchSubtree(ctx context.Context, baseDN string, fn func(*Entry) error) error
+	// ApplyChange applies the change to the entries, returning an *Error such
+	// as LDAPResultNoSuchObject if it cannot
+	ApplyChange(ctx context.Context, change *BackendChange) error
+}
+
+// BackendChange is a change applied to the entries of a Backend, one of its
+// fields being set
+type BackendChange struct {
+	// Add adds an entry
+	Add *AddRequest
+	// Modify modifies an entry
+	Modify *ModifyRequest
+	// Del deletes an entry
+	Del *DelRequest
+}
+
+// BackendHandler handles the requests received by a Server with a Backend.
+// Binds are checked against the userPassword attribute of the entries.
+// Searches are evaluated by the handler, with filters matching values
+// ignoring case, and comparing them as integers in ordering filters if they
+// both are. Extensible matches are only supported without a matching rule.
+type BackendHandler struct {
+	Backend Backend
+}
+
+// NewBackendServer returns a Server handling its requests with the backend
+func NewBackendServer(backend Backend) *Server {
+	handler := &BackendHandler{Backend: backend}
+	return &Server{
+		Binder:   handler,
+		Searcher: handler,
+		Modifier: handler,
+	}
+}
+
+// Bind checks the password against the userPassword attribute of the entry,
+// anonymous binds succeeding
+func (h *BackendHandler) Bind(ctx context.Context, request *SimpleBindRequest) error {
+	if request.Username == "" {
+		return nil
+	}
+	entry, err := h.Backend.Lookup(ctx, request.Username)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		if attribute := entry.getAttribute("userPassword"); attribute != nil {
+			for _, value := range attribute.Values {
+				if value == request.Password {
+					return nil
+				}
+			}
+		}
+	}
+	return NewError(LDAPResultInvalidCredentials, errors.New("ldap: invalid credentials"))
+}
+
+// errSizeLimitExceeded stops the search of the subtree once the size limit
+// is reached
+var errSizeLimitExceeded = NewError(LDAPResultSizeLimitExceeded, errors.New("ldap: size limit exceeded"))
+
+// Search sends the entries in the scope of the search matching its filter,
+// with the requested attributes
+func (h *BackendHandler) Search(ctx context.Context, request *SearchRequest, send func(*Entry) error) error {
+	filter, err := CompileFilter(request.Filter)
+	if err != nil {
+		return NewError(LDAPResultProtocolError, err)
+	}
+	baseDN, err := ParseDN(request.BaseDN)
+	if err != nil {
+		return NewError(LDAPResultInvalidDNSyntax, err)
+	}
+	base, err := h.Backend.Lookup(ctx, request.BaseDN)
+	if err != nil {
+		return err
+	}
+	if base == nil && len(baseDN.RDNs) > 0 {
+		return NewError(LDAPResultNoSuchObject, fmt.Errorf("ldap: no entry %s", request.BaseDN))
+	}
+
+	sent := 0
+	emit := func(entry *Entry) error {
+		if !matchFilter(filter, entry) {
+			return nil
+		}
+		if request.SizeLimit > 0 && sent == request.SizeLimit {
+			return errSizeLimitExceeded
+		}
+		sent++
+		return send(selectAttributes(entry, request.Attributes, request.TypesOnly))
+	}
+
+	switch request.Scope {
+	case ScopeBaseObject:
+		if base != nil {
+			return emit(base)
+		}
+		return nil
+	case ScopeSingleLevel, ScopeWholeSubtree, ScopeSubordinateSubtree:
+		return h.Backend.SearchSubtree(ctx, request.BaseDN, func(entry *Entry) error {
+			dn, err := ParseDN(entry.DN)
+			if err != nil {
+				return err
+			}
+			if request.Scope == ScopeSingleLevel && (len(dn.RDNs) == 0 || !baseDN.EqualFold(dn.Parent())) {
+				return nil
+			}
+			if request.Scope == ScopeSubordinateSubtree && baseDN.EqualFold(dn) {
+				return nil
+			}
+			return emit(entry)
+		})
+	}
+	return NewError(LDAPResultProtocolError, fmt.Errorf("ldap: unknown scope %d", request.Scope))
+}
+
+// Add adds the entry with the backend
+func (h *BackendHandler) Add(ctx context.Context, request *AddRequest) error {
+	return h.Backend.ApplyChange(ctx, &BackendChange{Add: request})
+}
+
+// Modify modifies the entry with the backend
+func (h *BackendHandler) Modify(ctx context.Context, request *ModifyRequest) error {
+	return h.Backend.ApplyChange(ctx, &BackendChange{Modify: request})
+}
+
+// Delete deletes the entry with the backend
+func (h *BackendHandler) Delete(ctx context.Context, request *DelRequest) error {
+	return h.Backend.ApplyChange(ctx, &BackendChange{Del: request})
+}
+
+// selectAttributes returns a copy of the entry holding the requested
+// attributes, all of them if none or "*" is requested
+func selectAttributes(entry *Entry, attributes []string, typesOnly bool) *Entry {
+	all := len(attributes) == 0
+	requested := make(map[string]bool)
+	for _, attribute := range attributes {
+		if attribute == "*" {
+			all = true
+		}
+		requested[strings.ToLower(attribute)] = true
+	}
+	selected := &Entry{DN: entry.DN}
+	for _, attribute := range entry.Attributes {
+		if !all && !requested[strings.ToLower(attribute.Name)] {
+			continue
+		}
+		if typesOnly {
+			selected.Attributes = append(selected.Attributes, NewEntryAttribute(attribute.Name, nil))
+		} else {
+			selected.Attributes = append(selected.Attributes, NewEntryAttribute(attribute.Name, attribute.Values))
+		}
+	}
+	return selected
+}
+
+// matchFilter reports whether the entry matches the compiled filter
+func matchFilter(filter *asn1.Packet, entry *Entry) bool {
+	switch filter.Tag {
+	case FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return !matchFilter(filter.Children[0], entry)
+	case FilterEqualityMatch, FilterApproxMatch:
+		return matchValue(entry, decodeOctetString(filter.Children[0]), func(value string) bool {
+			return strings.EqualFold(value, decodeOctetString(filter.Children[1]))
+		})
+	case FilterGreaterOrEqual:
+		return matchValue(entry, decodeOctetString(filter.Children[0]), func(value string) bool {
+			return compareValues(value, decodeOctetString(filter.Children[1])) >= 0
+		})
+	case FilterLessOrEqual:
+		return matchValue(entry, decodeOctetString(filter.Children[0]), func(value string) bool {
+			return compareValues(value, decodeOctetString(filter.Children[1])) <= 0
+		})
+	case FilterPresent:
+		attribute := decodeOctetString(filter)
+		// every entry of a directory has an object class
+		return strings.EqualFold(attribute, objectClassAttribute) || entry.getAttribute(attribute) != nil
+	case FilterSubstrings:
+		return matchValue(entry, decodeOctetString(filter.Children[0]), func(value string) bool {
+			return matchSubstrings(strings.ToLower(value), filter.Children[1].Children)
+		})
+	case FilterExtensibleMatch:
+		var attribute, value string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case MatchingRuleAssertionMatchingRule:
+				return false
+			case MatchingRuleAssertionType:
+				attribute = decodeOctetString(child)
+			case MatchingRuleAssertionMatchValue:
+				value = decodeOctetString(child)
+			}
+		}
+		return matchValue(entry, attribute, func(v string) bool {
+			return strings.EqualFold(v, value)
+		})
+	}
+	return false
+}
+
+// matchValue reports whether a value of the attribute matches
+func matchValue(entry *Entry, name string, match func(string) bool) bool {
+	attribute := entry.getAttribute(name)
+	if attribute == nil {
+		return false
+	}
+	for _, value := range attribute.Values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSubstrings reports whether the lower case value holds the initial,
+// any and final substrings in order
+func matchSubstrings(value string, substrings []*asn1.Packet) bool {
+	for _, substring := range substrings {
+		s := strings.ToLower(decodeOctetString(substring))
+		switch substring.Tag {
+		case FilterSubstringsInitial:
+			if !strings.HasPrefix(value, s) {
+				return false
+			}
+			value = value[len(s):]
+		case FilterSubstringsAny:
+			i := strings.Index(value, s)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(s):]
+		case FilterSubstringsFinal:
+			if !strings.HasSuffix(value, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareValues compares the values as integers if they both are, or as
+// lower case strings otherwise
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseInt(a, 10, 64)
+	y, errB := strconv.ParseInt(b, 10, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
@@ -0,0 +1,136 @@
+package ldap
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+// startTestBackendServer starts a Server with a MemoryBackend, returning it
+// and its address
+func startTestBackendServer(t testing.TB) (*Server, string) {
+	backend, err := NewMemoryBackend(
+		NewEntry("dc=example,dc=com", map[string][]string{"objectClass": {"domain"}, "dc": {"example"}}),
+		NewEntry("ou=people,dc=example,dc=com", map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"people"}}),
+		NewEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{"uid": {"jdoe"}, "cn": {"John Doe"}, "uidNumber": {"1000"}, "userPassword": {"secret"}}),
+		NewEntry("uid=asmith,ou=people,dc=example,dc=com", map[string][]string{"uid": {"asmith"}, "cn": {"Alice Smith"}, "uidNumber": {"999"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewBackendServer(backend)
+	go server.Serve(listener)
+	return server, listener.Addr().String()
+}
+
+func newTestBackendServer(t testing.TB) (*Server, *Conn) {
+	server, address := startTestBackendServer(t)
+	conn, err := DialURL("ldap://" + address)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, conn
+}
+
+func TestBackendServerBind(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "wrong"); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+		t.Errorf("bind with a wrong password: got %v", err)
+	}
+	if err := conn.Bind("uid=nobody,dc=example,dc=com", "secret"); !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+		t.Errorf("bind of an unknown entry: got %v", err)
+	}
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Errorf("bind: %s", err)
+	}
+}
+
+func TestBackendServerSearch(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	for _, test := range []struct {
+		baseDN    string
+		scope     int
+		sizeLimit int
+		filter    string
+		expected  []string
+		code      uint8
+	}{
+		{"dc=example,dc=com", ScopeBaseObject, 0, "(objectClass=*)", []string{"dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeSingleLevel, 0, "(objectClass=*)", []string{"ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 0, "(uid=*)", []string{"uid=asmith,ou=people,dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com"}, 0},
+		{"ou=people,dc=example,dc=com", ScopeSubordinateSubtree, 0, "(objectClass=*)", []string{"uid=asmith,ou=people,dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 0, "(cn=john*)", []string{"uid=jdoe,ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 0, "(uidNumber>=1000)", []string{"uid=jdoe,ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 0, "(&(uid=*)(!(uid=jdoe)))", []string{"uid=asmith,ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 0, "(|(ou=people)(cn=*smith))", []string{"ou=people,dc=example,dc=com", "uid=asmith,ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 0, "(uid:=JDOE)", []string{"uid=jdoe,ou=people,dc=example,dc=com"}, 0},
+		{"dc=example,dc=com", ScopeWholeSubtree, 1, "(uid=*)", []string{"uid=asmith,ou=people,dc=example,dc=com"}, LDAPResultSizeLimitExceeded},
+		{"ou=missing,dc=example,dc=com", ScopeWholeSubtree, 0, "(uid=*)", nil, LDAPResultNoSuchObject},
+	} {
+		request := NewSearchRequest(test.baseDN, test.scope, NeverDerefAliases, test.sizeLimit, 0, false, test.filter, []string{"uid"}, nil)
+		result, err := conn.Search(request)
+		if test.code != 0 {
+			if !IsErrorWithCode(err, test.code) {
+				t.Errorf("%s %s: expected result code %d, got %v", test.baseDN, test.filter, test.code, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s %s: %s", test.baseDN, test.filter, err)
+			continue
+		}
+		var dns []string
+		if result != nil {
+			for _, entry := range result.Entries {
+				dns = append(dns, entry.DN)
+				for _, attribute := range entry.Attributes {
+					if attribute.Name != "uid" {
+						t.Errorf("%s %s: unexpected attribute %s", test.baseDN, test.filter, attribute.Name)
+					}
+				}
+			}
+		}
+		if !reflect.DeepEqual(dns, test.expected) {
+			t.Errorf("%s %s: expected %v, got %v", test.baseDN, test.filter, test.expected, dns)
+		}
+	}
+}
+
+func TestBackendServerUpdates(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	add := NewAddRequest("uid=bwayne,ou=people,dc=example,dc=com")
+	add.Attribute("uid", []string{"bwayne"})
+	if err := conn.Add(add); err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	modify := NewModifyRequest("uid=bwayne,ou=people,dc=example,dc=com")
+	modify.Replace("cn", []string{"Bruce Wayne"})
+	if err := conn.Modify(modify); err != nil {
+		t.Fatalf("modify: %s", err)
+	}
+	result, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(cn=Bruce Wayne)", nil, nil))
+	if err != nil {
+		t.Fatalf("search: %s", err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].GetAttributeValue("uid") != "bwayne" {
+		t.Errorf("search of the modified entry: got %v", result.Entries)
+	}
+	if err := conn.Del(NewDelRequest("ou=people,dc=example,dc=com", nil)); !IsErrorWithCode(err, LDAPResultNotAllowedOnNonLeaf) {
+		t.Errorf("delete of a non-leaf entry: got %v", err)
+	}
+	if err := conn.Del(NewDelRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)); err != nil {
+		t.Errorf("delete: %s", err)
+	}
+}
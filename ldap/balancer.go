@@ -0,0 +1,428 @@
+// File contains the load-balancing Client
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalancerBackend is a server of a Balancer
+type BalancerBackend struct {
+	// Client is the connection to the server
+	Client Client
+	// Weight is the share of the operations sent to the server by the
+	// Weighted strategy, 1 if not positive
+	Weight int
+
+	outstanding int64
+	// failures is the number of consecutive failed operations, and
+	// openUntil the end of the quarantine of the backend, both guarded by
+	// the mutex of the balancer
+	failures  int
+	openUntil time.Time
+}
+
+// Outstanding returns the number of operations in progress on the backend
+func (b *BalancerBackend) Outstanding() int64 {
+	return atomic.LoadInt64(&b.outstanding)
+}
+
+// BalancerStrategy chooses the backend of each operation of a Balancer. It
+// must be safe for concurrent use.
+type BalancerStrategy interface {
+	// Pick returns one of the given backends, of which there is at least one
+	Pick(backends []*BalancerBackend) *BalancerBackend
+}
+
+// RoundRobin returns a strategy using the backends in turn
+func RoundRobin() BalancerStrategy {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+func (s *roundRobin) Pick(backends []*BalancerBackend) *BalancerBackend {
+	n := atomic.AddUint64(&s.next, 1) - 1
+	return backends[n%uint64(len(backends))]
+}
+
+// LeastOutstanding returns a strategy using the backend with the fewest
+// operations in progress, the first one on ties
+func LeastOutstanding() BalancerStrategy {
+	return leastOutstanding{}
+}
+
+type leastOutstanding struct{}
+
+func (leastOutstanding) Pick(backends []*BalancerBackend) *BalancerBackend {
+	best := backends[0]
+	for _, backend := range backends[1:] {
+		if backend.Outstanding() < best.Outstanding() {
+			best = backend
+		}
+	}
+	return best
+}
+
+// Weighted returns a strategy spreading the operations over the backends in
+// proportion to their weight, interleaving them smoothly
+func Weighted() BalancerStrategy {
+	return &weighted{current: make(map[*BalancerBackend]int)}
+}
+
+type weighted struct {
+	mu      sync.Mutex
+	current map[*BalancerBackend]int
+}
+
+func (s *weighted) Pick(backends []*BalancerBackend) *BalancerBackend {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// smooth weighted round-robin: every backend gains its weight and the
+	// one with the highest current weight is picked and loses the total
+	var best *BalancerBackend
+	total := 0
+	for _, backend := range backends {
+		weight := backend.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		s.current[backend] += weight
+		if best == nil || s.current[backend] > s.current[best] {
+			best = backend
+		}
+	}
+	s.current[best] -= total
+	return best
+}
+
+// Balancer is a Client spreading the operations over several equivalent
+// servers, such as the replicas of a directory. Bind, StartTLS, Close and
+// SetTimeout apply to every backend, other operations are sent to the
+// backend chosen by the strategy.
+type Balancer struct {
+	backends []*BalancerBackend
+	strategy BalancerStrategy
+
+	mu               sync.Mutex
+	failureThreshold int
+	cooldown         time.Duration
+	// now replaces time.Now in tests
+	now func() time.Time
+}
+
+var _ Client = &Balancer{}
+
+// NewBalancer returns a Balancer using the given strategy, RoundRobin if nil,
+// to choose among the backends
+func NewBalancer(strategy BalancerStrategy, backends ...*BalancerBackend) *Balancer {
+	if strategy == nil {
+		strategy = RoundRobin()
+	}
+	return &Balancer{
+		backends: backends,
+		strategy: strategy,
+	}
+}
+
+// Backends returns the backends of the balancer
+func (b *Balancer) Backends() []*BalancerBackend {
+	return b.backends
+}
+
+// SetCircuitBreaker quarantines the backends for the cooldown period, or
+// DefaultFailoverCooldown if zero, once threshold consecutive operations
+// failed on them with a network error or a busy or unavailable server, so
+// that the operations are sent to the other backends instead of waiting for
+// an unhealthy server. After the cooldown, operations are sent to the backend
+// again, its first failure quarantining it right away. If all
+// backends are quarantined, they are all used. Backends are not quarantined
+// if threshold is zero, which is the default.
+func (b *Balancer) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failureThreshold = threshold
+	b.cooldown = cooldown
+}
+
+// Quarantined reports whether the backend is quarantined by the circuit
+// breaker of the balancer
+func (b *Balancer) Quarantined(backend *BalancerBackend) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.timeNow().Before(backend.openUntil)
+}
+
+// pick returns the backend of the next operation, counted as outstanding
+// until done is called with the error of the operation
+func (b *Balancer) pick() (backend *BalancerBackend, done func(error)) {
+	backend = b.strategy.Pick(b.available())
+	atomic.AddInt64(&backend.outstanding, 1)
+	return backend, func(err error) {
+		atomic.AddInt64(&backend.outstanding, -1)
+		b.record(backend, err)
+	}
+}
+
+// available returns the backends which are not quarantined, or all of them
+// if they all are
+func (b *Balancer) available() []*BalancerBackend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failureThreshold == 0 {
+		return b.backends
+	}
+	now := b.timeNow()
+	available := make([]*BalancerBackend, 0, len(b.backends))
+	for _, backend := range b.backends {
+		if !now.Before(backend.openUntil) {
+			available = append(available, backend)
+		}
+	}
+	if len(available) == 0 {
+		return b.backends
+	}
+	return available
+}
+
+// record counts the failures of the backend, quarantining it once they reach
+// the threshold
+func (b *Balancer) record(backend *BalancerBackend, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failureThreshold == 0 {
+		return
+	}
+	if !IsNetworkError(err) && !IsBusyOrUnavailable(err) {
+		backend.failures = 0
+		return
+	}
+	backend.failures++
+	if backend.failures >= b.failureThreshold {
+		cooldown := b.cooldown
+		if cooldown == 0 {
+			cooldown = DefaultFailoverCooldown
+		}
+		backend.openUntil = b.timeNow().Add(cooldown)
+	}
+}
+
+func (b *Balancer) timeNow() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// all calls f for every backend and returns the first error
+func (b *Balancer) all(f func(Client) error) error {
+	var firstErr error
+	for _, backend := range b.backends {
+		if err := f(backend.Client); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Start starts every backend
+func (b *Balancer) Start() {
+	for _, backend := range b.backends {
+		backend.Client.Start()
+	}
+}
+
+// StartTLS sends the command to start a TLS session on every backend
+func (b *Balancer) StartTLS(config *tls.Config) error {
+	return b.all(func(c Client) error {
+		return c.StartTLS(config)
+	})
+}
+
+// Close closes every backend
+func (b *Balancer) Close() {
+	for _, backend := range b.backends {
+		backend.Client.Close()
+	}
+}
+
+// SetTimeout sets the time after a request is sent that a MessageTimeout
+// triggers on every backend
+func (b *Balancer) SetTimeout(timeout time.Duration) {
+	for _, backend := range b.backends {
+		backend.Client.SetTimeout(timeout)
+	}
+}
+
+// Bind performs a bind with the given username and password on every backend
+func (b *Balancer) Bind(username, password string) error {
+	return b.BindContext(context.Background(), username, password)
+}
+
+// BindContext performs a bind with the given username and password on every backend
+func (b *Balancer) BindContext(ctx context.Context, username, password string) error {
+	return b.all(func(c Client) error {
+		return c.BindContext(ctx, username, password)
+	})
+}
+
+// SimpleBind performs the simple bind defined in the given request on every
+// backend and returns the result of the first one
+func (b *Balancer) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	return b.SimpleBindContext(context.Background(), simpleBindRequest)
+}
+
+// SimpleBindContext performs the simple bind defined in the given request on
+// every backend and returns the result of the first one
+func (b *Balancer) SimpleBindContext(ctx context.Context, simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	var result *SimpleBindResult
+	err := b.all(func(c Client) error {
+		r, err := c.SimpleBindContext(ctx, simpleBindRequest)
+		if result == nil {
+			result = r
+		}
+		return err
+	})
+	return result, err
+}
+
+// Add performs the given add request on one of the backends
+func (b *Balancer) Add(addRequest *AddRequest) error {
+	return b.AddContext(context.Background(), addRequest)
+}
+
+// AddContext performs the given add request on one of the backends
+func (b *Balancer) AddContext(ctx context.Context, addRequest *AddRequest) error {
+	backend, done := b.pick()
+	err := backend.Client.AddContext(ctx, addRequest)
+	done(err)
+	return err
+}
+
+// Del performs the given delete request on one of the backends
+func (b *Balancer) Del(delRequest *DelRequest) error {
+	return b.DelContext(context.Background(), delRequest)
+}
+
+// DelContext performs the given delete request on one of the backends
+func (b *Balancer) DelContext(ctx context.Context, delRequest *DelRequest) error {
+	backend, done := b.pick()
+	err := backend.Client.DelContext(ctx, delRequest)
+	done(err)
+	return err
+}
+
+// Modify performs the given modify request on one of the backends
+func (b *Balancer) Modify(modifyRequest *ModifyRequest) error {
+	return b.ModifyContext(context.Background(), modifyRequest)
+}
+
+// ModifyContext performs the given modify request on one of the backends
+func (b *Balancer) ModifyContext(ctx context.Context, modifyRequest *ModifyRequest) error {
+	backend, done := b.pick()
+	err := backend.Client.ModifyContext(ctx, modifyRequest)
+	done(err)
+	return err
+}
+
+// ModifyDN performs the given modify DN request on one of the backends
+func (b *Balancer) ModifyDN(modifyDNRequest *ModifyDNRequest) error {
+	return b.ModifyDNContext(context.Background(), modifyDNRequest)
+}
+
+// ModifyDNContext performs the given modify DN request on one of the backends
+func (b *Balancer) ModifyDNContext(ctx context.Context, modifyDNRequest *ModifyDNRequest) error {
+	backend, done := b.pick()
+	err := backend.Client.ModifyDNContext(ctx, modifyDNRequest)
+	done(err)
+	return err
+}
+
+// Compare checks to see if the attribute of the dn matches value on one of the backends
+func (b *Balancer) Compare(dn, attribute, value string) (bool, error) {
+	return b.CompareContext(context.Background(), dn, attribute, value)
+}
+
+// CompareContext checks to see if the attribute of the dn matches value on
+// one of the backends
+func (b *Balancer) CompareContext(ctx context.Context, dn, attribute, value string) (bool, error) {
+	backend, done := b.pick()
+	result, err := backend.Client.CompareContext(ctx, dn, attribute, value)
+	done(err)
+	return result, err
+}
+
+// PasswordModify performs the modification request on one of the backends
+func (b *Balancer) PasswordModify(passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
+	return b.PasswordModifyContext(context.Background(), passwordModifyRequest)
+}
+
+// PasswordModifyContext performs the modification request on one of the backends
+func (b *Balancer) PasswordModifyContext(ctx context.Context, passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
+	backend, done := b.pick()
+	result, err := backend.Client.PasswordModifyContext(ctx, passwordModifyRequest)
+	done(err)
+	return result, err
+}
+
+// Extended performs the given extended request on one of the backends
+func (b *Balancer) Extended(extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	return b.ExtendedContext(context.Background(), extendedRequest)
+}
+
+// ExtendedContext performs the given extended request on one of the backends
+func (b *Balancer) ExtendedContext(ctx context.Context, extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	backend, done := b.pick()
+	result, err := backend.Client.ExtendedContext(ctx, extendedRequest)
+	done(err)
+	return result, err
+}
+
+// Do performs the given request on one of the backends
+func (b *Balancer) Do(request Request) (*Response, error) {
+	return b.DoContext(context.Background(), request)
+}
+
+// DoContext performs the given request on one of the backends
+func (b *Balancer) DoContext(ctx context.Context, request Request) (*Response, error) {
+	backend, done := b.pick()
+	result, err := backend.Client.DoContext(ctx, request)
+	done(err)
+	return result, err
+}
+
+// Search performs the given search request on one of the backends
+func (b *Balancer) Search(searchRequest *SearchRequest) (*SearchResult, error) {
+	return b.SearchContext(context.Background(), searchRequest)
+}
+
+// SearchContext performs the given search request on one of the backends
+func (b *Balancer) SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	backend, done := b.pick()
+	result, err := backend.Client.SearchContext(ctx, searchRequest)
+	done(err)
+	return result, err
+}
+
+// SearchWithPaging performs the given search request with paging on one of
+// the backends. All pages are requested from the same backend, as the paging
+// cookie is only valid on the server which issued it.
+func (b *Balancer) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	return b.SearchWithPagingContext(context.Background(), searchRequest, pagingSize)
+}
+
+// SearchWithPagingContext performs the given search request with paging on
+// one of the backends
+func (b *Balancer) SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	backend, done := b.pick()
+	result, err := backend.Client.SearchWithPagingContext(ctx, searchRequest, pagingSize)
+	done(err)
+	return result, err
+}
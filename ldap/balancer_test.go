@@ -0,0 +1,147 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingClient counts the binds and searches it receives
+type countingClient struct {
+	Client
+	binds     int64
+	searches  int64
+	bindErr   error
+	searchErr error
+}
+
+func (c *countingClient) BindContext(ctx context.Context, username, password string) error {
+	atomic.AddInt64(&c.binds, 1)
+	return c.bindErr
+}
+
+func (c *countingClient) SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	atomic.AddInt64(&c.searches, 1)
+	return &SearchResult{}, c.searchErr
+}
+
+func newTestBackends(weights ...int) []*BalancerBackend {
+	backends := make([]*BalancerBackend, len(weights))
+	for i, weight := range weights {
+		backends[i] = &BalancerBackend{Client: &countingClient{}, Weight: weight}
+	}
+	return backends
+}
+
+func TestBalancerStrategies(t *testing.T) {
+	backends := newTestBackends(3, 1, 0)
+	testcases := map[string]struct {
+		strategy BalancerStrategy
+		expected []int
+	}{
+		"round-robin": {RoundRobin(), []int{0, 1, 2, 0, 1, 2}},
+		"weighted":    {Weighted(), []int{0, 1, 0, 2, 0, 0, 1, 0, 2, 0}},
+	}
+
+	for name, tc := range testcases {
+		for i, expected := range tc.expected {
+			if picked := tc.strategy.Pick(backends); picked != backends[expected] {
+				t.Errorf("%s: pick %d: expected backend %d", name, i, expected)
+			}
+		}
+	}
+
+	backends[0].outstanding = 2
+	backends[1].outstanding = 1
+	backends[2].outstanding = 1
+	if picked := LeastOutstanding().Pick(backends); picked != backends[1] {
+		t.Error("least-outstanding: expected backend 1")
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	backends := newTestBackends(1, 1)
+	b := NewBalancer(nil, backends...)
+
+	for i := 0; i < 4; i++ {
+		if _, err := b.Search(&SearchRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, backend := range backends {
+		client := backend.Client.(*countingClient)
+		if client.searches != 2 {
+			t.Errorf("backend %d: got %d searches, expected 2", i, client.searches)
+		}
+		if backend.Outstanding() != 0 {
+			t.Errorf("backend %d: got %d outstanding operations, expected 0", i, backend.Outstanding())
+		}
+	}
+
+	bindErr := errors.New("invalid credentials")
+	backends[0].Client.(*countingClient).bindErr = bindErr
+	if err := b.Bind("cn=admin", "secret"); err != bindErr {
+		t.Errorf("got error %v, expected %v", err, bindErr)
+	}
+	for i, backend := range backends {
+		if binds := backend.Client.(*countingClient).binds; binds != 1 {
+			t.Errorf("backend %d: got %d binds, expected 1", i, binds)
+		}
+	}
+}
+
+func TestBalancerCircuitBreaker(t *testing.T) {
+	backends := newTestBackends(1, 1)
+	failing := backends[0].Client.(*countingClient)
+	failing.searchErr = NewError(ErrorNetwork, errors.New("connection refused"))
+	b := NewBalancer(nil, backends...)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	b.SetCircuitBreaker(2, time.Minute)
+
+	search := func(n int) {
+		for i := 0; i < n; i++ {
+			b.Search(&SearchRequest{})
+		}
+	}
+
+	// the second failure quarantines the backend
+	search(4)
+	if failing.searches != 2 || !b.Quarantined(backends[0]) {
+		t.Fatalf("expected the backend to be quarantined after 2 failures, got %d searches", failing.searches)
+	}
+	search(4)
+	if failing.searches != 2 {
+		t.Errorf("expected no search on the quarantined backend, got %d", failing.searches-2)
+	}
+
+	// after the cooldown, one failed operation quarantines it again
+	now = now.Add(2 * time.Minute)
+	search(2)
+	if failing.searches != 3 || !b.Quarantined(backends[0]) {
+		t.Errorf("expected the backend to be tested once and quarantined again, got %d searches", failing.searches)
+	}
+
+	// a successful operation closes the circuit
+	now = now.Add(2 * time.Minute)
+	failing.searchErr = nil
+	search(4)
+	if failing.searches != 5 || b.Quarantined(backends[0]) {
+		t.Errorf("expected the backend to be used again, got %d searches", failing.searches)
+	}
+
+	// all backends are used if they are all quarantined
+	backends[1].Client.(*countingClient).searchErr = NewError(LDAPResultUnavailable, errors.New("unavailable"))
+	failing.searchErr = NewError(LDAPResultBusy, errors.New("busy"))
+	search(4)
+	if !b.Quarantined(backends[0]) || !b.Quarantined(backends[1]) {
+		t.Fatalf("expected both backends to be quarantined")
+	}
+	searches := failing.searches
+	search(2)
+	if failing.searches != searches+1 {
+		t.Errorf("expected the quarantined backends to be used, got %d searches", failing.searches-searches)
+	}
+}
@@ -0,0 +1,118 @@
+package ldap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The benchmarks of the BER layer and of the message loop, to be compared
+// with the ones of a build with the ldap_packets tag, e.g.
+//
+//	go test -run XXX -bench . -benchmem
+//	go test -run XXX -bench . -benchmem -tags ldap_packets
+
+func BenchmarkEncodeBindRequest(b *testing.B) {
+	bindRequest := NewSimpleBindRequest("uid=jdoe,ou=people,dc=example,dc=com", "secret", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bindRequest.encode().Bytes()
+	}
+}
+
+func BenchmarkEncodeSearchRequest(b *testing.B) {
+	searchRequest := NewSearchRequest("ou=people,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 100, 0, false,
+		"(&(objectClass=person)(|(uid=jdoe)(mail=jdoe@*)))", []string{"uid", "cn", "mail", "memberOf"}, []Control{NewControlPaging(100)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		packet, err := searchRequest.encode()
+		if err != nil {
+			b.Fatal(err)
+		}
+		packet.Bytes()
+	}
+}
+
+func BenchmarkDecodeResult(b *testing.B) {
+	data := newResultPacket(7, ApplicationModifyResponse, LDAPResultSuccess, "").Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader := bytes.NewReader(data)
+		header, length, err := readMessageHeader(reader, DefaultMaxMessageSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		packet, err := readMessage(reader, header, length)
+		if err != nil {
+			b.Fatal(err)
+		}
+		decodeResponse(packet)
+	}
+}
+
+func BenchmarkBind(b *testing.B) {
+	server, conn := newTestBackendServer(b)
+	defer server.Close()
+	defer conn.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	server, conn := newTestBackendServer(b)
+	defer server.Close()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("ou=people,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.Search(searchRequest); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkModify(b *testing.B) {
+	server, conn := newTestBackendServer(b)
+	defer server.Close()
+	defer conn.Close()
+
+	modifyRequest := NewModifyRequest("uid=asmith,ou=people,dc=example,dc=com")
+	for i := 0; i < 12; i++ {
+		modifyRequest.Replace(fmt.Sprintf("attribute%d", i), []string{strings.Repeat("x", 40)})
+	}
+	modifyRequest.Increment("uidNumber", 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := conn.Modify(modifyRequest); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParallelSearch(b *testing.B) {
+	server, conn := newTestBackendServer(b)
+	defer server.Close()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("uid=jdoe,ou=people,dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := conn.Search(searchRequest); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,160 @@
+// File contains the encoding of requests into pooled buffers
+
+package ldap
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// maxPooledBufferSize is the capacity above which a buffer is not returned
+// to the pool, so that a single large request does not keep its memory
+const maxPooledBufferSize = 64 << 10
+
+var berBufferPool = sync.Pool{
+	New: func() interface{} { return new(berBuffer) },
+}
+
+// berBuffer encodes BER elements directly into a byte slice, rather than
+// building a tree of packets with an allocation per node and a copy of the
+// content at every level
+type berBuffer struct {
+	data []byte
+	// starts are the offsets of the content of the constructed elements
+	// begun but not ended yet
+	starts []int
+}
+
+// bufferEncoder is implemented by the requests which can encode their
+// protocol operation into a berBuffer, as encode would
+type bufferEncoder interface {
+	encodeBuffer(b *berBuffer)
+}
+
+// getBerBuffer returns an empty buffer from the pool
+func getBerBuffer() *berBuffer {
+	return berBufferPool.Get().(*berBuffer)
+}
+
+// release returns the buffer to the pool
+func (b *berBuffer) release() {
+	if cap(b.data) > maxPooledBufferSize {
+		return
+	}
+	b.data = b.data[:0]
+	b.starts = b.starts[:0]
+	berBufferPool.Put(b)
+}
+
+// begin starts a constructed element, whose content is the elements appended
+// until end is called
+func (b *berBuffer) begin(identifier byte) {
+	b.data = append(b.data, identifier, 0)
+	b.starts = append(b.starts, len(b.data))
+}
+
+// end ends the last constructed element begun, writing its length
+func (b *berBuffer) end() {
+	start := b.starts[len(b.starts)-1]
+	b.starts = b.starts[:len(b.starts)-1]
+	length := len(b.data) - start
+	if length < 0x80 {
+		b.data[start-1] = byte(length)
+		return
+	}
+
+	// the long form needs more octets, so the content is moved after them
+	n := lengthOctets(length)
+	b.data = append(b.data, make([]byte, n)...)
+	copy(b.data[start+n:], b.data[start:len(b.data)-n])
+	b.data[start-1] = 0x80 | byte(n)
+	for i := 0; i < n; i++ {
+		b.data[start+i] = byte(length >> uint(8*(n-1-i)))
+	}
+}
+
+// appendHeader appends the identifier and the length octets of an element
+func (b *berBuffer) appendHeader(identifier byte, length int) {
+	b.data = append(b.data, identifier)
+	if length < 0x80 {
+		b.data = append(b.data, byte(length))
+		return
+	}
+	n := lengthOctets(length)
+	b.data = append(b.data, 0x80|byte(n))
+	for i := n - 1; i >= 0; i-- {
+		b.data = append(b.data, byte(length>>uint(8*i)))
+	}
+}
+
+// appendString appends a primitive element holding the string
+func (b *berBuffer) appendString(identifier byte, value string) {
+	b.appendHeader(identifier, len(value))
+	b.data = append(b.data, value...)
+}
+
+// appendBytes appends a primitive element holding the bytes
+func (b *berBuffer) appendBytes(identifier byte, value []byte) {
+	b.appendHeader(identifier, len(value))
+	b.data = append(b.data, value...)
+}
+
+// appendInteger appends a primitive element holding the integer, in the
+// fewest octets of two's complement
+func (b *berBuffer) appendInteger(identifier byte, value int64) {
+	n := 1
+	for v := value; v > 127 || v < -128; v >>= 8 {
+		n++
+	}
+	b.appendHeader(identifier, n)
+	for i := n - 1; i >= 0; i-- {
+		b.data = append(b.data, byte(value>>uint(8*i)))
+	}
+}
+
+// packet returns the single element encoded as a packet without children,
+// holding a copy of its content
+func (b *berBuffer) packet() *asn1.Packet {
+	identifier := b.data[0]
+	_, start, end, _ := berElement(b.data, 0)
+	return &asn1.Packet{
+		Identifier: asn1.Identifier{
+			ClassType: asn1.Class(identifier) & asn1.ClassBitmask,
+			TagType:   asn1.Type(identifier) & asn1.TypeBitmask,
+			Tag:       asn1.Tag(identifier) & asn1.TagBitmask,
+		},
+		Data: bytes.NewBuffer(append([]byte(nil), b.data[start:end]...)),
+	}
+}
+
+// lengthOctets returns the number of octets of the long form of the length
+func lengthOctets(length int) int {
+	n := 1
+	for length > 0xff {
+		length >>= 8
+		n++
+	}
+	return n
+}
+
+// encodeRequest returns the protocol operation of the request, encoded into
+// a pooled buffer if the request supports it, unless the tree of packets is
+// needed, e.g. for the debug output or a send hook
+func encodeRequest(request Request, tree bool) *asn1.Packet {
+	encoder, ok := request.(bufferEncoder)
+	if !ok || tree || packetTrees {
+		return request.encode()
+	}
+	b := getBerBuffer()
+	defer b.release()
+	encoder.encodeBuffer(b)
+	return b.packet()
+}
+
+// berApplication returns the identifier octet of a constructed element of
+// the application class
+func berApplication(tag asn1.Tag) byte {
+	return byte(asn1.ClassApplication) | byte(asn1.TypeConstructed) | byte(tag)
+}
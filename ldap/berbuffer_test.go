@@ -0,0 +1,111 @@
+package ldap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newTestModifyRequest returns a modify request with the given number of
+// changes, with values of the given size
+func newTestModifyRequest(changes, size int) *ModifyRequest {
+	modifyRequest := NewModifyRequest("uid=jdoe,ou=people,dc=example,dc=com")
+	for i := 0; i < changes; i++ {
+		value := strings.Repeat(fmt.Sprint(i%10), size)
+		switch i % 4 {
+		case 0:
+			modifyRequest.Replace(fmt.Sprintf("attribute%d", i), []string{value, value + "2"})
+		case 1:
+			modifyRequest.AddBinary("jpegPhoto", [][]byte{[]byte(value)})
+		case 2:
+			modifyRequest.Delete("description", nil)
+		case 3:
+			modifyRequest.Increment("uidNumber", int64(-i*1000))
+		}
+	}
+	return modifyRequest
+}
+
+func TestEncodeBuffer(t *testing.T) {
+	addRequest := NewAddRequest("uid=jdoe,ou=people,dc=example,dc=com")
+	addRequest.Attribute("objectClass", []string{"top", "person"})
+	addRequest.BinaryAttribute("jpegPhoto", [][]byte{bytes.Repeat([]byte{0xff}, 70000)})
+	addRequest.Attribute("description", nil)
+
+	requests := []Request{
+		NewModifyRequest("dc=example,dc=com"),
+		newTestModifyRequest(1, 10),
+		newTestModifyRequest(8, 100),
+		newTestModifyRequest(20, 300),
+		newTestModifyRequest(3, 70000),
+		NewAddRequest(""),
+		addRequest,
+	}
+	for i, request := range requests {
+		expected := request.encode().Bytes()
+		// the buffers are reused from the pool between the requests
+		packet := encodeRequest(request, false)
+		if len(packet.Children) != 0 && !packetTrees {
+			t.Errorf("%d: expected the request to be encoded into a buffer", i)
+		}
+		if !bytes.Equal(packet.Bytes(), expected) {
+			t.Errorf("%d: expected %x, got %x", i, expected, packet.Bytes())
+		}
+		if packet := encodeRequest(request, true); len(packet.Children) == 0 {
+			t.Errorf("%d: expected a tree of packets", i)
+		}
+	}
+}
+
+func TestBerBufferInteger(t *testing.T) {
+	for _, value := range []int64{0, 1, -1, 127, 128, -128, -129, 255, 256, 65535, -65536, 1 << 40, -1 << 62} {
+		b := getBerBuffer()
+		b.appendInteger(berInteger, value)
+		expected := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, value, "Integer").Bytes()
+		if !bytes.Equal(b.data, expected) {
+			t.Errorf("%d: expected %x, got %x", value, expected, b.data)
+		}
+		b.release()
+	}
+}
+
+func TestModifyWithBuffer(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	modifyRequest := NewModifyRequest("uid=jdoe,ou=people,dc=example,dc=com")
+	modifyRequest.Replace("cn", []string{"Johnny Doe"})
+	modifyRequest.Increment("uidNumber", 5)
+	if err := conn.Modify(modifyRequest); err != nil {
+		t.Fatal(err)
+	}
+	result, err := conn.Search(NewSearchRequest("uid=jdoe,ou=people,dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"cn", "uidNumber"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn, uidNumber := result.Entries[0].GetAttributeValue("cn"), result.Entries[0].GetAttributeValue("uidNumber"); cn != "Johnny Doe" || uidNumber != "1005" {
+		t.Errorf("unexpected entry cn=%q uidNumber=%q", cn, uidNumber)
+	}
+}
+
+func BenchmarkEncodeModifyPackets(b *testing.B) {
+	modifyRequest := newTestModifyRequest(50, 40)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encodeRequest(modifyRequest, true).Bytes()
+	}
+}
+
+func BenchmarkEncodeModifyBuffer(b *testing.B) {
+	modifyRequest := newTestModifyRequest(50, 40)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encodeRequest(modifyRequest, false).Bytes()
+	}
+}
@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 
 	"github.com/gostores/encoding/asn1"
@@ -24,6 +25,17 @@ type SimpleBindResult struct {
 	Controls []Control
 }
 
+// AuthzID returns the authorization identity of the connection returned in
+// a ControlAuthzIDResponse, when the bind request was sent with a
+// ControlAuthzIDRequest, and whether the server returned it
+func (r *SimpleBindResult) AuthzID() (string, bool) {
+	control, ok := FindControl(r.Controls, ControlTypeAuthzIDResponse).(*ControlAuthzIDResponse)
+	if !ok {
+		return "", false
+	}
+	return control.AuthzID, true
+}
+
 // NewSimpleBindRequest returns a bind request
 func NewSimpleBindRequest(username string, password string, controls []Control) *SimpleBindRequest {
 	return &SimpleBindRequest{
@@ -40,65 +52,50 @@ func (bindRequest *SimpleBindRequest) encode() *asn1.Packet {
 	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, bindRequest.Username, "User Name"))
 	request.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, bindRequest.Password, "Password"))
 
-	request.AppendChild(encodeControls(bindRequest.Controls))
-
 	return request
 }
 
-// SimpleBind performs the simple bind operation defined in the given request
-func (l *Conn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
-	if simpleBindRequest.Password == "" && !simpleBindRequest.AllowEmptyPassword {
-		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
-	}
-
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
-	encodedBindRequest := simpleBindRequest.encode()
-	packet.AppendChild(encodedBindRequest)
-
-	if l.Debug {
-		asn1.PrintPacket(packet)
-	}
-
-	msgCtx, err := l.sendMessage(packet)
-	if err != nil {
-		return nil, err
-	}
-	defer l.finishMessage(msgCtx)
+func (bindRequest *SimpleBindRequest) controls() []Control {
+	return bindRequest.Controls
+}
 
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
-	if err != nil {
-		return nil, err
-	}
+func (bindRequest *SimpleBindRequest) responseTag() asn1.Tag {
+	return ApplicationBindResponse
+}
 
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return nil, err
-		}
-		asn1.PrintPacket(packet)
+// decodeSimpleBindRequest decodes the protocol operation of a bind request
+// received by a Server, which must be a simple bind
+func decodeSimpleBindRequest(packet *asn1.Packet) (*SimpleBindRequest, error) {
+	authentication := packet.Children[2]
+	if authentication.ClassType != asn1.ClassContext || authentication.Tag != 0 {
+		return nil, NewError(LDAPResultAuthMethodNotSupported, errors.New("ldap: only simple binds are supported"))
 	}
+	return &SimpleBindRequest{
+		Username:           decodeOctetString(packet.Children[1]),
+		Password:           decodeOctetString(authentication),
+		AllowEmptyPassword: true,
+	}, nil
+}
 
-	result := &SimpleBindResult{
-		Controls: make([]Control, 0),
-	}
+// SimpleBind performs the simple bind operation defined in the given request
+func (l *Conn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	return l.SimpleBindContext(context.Background(), simpleBindRequest)
+}
 
-	if len(packet.Children) == 3 {
-		for _, child := range packet.Children[2].Children {
-			result.Controls = append(result.Controls, DecodeControl(child))
-		}
+// SimpleBindContext performs the simple bind operation defined in the given
+// request. If ctx is done before the server responds, the connection is closed
+// and ctx.Err() is returned: a bind cannot be abandoned (RFC 4511 section
+// 4.11), so the authentication state of the connection would be unknown.
+func (l *Conn) SimpleBindContext(ctx context.Context, simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	if simpleBindRequest.Password == "" && !simpleBindRequest.AllowEmptyPassword {
+		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
 	}
 
-	resultCode, resultDescription := getLDAPResultCode(packet)
-	if resultCode != 0 {
-		return result, NewError(resultCode, errors.New(resultDescription))
+	response, err := l.DoContext(ctx, simpleBindRequest)
+	if response == nil {
+		return nil, err
 	}
-
-	return result, nil
+	return &SimpleBindResult{Controls: response.Controls}, err
 }
 
 // Bind performs a bind with the given username and password.
@@ -106,12 +103,18 @@ func (l *Conn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResu
 // It does not allow unauthenticated bind (i.e. empty password). Use the UnauthenticatedBind method
 // for that.
 func (l *Conn) Bind(username, password string) error {
+	return l.BindContext(context.Background(), username, password)
+}
+
+// BindContext is like Bind, but closes the connection and returns ctx.Err() if
+// ctx is done before the server responds.
+func (l *Conn) BindContext(ctx context.Context, username, password string) error {
 	req := &SimpleBindRequest{
 		Username:           username,
 		Password:           password,
 		AllowEmptyPassword: false,
 	}
-	_, err := l.SimpleBind(req)
+	_, err := l.SimpleBindContext(ctx, req)
 	return err
 }
 
@@ -123,11 +126,17 @@ func (l *Conn) Bind(username, password string) error {
 // See https://tools.ietf.org/html/rfc4513#section-5.1.2 .
 // See https://tools.ietf.org/html/rfc4513#section-6.3.1 .
 func (l *Conn) UnauthenticatedBind(username string) error {
+	return l.UnauthenticatedBindContext(context.Background(), username)
+}
+
+// UnauthenticatedBindContext is like UnauthenticatedBind, but closes the
+// connection and returns ctx.Err() if ctx is done before the server responds.
+func (l *Conn) UnauthenticatedBindContext(ctx context.Context, username string) error {
 	req := &SimpleBindRequest{
 		Username:           username,
 		Password:           "",
 		AllowEmptyPassword: true,
 	}
-	_, err := l.SimpleBind(req)
+	_, err := l.SimpleBindContext(ctx, req)
 	return err
 }
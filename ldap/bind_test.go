@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newBeheraPasswordPolicyResponse returns the password policy response control
+// as sent by a server, holding either a warning or an error.
+func newBeheraPasswordPolicyResponse(warning asn1.Tag, warningValue int64, errorValue int64) *asn1.Packet {
+	sequence := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "PasswordPolicyResponseValue")
+	if warningValue >= 0 {
+		choice := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Warning")
+		choice.AppendChild(asn1.NewInteger(asn1.ClassContext, asn1.TypePrimitive, warning, warningValue, "Warning Value"))
+		sequence.AppendChild(choice)
+	}
+	if errorValue >= 0 {
+		sequence.AppendChild(asn1.NewInteger(asn1.ClassContext, asn1.TypePrimitive, 1, errorValue, "Error"))
+	}
+
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type"))
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value")
+	value.AppendChild(sequence)
+	packet.AppendChild(value)
+	return asn1.DecodePacket(packet.Bytes())
+}
+
+func TestDecodeControlBeheraPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		packet *asn1.Packet
+		expire int64
+		grace  int64
+		err    int8
+	}{
+		{newBeheraPasswordPolicyResponse(0, 3600, -1), 3600, -1, -1},
+		{newBeheraPasswordPolicyResponse(1, 2, -1), -1, 2, -1},
+		{newBeheraPasswordPolicyResponse(0, -1, BeheraAccountLocked), -1, -1, BeheraAccountLocked},
+		{newBeheraPasswordPolicyResponse(1, 0, BeheraPasswordExpired), -1, 0, BeheraPasswordExpired},
+	}
+	for i, test := range tests {
+		c, ok := DecodeControl(test.packet).(*ControlBeheraPasswordPolicy)
+		if !ok {
+			t.Fatalf("%d: expected *ControlBeheraPasswordPolicy", i)
+		}
+		if c.Expire != test.expire || c.Grace != test.grace || c.Error != test.err {
+			t.Errorf("%d: unexpected control %s", i, c)
+		}
+		if c.ErrorString != BeheraPasswordPolicyErrorMap[test.err] {
+			t.Errorf("%d: unexpected error string %q", i, c.ErrorString)
+		}
+	}
+}
+
+func TestSimpleBindControls(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *asn1.Packet, 1)
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		requests <- request
+		response := newResultPacket(request.Children[0].Value.(int64), ApplicationBindResponse, LDAPResultInvalidCredentials, "")
+		controls := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+		controls.AppendChild(newBeheraPasswordPolicyResponse(0, -1, BeheraPasswordExpired))
+		response.AppendChild(controls)
+		return []*asn1.Packet{response}
+	})
+
+	var (
+		result *SimpleBindResult
+		err    error
+	)
+	runWithTimeout(t, time.Second, func() {
+		req := NewSimpleBindRequest("uid=jdoe,dc=example,dc=com", "secret", []Control{NewControlBeheraPasswordPolicy()})
+		result, err = conn.SimpleBind(req)
+	})
+	if !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+		t.Fatalf("expected invalid credentials error, got %v", err)
+	}
+
+	request := <-requests
+	if len(request.Children[1].Children) != 3 {
+		t.Errorf("expected controls to be sent outside of the bind request")
+	}
+	if len(request.Children) != 3 || DecodeControl(request.Children[2].Children[0]).GetControlType() != ControlTypeBeheraPasswordPolicy {
+		t.Fatalf("expected password policy control in the request")
+	}
+
+	ppolicy, ok := FindControl(result.Controls, ControlTypeBeheraPasswordPolicy).(*ControlBeheraPasswordPolicy)
+	if !ok {
+		t.Fatalf("expected password policy control in the result")
+	}
+	if ppolicy.Error != BeheraPasswordExpired {
+		t.Errorf("expected password expired error, got %s", ppolicy)
+	}
+}
@@ -0,0 +1,80 @@
+// File contains TLS channel binding functionality
+//
+// https://tools.ietf.org/html/rfc5929
+
+package ldap
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	// register the hash functions used by certificate signatures
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Channel binding types
+const (
+	ChannelBindingTLSUnique         = "tls-unique"
+	ChannelBindingTLSServerEndPoint = "tls-server-end-point"
+)
+
+// ChannelBinding holds the data binding an authentication to the underlying
+// TLS channel, so that the credentials cannot be relayed by a man in the middle
+type ChannelBinding struct {
+	// Type is the channel binding type, e.g. ChannelBindingTLSServerEndPoint
+	Type string
+	// Data is the channel binding data
+	Data []byte
+}
+
+// ChannelBinding returns the channel binding of the given type for the TLS
+// connection. tls-unique is not defined for TLS 1.3 connections, on which
+// tls-server-end-point has to be used.
+func (l *Conn) ChannelBinding(bindingType string) (*ChannelBinding, error) {
+	state, ok := l.TLSConnectionState()
+	if !ok {
+		return nil, NewError(ErrorNetwork, errors.New("ldap: channel binding requires a TLS connection"))
+	}
+
+	switch bindingType {
+	case ChannelBindingTLSUnique:
+		if len(state.TLSUnique) == 0 {
+			return nil, NewError(ErrorNetwork, errors.New("ldap: tls-unique channel binding not available for the connection"))
+		}
+		return &ChannelBinding{Type: bindingType, Data: state.TLSUnique}, nil
+	case ChannelBindingTLSServerEndPoint:
+		if len(state.PeerCertificates) == 0 {
+			return nil, NewError(ErrorNetwork, errors.New("ldap: server did not send a certificate"))
+		}
+		data, err := tlsServerEndPoint(state.PeerCertificates[0])
+		if err != nil {
+			return nil, err
+		}
+		return &ChannelBinding{Type: bindingType, Data: data}, nil
+	}
+	return nil, fmt.Errorf("ldap: unknown channel binding type %q", bindingType)
+}
+
+// tlsServerEndPoint returns the hash of the server certificate, as described
+// in https://tools.ietf.org/html/rfc5929#section-4.1
+func tlsServerEndPoint(certificate *x509.Certificate) ([]byte, error) {
+	var h crypto.Hash
+	switch certificate.SignatureAlgorithm {
+	case x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1,
+		x509.SHA256WithRSA, x509.DSAWithSHA256, x509.ECDSAWithSHA256, x509.SHA256WithRSAPSS:
+		// MD5 and SHA-1 are replaced with SHA-256
+		h = crypto.SHA256
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		h = crypto.SHA384
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		h = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("ldap: no tls-server-end-point channel binding for certificate signature algorithm %s", certificate.SignatureAlgorithm)
+	}
+	hash := h.New()
+	hash.Write(certificate.Raw)
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,92 @@
+package ldap
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTLSConnPair returns a client connection which completed a TLS handshake
+// with a server using a self-signed certificate, and that certificate. The
+// connection is closed by closing the returned server side.
+func newTLSConnPair(t *testing.T) (*tls.Conn, net.Conn, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	server := tls.Server(serverConn, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MaxVersion:   tls.VersionTLS12,
+	})
+	go server.Handshake()
+	client := tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true})
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	return client, serverConn, certificate
+}
+
+func TestChannelBinding(t *testing.T) {
+	tlsConn, serverConn, certificate := newTLSConnPair(t)
+	defer serverConn.Close()
+	conn := NewConn(tlsConn, true)
+
+	binding, err := conn.ChannelBinding(ChannelBindingTLSServerEndPoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(certificate.Raw)
+	if binding.Type != ChannelBindingTLSServerEndPoint || !bytes.Equal(binding.Data, hash[:]) {
+		t.Errorf("unexpected tls-server-end-point channel binding %x", binding.Data)
+	}
+
+	binding, err = conn.ChannelBinding(ChannelBindingTLSUnique)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := conn.TLSConnectionState(); !bytes.Equal(binding.Data, state.TLSUnique) {
+		t.Errorf("unexpected tls-unique channel binding %x", binding.Data)
+	}
+
+	if _, err := conn.ChannelBinding("tls-exporter"); err == nil {
+		t.Errorf("expected error for unknown channel binding type")
+	}
+}
+
+func TestChannelBindingWithoutTLS(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	if _, ok := conn.TLSConnectionState(); ok {
+		t.Errorf("expected no TLS connection state")
+	}
+	if _, err := conn.ChannelBinding(ChannelBindingTLSServerEndPoint); err == nil {
+		t.Errorf("expected error without TLS")
+	}
+}
@@ -0,0 +1,390 @@
+// File contains Connectionless LDAP functionality
+//
+// https://tools.ietf.org/html/rfc1798
+//
+// CLDAP carries LDAP messages in UDP datagrams without binding first. Its
+// main use today is the Active Directory LDAP ping, a rootDSE search for the
+// Netlogon attribute, described in
+// https://msdn.microsoft.com/en-us/library/cc223811.aspx
+
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// NtVersion flags of the LDAP ping, selecting the format of the Netlogon response
+const (
+	NetlogonNtVersion1               = 0x00000001
+	NetlogonNtVersion5               = 0x00000002
+	NetlogonNtVersion5EX             = 0x00000004
+	NetlogonNtVersion5EXWithIP       = 0x00000008
+	NetlogonNtVersionWithClosestSite = 0x00000010
+)
+
+// Flags of the domain controller in the Netlogon response
+const (
+	NetlogonFlagPDC           = 0x00000001
+	NetlogonFlagGC            = 0x00000004
+	NetlogonFlagLDAP          = 0x00000008
+	NetlogonFlagDS            = 0x00000010
+	NetlogonFlagKDC           = 0x00000020
+	NetlogonFlagTimeServ      = 0x00000040
+	NetlogonFlagClosest       = 0x00000080
+	NetlogonFlagWritable      = 0x00000100
+	NetlogonFlagGoodTimeServ  = 0x00000200
+	NetlogonFlagNDNC          = 0x00000400
+	NetlogonFlagDNSController = 0x20000000
+	NetlogonFlagDNSDomain     = 0x40000000
+	NetlogonFlagDNSForest     = 0x80000000
+)
+
+// Opcodes of the NETLOGON_SAM_LOGON_RESPONSE_EX structure
+const (
+	netlogonLogonSAMResponseEX    = 23
+	netlogonLogonSAMUserUnknownEX = 25
+)
+
+// cldapMaxDatagramSize is the largest UDP payload
+const cldapMaxDatagramSize = 65507
+
+// CLDAPConn represents a Connectionless LDAP client
+type CLDAPConn struct {
+	conn           net.Conn
+	Debug          debugging
+	messageMutex   sync.Mutex
+	messageID      int64
+	requestTimeout time.Duration
+}
+
+// NetlogonResponse is the NETLOGON_SAM_LOGON_RESPONSE_EX returned by a domain
+// controller to an LDAP ping
+type NetlogonResponse struct {
+	// Opcode is 23 for a known user or no user, 25 for an unknown user
+	Opcode uint16
+	// Flags are the NetlogonFlag bits describing the domain controller
+	Flags uint32
+	// DomainGUID is the GUID of the domain
+	DomainGUID [16]byte
+	// DNSForestName is the DNS name of the forest
+	DNSForestName string
+	// DNSDomainName is the DNS name of the domain
+	DNSDomainName string
+	// DNSHostName is the DNS name of the domain controller
+	DNSHostName string
+	// NetbiosDomainName is the NetBIOS name of the domain
+	NetbiosDomainName string
+	// NetbiosComputerName is the NetBIOS name of the domain controller
+	NetbiosComputerName string
+	// UserName is the user name given in the request
+	UserName string
+	// DCSiteName is the site of the domain controller
+	DCSiteName string
+	// ClientSiteName is the site of the client, empty if its address is in no site
+	ClientSiteName string
+	// DCAddress is the IPv4 address of the domain controller, only sent for
+	// NetlogonNtVersion5EXWithIP
+	DCAddress net.IP
+	// NextClosestSiteName is the site closest to the client with a domain
+	// controller, only sent for NetlogonNtVersionWithClosestSite
+	NextClosestSiteName string
+	// NtVersion is the version of the response
+	NtVersion uint32
+}
+
+// DialCLDAP returns a new CLDAPConn sending its requests to the given address
+// over UDP. The default port is DefaultLdapPort.
+func DialCLDAP(address string) (*CLDAPConn, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultLdapPort)
+	}
+	c, err := net.DialTimeout("udp", address, DefaultTimeout)
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	return &CLDAPConn{
+		conn:           c,
+		requestTimeout: 5 * time.Second,
+	}, nil
+}
+
+// Close closes the connection
+func (c *CLDAPConn) Close() error {
+	return c.conn.Close()
+}
+
+// SetTimeout sets how long to wait for a response when the context of a
+// request has no deadline, 5 seconds by default
+func (c *CLDAPConn) SetTimeout(timeout time.Duration) {
+	c.requestTimeout = timeout
+}
+
+// Search performs the given search request over CLDAP
+func (c *CLDAPConn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
+	return c.SearchContext(context.Background(), searchRequest)
+}
+
+// SearchContext performs the given search request over CLDAP. If ctx is done
+// before the search completes, ctx.Err() is returned. The request is not
+// retransmitted, as lost datagrams are expected to be handled by the caller.
+func (c *CLDAPConn) SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	c.messageMutex.Lock()
+	defer c.messageMutex.Unlock()
+	c.messageID++
+	messageID := c.messageID
+
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	encodedSearchRequest, err := searchRequest.encode()
+	if err != nil {
+		return nil, err
+	}
+	packet.AppendChild(encodedSearchRequest)
+	if searchRequest.Controls != nil {
+		packet.AppendChild(encodeControls(searchRequest.Controls))
+	}
+
+	c.Debug.PrintPacket(packet)
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.requestTimeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// unblock the pending read
+			c.conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	if _, err := c.conn.Write(packet.Bytes()); err != nil {
+		return nil, c.networkError(ctx, err)
+	}
+
+	result := &SearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: make([]string, 0),
+		Controls:  make([]Control, 0)}
+
+	buf := make([]byte, cldapMaxDatagramSize)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return nil, c.networkError(ctx, err)
+		}
+
+		// a datagram holds one or more LDAP messages
+		reader := bytes.NewReader(buf[:n])
+		for reader.Len() > 0 {
+			packet, err := asn1.ReadPacket(reader)
+			if err != nil {
+				return nil, NewError(ErrorUnexpectedResponse, err)
+			}
+			if len(packet.Children) < 2 {
+				return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: malformed CLDAP response"))
+			}
+			if id, ok := packet.Children[0].Value.(int64); !ok || id != messageID {
+				// response to an earlier request which timed out
+				continue
+			}
+
+			if c.Debug {
+				if err := addLDAPDescriptions(packet); err != nil {
+					return nil, err
+				}
+				asn1.PrintPacket(packet)
+			}
+
+			switch packet.Children[1].Tag {
+			case ApplicationSearchResultEntry:
+				result.Entries = append(result.Entries, decodeEntry(packet.Children[1]))
+			case ApplicationSearchResultDone:
+				resultCode, _ := getLDAPResultCode(packet)
+				if resultCode != 0 {
+					return result, newResultError(packet)
+				}
+				if len(packet.Children) == 3 {
+					for _, child := range packet.Children[2].Children {
+						result.Controls = append(result.Controls, DecodeControl(child))
+					}
+				}
+				return result, nil
+			case ApplicationSearchResultReference:
+				result.Referrals = append(result.Referrals, packet.Children[1].Children[0].Value.(string))
+			}
+		}
+	}
+}
+
+// networkError returns ctx.Err() if the I/O error was caused by ctx being done
+func (c *CLDAPConn) networkError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return NewError(ErrorNetwork, err)
+}
+
+// NetlogonPing performs an LDAP ping for the given DNS domain name, which may
+// be empty, and returns the parsed Netlogon response of the domain controller
+func (c *CLDAPConn) NetlogonPing(dnsDomain string, ntVersion uint32) (*NetlogonResponse, error) {
+	return c.NetlogonPingContext(context.Background(), dnsDomain, ntVersion)
+}
+
+// NetlogonPingContext performs an LDAP ping for the given DNS domain name,
+// which may be empty, and returns the parsed Netlogon response of the domain
+// controller. ntVersion must include NetlogonNtVersion5EX.
+func (c *CLDAPConn) NetlogonPingContext(ctx context.Context, dnsDomain string, ntVersion uint32) (*NetlogonResponse, error) {
+	if ntVersion&NetlogonNtVersion5EX == 0 {
+		return nil, errors.New("ldap: NtVersion must include NetlogonNtVersion5EX")
+	}
+
+	var filter bytes.Buffer
+	filter.WriteString("(&")
+	if dnsDomain != "" {
+		filter.WriteString("(DnsDomain=" + EscapeFilter(dnsDomain) + ")")
+	}
+	filter.WriteString("(NtVer=")
+	var version [4]byte
+	binary.LittleEndian.PutUint32(version[:], ntVersion)
+	for _, b := range version {
+		fmt.Fprintf(&filter, "\\%02x", b)
+	}
+	filter.WriteString("))")
+
+	searchRequest := NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, filter.String(), []string{"Netlogon"}, nil)
+	result, err := c.SearchContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range result.Entries {
+		for _, attribute := range entry.Attributes {
+			if strings.EqualFold(attribute.Name, "Netlogon") && len(attribute.ByteValues) > 0 {
+				return ParseNetlogonResponse(attribute.ByteValues[0])
+			}
+		}
+	}
+	return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: no Netlogon attribute in CLDAP response"))
+}
+
+// ParseNetlogonResponse parses the NETLOGON_SAM_LOGON_RESPONSE_EX structure
+// held by the Netlogon attribute, as described in
+// https://msdn.microsoft.com/en-us/library/cc223807.aspx
+func ParseNetlogonResponse(data []byte) (*NetlogonResponse, error) {
+	malformed := NewError(ErrorUnexpectedResponse, errors.New("ldap: malformed Netlogon response"))
+	// opcode, sbz, flags and domain GUID, followed by the names and the
+	// trailing NtVersion, LmNtToken and Lm20Token
+	if len(data) < 24+8 {
+		return nil, malformed
+	}
+	response := &NetlogonResponse{
+		Opcode:    binary.LittleEndian.Uint16(data[0:2]),
+		Flags:     binary.LittleEndian.Uint32(data[4:8]),
+		NtVersion: binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4]),
+	}
+	if response.Opcode != netlogonLogonSAMResponseEX && response.Opcode != netlogonLogonSAMUserUnknownEX {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: unsupported Netlogon response opcode %d", response.Opcode))
+	}
+	copy(response.DomainGUID[:], data[8:24])
+
+	offset := 24
+	names := []*string{
+		&response.DNSForestName,
+		&response.DNSDomainName,
+		&response.DNSHostName,
+		&response.NetbiosDomainName,
+		&response.NetbiosComputerName,
+		&response.UserName,
+		&response.DCSiteName,
+		&response.ClientSiteName,
+	}
+	for _, name := range names {
+		var err error
+		*name, offset, err = parseNetlogonName(data, offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if response.NtVersion&NetlogonNtVersion5EXWithIP != 0 {
+		if offset >= len(data) {
+			return nil, malformed
+		}
+		size := int(data[offset])
+		offset++
+		// SOCKADDR_IN: family, port, IPv4 address and padding
+		if size < 8 || offset+size > len(data) {
+			return nil, malformed
+		}
+		response.DCAddress = net.IPv4(data[offset+4], data[offset+5], data[offset+6], data[offset+7])
+		offset += size
+	}
+	if response.NtVersion&NetlogonNtVersionWithClosestSite != 0 {
+		var err error
+		response.NextClosestSiteName, offset, err = parseNetlogonName(data, offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if offset > len(data)-8 {
+		return nil, malformed
+	}
+	return response, nil
+}
+
+// parseNetlogonName parses the name at the given offset, compressed as
+// described in https://tools.ietf.org/html/rfc1035#section-4.1.4, and returns
+// it with the offset of the following field
+func parseNetlogonName(data []byte, offset int) (string, int, error) {
+	malformed := NewError(ErrorUnexpectedResponse, errors.New("ldap: malformed name in Netlogon response"))
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(data) {
+			return "", 0, malformed
+		}
+		length := int(data[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(data) {
+				return "", 0, malformed
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			// guard against pointer loops
+			jumps++
+			if jumps > len(data) {
+				return "", 0, malformed
+			}
+			offset = (length&0x3f)<<8 | int(data[offset+1])
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, malformed
+			}
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
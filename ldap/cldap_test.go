@@ -0,0 +1,157 @@
+package ldap
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newNetlogonResponse returns a NETLOGON_SAM_LOGON_RESPONSE_EX for
+// dc1.example.com using name compression and holding the address of the
+// domain controller
+func newNetlogonResponse() []byte {
+	var b bytes.Buffer
+	b.Write([]byte{23, 0, 0, 0})              // opcode and sbz
+	b.Write([]byte{0xfd, 0x03, 0x00, 0xe0})   // flags
+	b.Write(bytes.Repeat([]byte{0xaa}, 16))   // domain GUID
+	b.Write([]byte("\x07example\x03com\x00")) // forest, at offset 24
+	b.Write([]byte{0xc0, 24})                 // domain
+	b.Write([]byte("\x03dc1\xc0\x18"))        // host
+	b.Write([]byte("\x07EXAMPLE\x00"))        // NetBIOS domain
+	b.Write([]byte("\x03DC1\x00"))            // NetBIOS computer
+	b.Write([]byte{0})                        // user
+	siteOffset := b.Len()
+	b.Write([]byte("\x17Default-First-Site-Name\x00"))                       // DC site
+	b.Write([]byte{0xc0, byte(siteOffset)})                                  // client site
+	b.Write([]byte{16, 2, 0, 0, 0, 192, 168, 1, 10, 0, 0, 0, 0, 0, 0, 0, 0}) // DC address
+	b.Write([]byte{0x0c, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})                   // NtVersion and tokens
+	return b.Bytes()
+}
+
+func TestParseNetlogonResponse(t *testing.T) {
+	response, err := ParseNetlogonResponse(newNetlogonResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{
+		"DNSForestName":       response.DNSForestName,
+		"DNSDomainName":       response.DNSDomainName,
+		"DNSHostName":         response.DNSHostName,
+		"NetbiosDomainName":   response.NetbiosDomainName,
+		"NetbiosComputerName": response.NetbiosComputerName,
+		"UserName":            response.UserName,
+		"DCSiteName":          response.DCSiteName,
+		"ClientSiteName":      response.ClientSiteName,
+	}
+	expected := map[string]string{
+		"DNSForestName":       "example.com",
+		"DNSDomainName":       "example.com",
+		"DNSHostName":         "dc1.example.com",
+		"NetbiosDomainName":   "EXAMPLE",
+		"NetbiosComputerName": "DC1",
+		"UserName":            "",
+		"DCSiteName":          "Default-First-Site-Name",
+		"ClientSiteName":      "Default-First-Site-Name",
+	}
+	for field, value := range expected {
+		if got[field] != value {
+			t.Errorf("%s: got %q, expected %q", field, got[field], value)
+		}
+	}
+	if response.Flags&NetlogonFlagDNSForest == 0 || response.Flags&NetlogonFlagPDC == 0 {
+		t.Errorf("unexpected flags %#x", response.Flags)
+	}
+	if !response.DCAddress.Equal(net.IPv4(192, 168, 1, 10)) {
+		t.Errorf("got address %s, expected 192.168.1.10", response.DCAddress)
+	}
+	if response.NtVersion != NetlogonNtVersion5EX|NetlogonNtVersion5EXWithIP {
+		t.Errorf("got NtVersion %#x", response.NtVersion)
+	}
+}
+
+func TestParseInvalidNetlogonResponse(t *testing.T) {
+	data := newNetlogonResponse()
+	testcases := map[string][]byte{
+		"truncated":     data[:30],
+		"bad opcode":    append([]byte{19, 0}, data[2:]...),
+		"pointer loop":  append(append(append([]byte{}, data[:24]...), 0xc0, 24), data[len(data)-8:]...),
+		"label overrun": append(append(append([]byte{}, data[:24]...), 0x3f, 'a'), data[len(data)-8:]...),
+	}
+
+	for name, data := range testcases {
+		if _, err := ParseNetlogonResponse(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCLDAPNetlogonPing(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	filters := make(chan string, 1)
+	go func() {
+		buf := make([]byte, cldapMaxDatagramSize)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request := asn1.DecodePacket(buf[:n])
+		filter, _ := DecompileFilter(request.Children[1].Children[6])
+		filters <- filter
+
+		messageID := request.Children[0].Value.(int64)
+		netlogon := NewEntryAttribute("netlogon", []string{string(newNetlogonResponse())})
+		var datagram bytes.Buffer
+		// a stale response to be skipped
+		datagram.Write(newResultPacket(messageID-1, ApplicationSearchResultDone, LDAPResultSuccess, "").Bytes())
+		datagram.Write(newEntryPacket(messageID, "", netlogon).Bytes())
+		datagram.Write(newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, "").Bytes())
+		pc.WriteTo(datagram.Bytes(), addr)
+	}()
+
+	c, err := DialCLDAP(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	response, err := c.NetlogonPing("example.com", NetlogonNtVersion5EX|NetlogonNtVersion5EXWithIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.DNSHostName != "dc1.example.com" {
+		t.Errorf("got host %q, expected dc1.example.com", response.DNSHostName)
+	}
+	if filter := <-filters; !strings.HasPrefix(filter, "(&(DnsDomain=example.com)(NtVer=") {
+		t.Errorf("unexpected filter %q", filter)
+	}
+}
+
+func TestCLDAPTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	c, err := DialCLDAP(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetTimeout(50 * time.Millisecond)
+
+	_, err = c.NetlogonPing("", NetlogonNtVersion5EX)
+	if !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected network error, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"crypto/tls"
 	"time"
 )
@@ -13,15 +14,30 @@ type Client interface {
 	SetTimeout(time.Duration)
 
 	Bind(username, password string) error
+	BindContext(ctx context.Context, username, password string) error
 	SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error)
+	SimpleBindContext(ctx context.Context, simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error)
 
 	Add(addRequest *AddRequest) error
+	AddContext(ctx context.Context, addRequest *AddRequest) error
 	Del(delRequest *DelRequest) error
+	DelContext(ctx context.Context, delRequest *DelRequest) error
 	Modify(modifyRequest *ModifyRequest) error
+	ModifyContext(ctx context.Context, modifyRequest *ModifyRequest) error
+	ModifyDN(modifyDNRequest *ModifyDNRequest) error
+	ModifyDNContext(ctx context.Context, modifyDNRequest *ModifyDNRequest) error
 
 	Compare(dn, attribute, value string) (bool, error)
+	CompareContext(ctx context.Context, dn, attribute, value string) (bool, error)
 	PasswordModify(passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error)
+	PasswordModifyContext(ctx context.Context, passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error)
+	Extended(extendedRequest *ExtendedRequest) (*ExtendedResponse, error)
+	ExtendedContext(ctx context.Context, extendedRequest *ExtendedRequest) (*ExtendedResponse, error)
+	Do(request Request) (*Response, error)
+	DoContext(ctx context.Context, request Request) (*Response, error)
 
 	Search(searchRequest *SearchRequest) (*SearchResult, error)
+	SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error)
 	SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error)
+	SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error)
 }
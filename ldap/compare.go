@@ -20,62 +20,54 @@
 package ldap
 
 import (
-	"errors"
-	"fmt"
+	"context"
 
 	"github.com/gostores/encoding/asn1"
 )
 
-// Compare checks to see if the attribute of the dn matches value. Returns true if it does otherwise
-// false with any error that occurs if any.
-func (l *Conn) Compare(dn, attribute, value string) (bool, error) {
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
+type compareRequest struct {
+	DN        string
+	Attribute string
+	Value     string
+}
 
+func (req *compareRequest) encode() *asn1.Packet {
 	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationCompareRequest, nil, "Compare Request")
-	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, dn, "DN"))
+	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, req.DN, "DN"))
 
 	ava := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "AttributeValueAssertion")
-	ava.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, attribute, "AttributeDesc"))
-	ava.AppendChild(asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagOctetString, value, "AssertionValue"))
+	ava.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, req.Attribute, "AttributeDesc"))
+	ava.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, req.Value, "AssertionValue"))
 	request.AppendChild(ava)
-	packet.AppendChild(request)
+	return request
+}
 
-	l.Debug.PrintPacket(packet)
+func (req *compareRequest) controls() []Control {
+	return nil
+}
 
-	msgCtx, err := l.sendMessage(packet)
-	if err != nil {
-		return false, err
-	}
-	defer l.finishMessage(msgCtx)
+func (req *compareRequest) responseTag() asn1.Tag {
+	return ApplicationCompareResponse
+}
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return false, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+// Compare checks to see if the attribute of the dn matches value. Returns true if it does otherwise
+// false with any error that occurs if any.
+func (l *Conn) Compare(dn, attribute, value string) (bool, error) {
+	return l.CompareContext(context.Background(), dn, attribute, value)
+}
+
+// CompareContext is like Compare, but abandons the request and returns
+// ctx.Err() if ctx is done before the server responds.
+func (l *Conn) CompareContext(ctx context.Context, dn, attribute, value string) (bool, error) {
+	response, err := l.DoContext(ctx, &compareRequest{DN: dn, Attribute: attribute, Value: value})
 	if err != nil {
 		return false, err
 	}
-
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return false, err
-		}
-		asn1.PrintPacket(packet)
-	}
-
-	if packet.Children[1].Tag == ApplicationCompareResponse {
-		resultCode, resultDescription := getLDAPResultCode(packet)
-		if resultCode == LDAPResultCompareTrue {
-			return true, nil
-		} else if resultCode == LDAPResultCompareFalse {
-			return false, nil
-		} else {
-			return false, NewError(resultCode, errors.New(resultDescription))
-		}
+	switch response.ResultCode {
+	case LDAPResultCompareTrue:
+		return true, nil
+	case LDAPResultCompareFalse:
+		return false, nil
 	}
-	return false, fmt.Errorf("Unexpected Response: %d", packet.Children[1].Tag)
+	return false, newResultError(response.Packet)
 }
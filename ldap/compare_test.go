@@ -0,0 +1,65 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestCompareRequestEncoding(t *testing.T) {
+	req := &compareRequest{DN: "uid=bob,dc=example,dc=com", Attribute: "mail", Value: "bob@example.com"}
+	packet := asn1.DecodePacket(req.encode().Bytes())
+
+	if packet.Tag != ApplicationCompareRequest {
+		t.Fatalf("expected compare request, got application tag %d", packet.Tag)
+	}
+	if got := packet.Children[0].Value; got != "uid=bob,dc=example,dc=com" {
+		t.Errorf("unexpected entry: %v", got)
+	}
+	ava := packet.Children[1]
+	if got := ava.Children[0].Value; got != "mail" {
+		t.Errorf("unexpected attribute description: %v", got)
+	}
+	if ava.Children[1].TagType != asn1.TypePrimitive {
+		t.Errorf("assertion value must be a primitive octet string")
+	}
+	if got := ava.Children[1].Value; got != "bob@example.com" {
+		t.Errorf("unexpected assertion value: %v", got)
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	testcases := []struct {
+		resultCode int
+		expected   bool
+		errCode    uint8
+	}{
+		{LDAPResultCompareTrue, true, 0},
+		{LDAPResultCompareFalse, false, 0},
+		{LDAPResultNoSuchObject, false, LDAPResultNoSuchObject},
+		{LDAPResultUndefinedAttributeType, false, LDAPResultUndefinedAttributeType},
+	}
+
+	for _, tc := range testcases {
+		ptc := newPacketTranslatorConn()
+		conn := NewConn(ptc, false)
+		conn.Start()
+
+		go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationCompareResponse, tc.resultCode, "")}
+		})
+
+		matched, err := conn.Compare("uid=bob,dc=example,dc=com", "mail", "bob@example.com")
+		if tc.errCode == 0 && err != nil {
+			t.Errorf("result code %d: unexpected error: %s", tc.resultCode, err)
+		} else if tc.errCode != 0 && !IsErrorWithCode(err, tc.errCode) {
+			t.Errorf("result code %d: expected error with code %d, got %v", tc.resultCode, tc.errCode, err)
+		}
+		if matched != tc.expected {
+			t.Errorf("result code %d: expected %t, got %t", tc.resultCode, tc.expected, matched)
+		}
+
+		conn.Close()
+		ptc.Close()
+	}
+}
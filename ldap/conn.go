@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -24,6 +25,8 @@ const (
 	MessageFinish = 3
 	// MessageTimeout indicates the client-specified timeout for a particular message ID has been reached
 	MessageTimeout = 4
+	// MessageAbandon indicates the client abandoned a particular message ID
+	MessageAbandon = 5
 )
 
 // PacketResponse contains the packet or error encountered reading a response
@@ -44,21 +47,105 @@ func (pr *PacketResponse) ReadPacket() (*asn1.Packet, error) {
 
 type messageContext struct {
 	id int64
+	// hasDeadline is set to 1 once a context with a deadline waits for the
+	// response, which then replaces the timeout of the connection
+	hasDeadline int32
 	// close(done) should only be called from finishMessage()
 	done chan struct{}
-	// close(responses) should only be called from processMessages(), and only sent to from sendResponse()
+	// responses should only be sent to from sendResponse(), and closed with
+	// closeResponses(), which are called by processMessages()
 	responses chan *PacketResponse
+	// queue holds the responses not yet delivered once the buffer of
+	// responses is full. While forwarding is set, a goroutine delivers them,
+	// and closes responses once the queue is empty if closed is set.
+	queueMutex sync.Mutex
+	queue      []*PacketResponse
+	forwarding bool
+	closed     bool
+	// operation, started and resultCode are only accessed by processMessages()
+	// to report the operation to the Instrumentation of the connection
+	operation  string
+	started    time.Time
+	resultCode uint8
+	// bind is set for bind requests, which cannot be abandoned
+	bind bool
 }
 
 // sendResponse should only be called within the processMessages() loop which
-// is also responsible for closing the responses channel.
+// is also responsible for closing the responses channel. It never waits for
+// the request to read the response: once the buffer of responses is full,
+// the responses are queued and delivered by a goroutine of the request, so
+// that a request slow to read its responses does not delay the others.
 func (msgCtx *messageContext) sendResponse(packet *PacketResponse) {
 	select {
-	case msgCtx.responses <- packet:
-		// Successfully sent packet to message handler.
 	case <-msgCtx.done:
 		// The request handler is done and will not receive more
 		// packets.
+		return
+	default:
+	}
+
+	msgCtx.queueMutex.Lock()
+	defer msgCtx.queueMutex.Unlock()
+	if !msgCtx.forwarding {
+		select {
+		case msgCtx.responses <- packet:
+			// Successfully sent packet to message handler.
+			return
+		default:
+		}
+		msgCtx.forwarding = true
+		go msgCtx.forwardResponses()
+	}
+	msgCtx.queue = append(msgCtx.queue, packet)
+}
+
+// closeResponses closes the responses channel, once the queued responses are
+// delivered if there are any
+func (msgCtx *messageContext) closeResponses() {
+	msgCtx.queueMutex.Lock()
+	defer msgCtx.queueMutex.Unlock()
+	if msgCtx.forwarding {
+		msgCtx.closed = true
+		return
+	}
+	close(msgCtx.responses)
+}
+
+// forwardResponses delivers the queued responses in order until the queue is
+// empty or the request is done
+func (msgCtx *messageContext) forwardResponses() {
+	for {
+		msgCtx.queueMutex.Lock()
+		if len(msgCtx.queue) == 0 {
+			msgCtx.stopForwarding()
+			msgCtx.queueMutex.Unlock()
+			return
+		}
+		packet := msgCtx.queue[0]
+		msgCtx.queue[0] = nil
+		msgCtx.queue = msgCtx.queue[1:]
+		msgCtx.queueMutex.Unlock()
+
+		select {
+		case msgCtx.responses <- packet:
+		case <-msgCtx.done:
+			msgCtx.queueMutex.Lock()
+			msgCtx.queue = nil
+			msgCtx.stopForwarding()
+			msgCtx.queueMutex.Unlock()
+			return
+		}
+	}
+}
+
+// stopForwarding ends the forwarding of the queued responses, closing the
+// responses channel if closeResponses was called meanwhile. queueMutex must
+// be held.
+func (msgCtx *messageContext) stopForwarding() {
+	msgCtx.forwarding = false
+	if msgCtx.closed {
+		close(msgCtx.responses)
 	}
 }
 
@@ -75,11 +162,24 @@ const (
 	startTLS sendMessageFlags = 1 << iota
 )
 
-// Conn represents an LDAP Connection
+// responseBufferSize is the number of responses buffered for each request
+// before they are queued and delivered by a goroutine of the request
+const responseBufferSize = 64
+
+// Conn represents an LDAP Connection. It is safe for concurrent use by
+// multiple goroutines: requests are sent in the order they are made and may
+// be outstanding at the same time, up to the limit set with SetMaxInFlight.
+// Responses are routed to their request by message ID as they arrive, so that
+// requests complete independently and in any order. The reading of the
+// connection never waits for a request: the responses a request, such as a
+// search read with a SearchCursor, has not read yet are held in memory until
+// it does, so that a slow request does not delay the others. Large searches
+// read slowly should therefore be read in pages.
 type Conn struct {
 	conn                net.Conn
 	isTLS               bool
 	closing             uint32
+	ignoreLimitExceeded uint32
 	closeErr            atomicValue
 	isStartingTLS       bool
 	Debug               debugging
@@ -89,8 +189,31 @@ type Conn struct {
 	chanMessageID       chan int64
 	wgClose             sync.WaitGroup
 	outstandingRequests uint
+	maxInFlight         uint
 	messageMutex        sync.Mutex
+	inFlightCond        *sync.Cond
 	requestTimeout      int64
+	maxMessageSize      int64
+	valueStreams        valueStreams
+	defaultControls     atomicValue
+	unsolicitedHandler  atomicValue
+	referralPolicy      atomicValue
+	schema              atomicValue
+	instrumentation     atomicValue
+	tracer              atomicValue
+	sendHook            atomicValue
+	receiveHook         atomicValue
+	interceptors        atomicValue
+	extraAttributes     atomicValue
+	stats               connStats
+	chanClose           chan struct{}
+	keepaliveStop       chan struct{}
+	createdAt           time.Time
+	lastActive          time.Time
+	idleTimeout         time.Duration
+	maxLifetime         time.Duration
+	lifetimeOnce        sync.Once
+	lifetimeWake        chan struct{}
 }
 
 var _ Client = &Conn{}
@@ -133,17 +256,157 @@ func DialTLS(network, addr string, config *tls.Config) (*Conn, error) {
 	return conn, nil
 }
 
+// DialOpt configures the connections made by DialURL, DialDomain and DialMulti
+type DialOpt func(*dialConfig)
+
+// dialConfig holds the options of DialURL, DialDomain and DialMulti
+type dialConfig struct {
+	dialer      *net.Dialer
+	dialFunc    DialContextFunc
+	proxy       proxyFunc
+	tlsConfig   *tls.Config
+	srvResolver *SRVResolver
+	randomOrder bool
+}
+
+// newDialConfig returns the configuration with the given options applied
+func newDialConfig(opts []DialOpt) *dialConfig {
+	dc := &dialConfig{
+		dialer:      &net.Dialer{Timeout: DefaultTimeout},
+		srvResolver: &SRVResolver{},
+	}
+	for _, opt := range opts {
+		opt(dc)
+	}
+	return dc
+}
+
+// DialWithDialer sets the dialer used to connect to the server
+func DialWithDialer(d *net.Dialer) DialOpt {
+	return func(dc *dialConfig) {
+		dc.dialer = d
+	}
+}
+
+// DialContextFunc connects to the address on the named network, like
+// net.Dialer.DialContext
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DialWithDialContext sets the function used to connect to the server in
+// place of the dialer, e.g. to connect through a SOCKS5 proxy or an SSH
+// bastion, or to resolve the host names of the servers. The TLS handshake of
+// ldaps connections is performed over the returned connection with the
+// configuration set by DialWithTLSConfig.
+func DialWithDialContext(dial DialContextFunc) DialOpt {
+	return func(dc *dialConfig) {
+		dc.dialFunc = dial
+	}
+}
+
+// DialWithTLSConfig sets the TLS configuration of ldaps connections. If its
+// ServerName is empty, the host name of the URL is used.
+func DialWithTLSConfig(tc *tls.Config) DialOpt {
+	return func(dc *dialConfig) {
+		dc.tlsConfig = tc
+	}
+}
+
+// DialURL connects to the server of the given LDAP URL, such as
+// "ldap://ldap.example.com", "ldaps://ldap.example.com:10636" or
+// "ldapi://%2Fvar%2Frun%2Fslapd%2Fldapi", and then returns a new Conn for the
+// connection. The rest of the URL is ignored, use ParseURL to access the
+// search it describes.
+func DialURL(rawURL string, opts ...DialOpt) (*Conn, error) {
+	u, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return newDialConfig(opts).dial(u)
+}
+
+// DialURLContext is like DialURL, ctx limiting the time to connect to the
+// server
+func DialURLContext(ctx context.Context, rawURL string, opts ...DialOpt) (*Conn, error) {
+	u, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return newDialConfig(opts).dialContext(ctx, u)
+}
+
+// dial connects to the server of the URL
+func (dc *dialConfig) dial(u *URL) (*Conn, error) {
+	return dc.dialContext(context.Background(), u)
+}
+
+// dialContext connects to the server of the URL with the dial function if
+// set, or the dialer
+func (dc *dialConfig) dialContext(ctx context.Context, u *URL) (*Conn, error) {
+	address := u.Address()
+	dial := dc.dialFunc
+	if dial == nil {
+		dial = dc.dialer.DialContext
+	}
+	if dc.proxy != nil && u.Network() == "tcp" {
+		proxyURL, err := dc.proxy(address)
+		if err != nil {
+			return nil, NewError(ErrorNetwork, err)
+		}
+		if proxyURL != nil {
+			direct := dial
+			dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialProxy(ctx, proxyURL, direct, address)
+			}
+		}
+	}
+	c, err := dial(ctx, u.Network(), address)
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+
+	isTLS := false
+	if u.Scheme == "ldaps" {
+		config := dc.tlsConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			host, _, _ := net.SplitHostPort(address)
+			config = config.Clone()
+			config.ServerName = host
+		}
+		tc := tls.Client(c, config)
+		if err := tc.Handshake(); err != nil {
+			// Handshake error, close the established connection before we return an error
+			c.Close()
+			return nil, NewError(ErrorNetwork, err)
+		}
+		c = tc
+		isTLS = true
+	}
+
+	conn := NewConn(c, isTLS)
+	conn.Start()
+	return conn, nil
+}
+
 // NewConn returns a new Conn using conn for network I/O.
 func NewConn(conn net.Conn, isTLS bool) *Conn {
-	return &Conn{
+	l := &Conn{
 		conn:            conn,
 		chanConfirm:     make(chan struct{}),
 		chanMessageID:   make(chan int64),
 		chanMessage:     make(chan *messagePacket, 10),
+		chanClose:       make(chan struct{}),
 		messageContexts: map[int64]*messageContext{},
 		requestTimeout:  0,
 		isTLS:           isTLS,
+		createdAt:       time.Now(),
+		lifetimeWake:    make(chan struct{}, 1),
 	}
+	l.lastActive = l.createdAt
+	l.inFlightCond = sync.NewCond(&l.messageMutex)
+	return l
 }
 
 // Start initializes goroutines to read responses and process messages
@@ -169,6 +432,8 @@ func (l *Conn) Close() {
 	defer l.messageMutex.Unlock()
 
 	if l.setClosing() {
+		l.inFlightCond.Broadcast()
+		close(l.chanClose)
 		l.Debug.Printf("Sending quit message and waiting for confirmation")
 		l.chanMessage <- &messagePacket{Op: MessageQuit}
 		<-l.chanConfirm
@@ -184,13 +449,85 @@ func (l *Conn) Close() {
 	l.wgClose.Wait()
 }
 
-// SetTimeout sets the time after a request is sent that a MessageTimeout triggers
+// errRequestTimeout is returned when no response is received within the
+// timeout of the connection
+var errRequestTimeout = errors.New("ldap: connection timed out")
+
+// errRequestAbandoned is returned to operations abandoned with Abandon
+var errRequestAbandoned = errors.New("ldap: request abandoned")
+
+// SetTimeout sets the time after a request is sent that a MessageTimeout
+// triggers. The request is then abandoned, or the connection closed if it is a
+// bind, which cannot be abandoned. Operations given a context with a
+// deadline wait until that deadline instead, so that a single slow request
+// can be given more, or less, time than the others.
 func (l *Conn) SetTimeout(timeout time.Duration) {
 	if timeout > 0 {
 		atomic.StoreInt64(&l.requestTimeout, int64(timeout))
 	}
 }
 
+// SetMaxInFlight limits the number of requests outstanding at the same time.
+// Once the limit is reached, new requests wait for an outstanding one to
+// complete before being sent. A limit of 0, the default, means no limit.
+func (l *Conn) SetMaxInFlight(max uint) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	l.maxInFlight = max
+	l.inFlightCond.Broadcast()
+}
+
+// SetDefaultControls sets controls which are sent with every request, in
+// addition to the controls of the request itself. Controls of a type already
+// present in a request are not added to it. This can be used to attach a
+// session tracking control to all operations of the connection.
+func (l *Conn) SetDefaultControls(controls ...Control) {
+	l.defaultControls.Store(controls)
+}
+
+// addDefaultControls adds the default controls to the request packet
+func (l *Conn) addDefaultControls(packet *asn1.Packet) {
+	controls, _ := l.defaultControls.Load().([]Control)
+	if len(controls) == 0 || len(packet.Children) < 2 {
+		return
+	}
+	switch packet.Children[1].Tag {
+	case ApplicationAbandonRequest, ApplicationUnbindRequest:
+		return
+	}
+
+	children := packet.Children
+	var encoded []*asn1.Packet
+	if len(children) > 2 {
+		encoded = children[2].Children
+	}
+	present := make(map[string]bool, len(encoded))
+	for _, child := range encoded {
+		if len(child.Children) > 0 {
+			if controlType, ok := child.Children[0].Value.(string); ok {
+				present[controlType] = true
+			}
+		}
+	}
+
+	controlsPacket := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+	for _, child := range encoded {
+		controlsPacket.AppendChild(child)
+	}
+	for _, control := range controls {
+		if !present[control.GetControlType()] {
+			controlsPacket.AppendChild(control.Encode())
+		}
+	}
+
+	// the encoded children are part of the packet data, so it has to be rebuilt
+	packet.Data.Reset()
+	packet.Children = nil
+	packet.AppendChild(children[0])
+	packet.AppendChild(children[1])
+	packet.AppendChild(controlsPacket)
+}
+
 // Returns the next available messageID
 func (l *Conn) nextMessageID() int64 {
 	if messageID, ok := <-l.chanMessageID; ok {
@@ -199,6 +536,25 @@ func (l *Conn) nextMessageID() int64 {
 	return 0
 }
 
+// maxMessageID is the largest message ID, maxInt in RFC 4511 section 4.1.1
+const maxMessageID = 1<<31 - 1
+
+// nextFreeMessageID returns the message ID following messageID, wrapping
+// around after maxMessageID and skipping the IDs of the outstanding messages
+// as well as 0, which is reserved for unsolicited notifications
+func nextFreeMessageID(messageID int64, outstanding map[int64]*messageContext) int64 {
+	for {
+		if messageID >= maxMessageID {
+			messageID = 1
+		} else {
+			messageID++
+		}
+		if _, ok := outstanding[messageID]; !ok {
+			return messageID
+		}
+	}
+}
+
 // StartTLS sends the command to start a TLS session and then creates a new TLS Client
 func (l *Conn) StartTLS(config *tls.Config) error {
 	if l.isTLS {
@@ -256,6 +612,17 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 	return nil
 }
 
+// TLSConnectionState returns the state of the TLS connection, established
+// either with DialTLS or StartTLS. ok is false if the connection is not
+// encrypted with TLS.
+func (l *Conn) TLSConnectionState() (state tls.ConnectionState, ok bool) {
+	tc, ok := l.conn.(*tls.Conn)
+	if !ok {
+		return state, false
+	}
+	return tc.ConnectionState(), true
+}
+
 func (l *Conn) sendMessage(packet *asn1.Packet) (*messageContext, error) {
 	return l.sendMessageWithFlags(packet, 0)
 }
@@ -265,6 +632,13 @@ func (l *Conn) sendMessageWithFlags(packet *asn1.Packet, flags sendMessageFlags)
 		return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
 	}
 	l.messageMutex.Lock()
+	for l.maxInFlight > 0 && l.outstandingRequests >= l.maxInFlight && !l.isClosing() {
+		l.inFlightCond.Wait()
+	}
+	if l.isClosing() {
+		l.messageMutex.Unlock()
+		return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
+	}
 	l.Debug.Printf("flags&startTLS = %d", flags&startTLS)
 	if l.isStartingTLS {
 		l.messageMutex.Unlock()
@@ -281,7 +655,9 @@ func (l *Conn) sendMessageWithFlags(packet *asn1.Packet, flags sendMessageFlags)
 
 	l.messageMutex.Unlock()
 
-	responses := make(chan *PacketResponse)
+	l.addDefaultControls(packet)
+
+	responses := make(chan *PacketResponse, responseBufferSize)
 	messageID := packet.Children[0].Value.(int64)
 	message := &messagePacket{
 		Op:        MessageRequest,
@@ -291,12 +667,58 @@ func (l *Conn) sendMessageWithFlags(packet *asn1.Packet, flags sendMessageFlags)
 			id:        messageID,
 			done:      make(chan struct{}),
 			responses: responses,
+			bind:      len(packet.Children) > 1 && packet.Children[1].ClassType == asn1.ClassApplication && packet.Children[1].Tag == ApplicationBindRequest,
 		},
 	}
 	l.sendProcessMessage(message)
 	return message.Context, nil
 }
 
+// receivePacket waits for the next response packet for the given message. If
+// ctx is done first, or the request times out, the outstanding request is
+// canceled and ctx.Err(), or the timeout error, is returned. If ctx has a
+// deadline, the timeout of the connection does not apply.
+func (l *Conn) receivePacket(ctx context.Context, msgCtx *messageContext) (*asn1.Packet, error) {
+	if _, ok := ctx.Deadline(); ok {
+		atomic.StoreInt32(&msgCtx.hasDeadline, 1)
+	}
+	select {
+	case packetResponse, ok := <-msgCtx.responses:
+		if !ok {
+			return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
+		}
+		packet, err := packetResponse.ReadPacket()
+		if err == errRequestTimeout {
+			l.Debug.Printf("%d: request timed out, canceling request", msgCtx.id)
+			l.cancel(msgCtx)
+		}
+		return packet, err
+	case <-ctx.Done():
+		l.Debug.Printf("%d: context done, canceling request", msgCtx.id)
+		if msgCtx.bind {
+			l.cancel(msgCtx)
+		} else {
+			// the abandon request is sent once the caller finished the
+			// message, as responses to the request may still be waiting to
+			// be delivered
+			go l.cancel(msgCtx)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// cancel abandons the outstanding request, or closes the connection if the
+// request is a bind: a bind cannot be abandoned (RFC 4511 section 4.11), and
+// the authentication state of the connection is unknown until the server
+// responds to it.
+func (l *Conn) cancel(msgCtx *messageContext) {
+	if msgCtx.bind {
+		l.Close()
+		return
+	}
+	l.abandon(msgCtx.id)
+}
+
 func (l *Conn) finishMessage(msgCtx *messageContext) {
 	close(msgCtx.done)
 
@@ -309,6 +731,11 @@ func (l *Conn) finishMessage(msgCtx *messageContext) {
 	if l.isStartingTLS {
 		l.isStartingTLS = false
 	}
+	if l.outstandingRequests == 0 {
+		l.lastActive = time.Now()
+		l.wakeLifetimeWatcher()
+	}
+	l.inFlightCond.Signal()
 	l.messageMutex.Unlock()
 
 	message := &messagePacket{
@@ -340,8 +767,9 @@ func (l *Conn) processMessages() {
 				msgCtx.sendResponse(&PacketResponse{Error: l.closeErr.Load().(error)})
 			}
 			l.Debug.Printf("Closing channel for MessageID %d", messageID)
-			close(msgCtx.responses)
+			msgCtx.closeResponses()
 			delete(l.messageContexts, messageID)
+			l.messageFinished(msgCtx)
 		}
 		close(l.chanMessageID)
 		close(l.chanConfirm)
@@ -351,7 +779,7 @@ func (l *Conn) processMessages() {
 	for {
 		select {
 		case l.chanMessageID <- messageID:
-			messageID++
+			messageID = nextFreeMessageID(messageID, l.messageContexts)
 		case message := <-l.chanMessage:
 			switch message.Op {
 			case MessageQuit:
@@ -362,17 +790,21 @@ func (l *Conn) processMessages() {
 				l.Debug.Printf("Sending message %d", message.MessageID)
 
 				buf := message.Packet.Bytes()
+				if hook := l.loadSendHook(); hook != nil {
+					hook(buf, message.Packet)
+				}
 				_, err := l.conn.Write(buf)
 				if err != nil {
 					l.Debug.Printf("Error Sending Message: %s", err.Error())
 					message.Context.sendResponse(&PacketResponse{Error: fmt.Errorf("unable to send request: %s", err)})
-					close(message.Context.responses)
+					message.Context.closeResponses()
 					break
 				}
 
 				// Only add to messageContexts if we were able to
 				// successfully write the message.
 				l.messageContexts[message.MessageID] = message.Context
+				l.messageStarted(message.Context, message.Packet, len(buf))
 
 				// Add timeout if defined
 				requestTimeout := time.Duration(atomic.LoadInt64(&l.requestTimeout))
@@ -393,7 +825,12 @@ func (l *Conn) processMessages() {
 				}
 			case MessageResponse:
 				l.Debug.Printf("Receiving message %d", message.MessageID)
-				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+				if message.MessageID == 0 {
+					l.handleUnsolicitedNotification(message.Packet)
+				} else if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+					if len(message.Packet.Children) > 1 && isResult(uint8(message.Packet.Children[1].Tag)) {
+						msgCtx.resultCode, _ = getLDAPResultCode(message.Packet)
+					}
 					msgCtx.sendResponse(&PacketResponse{message.Packet, nil})
 				} else {
 					log.Printf("Received unexpected message %d, %v", message.MessageID, l.isClosing())
@@ -402,17 +839,27 @@ func (l *Conn) processMessages() {
 			case MessageTimeout:
 				// Handle the timeout by closing the channel
 				// All reads will return immediately
-				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+				if msgCtx, ok := l.messageContexts[message.MessageID]; ok && atomic.LoadInt32(&msgCtx.hasDeadline) == 0 {
 					l.Debug.Printf("Receiving message timeout for %d", message.MessageID)
-					msgCtx.sendResponse(&PacketResponse{message.Packet, errors.New("ldap: connection timed out")})
+					msgCtx.sendResponse(&PacketResponse{message.Packet, errRequestTimeout})
+					delete(l.messageContexts, message.MessageID)
+					msgCtx.closeResponses()
+					l.messageFinished(msgCtx)
+				}
+			case MessageAbandon:
+				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
+					l.Debug.Printf("Abandoning message %d", message.MessageID)
+					msgCtx.sendResponse(&PacketResponse{nil, errRequestAbandoned})
 					delete(l.messageContexts, message.MessageID)
-					close(msgCtx.responses)
+					msgCtx.closeResponses()
+					l.messageFinished(msgCtx)
 				}
 			case MessageFinish:
 				l.Debug.Printf("Finished message %d", message.MessageID)
 				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
 					delete(l.messageContexts, message.MessageID)
-					close(msgCtx.responses)
+					msgCtx.closeResponses()
+					l.messageFinished(msgCtx)
 				}
 			}
 		}
@@ -435,11 +882,21 @@ func (l *Conn) reader() {
 			l.Debug.Printf("reader clean stopping (without closing the connection)")
 			return
 		}
-		packet, err := asn1.ReadPacket(l.conn)
+		packet, n, err := l.readPacket()
+		if n > 0 {
+			l.stats.read(n)
+		}
+		if instrumentation := l.loadInstrumentation(); instrumentation != nil && n > 0 {
+			instrumentation.BytesRead(n)
+		}
 		if err != nil {
 			// A read error is expected here if we are closing the connection...
-			if !l.isClosing() {
-				l.closeErr.Store(fmt.Errorf("unable to read LDAP response packet: %s", err))
+			if !l.isClosing() && l.closeErr.Load() == nil {
+				if IsErrorWithCode(err, ErrorMessageTooLarge, ErrorUnexpectedResponse) {
+					l.closeErr.Store(err)
+				} else {
+					l.closeErr.Store(NewError(ErrorNetwork, fmt.Errorf("unable to read LDAP response packet: %s", err)))
+				}
 				l.Debug.Printf("reader error: %s", err.Error())
 			}
 			return
@@ -2,7 +2,9 @@ package ldap
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -104,6 +106,382 @@ func TestFinishMessage(t *testing.T) {
 	conn.Close()
 }
 
+// TestContextCancelAbandonsRequest tests that cancelling the context of a
+// pending operation returns the context error and abandons the request.
+func TestContextCancelAbandonsRequest(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		_, err := conn.SearchContext(ctx, searchRequest)
+		errs <- err
+	}()
+
+	var searchPacket, abandonPacket *asn1.Packet
+	runWithTimeout(t, time.Second, func() {
+		var err error
+		if searchPacket, err = ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive search request: %s", err)
+		}
+	})
+
+	cancel()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := <-errs; err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+	runWithTimeout(t, time.Second, func() {
+		var err error
+		if abandonPacket, err = ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive abandon request: %s", err)
+		}
+	})
+
+	if abandonPacket.Children[1].Tag != ApplicationAbandonRequest {
+		t.Fatalf("expected abandon request, got application tag %d", abandonPacket.Children[1].Tag)
+	}
+	searchID := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, searchPacket.Children[0].Value, "MessageID")
+	if !bytes.Equal(abandonPacket.Children[1].Data.Bytes(), searchID.Data.Bytes()) {
+		t.Errorf("abandon request does not reference message %v", searchPacket.Children[0].Value)
+	}
+}
+
+// TestContextCancelClosesBind tests that cancelling the context of a pending
+// bind closes the connection rather than abandoning the bind.
+func TestContextCancelClosesBind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.BindContext(ctx, "cn=admin,dc=example,dc=com", "secret")
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if _, err := ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive bind request: %s", err)
+		}
+	})
+
+	cancel()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := <-errs; err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+	if !conn.isClosing() {
+		t.Errorf("expected the connection to be closed")
+	}
+	if packet, err := ptc.ReceiveRequest(); err == nil {
+		t.Errorf("expected no request after the bind, got application tag %d", packet.Children[1].Tag)
+	}
+}
+
+// TestTimeoutAbandonsRequest tests that a request without a response within
+// the timeout of the connection is abandoned.
+func TestTimeoutAbandonsRequest(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.SetTimeout(10 * time.Millisecond)
+	conn.Start()
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := conn.Compare("uid=jdoe,dc=example,dc=com", "uid", "jdoe")
+		errs <- err
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		compareRequest, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive compare request: %s", err)
+		}
+		if err := <-errs; err != errRequestTimeout {
+			t.Fatalf("expected timeout error, got %v", err)
+		}
+		abandonRequest, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive abandon request: %s", err)
+		}
+		if abandonRequest.Children[1].Tag != ApplicationAbandonRequest {
+			t.Fatalf("expected abandon request, got application tag %d", abandonRequest.Children[1].Tag)
+		}
+		compareID := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, compareRequest.Children[0].Value, "MessageID")
+		if !bytes.Equal(abandonRequest.Children[1].Data.Bytes(), compareID.Data.Bytes()) {
+			t.Errorf("abandon request does not reference message %v", compareRequest.Children[0].Value)
+		}
+	})
+}
+
+// TestContextDeadlineOverridesTimeout tests that the deadline of the context
+// of an operation replaces the timeout of the connection.
+func TestContextDeadlineOverridesTimeout(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.SetTimeout(10 * time.Millisecond)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		time.Sleep(100 * time.Millisecond)
+		return []*asn1.Packet{newResultPacket(request.Children[0].Value.(int64), ApplicationSearchResultDone, LDAPResultSuccess, "")}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		if _, err := conn.SearchContext(ctx, searchRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestDefaultControls(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	conn.SetDefaultControls(
+		NewControlSessionTracking("192.0.2.10", "", SessionTrackingUsername, "jdoe"),
+		NewControlManageDsaIT(false),
+	)
+
+	requests := make(chan *asn1.Packet, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+				requests <- p
+				return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+			})
+		}
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := conn.Del(NewDelRequest("cn=b,dc=example,dc=com", []Control{NewControlManageDsaIT(true)})); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	for i, expected := range [][]string{
+		{ControlTypeSessionTracking, ControlTypeManageDsaIT},
+		{ControlTypeManageDsaIT, ControlTypeSessionTracking},
+	} {
+		// decode from the wire bytes to make sure the packet was re-encoded
+		request := asn1.DecodePacket((<-requests).Bytes())
+		if len(request.Children) != 3 || len(request.Children[2].Children) != len(expected) {
+			t.Fatalf("%d: expected %d controls in request", i, len(expected))
+		}
+		for j, controlType := range expected {
+			if c := DecodeControl(request.Children[2].Children[j]); c.GetControlType() != controlType {
+				t.Errorf("%d: expected control %s at position %d, got %s", i, controlType, j, c)
+			}
+		}
+	}
+}
+
+// TestOutOfOrderResponses tests that requests complete in the order of their
+// responses rather than in the order they were sent.
+func TestOutOfOrderResponses(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	slow := make(chan error, 1)
+	go func() {
+		slow <- conn.Del(NewDelRequest("cn=slow,dc=example,dc=com", nil))
+	}()
+	var slowRequest *asn1.Packet
+	runWithTimeout(t, time.Second, func() {
+		var err error
+		if slowRequest, err = ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+	})
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+	})
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.Del(NewDelRequest("cn=fast,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	select {
+	case err := <-slow:
+		t.Fatalf("slow request completed before its response: %v", err)
+	default:
+	}
+
+	ptc.SendResponse(newResultPacket(slowRequest.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, ""))
+	runWithTimeout(t, time.Second, func() {
+		if err := <-slow; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
+
+// TestStalledSearch tests that a search whose responses are not read does not
+// delay the responses of the other requests.
+func TestStalledSearch(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	cursor := conn.SearchCursor(searchRequest, 0)
+	defer cursor.Close()
+	const entries = 4 * responseBufferSize
+	runWithTimeout(t, time.Second, func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			responses := make([]*asn1.Packet, 0, entries+1)
+			for i := 0; i < entries; i++ {
+				responses = append(responses, newEntryPacket(messageID, fmt.Sprintf("cn=%d,dc=example,dc=com", i)))
+			}
+			return append(responses, newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""))
+		})
+	})
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationCompareResponse, LDAPResultCompareTrue, "")}
+	})
+	runWithTimeout(t, time.Second, func() {
+		if matched, err := conn.Compare("uid=jdoe,dc=example,dc=com", "uid", "jdoe"); err != nil || !matched {
+			t.Fatalf("unexpected compare result %t (%v)", matched, err)
+		}
+	})
+
+	read := 0
+	runWithTimeout(t, time.Second, func() {
+		for ; cursor.Next(); read++ {
+			if dn, expected := cursor.Entry().DN, fmt.Sprintf("cn=%d,dc=example,dc=com", read); dn != expected {
+				t.Fatalf("expected entry %s, got %s", expected, dn)
+			}
+		}
+	})
+	if err := cursor.Err(); err != nil || read != entries {
+		t.Errorf("expected %d entries, got %d (%v)", entries, read, err)
+	}
+}
+
+// TestMaxInFlight tests that requests over the limit wait for an outstanding
+// one to complete before being sent.
+func TestMaxInFlight(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	conn.SetMaxInFlight(1)
+
+	errs := make(chan error, 2)
+	for _, dn := range []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"} {
+		go func(dn string) {
+			errs <- conn.Del(NewDelRequest(dn, nil))
+		}(dn)
+	}
+
+	var first *asn1.Packet
+	runWithTimeout(t, time.Second, func() {
+		var err error
+		if first, err = ptc.ReceiveRequest(); err != nil {
+			t.Fatalf("unable to receive request packet: %s", err)
+		}
+	})
+	time.Sleep(50 * time.Millisecond)
+	ptc.lock.Lock()
+	pending := ptc.requestBuf.Len()
+	ptc.lock.Unlock()
+	if pending != 0 {
+		t.Fatal("second request sent while the first one is outstanding")
+	}
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+	})
+	ptc.SendResponse(newResultPacket(first.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, ""))
+	runWithTimeout(t, time.Second, func() {
+		for i := 0; i < 2; i++ {
+			if err := <-errs; err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+	})
+}
+
+func TestNextFreeMessageID(t *testing.T) {
+	outstanding := map[int64]*messageContext{
+		3:                {},
+		maxMessageID:     {},
+		1:                {},
+		2:                {},
+		maxMessageID - 1: {},
+	}
+	tests := []struct {
+		messageID int64
+		expected  int64
+	}{
+		{5, 6},
+		{4, 5},
+		// 1, 2 and 3 are outstanding
+		{maxMessageID - 2, 4},
+		{maxMessageID, 4},
+		{maxMessageID - 3, maxMessageID - 2},
+	}
+	for _, test := range tests {
+		if next := nextFreeMessageID(test.messageID, outstanding); next != test.expected {
+			t.Errorf("after %d: expected %d, got %d", test.messageID, test.expected, next)
+		}
+	}
+
+	if next := nextFreeMessageID(maxMessageID, nil); next != 1 {
+		t.Errorf("expected the message ID to wrap around to 1, got %d", next)
+	}
+
+	// the whole range without outstanding messages never yields 0
+	messageID := int64(maxMessageID - 2)
+	for i := 0; i < 4; i++ {
+		messageID = nextFreeMessageID(messageID, nil)
+		if messageID <= 0 || messageID > maxMessageID {
+			t.Fatalf("invalid message ID %d", messageID)
+		}
+	}
+}
+
 func testSendRequest(t *testing.T, ptc *packetTranslatorConn, conn *Conn) (msgCtx *messageContext) {
 	var msgID int64
 	runWithTimeout(t, time.Second, func() {
@@ -173,6 +551,36 @@ func testSendUnhandledResponsesAndFinish(t *testing.T, ptc *packetTranslatorConn
 	})
 }
 
+// serveRequest reads the next request packet from ptc and sends back the
+// response packets returned by respond. It is meant to be run in its own
+// goroutine to emulate a server answering a single request.
+func serveRequest(t *testing.T, ptc *packetTranslatorConn, respond func(request *asn1.Packet) []*asn1.Packet) {
+	request, err := ptc.ReceiveRequest()
+	if err != nil {
+		t.Errorf("unable to receive request packet: %s", err)
+		return
+	}
+	for _, response := range respond(request) {
+		if err := ptc.SendResponse(response); err != nil {
+			t.Errorf("unable to send response packet: %s", err)
+			return
+		}
+	}
+}
+
+// newResultPacket returns a response packet for the given message ID holding
+// an LDAPResult with the given application tag and result code.
+func newResultPacket(messageID int64, application asn1.Tag, resultCode int, diagnosticMessage string) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, application, nil, "Response")
+	response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, resultCode, "Result Code"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Matched DN"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, diagnosticMessage, "Diagnostic Message"))
+	packet.AppendChild(response)
+	return packet
+}
+
 func runWithTimeout(t *testing.T, timeout time.Duration, f func()) {
 	done := make(chan struct{})
 	go func() {
@@ -334,3 +742,26 @@ func (c *packetTranslatorConn) SetReadDeadline(t time.Time) error {
 func (c *packetTranslatorConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
+
+// newEntryPacket returns a SearchResultEntry response packet for the given
+// message ID. Attributes are encoded in the given order as name/values pairs.
+func newEntryPacket(messageID int64, dn string, attributes ...*EntryAttribute) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	entry := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, dn, "Object Name"))
+	attrs := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attributes")
+	for _, attribute := range attributes {
+		attr := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attribute")
+		attr.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, attribute.Name, "Attribute Name"))
+		values := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSet, nil, "Attribute Values")
+		for _, value := range attribute.Values {
+			values.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, value, "Attribute Value"))
+		}
+		attr.AppendChild(values)
+		attrs.AppendChild(attr)
+	}
+	entry.AppendChild(attrs)
+	packet.AppendChild(entry)
+	return packet
+}
@@ -18,13 +18,111 @@ const (
 	ControlTypeVChuPasswordWarning = "2.16.840.1.113730.3.4.5"
 	// ControlTypeManageDsaIT - https://tools.ietf.org/html/rfc3296
 	ControlTypeManageDsaIT = "2.16.840.1.113730.3.4.2"
+	// ControlTypeServerSideSorting - https://tools.ietf.org/html/rfc2891
+	ControlTypeServerSideSorting = "1.2.840.113556.1.4.473"
+	// ControlTypeServerSideSortingResult - https://tools.ietf.org/html/rfc2891
+	ControlTypeServerSideSortingResult = "1.2.840.113556.1.4.474"
+	// ControlTypeVLV - https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+	ControlTypeVLV = "2.16.840.1.113730.3.4.9"
+	// ControlTypeVLVResult - https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+	ControlTypeVLVResult = "2.16.840.1.113730.3.4.10"
+	// ControlTypeProxiedAuthorization - https://tools.ietf.org/html/rfc4370
+	ControlTypeProxiedAuthorization = "2.16.840.1.113730.3.4.18"
+	// ControlTypePersistentSearch - https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+	ControlTypePersistentSearch = "2.16.840.1.113730.3.4.3"
+	// ControlTypeEntryChangeNotification - https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+	ControlTypeEntryChangeNotification = "2.16.840.1.113730.3.4.7"
+	// ControlTypePreRead - https://tools.ietf.org/html/rfc4527
+	ControlTypePreRead = "1.3.6.1.1.13.1"
+	// ControlTypePostRead - https://tools.ietf.org/html/rfc4527
+	ControlTypePostRead = "1.3.6.1.1.13.2"
+	// ControlTypeSyncRequest - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncRequest = "1.3.6.1.4.1.4203.1.9.1.1"
+	// ControlTypeSyncState - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncState = "1.3.6.1.4.1.4203.1.9.1.2"
+	// ControlTypeSyncDone - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncDone = "1.3.6.1.4.1.4203.1.9.1.3"
+	// ControlTypeMicrosoftShowDeleted - https://msdn.microsoft.com/en-us/library/aa366989(v=vs.85).aspx
+	ControlTypeMicrosoftShowDeleted = "1.2.840.113556.1.4.417"
+	// ControlTypeMicrosoftShowRecycled - https://msdn.microsoft.com/en-us/library/dd303652.aspx
+	ControlTypeMicrosoftShowRecycled = "1.2.840.113556.1.4.2064"
+	// ControlTypeMicrosoftTreeDelete - https://msdn.microsoft.com/en-us/library/aa366991(v=vs.85).aspx
+	ControlTypeMicrosoftTreeDelete = "1.2.840.113556.1.4.805"
+	// ControlTypeMicrosoftPermissiveModify - https://msdn.microsoft.com/en-us/library/aa366984(v=vs.85).aspx
+	ControlTypeMicrosoftPermissiveModify = "1.2.840.113556.1.4.1413"
+	// ControlTypeSessionTracking - https://tools.ietf.org/html/draft-wahl-ldap-session-03
+	ControlTypeSessionTracking = "1.3.6.1.4.1.21008.108.63.1"
+	// ControlTypeTransactionSpecification - https://tools.ietf.org/html/rfc5805
+	ControlTypeTransactionSpecification = "1.3.6.1.1.21.2"
+	// ControlTypeRelaxRules - https://tools.ietf.org/html/draft-zeilenga-ldap-relax-03
+	ControlTypeRelaxRules = "1.3.6.1.4.1.4203.666.5.12"
+	// ControlTypeNoOp - https://tools.ietf.org/html/draft-zeilenga-ldap-noop-01
+	ControlTypeNoOp = "1.3.6.1.4.1.4203.666.5.2"
+	// ControlTypeDontUseCopy - https://tools.ietf.org/html/rfc6171
+	ControlTypeDontUseCopy = "1.3.6.1.1.22"
+	// ControlTypeSubentries - https://tools.ietf.org/html/rfc3672
+	ControlTypeSubentries = "1.3.6.1.4.1.4203.1.10.1"
+	// ControlTypeAuthzIDRequest - https://tools.ietf.org/html/rfc3829
+	ControlTypeAuthzIDRequest = "2.16.840.1.113730.3.4.16"
+	// ControlTypeAuthzIDResponse - https://tools.ietf.org/html/rfc3829
+	ControlTypeAuthzIDResponse = "2.16.840.1.113730.3.4.15"
 )
 
+// Session tracking identifier formats - https://tools.ietf.org/html/draft-wahl-ldap-session-03
+const (
+	SessionTrackingRADIUSAcctSessionID      = "1.3.6.1.4.1.21008.108.63.1.1"
+	SessionTrackingRADIUSAcctMultiSessionID = "1.3.6.1.4.1.21008.108.63.1.2"
+	SessionTrackingUsername                 = "1.3.6.1.4.1.21008.108.63.1.3"
+)
+
+// Entry change types of the persistent search and entry change notification controls
+const (
+	EntryChangeAdd    = 1
+	EntryChangeDelete = 2
+	EntryChangeModify = 4
+	EntryChangeModDN  = 8
+	// EntryChangeAll selects all change types in a persistent search
+	EntryChangeAll = EntryChangeAdd | EntryChangeDelete | EntryChangeModify | EntryChangeModDN
+)
+
+// EntryChangeMap contains human readable descriptions of entry change types
+var EntryChangeMap = map[int]string{
+	EntryChangeAdd:    "Add",
+	EntryChangeDelete: "Delete",
+	EntryChangeModify: "Modify",
+	EntryChangeModDN:  "ModDN",
+}
+
 // ControlTypeMap maps controls to text descriptions
 var ControlTypeMap = map[string]string{
 	ControlTypePaging:               "Paging",
 	ControlTypeBeheraPasswordPolicy: "Password Policy - Behera Draft",
 	ControlTypeManageDsaIT:          "Manage DSA IT",
+
+	ControlTypeServerSideSorting:         "Server Side Sorting Request",
+	ControlTypeServerSideSortingResult:   "Server Side Sorting Response",
+	ControlTypeVLV:                       "Virtual List View Request",
+	ControlTypeVLVResult:                 "Virtual List View Response",
+	ControlTypeProxiedAuthorization:      "Proxied Authorization",
+	ControlTypePersistentSearch:          "Persistent Search",
+	ControlTypeEntryChangeNotification:   "Entry Change Notification",
+	ControlTypePreRead:                   "Pre-Read",
+	ControlTypePostRead:                  "Post-Read",
+	ControlTypeSyncRequest:               "Sync Request",
+	ControlTypeSyncState:                 "Sync State",
+	ControlTypeSyncDone:                  "Sync Done",
+	ControlTypeMicrosoftShowDeleted:      "Show Deleted Objects - Microsoft",
+	ControlTypeMicrosoftShowRecycled:     "Show Recycled Objects - Microsoft",
+	ControlTypeMicrosoftTreeDelete:       "Tree Delete - Microsoft",
+	ControlTypeMicrosoftPermissiveModify: "Permissive Modify - Microsoft",
+	ControlTypeSessionTracking:           "Session Tracking",
+	ControlTypeTransactionSpecification:  "Transaction Specification",
+	ControlTypeRelaxRules:                "Relax Rules",
+	ControlTypeNoOp:                      "No-Op",
+	ControlTypeDontUseCopy:               "Don't Use Copy",
+	ControlTypeSubentries:                "Subentries",
+	ControlTypeAuthzIDRequest:            "Authorization Identity Request",
+	ControlTypeAuthzIDResponse:           "Authorization Identity Response",
 }
 
 // Control defines an interface controls provide to encode and describe themselves
@@ -67,6 +165,8 @@ func (c *ControlString) String() string {
 
 // ControlPaging implements the paging control described in https://www.ietf.org/rfc/rfc2696.txt
 type ControlPaging struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// PagingSize indicates the page size
 	PagingSize uint32
 	// Cookie is an opaque value returned by the server to track a paging cursor
@@ -82,6 +182,9 @@ func (c *ControlPaging) GetControlType() string {
 func (c *ControlPaging) Encode() *asn1.Packet {
 	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
 	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypePaging, "Control Type ("+ControlTypeMap[ControlTypePaging]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
 
 	p2 := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Paging)")
 	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Search Control Value")
@@ -102,7 +205,7 @@ func (c *ControlPaging) String() string {
 		"Control Type: %s (%q)  Criticality: %t  PagingSize: %d  Cookie: %q",
 		ControlTypeMap[ControlTypePaging],
 		ControlTypePaging,
-		false,
+		c.Criticality,
 		c.PagingSize,
 		c.Cookie)
 }
@@ -114,6 +217,8 @@ func (c *ControlPaging) SetCookie(cookie []byte) {
 
 // ControlBeheraPasswordPolicy implements the control described in https://tools.ietf.org/html/draft-behera-ldap-password-policy-10
 type ControlBeheraPasswordPolicy struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// Expire contains the number of seconds before a password will expire
 	Expire int64
 	// Grace indicates the remaining number of times a user will be allowed to authenticate with an expired password
@@ -133,6 +238,30 @@ func (c *ControlBeheraPasswordPolicy) GetControlType() string {
 func (c *ControlBeheraPasswordPolicy) Encode() *asn1.Packet {
 	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
 	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type ("+ControlTypeMap[ControlTypeBeheraPasswordPolicy]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	if c.Expire < 0 && c.Grace < 0 && c.Error < 0 {
+		// the request control carries no value
+		return packet
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Password Policy - Behera)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "PasswordPolicyResponseValue")
+	if c.Expire >= 0 || c.Grace >= 0 {
+		warning := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Warning")
+		if c.Expire >= 0 {
+			warning.AppendChild(asn1.NewInteger(asn1.ClassContext, asn1.TypePrimitive, 0, c.Expire, "Time Before Expiration"))
+		} else {
+			warning.AppendChild(asn1.NewInteger(asn1.ClassContext, asn1.TypePrimitive, 1, c.Grace, "Grace Authentications Remaining"))
+		}
+		seq.AppendChild(warning)
+	}
+	if c.Error >= 0 {
+		seq.AppendChild(asn1.NewInteger(asn1.ClassContext, asn1.TypePrimitive, 1, int64(c.Error), "Error"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
 
 	return packet
 }
@@ -143,7 +272,7 @@ func (c *ControlBeheraPasswordPolicy) String() string {
 		"Control Type: %s (%q)  Criticality: %t  Expire: %d  Grace: %d  Error: %d, ErrorString: %s",
 		ControlTypeMap[ControlTypeBeheraPasswordPolicy],
 		ControlTypeBeheraPasswordPolicy,
-		false,
+		c.Criticality,
 		c.Expire,
 		c.Grace,
 		c.Error,
@@ -152,6 +281,8 @@ func (c *ControlBeheraPasswordPolicy) String() string {
 
 // ControlVChuPasswordMustChange implements the control described in https://tools.ietf.org/html/draft-vchu-ldap-pwd-policy-00
 type ControlVChuPasswordMustChange struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// MustChange indicates if the password is required to be changed
 	MustChange bool
 }
@@ -163,7 +294,14 @@ func (c *ControlVChuPasswordMustChange) GetControlType() string {
 
 // Encode returns the ber packet representation
 func (c *ControlVChuPasswordMustChange) Encode() *asn1.Packet {
-	return nil
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeVChuPasswordMustChange, "Control Type ("+ControlTypeMap[ControlTypeVChuPasswordMustChange]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	// the control is only sent when the password must change, with the value "0"
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "0", "Control Value (Password Must Change)"))
+	return packet
 }
 
 // String returns a human-readable description
@@ -172,12 +310,14 @@ func (c *ControlVChuPasswordMustChange) String() string {
 		"Control Type: %s (%q)  Criticality: %t  MustChange: %v",
 		ControlTypeMap[ControlTypeVChuPasswordMustChange],
 		ControlTypeVChuPasswordMustChange,
-		false,
+		c.Criticality,
 		c.MustChange)
 }
 
 // ControlVChuPasswordWarning implements the control described in https://tools.ietf.org/html/draft-vchu-ldap-pwd-policy-00
 type ControlVChuPasswordWarning struct {
+	// Criticality indicates if this control is required
+	Criticality bool
 	// Expire indicates the time in seconds until the password expires
 	Expire int64
 }
@@ -189,16 +329,22 @@ func (c *ControlVChuPasswordWarning) GetControlType() string {
 
 // Encode returns the ber packet representation
 func (c *ControlVChuPasswordWarning) Encode() *asn1.Packet {
-	return nil
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeVChuPasswordWarning, "Control Type ("+ControlTypeMap[ControlTypeVChuPasswordWarning]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, strconv.FormatInt(c.Expire, 10), "Control Value (Password Expiring)"))
+	return packet
 }
 
 // String returns a human-readable description
 func (c *ControlVChuPasswordWarning) String() string {
 	return fmt.Sprintf(
-		"Control Type: %s (%q)  Criticality: %t  Expire: %b",
+		"Control Type: %s (%q)  Criticality: %t  Expire: %d",
 		ControlTypeMap[ControlTypeVChuPasswordWarning],
 		ControlTypeVChuPasswordWarning,
-		false,
+		c.Criticality,
 		c.Expire)
 }
 
@@ -238,131 +384,1734 @@ func NewControlManageDsaIT(Criticality bool) *ControlManageDsaIT {
 	return &ControlManageDsaIT{Criticality: Criticality}
 }
 
-// FindControl returns the first control of the given type in the list, or nil
-func FindControl(controls []Control, controlType string) Control {
-	for _, c := range controls {
-		if c.GetControlType() == controlType {
-			return c
-		}
+// ControlMicrosoftShowDeleted implements the control described in https://msdn.microsoft.com/en-us/library/aa366989(v=vs.85).aspx
+//
+// It makes searches return deleted objects (tombstones), which are hidden otherwise.
+type ControlMicrosoftShowDeleted struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftShowDeleted) GetControlType() string {
+	return ControlTypeMicrosoftShowDeleted
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftShowDeleted) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeMicrosoftShowDeleted, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftShowDeleted]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
 	}
-	return nil
+	return packet
 }
 
-// DecodeControl returns a control read from the given packet, or nil if no recognized control can be made
-func DecodeControl(packet *asn1.Packet) Control {
-	var (
-		ControlType = ""
-		Criticality = false
-		value       *asn1.Packet
-	)
+// String returns a human-readable description
+func (c *ControlMicrosoftShowDeleted) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftShowDeleted],
+		ControlTypeMicrosoftShowDeleted,
+		c.Criticality)
+}
 
-	switch len(packet.Children) {
-	case 0:
-		// at least one child is required for control type
-		return nil
+// NewControlMicrosoftShowDeleted returns a ControlMicrosoftShowDeleted control
+func NewControlMicrosoftShowDeleted(Criticality bool) *ControlMicrosoftShowDeleted {
+	return &ControlMicrosoftShowDeleted{Criticality: Criticality}
+}
 
-	case 1:
-		// just type, no criticality or value
-		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
+// ControlMicrosoftShowRecycled implements the control described in https://msdn.microsoft.com/en-us/library/dd303652.aspx
+//
+// It makes searches return deleted and recycled objects when the Active Directory
+// Recycle Bin is enabled.
+type ControlMicrosoftShowRecycled struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
 
-	case 2:
-		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
+// GetControlType returns the OID
+func (c *ControlMicrosoftShowRecycled) GetControlType() string {
+	return ControlTypeMicrosoftShowRecycled
+}
 
-		// Children[1] could be criticality or value (both are optional)
-		// duck-type on whether this is a boolean
-		if _, ok := packet.Children[1].Value.(bool); ok {
-			packet.Children[1].Description = "Criticality"
-			Criticality = packet.Children[1].Value.(bool)
-		} else {
-			packet.Children[1].Description = "Control Value"
-			value = packet.Children[1]
-		}
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftShowRecycled) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeMicrosoftShowRecycled, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftShowRecycled]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
 
-	case 3:
-		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
+// String returns a human-readable description
+func (c *ControlMicrosoftShowRecycled) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftShowRecycled],
+		ControlTypeMicrosoftShowRecycled,
+		c.Criticality)
+}
 
-		packet.Children[1].Description = "Criticality"
-		Criticality = packet.Children[1].Value.(bool)
+// NewControlMicrosoftShowRecycled returns a ControlMicrosoftShowRecycled control
+func NewControlMicrosoftShowRecycled(Criticality bool) *ControlMicrosoftShowRecycled {
+	return &ControlMicrosoftShowRecycled{Criticality: Criticality}
+}
 
-		packet.Children[2].Description = "Control Value"
-		value = packet.Children[2]
+// ControlMicrosoftTreeDelete implements the control described in https://msdn.microsoft.com/en-us/library/aa366991(v=vs.85).aspx
+//
+// Sent with a DelRequest, it makes the server delete the entry and all of its subordinates.
+type ControlMicrosoftTreeDelete struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
 
-	default:
-		// more than 3 children is invalid
-		return nil
+// GetControlType returns the OID
+func (c *ControlMicrosoftTreeDelete) GetControlType() string {
+	return ControlTypeMicrosoftTreeDelete
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftTreeDelete) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeMicrosoftTreeDelete, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftTreeDelete]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
 	}
+	return packet
+}
 
-	switch ControlType {
-	case ControlTypeManageDsaIT:
-		return NewControlManageDsaIT(Criticality)
-	case ControlTypePaging:
-		value.Description += " (Paging)"
-		c := new(ControlPaging)
-		if value.Value != nil {
-			valueChildren := asn1.DecodePacket(value.Data.Bytes())
-			value.Data.Truncate(0)
-			value.Value = nil
-			value.AppendChild(valueChildren)
-		}
-		value = value.Children[0]
-		value.Description = "Search Control Value"
-		value.Children[0].Description = "Paging Size"
-		value.Children[1].Description = "Cookie"
-		c.PagingSize = uint32(value.Children[0].Value.(int64))
-		c.Cookie = value.Children[1].Data.Bytes()
-		value.Children[1].Value = c.Cookie
-		return c
-	case ControlTypeBeheraPasswordPolicy:
-		value.Description += " (Password Policy - Behera)"
-		c := NewControlBeheraPasswordPolicy()
-		if value.Value != nil {
-			valueChildren := asn1.DecodePacket(value.Data.Bytes())
-			value.Data.Truncate(0)
-			value.Value = nil
-			value.AppendChild(valueChildren)
-		}
+// String returns a human-readable description
+func (c *ControlMicrosoftTreeDelete) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftTreeDelete],
+		ControlTypeMicrosoftTreeDelete,
+		c.Criticality)
+}
 
-		sequence := value.Children[0]
+// NewControlMicrosoftTreeDelete returns a ControlMicrosoftTreeDelete control
+func NewControlMicrosoftTreeDelete(Criticality bool) *ControlMicrosoftTreeDelete {
+	return &ControlMicrosoftTreeDelete{Criticality: Criticality}
+}
 
-		for _, child := range sequence.Children {
-			if child.Tag == 0 {
-				//Warning
-				warningPacket := child.Children[0]
-				packet := asn1.DecodePacket(warningPacket.Data.Bytes())
-				val, ok := packet.Value.(int64)
-				if ok {
-					if warningPacket.Tag == 0 {
-						//timeBeforeExpiration
-						c.Expire = val
-						warningPacket.Value = c.Expire
-					} else if warningPacket.Tag == 1 {
-						//graceAuthNsRemaining
-						c.Grace = val
-						warningPacket.Value = c.Grace
-					}
-				}
-			} else if child.Tag == 1 {
-				// Error
-				packet := asn1.DecodePacket(child.Data.Bytes())
-				val, ok := packet.Value.(int8)
-				if !ok {
-					// what to do?
-					val = -1
-				}
-				c.Error = val
-				child.Value = c.Error
-				c.ErrorString = BeheraPasswordPolicyErrorMap[c.Error]
-			}
-		}
-		return c
+// ControlMicrosoftPermissiveModify implements the control described in https://msdn.microsoft.com/en-us/library/aa366984(v=vs.85).aspx
+//
+// Sent with a ModifyRequest, it makes adding a value which is already present
+// or deleting a value which is missing succeed instead of failing.
+type ControlMicrosoftPermissiveModify struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftPermissiveModify) GetControlType() string {
+	return ControlTypeMicrosoftPermissiveModify
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftPermissiveModify) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeMicrosoftPermissiveModify, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftPermissiveModify]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftPermissiveModify) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeMicrosoftPermissiveModify],
+		ControlTypeMicrosoftPermissiveModify,
+		c.Criticality)
+}
+
+// NewControlMicrosoftPermissiveModify returns a ControlMicrosoftPermissiveModify control
+func NewControlMicrosoftPermissiveModify(Criticality bool) *ControlMicrosoftPermissiveModify {
+	return &ControlMicrosoftPermissiveModify{Criticality: Criticality}
+}
+
+// ControlRelaxRules implements the control described in https://tools.ietf.org/html/draft-zeilenga-ldap-relax-03
+//
+// Sent with an update operation, it asks the server to relax the data and
+// service rules it enforces, e.g. to set the operational attributes of an
+// entry when importing it or to add an entry of an obsolete object class.
+// The criticality should be true, as required by the draft.
+type ControlRelaxRules struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlRelaxRules) GetControlType() string {
+	return ControlTypeRelaxRules
+}
+
+// Encode returns the ber packet representation
+func (c *ControlRelaxRules) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeRelaxRules, "Control Type ("+ControlTypeMap[ControlTypeRelaxRules]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlRelaxRules) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeRelaxRules],
+		ControlTypeRelaxRules,
+		c.Criticality)
+}
+
+// NewControlRelaxRules returns a ControlRelaxRules control
+func NewControlRelaxRules(Criticality bool) *ControlRelaxRules {
+	return &ControlRelaxRules{Criticality: Criticality}
+}
+
+// ControlNoOp implements the control described in https://tools.ietf.org/html/draft-zeilenga-ldap-noop-01
+//
+// Sent with an update operation, it asks the server to process the operation
+// up to its result without changing the directory, to find out whether it
+// would succeed. The criticality should be true, as required by the draft.
+// OpenLDAP reports an operation which would succeed with the non standard
+// result code 0x410e, rather than with LDAPResultSuccess.
+type ControlNoOp struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlNoOp) GetControlType() string {
+	return ControlTypeNoOp
+}
+
+// Encode returns the ber packet representation
+func (c *ControlNoOp) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeNoOp, "Control Type ("+ControlTypeMap[ControlTypeNoOp]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlNoOp) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeNoOp],
+		ControlTypeNoOp,
+		c.Criticality)
+}
+
+// NewControlNoOp returns a ControlNoOp control
+func NewControlNoOp(Criticality bool) *ControlNoOp {
+	return &ControlNoOp{Criticality: Criticality}
+}
+
+// ControlDontUseCopy implements the control described in https://tools.ietf.org/html/rfc6171
+//
+// Sent with a search or compare request, it asks the server not to use a
+// copy of the entries, such as a shadow of a replica, which may be outdated,
+// but the original entries. The criticality must be true, as required by
+// the RFC.
+type ControlDontUseCopy struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlDontUseCopy) GetControlType() string {
+	return ControlTypeDontUseCopy
+}
+
+// Encode returns the ber packet representation
+func (c *ControlDontUseCopy) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeDontUseCopy, "Control Type ("+ControlTypeMap[ControlTypeDontUseCopy]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlDontUseCopy) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeDontUseCopy],
+		ControlTypeDontUseCopy,
+		c.Criticality)
+}
+
+// NewControlDontUseCopy returns a ControlDontUseCopy control
+func NewControlDontUseCopy(Criticality bool) *ControlDontUseCopy {
+	return &ControlDontUseCopy{Criticality: Criticality}
+}
+
+// ControlSubentries implements the subentries control described in
+// https://tools.ietf.org/html/rfc3672
+//
+// Sent with a search request, it selects whether the search returns the
+// subentries, such as the collective attribute and access control
+// subentries, or the regular entries. Without it, searches only return
+// subentries when filtering on objectClass=subentry.
+type ControlSubentries struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Visibility returns only the subentries if true, and only the regular
+	// entries if false
+	Visibility bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSubentries) GetControlType() string {
+	return ControlTypeSubentries
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSubentries) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeSubentries, "Control Type ("+ControlTypeMap[ControlTypeSubentries]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Subentries)")
+	value.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Visibility, "Visibility"))
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSubentries) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Visibility: %t",
+		ControlTypeMap[ControlTypeSubentries],
+		ControlTypeSubentries,
+		c.Criticality,
+		c.Visibility)
+}
+
+// NewControlSubentries returns a critical ControlSubentries with the given
+// visibility, so that servers which do not support it fail the search
+// instead of returning the regular entries
+func NewControlSubentries(visibility bool) *ControlSubentries {
+	return &ControlSubentries{Criticality: true, Visibility: visibility}
+}
+
+// SortKey describes a single key of a server side sort request
+type SortKey struct {
+	// AttributeType is the attribute to sort by
+	AttributeType string
+	// MatchingRule is the optional OID of the ordering rule to sort with
+	MatchingRule string
+	// Reverse sorts in descending instead of ascending order
+	Reverse bool
+}
+
+// ControlServerSideSorting implements the sort request control described in https://tools.ietf.org/html/rfc2891
+type ControlServerSideSorting struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// SortKeys are the sort keys in order of precedence
+	SortKeys []*SortKey
+}
+
+// GetControlType returns the OID
+func (c *ControlServerSideSorting) GetControlType() string {
+	return ControlTypeServerSideSorting
+}
+
+// Encode returns the ber packet representation
+func (c *ControlServerSideSorting) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeServerSideSorting, "Control Type ("+ControlTypeMap[ControlTypeServerSideSorting]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Server Side Sorting)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "SortKeyList")
+	for _, key := range c.SortKeys {
+		keySeq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "SortKey")
+		keySeq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, key.AttributeType, "Attribute Type"))
+		if key.MatchingRule != "" {
+			keySeq.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, key.MatchingRule, "Ordering Rule"))
+		}
+		if key.Reverse {
+			keySeq.AppendChild(asn1.NewBoolean(asn1.ClassContext, asn1.TypePrimitive, 1, key.Reverse, "Reverse Order"))
+		}
+		seq.AppendChild(keySeq)
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlServerSideSorting) String() string {
+	keys := make([]string, 0, len(c.SortKeys))
+	for _, key := range c.SortKeys {
+		keys = append(keys, fmt.Sprintf("%s:%s:%t", key.AttributeType, key.MatchingRule, key.Reverse))
+	}
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  SortKeys: %v",
+		ControlTypeMap[ControlTypeServerSideSorting],
+		ControlTypeServerSideSorting,
+		c.Criticality,
+		keys)
+}
+
+// NewControlServerSideSorting returns a ControlServerSideSorting control for the given sort keys
+func NewControlServerSideSorting(sortKeys []*SortKey) *ControlServerSideSorting {
+	return &ControlServerSideSorting{SortKeys: sortKeys}
+}
+
+// ControlServerSideSortingResult implements the sort response control described in https://tools.ietf.org/html/rfc2891
+type ControlServerSideSortingResult struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Result is the LDAP result code of the sort, LDAPResultSuccess if the server sorted the results
+	Result uint8
+	// AttributeType optionally names the attribute which caused the sort to fail
+	AttributeType string
+}
+
+// GetControlType returns the OID
+func (c *ControlServerSideSortingResult) GetControlType() string {
+	return ControlTypeServerSideSortingResult
+}
+
+// Encode returns the ber packet representation
+func (c *ControlServerSideSortingResult) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeServerSideSortingResult, "Control Type ("+ControlTypeMap[ControlTypeServerSideSortingResult]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Server Side Sorting Result)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "SortResult")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, int64(c.Result), "Sort Result"))
+	if c.AttributeType != "" {
+		seq.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, c.AttributeType, "Attribute Type"))
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlServerSideSortingResult) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Result: %d (%s)  AttributeType: %s",
+		ControlTypeMap[ControlTypeServerSideSortingResult],
+		ControlTypeServerSideSortingResult,
+		c.Criticality,
+		c.Result,
+		LDAPResultCodeMap[c.Result],
+		c.AttributeType)
+}
+
+// ControlVLV implements the virtual list view request control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09. Servers only
+// honor it when the search also carries a ControlServerSideSorting.
+//
+// The target entry is selected by offset, unless GreaterThanOrEqual is non-nil,
+// in which case the target is the first entry whose sort key is greater than or
+// equal to that assertion value.
+type ControlVLV struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// BeforeCount is the number of entries to return before the target entry
+	BeforeCount uint32
+	// AfterCount is the number of entries to return after the target entry
+	AfterCount uint32
+	// Offset is the 1-based position of the target entry in the list
+	Offset uint32
+	// ContentCount is the client's estimate of the list size, 0 if unknown
+	ContentCount uint32
+	// GreaterThanOrEqual, if not nil, selects the target entry by assertion value
+	GreaterThanOrEqual []byte
+	// ContextID is the opaque value returned by the server in the previous response, if any
+	ContextID []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlVLV) GetControlType() string {
+	return ControlTypeVLV
+}
+
+// Encode returns the ber packet representation
+func (c *ControlVLV) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeVLV, "Control Type ("+ControlTypeMap[ControlTypeVLV]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Virtual List View)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "VirtualListViewRequest")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.BeforeCount), "Before Count"))
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.AfterCount), "After Count"))
+	if c.GreaterThanOrEqual != nil {
+		seq.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 1, string(c.GreaterThanOrEqual), "Greater Than Or Equal"))
+	} else {
+		byOffset := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "By Offset")
+		byOffset.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.Offset), "Offset"))
+		byOffset.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.ContentCount), "Content Count"))
+		seq.AppendChild(byOffset)
+	}
+	if len(c.ContextID) > 0 {
+		seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(c.ContextID), "Context ID"))
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlVLV) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  BeforeCount: %d  AfterCount: %d  Offset: %d  ContentCount: %d  GreaterThanOrEqual: %q  ContextID: %q",
+		ControlTypeMap[ControlTypeVLV],
+		ControlTypeVLV,
+		c.Criticality,
+		c.BeforeCount,
+		c.AfterCount,
+		c.Offset,
+		c.ContentCount,
+		c.GreaterThanOrEqual,
+		c.ContextID)
+}
+
+// SetContextID stores the context ID returned by the server for use in the next request
+func (c *ControlVLV) SetContextID(contextID []byte) {
+	c.ContextID = contextID
+}
+
+// NewControlVLVByOffset returns a ControlVLV selecting the target entry by its 1-based offset
+// in a list the client believes to hold contentCount entries
+func NewControlVLVByOffset(beforeCount, afterCount, offset, contentCount uint32) *ControlVLV {
+	return &ControlVLV{
+		BeforeCount:  beforeCount,
+		AfterCount:   afterCount,
+		Offset:       offset,
+		ContentCount: contentCount,
+	}
+}
+
+// NewControlVLVByValue returns a ControlVLV selecting as target the first entry whose
+// sort key is greater than or equal to the given assertion value
+func NewControlVLVByValue(beforeCount, afterCount uint32, greaterThanOrEqual string) *ControlVLV {
+	return &ControlVLV{
+		BeforeCount:        beforeCount,
+		AfterCount:         afterCount,
+		GreaterThanOrEqual: []byte(greaterThanOrEqual),
+	}
+}
+
+// ControlVLVResult implements the virtual list view response control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+type ControlVLVResult struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// TargetPosition is the server's 1-based position of the target entry in the list
+	TargetPosition uint32
+	// ContentCount is the server's estimate of the list size
+	ContentCount uint32
+	// Result is the LDAP result code of the list view operation
+	Result uint8
+	// ContextID is an opaque value to send with the next VLV request, if any
+	ContextID []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlVLVResult) GetControlType() string {
+	return ControlTypeVLVResult
+}
+
+// Encode returns the ber packet representation
+func (c *ControlVLVResult) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeVLVResult, "Control Type ("+ControlTypeMap[ControlTypeVLVResult]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Virtual List View Result)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "VirtualListViewResponse")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.TargetPosition), "Target Position"))
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.ContentCount), "Content Count"))
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, int64(c.Result), "Virtual List View Result"))
+	if len(c.ContextID) > 0 {
+		seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(c.ContextID), "Context ID"))
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlVLVResult) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  TargetPosition: %d  ContentCount: %d  Result: %d (%s)  ContextID: %q",
+		ControlTypeMap[ControlTypeVLVResult],
+		ControlTypeVLVResult,
+		c.Criticality,
+		c.TargetPosition,
+		c.ContentCount,
+		c.Result,
+		LDAPResultCodeMap[c.Result],
+		c.ContextID)
+}
+
+// ControlProxiedAuthorization implements the control described in https://tools.ietf.org/html/rfc4370.
+// It asks the server to perform the operation it is attached to under the authorization identity
+// AuthzID instead of the identity the connection is bound as. The RFC requires the control to be
+// critical, which NewControlProxiedAuthorization sets.
+type ControlProxiedAuthorization struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// AuthzID is the authorization identity, either "dn:<distinguished name>" or "u:<user id>".
+	// An empty value requests the anonymous identity.
+	AuthzID string
+}
+
+// GetControlType returns the OID
+func (c *ControlProxiedAuthorization) GetControlType() string {
+	return ControlTypeProxiedAuthorization
+}
+
+// Encode returns the ber packet representation
+func (c *ControlProxiedAuthorization) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeProxiedAuthorization, "Control Type ("+ControlTypeMap[ControlTypeProxiedAuthorization]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.AuthzID, "Control Value (Proxied Authorization)"))
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlProxiedAuthorization) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  AuthzID: %q",
+		ControlTypeMap[ControlTypeProxiedAuthorization],
+		ControlTypeProxiedAuthorization,
+		c.Criticality,
+		c.AuthzID)
+}
+
+// NewControlProxiedAuthorization returns a ControlProxiedAuthorization for the given
+// authorization identity, e.g. "dn:uid=jdoe,ou=people,dc=example,dc=com" or "u:jdoe"
+func NewControlProxiedAuthorization(authzID string) *ControlProxiedAuthorization {
+	return &ControlProxiedAuthorization{Criticality: true, AuthzID: authzID}
+}
+
+// ControlAuthzIDRequest implements the authorization identity request control
+// described in https://tools.ietf.org/html/rfc3829.
+// Sent with a bind request, it asks the server to return the authorization
+// identity of the connection in a ControlAuthzIDResponse, as returned by
+// SimpleBindResult.AuthzID.
+type ControlAuthzIDRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+}
+
+// GetControlType returns the OID
+func (c *ControlAuthzIDRequest) GetControlType() string {
+	return ControlTypeAuthzIDRequest
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAuthzIDRequest) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeAuthzIDRequest, "Control Type ("+ControlTypeMap[ControlTypeAuthzIDRequest]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAuthzIDRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t",
+		ControlTypeMap[ControlTypeAuthzIDRequest],
+		ControlTypeAuthzIDRequest,
+		c.Criticality)
+}
+
+// NewControlAuthzIDRequest returns a ControlAuthzIDRequest control
+func NewControlAuthzIDRequest(Criticality bool) *ControlAuthzIDRequest {
+	return &ControlAuthzIDRequest{Criticality: Criticality}
+}
+
+// ControlAuthzIDResponse implements the authorization identity response
+// control described in https://tools.ietf.org/html/rfc3829, returned with a
+// successful bind requested with a ControlAuthzIDRequest
+type ControlAuthzIDResponse struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// AuthzID is the authorization identity of the connection, such as
+	// "dn:cn=admin,dc=example,dc=com", or empty if it is anonymous
+	AuthzID string
+}
+
+// GetControlType returns the OID
+func (c *ControlAuthzIDResponse) GetControlType() string {
+	return ControlTypeAuthzIDResponse
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAuthzIDResponse) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeAuthzIDResponse, "Control Type ("+ControlTypeMap[ControlTypeAuthzIDResponse]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.AuthzID, "Control Value (Authorization Identity Response)"))
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAuthzIDResponse) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  AuthzID: %q",
+		ControlTypeMap[ControlTypeAuthzIDResponse],
+		ControlTypeAuthzIDResponse,
+		c.Criticality,
+		c.AuthzID)
+}
+
+// ControlTransactionSpecification implements the control described in https://tools.ietf.org/html/rfc5805.
+// It makes the update operation it is attached to part of the transaction TransactionID.
+// The RFC requires the control to be critical, which NewControlTransactionSpecification sets.
+type ControlTransactionSpecification struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// TransactionID is the transaction identifier returned by the server when starting the transaction
+	TransactionID []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlTransactionSpecification) GetControlType() string {
+	return ControlTypeTransactionSpecification
+}
+
+// Encode returns the ber packet representation
+func (c *ControlTransactionSpecification) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeTransactionSpecification, "Control Type ("+ControlTypeMap[ControlTypeTransactionSpecification]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Transaction Specification)")
+	value.Value = c.TransactionID
+	value.Data.Write(c.TransactionID)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlTransactionSpecification) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  TransactionID: %q",
+		ControlTypeMap[ControlTypeTransactionSpecification],
+		ControlTypeTransactionSpecification,
+		c.Criticality,
+		c.TransactionID)
+}
+
+// NewControlTransactionSpecification returns a ControlTransactionSpecification for the given transaction
+func NewControlTransactionSpecification(transactionID []byte) *ControlTransactionSpecification {
+	return &ControlTransactionSpecification{Criticality: true, TransactionID: transactionID}
+}
+
+// ControlPersistentSearch implements the persistent search control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+type ControlPersistentSearch struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// ChangeTypes is a bitmask of the EntryChange* types the client wants to be notified about
+	ChangeTypes int
+	// ChangesOnly suppresses the initial result set, so only changed entries are returned
+	ChangesOnly bool
+	// ReturnECs asks the server to attach an entry change notification control to changed entries
+	ReturnECs bool
+}
+
+// GetControlType returns the OID
+func (c *ControlPersistentSearch) GetControlType() string {
+	return ControlTypePersistentSearch
+}
+
+// Encode returns the ber packet representation
+func (c *ControlPersistentSearch) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypePersistentSearch, "Control Type ("+ControlTypeMap[ControlTypePersistentSearch]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Persistent Search)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "PersistentSearch")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(c.ChangeTypes), "Change Types"))
+	seq.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.ChangesOnly, "Changes Only"))
+	seq.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.ReturnECs, "Return ECs"))
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlPersistentSearch) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  ChangeTypes: %d  ChangesOnly: %t  ReturnECs: %t",
+		ControlTypeMap[ControlTypePersistentSearch],
+		ControlTypePersistentSearch,
+		c.Criticality,
+		c.ChangeTypes,
+		c.ChangesOnly,
+		c.ReturnECs)
+}
+
+// NewControlPersistentSearch returns a critical ControlPersistentSearch for the given change types
+// which asks for entry change notifications to be returned
+func NewControlPersistentSearch(changeTypes int, changesOnly bool) *ControlPersistentSearch {
+	return &ControlPersistentSearch{
+		Criticality: true,
+		ChangeTypes: changeTypes,
+		ChangesOnly: changesOnly,
+		ReturnECs:   true,
+	}
+}
+
+// ControlEntryChangeNotification implements the entry change notification control described in
+// https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+type ControlEntryChangeNotification struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// ChangeType is the EntryChange* type of the change
+	ChangeType int
+	// PreviousDN is the DN of the entry before a ModDN change
+	PreviousDN string
+	// ChangeNumber is the change log number of the change, if the server keeps one
+	ChangeNumber int64
+}
+
+// GetControlType returns the OID
+func (c *ControlEntryChangeNotification) GetControlType() string {
+	return ControlTypeEntryChangeNotification
+}
+
+// Encode returns the ber packet representation
+func (c *ControlEntryChangeNotification) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeEntryChangeNotification, "Control Type ("+ControlTypeMap[ControlTypeEntryChangeNotification]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Entry Change Notification)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "EntryChangeNotification")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, int64(c.ChangeType), "Change Type"))
+	if c.PreviousDN != "" {
+		seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.PreviousDN, "Previous DN"))
+	}
+	if c.ChangeNumber != 0 {
+		seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, c.ChangeNumber, "Change Number"))
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlEntryChangeNotification) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  ChangeType: %d (%s)  PreviousDN: %q  ChangeNumber: %d",
+		ControlTypeMap[ControlTypeEntryChangeNotification],
+		ControlTypeEntryChangeNotification,
+		c.Criticality,
+		c.ChangeType,
+		EntryChangeMap[c.ChangeType],
+		c.PreviousDN,
+		c.ChangeNumber)
+}
+
+// ControlPreRead implements the pre-read control described in https://tools.ietf.org/html/rfc4527
+//
+// Sent with a Modify, Del or ModifyDN request, it asks for the given attributes
+// of the entry as it was before the update. The server returns the entry in a
+// response control of the same type.
+type ControlPreRead struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Attributes are the attributes to return, all user attributes if empty
+	Attributes []string
+	// Entry is the entry returned by the server
+	Entry *Entry
+}
+
+// GetControlType returns the OID
+func (c *ControlPreRead) GetControlType() string {
+	return ControlTypePreRead
+}
+
+// Encode returns the ber packet representation
+func (c *ControlPreRead) Encode() *asn1.Packet {
+	return encodeReadEntryControl(ControlTypePreRead, c.Criticality, c.Attributes, c.Entry)
+}
+
+// String returns a human-readable description
+func (c *ControlPreRead) String() string {
+	return readEntryControlString(ControlTypePreRead, c.Criticality, c.Attributes, c.Entry)
+}
+
+// NewControlPreRead returns a critical ControlPreRead asking for the given attributes
+func NewControlPreRead(attributes ...string) *ControlPreRead {
+	return &ControlPreRead{
+		Criticality: true,
+		Attributes:  attributes,
+	}
+}
+
+// ControlPostRead implements the post-read control described in https://tools.ietf.org/html/rfc4527
+//
+// Sent with an Add, Modify or ModifyDN request, it asks for the given attributes
+// of the entry as it is after the update. The server returns the entry in a
+// response control of the same type.
+type ControlPostRead struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Attributes are the attributes to return, all user attributes if empty
+	Attributes []string
+	// Entry is the entry returned by the server
+	Entry *Entry
+}
+
+// GetControlType returns the OID
+func (c *ControlPostRead) GetControlType() string {
+	return ControlTypePostRead
+}
+
+// Encode returns the ber packet representation
+func (c *ControlPostRead) Encode() *asn1.Packet {
+	return encodeReadEntryControl(ControlTypePostRead, c.Criticality, c.Attributes, c.Entry)
+}
+
+// String returns a human-readable description
+func (c *ControlPostRead) String() string {
+	return readEntryControlString(ControlTypePostRead, c.Criticality, c.Attributes, c.Entry)
+}
+
+// NewControlPostRead returns a critical ControlPostRead asking for the given attributes
+func NewControlPostRead(attributes ...string) *ControlPostRead {
+	return &ControlPostRead{
+		Criticality: true,
+		Attributes:  attributes,
+	}
+}
+
+// encodeReadEntryControl encodes a pre-read or post-read control. The value
+// holds the returned entry if set, else the requested attribute selection.
+func encodeReadEntryControl(controlType string, criticality bool, attributes []string, entry *Entry) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, controlType, "Control Type ("+ControlTypeMap[controlType]+")"))
+	if criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value ("+ControlTypeMap[controlType]+")")
+	if entry != nil {
+		value.AppendChild(encodeEntry(entry))
+	} else {
+		selection := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attribute Selection")
+		for _, attribute := range attributes {
+			selection.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, attribute, "Attribute"))
+		}
+		value.AppendChild(selection)
+	}
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// decodeReadEntryValue decodes the value of a pre-read or post-read control
+// into either the attribute selection of a request or the entry of a
+// response. ok is false if the value is missing or malformed.
+func decodeReadEntryValue(value *asn1.Packet) (attributes []string, entry *Entry, ok bool) {
+	content := decodeControlValue(value)
+	if content == nil {
+		return nil, nil, false
+	}
+	if content.ClassType == asn1.ClassApplication {
+		if !isEntryPacket(content) {
+			return nil, nil, false
+		}
+		return nil, decodeEntry(content), true
+	}
+	for _, child := range content.Children {
+		attribute, ok := child.Value.(string)
+		if !ok {
+			return nil, nil, false
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes, nil, true
+}
+
+// isEntryPacket returns whether the packet has the structure of a
+// SearchResultEntry expected by decodeEntry
+func isEntryPacket(packet *asn1.Packet) bool {
+	if packet.Tag != ApplicationSearchResultEntry || len(packet.Children) != 2 {
+		return false
+	}
+	if _, ok := packet.Children[0].Value.(string); !ok {
+		return false
+	}
+	for _, attribute := range packet.Children[1].Children {
+		if len(attribute.Children) != 2 {
+			return false
+		}
+		if _, ok := attribute.Children[0].Value.(string); !ok {
+			return false
+		}
+		for _, value := range attribute.Children[1].Children {
+			if _, ok := value.Value.(string); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func readEntryControlString(controlType string, criticality bool, attributes []string, entry *Entry) string {
+	if entry != nil {
+		return fmt.Sprintf(
+			"Control Type: %s (%q)  Criticality: %t  Entry: %q",
+			ControlTypeMap[controlType],
+			controlType,
+			criticality,
+			entry.DN)
+	}
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Attributes: %v",
+		ControlTypeMap[controlType],
+		controlType,
+		criticality,
+		attributes)
+}
+
+// ControlSyncRequest implements the sync request control described in https://tools.ietf.org/html/rfc4533
+type ControlSyncRequest struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Mode is either SyncModeRefreshOnly or SyncModeRefreshAndPersist
+	Mode int
+	// Cookie is the state of a previous synchronization, or nil to synchronize all content
+	Cookie []byte
+	// ReloadHint asks the server to send all content instead of an e-syncRefreshRequired error
+	// if it cannot compute the changes since the state of Cookie
+	ReloadHint bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncRequest) GetControlType() string {
+	return ControlTypeSyncRequest
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncRequest) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeSyncRequest, "Control Type ("+ControlTypeMap[ControlTypeSyncRequest]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Sync Request)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "syncRequestValue")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, int64(c.Mode), "Mode"))
+	if c.Cookie != nil {
+		cookie := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Cookie")
+		cookie.Value = c.Cookie
+		cookie.Data.Write(c.Cookie)
+		seq.AppendChild(cookie)
+	}
+	if c.ReloadHint {
+		seq.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.ReloadHint, "Reload Hint"))
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Mode: %d  Cookie: %q  ReloadHint: %t",
+		ControlTypeMap[ControlTypeSyncRequest],
+		ControlTypeSyncRequest,
+		c.Criticality,
+		c.Mode,
+		c.Cookie,
+		c.ReloadHint)
+}
+
+// NewControlSyncRequest returns a critical ControlSyncRequest resuming from the given cookie
+func NewControlSyncRequest(mode int, cookie []byte) *ControlSyncRequest {
+	return &ControlSyncRequest{
+		Criticality: true,
+		Mode:        mode,
+		Cookie:      cookie,
+	}
+}
+
+// ControlSyncState implements the sync state control described in https://tools.ietf.org/html/rfc4533
+// which is attached by the server to every entry returned by a synchronization
+type ControlSyncState struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// State is one of the SyncState* constants
+	State int
+	// EntryUUID is the 16 byte UUID identifying the entry
+	EntryUUID []byte
+	// Cookie is the new synchronization state, if the server sent one
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncState) GetControlType() string {
+	return ControlTypeSyncState
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncState) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeSyncState, "Control Type ("+ControlTypeMap[ControlTypeSyncState]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Sync State)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "syncStateValue")
+	seq.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, int64(c.State), "State"))
+	uuid := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Entry UUID")
+	uuid.Value = c.EntryUUID
+	uuid.Data.Write(c.EntryUUID)
+	seq.AppendChild(uuid)
+	if c.Cookie != nil {
+		cookie := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Cookie")
+		cookie.Value = c.Cookie
+		cookie.Data.Write(c.Cookie)
+		seq.AppendChild(cookie)
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncState) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  State: %d (%s)  EntryUUID: %x  Cookie: %q",
+		ControlTypeMap[ControlTypeSyncState],
+		ControlTypeSyncState,
+		c.Criticality,
+		c.State,
+		SyncStateMap[c.State],
+		c.EntryUUID,
+		c.Cookie)
+}
+
+// ControlSyncDone implements the sync done control described in https://tools.ietf.org/html/rfc4533
+// which is attached by the server to the result of a refreshOnly synchronization
+type ControlSyncDone struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// Cookie is the new synchronization state, if the server sent one
+	Cookie []byte
+	// RefreshDeletes is true if deleted entries were sent during the refresh, and false if
+	// entries not sent as present during the refresh have to be considered deleted
+	RefreshDeletes bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncDone) GetControlType() string {
+	return ControlTypeSyncDone
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncDone) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeSyncDone, "Control Type ("+ControlTypeMap[ControlTypeSyncDone]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Sync Done)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "syncDoneValue")
+	if c.Cookie != nil {
+		cookie := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Cookie")
+		cookie.Value = c.Cookie
+		cookie.Data.Write(c.Cookie)
+		seq.AppendChild(cookie)
+	}
+	if c.RefreshDeletes {
+		seq.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.RefreshDeletes, "Refresh Deletes"))
+	}
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncDone) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Cookie: %q  RefreshDeletes: %t",
+		ControlTypeMap[ControlTypeSyncDone],
+		ControlTypeSyncDone,
+		c.Criticality,
+		c.Cookie,
+		c.RefreshDeletes)
+}
+
+// ControlSessionTracking implements the session tracking control described in
+// https://tools.ietf.org/html/draft-wahl-ldap-session-03
+//
+// It lets a client acting on behalf of end users, such as a proxy, tell the
+// server who the operation is performed for, so it can be recorded in audit logs.
+type ControlSessionTracking struct {
+	// Criticality indicates if this control is required
+	Criticality bool
+	// SourceIP is the IP address of the end user
+	SourceIP string
+	// SourceName is the host name of the end user, if known
+	SourceName string
+	// FormatOID is the format of Identifier, such as SessionTrackingUsername
+	FormatOID string
+	// Identifier identifies the end user or session
+	Identifier string
+}
+
+// GetControlType returns the OID
+func (c *ControlSessionTracking) GetControlType() string {
+	return ControlTypeSessionTracking
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSessionTracking) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, ControlTypeSessionTracking, "Control Type ("+ControlTypeMap[ControlTypeSessionTracking]+")"))
+	if c.Criticality {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, c.Criticality, "Criticality"))
+	}
+
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Control Value (Session Tracking)")
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "SessionIdentifierControlValue")
+	seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.SourceIP, "Session Source IP"))
+	seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.SourceName, "Session Source Name"))
+	seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.FormatOID, "Format OID"))
+	seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, c.Identifier, "Session Tracking Identifier"))
+	value.AppendChild(seq)
+
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSessionTracking) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  SourceIP: %q  SourceName: %q  FormatOID: %q  Identifier: %q",
+		ControlTypeMap[ControlTypeSessionTracking],
+		ControlTypeSessionTracking,
+		c.Criticality,
+		c.SourceIP,
+		c.SourceName,
+		c.FormatOID,
+		c.Identifier)
+}
+
+// NewControlSessionTracking returns a ControlSessionTracking control
+func NewControlSessionTracking(sourceIP, sourceName, formatOID, identifier string) *ControlSessionTracking {
+	return &ControlSessionTracking{
+		SourceIP:   sourceIP,
+		SourceName: sourceName,
+		FormatOID:  formatOID,
+		Identifier: identifier,
+	}
+}
+
+// FindControl returns the first control of the given type in the list, or nil.
+// The list may hold nil controls, which DecodeControl returns for the controls
+// it cannot decode.
+func FindControl(controls []Control, controlType string) Control {
+	for _, c := range controls {
+		if c != nil && c.GetControlType() == controlType {
+			return c
+		}
+	}
+	return nil
+}
+
+// decodeControlValue returns the packet encoded in the given control value, or
+// nil if there is no value or it cannot be decoded
+func decodeControlValue(value *asn1.Packet) *asn1.Packet {
+	if value == nil {
+		return nil
+	}
+	if value.Value != nil {
+		valueChildren, err := asn1.DecodePacketErr(value.Data.Bytes())
+		if err != nil {
+			return nil
+		}
+		value.Data.Truncate(0)
+		value.Value = nil
+		value.AppendChild(valueChildren)
+	}
+	if len(value.Children) == 0 {
+		return nil
+	}
+	return value.Children[0]
+}
+
+// decodeResponseControl returns the control of the given type among the
+// controls of a response packet, or nil if it has none. As it is used by the
+// goroutines delivering the responses of persistent searches, a control which
+// cannot be decoded is returned as an error rather than as nil or a panic.
+func decodeResponseControl(packet *asn1.Packet, controlType string) (control Control, err error) {
+	if len(packet.Children) < 3 {
+		return nil, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			control, err = nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: cannot decode control %s", controlType))
+		}
+	}()
+	for _, child := range packet.Children[2].Children {
+		if len(child.Children) == 0 {
+			continue
+		}
+		if oid, _ := child.Children[0].Value.(string); oid != controlType {
+			continue
+		}
+		if control = DecodeControl(child); control == nil {
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: cannot decode control %s", controlType))
+		}
+		return control, nil
+	}
+	return nil, nil
+}
+
+// DecodeControl returns a control read from the given packet, or nil if no recognized control can be made
+func DecodeControl(packet *asn1.Packet) Control {
+	var (
+		ControlType = ""
+		Criticality = false
+		value       *asn1.Packet
+	)
+
+	switch len(packet.Children) {
+	case 0:
+		// at least one child is required for control type
+		return nil
+
+	case 1:
+		// just type, no criticality or value
+		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
+		ControlType = packet.Children[0].Value.(string)
+
+	case 2:
+		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
+		ControlType = packet.Children[0].Value.(string)
+
+		// Children[1] could be criticality or value (both are optional)
+		// duck-type on whether this is a boolean
+		if _, ok := packet.Children[1].Value.(bool); ok {
+			packet.Children[1].Description = "Criticality"
+			Criticality = packet.Children[1].Value.(bool)
+		} else {
+			packet.Children[1].Description = "Control Value"
+			value = packet.Children[1]
+		}
+
+	case 3:
+		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
+		ControlType = packet.Children[0].Value.(string)
+
+		packet.Children[1].Description = "Criticality"
+		Criticality = packet.Children[1].Value.(bool)
+
+		packet.Children[2].Description = "Control Value"
+		value = packet.Children[2]
+
+	default:
+		// more than 3 children is invalid
+		return nil
+	}
+
+	switch ControlType {
+	case ControlTypeManageDsaIT:
+		return NewControlManageDsaIT(Criticality)
+	case ControlTypeMicrosoftShowDeleted:
+		return NewControlMicrosoftShowDeleted(Criticality)
+	case ControlTypeMicrosoftShowRecycled:
+		return NewControlMicrosoftShowRecycled(Criticality)
+	case ControlTypeMicrosoftTreeDelete:
+		return NewControlMicrosoftTreeDelete(Criticality)
+	case ControlTypeMicrosoftPermissiveModify:
+		return NewControlMicrosoftPermissiveModify(Criticality)
+	case ControlTypeRelaxRules:
+		return NewControlRelaxRules(Criticality)
+	case ControlTypeNoOp:
+		return NewControlNoOp(Criticality)
+	case ControlTypeDontUseCopy:
+		return NewControlDontUseCopy(Criticality)
+	case ControlTypePaging:
+		value.Description += " (Paging)"
+		c := &ControlPaging{Criticality: Criticality}
+		if value.Value != nil {
+			valueChildren := asn1.DecodePacket(value.Data.Bytes())
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		value = value.Children[0]
+		value.Description = "Search Control Value"
+		value.Children[0].Description = "Paging Size"
+		value.Children[1].Description = "Cookie"
+		c.PagingSize = uint32(value.Children[0].Value.(int64))
+		c.Cookie = value.Children[1].Data.Bytes()
+		value.Children[1].Value = c.Cookie
+		return c
+	case ControlTypeBeheraPasswordPolicy:
+		c := NewControlBeheraPasswordPolicy()
+		c.Criticality = Criticality
+		if value == nil {
+			// the request control carries no value
+			return c
+		}
+		value.Description += " (Password Policy - Behera)"
+		if value.Value != nil {
+			valueChildren := asn1.DecodePacket(value.Data.Bytes())
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+
+		sequence := value.Children[0]
+
+		for _, child := range sequence.Children {
+			if child.Tag == 0 {
+				//Warning
+				warningPacket := child.Children[0]
+				val, err := asn1.ParseInt64(warningPacket.Data.Bytes())
+				if err == nil {
+					if warningPacket.Tag == 0 {
+						//timeBeforeExpiration
+						c.Expire = val
+						warningPacket.Value = c.Expire
+					} else if warningPacket.Tag == 1 {
+						//graceAuthNsRemaining
+						c.Grace = val
+						warningPacket.Value = c.Grace
+					}
+				}
+			} else if child.Tag == 1 {
+				// Error
+				val, err := asn1.ParseInt64(child.Data.Bytes())
+				if err != nil {
+					val = -1
+				}
+				c.Error = int8(val)
+				child.Value = c.Error
+				c.ErrorString = BeheraPasswordPolicyErrorMap[c.Error]
+			}
+		}
+		return c
+	case ControlTypeServerSideSorting:
+		sequence := decodeControlValue(value)
+		if sequence == nil {
+			return nil
+		}
+		value.Description += " (Server Side Sorting)"
+		c := &ControlServerSideSorting{Criticality: Criticality}
+		for _, keySeq := range sequence.Children {
+			if len(keySeq.Children) == 0 {
+				return nil
+			}
+			key := &SortKey{AttributeType: asn1.DecodeString(keySeq.Children[0].Data.Bytes())}
+			for _, child := range keySeq.Children[1:] {
+				switch child.Tag {
+				case 0:
+					key.MatchingRule = asn1.DecodeString(child.Data.Bytes())
+				case 1:
+					key.Reverse = child.Data.Len() > 0 && child.Data.Bytes()[0] != 0
+				}
+			}
+			c.SortKeys = append(c.SortKeys, key)
+		}
+		return c
+	case ControlTypeServerSideSortingResult:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) == 0 {
+			return nil
+		}
+		value.Description += " (Server Side Sorting Result)"
+		result, ok := sequence.Children[0].Value.(int64)
+		if !ok {
+			return nil
+		}
+		c := &ControlServerSideSortingResult{Criticality: Criticality, Result: uint8(result)}
+		if len(sequence.Children) > 1 {
+			c.AttributeType = asn1.DecodeString(sequence.Children[1].Data.Bytes())
+		}
+		return c
+	case ControlTypeVLV:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) < 3 {
+			return nil
+		}
+		value.Description += " (Virtual List View)"
+		beforeCount, ok1 := sequence.Children[0].Value.(int64)
+		afterCount, ok2 := sequence.Children[1].Value.(int64)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		c := &ControlVLV{Criticality: Criticality, BeforeCount: uint32(beforeCount), AfterCount: uint32(afterCount)}
+		target := sequence.Children[2]
+		switch target.Tag {
+		case 0:
+			if len(target.Children) < 2 {
+				return nil
+			}
+			offset, ok1 := target.Children[0].Value.(int64)
+			contentCount, ok2 := target.Children[1].Value.(int64)
+			if !ok1 || !ok2 {
+				return nil
+			}
+			c.Offset = uint32(offset)
+			c.ContentCount = uint32(contentCount)
+		case 1:
+			c.GreaterThanOrEqual = append([]byte{}, target.Data.Bytes()...)
+		}
+		if len(sequence.Children) > 3 {
+			c.ContextID = append([]byte(nil), sequence.Children[3].Data.Bytes()...)
+		}
+		return c
+	case ControlTypeVLVResult:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) < 3 {
+			return nil
+		}
+		value.Description += " (Virtual List View Result)"
+		targetPosition, ok1 := sequence.Children[0].Value.(int64)
+		contentCount, ok2 := sequence.Children[1].Value.(int64)
+		result, ok3 := sequence.Children[2].Value.(int64)
+		if !ok1 || !ok2 || !ok3 {
+			return nil
+		}
+		c := &ControlVLVResult{
+			Criticality:    Criticality,
+			TargetPosition: uint32(targetPosition),
+			ContentCount:   uint32(contentCount),
+			Result:         uint8(result),
+		}
+		if len(sequence.Children) > 3 {
+			c.ContextID = append([]byte(nil), sequence.Children[3].Data.Bytes()...)
+		}
+		return c
+	case ControlTypeProxiedAuthorization:
+		c := &ControlProxiedAuthorization{Criticality: Criticality}
+		if value != nil {
+			value.Description += " (Proxied Authorization)"
+			c.AuthzID = asn1.DecodeString(value.Data.Bytes())
+		}
+		return c
+	case ControlTypeAuthzIDRequest:
+		return NewControlAuthzIDRequest(Criticality)
+	case ControlTypeAuthzIDResponse:
+		c := &ControlAuthzIDResponse{Criticality: Criticality}
+		if value != nil {
+			value.Description += " (Authorization Identity Response)"
+			c.AuthzID = asn1.DecodeString(value.Data.Bytes())
+		}
+		return c
+	case ControlTypeTransactionSpecification:
+		c := &ControlTransactionSpecification{Criticality: Criticality}
+		if value != nil {
+			value.Description += " (Transaction Specification)"
+			c.TransactionID = value.Data.Bytes()
+		}
+		return c
+	case ControlTypeSubentries:
+		c := &ControlSubentries{Criticality: Criticality}
+		if value == nil {
+			return c
+		}
+		value.Description += " (Subentries)"
+		if value.Value != nil {
+			valueChildren := asn1.DecodePacket(value.Data.Bytes())
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) > 0 {
+			c.Visibility, _ = value.Children[0].Value.(bool)
+		}
+		return c
+	case ControlTypePersistentSearch:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) < 3 {
+			return nil
+		}
+		value.Description += " (Persistent Search)"
+		changeTypes, ok1 := sequence.Children[0].Value.(int64)
+		changesOnly, ok2 := sequence.Children[1].Value.(bool)
+		returnECs, ok3 := sequence.Children[2].Value.(bool)
+		if !ok1 || !ok2 || !ok3 {
+			return nil
+		}
+		return &ControlPersistentSearch{
+			Criticality: Criticality,
+			ChangeTypes: int(changeTypes),
+			ChangesOnly: changesOnly,
+			ReturnECs:   returnECs,
+		}
+	case ControlTypeEntryChangeNotification:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) == 0 {
+			return nil
+		}
+		value.Description += " (Entry Change Notification)"
+		changeType, ok := sequence.Children[0].Value.(int64)
+		if !ok {
+			return nil
+		}
+		c := &ControlEntryChangeNotification{Criticality: Criticality, ChangeType: int(changeType)}
+		for _, child := range sequence.Children[1:] {
+			switch child.Tag {
+			case asn1.TagOctetString:
+				c.PreviousDN = asn1.DecodeString(child.Data.Bytes())
+			case asn1.TagInteger:
+				c.ChangeNumber, _ = child.Value.(int64)
+			}
+		}
+		return c
+	case ControlTypePreRead:
+		attributes, entry, ok := decodeReadEntryValue(value)
+		if !ok {
+			return nil
+		}
+		value.Description += " (Pre-Read)"
+		return &ControlPreRead{Criticality: Criticality, Attributes: attributes, Entry: entry}
+	case ControlTypePostRead:
+		attributes, entry, ok := decodeReadEntryValue(value)
+		if !ok {
+			return nil
+		}
+		value.Description += " (Post-Read)"
+		return &ControlPostRead{Criticality: Criticality, Attributes: attributes, Entry: entry}
+	case ControlTypeSyncRequest:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) == 0 {
+			return nil
+		}
+		value.Description += " (Sync Request)"
+		mode, ok := sequence.Children[0].Value.(int64)
+		if !ok {
+			return nil
+		}
+		c := &ControlSyncRequest{Criticality: Criticality, Mode: int(mode)}
+		for _, child := range sequence.Children[1:] {
+			switch child.Tag {
+			case asn1.TagOctetString:
+				c.Cookie = child.Data.Bytes()
+				child.Value = c.Cookie
+			case asn1.TagBoolean:
+				c.ReloadHint, _ = child.Value.(bool)
+			}
+		}
+		return c
+	case ControlTypeSyncState:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) < 2 {
+			return nil
+		}
+		value.Description += " (Sync State)"
+		state, ok := sequence.Children[0].Value.(int64)
+		if !ok {
+			return nil
+		}
+		c := &ControlSyncState{Criticality: Criticality, State: int(state)}
+		c.EntryUUID = sequence.Children[1].Data.Bytes()
+		sequence.Children[1].Value = c.EntryUUID
+		if len(sequence.Children) > 2 {
+			c.Cookie = sequence.Children[2].Data.Bytes()
+			sequence.Children[2].Value = c.Cookie
+		}
+		return c
+	case ControlTypeSyncDone:
+		sequence := decodeControlValue(value)
+		if sequence == nil {
+			return nil
+		}
+		value.Description += " (Sync Done)"
+		c := &ControlSyncDone{Criticality: Criticality}
+		for _, child := range sequence.Children {
+			switch child.Tag {
+			case asn1.TagOctetString:
+				c.Cookie = child.Data.Bytes()
+				child.Value = c.Cookie
+			case asn1.TagBoolean:
+				c.RefreshDeletes, _ = child.Value.(bool)
+			}
+		}
+		return c
+	case ControlTypeSessionTracking:
+		sequence := decodeControlValue(value)
+		if sequence == nil || len(sequence.Children) < 4 {
+			return nil
+		}
+		value.Description += " (Session Tracking)"
+		var fields [4]string
+		for i := range fields {
+			field, ok := sequence.Children[i].Value.(string)
+			if !ok {
+				return nil
+			}
+			fields[i] = field
+		}
+		return &ControlSessionTracking{
+			Criticality: Criticality,
+			SourceIP:    fields[0],
+			SourceName:  fields[1],
+			FormatOID:   fields[2],
+			Identifier:  fields[3],
+		}
 	case ControlTypeVChuPasswordMustChange:
-		c := &ControlVChuPasswordMustChange{MustChange: true}
+		c := &ControlVChuPasswordMustChange{Criticality: Criticality, MustChange: true}
 		return c
 	case ControlTypeVChuPasswordWarning:
-		c := &ControlVChuPasswordWarning{Expire: -1}
+		c := &ControlVChuPasswordWarning{Criticality: Criticality, Expire: -1}
 		expireStr := asn1.DecodeString(value.Data.Bytes())
 
 		expire, err := strconv.ParseInt(expireStr, 10, 64)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/gostores/encoding/asn1"
@@ -13,6 +14,15 @@ import (
 func TestControlPaging(t *testing.T) {
 	runControlTest(t, NewControlPaging(0))
 	runControlTest(t, NewControlPaging(100))
+
+	withCookie := NewControlPaging(100)
+	withCookie.SetCookie([]byte{0x00, 0x01, 0xfe, 0xff})
+	runControlTest(t, withCookie)
+
+	decoded := DecodeControl(asn1.DecodePacket(withCookie.Encode().Bytes())).(*ControlPaging)
+	if decoded.PagingSize != 100 || !bytes.Equal(decoded.Cookie, withCookie.Cookie) {
+		t.Errorf("paging control did not round-trip: %s", decoded)
+	}
 }
 
 func TestControlManageDsaIT(t *testing.T) {
@@ -20,6 +30,323 @@ func TestControlManageDsaIT(t *testing.T) {
 	runControlTest(t, NewControlManageDsaIT(false))
 }
 
+func TestControlMicrosoftShowDeleted(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftShowDeleted(true))
+	runControlTest(t, NewControlMicrosoftShowDeleted(false))
+}
+
+func TestControlMicrosoftShowRecycled(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftShowRecycled(true))
+	runControlTest(t, NewControlMicrosoftShowRecycled(false))
+}
+
+func TestControlMicrosoftTreeDelete(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftTreeDelete(true))
+	runControlTest(t, NewControlMicrosoftTreeDelete(false))
+}
+
+func TestControlMicrosoftPermissiveModify(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftPermissiveModify(true))
+	runControlTest(t, NewControlMicrosoftPermissiveModify(false))
+}
+
+func TestControlRelaxRules(t *testing.T) {
+	runControlTest(t, NewControlRelaxRules(true))
+	runControlTest(t, NewControlRelaxRules(false))
+}
+
+func TestControlNoOp(t *testing.T) {
+	runControlTest(t, NewControlNoOp(true))
+	runControlTest(t, NewControlNoOp(false))
+}
+
+func TestControlDontUseCopy(t *testing.T) {
+	runControlTest(t, NewControlDontUseCopy(true))
+	runControlTest(t, NewControlDontUseCopy(false))
+}
+
+func TestControlSubentries(t *testing.T) {
+	runControlTest(t, NewControlSubentries(true))
+	runControlTest(t, NewControlSubentries(false))
+	runControlTest(t, &ControlSubentries{Visibility: true})
+
+	control := DecodeControl(asn1.DecodePacket(NewControlSubentries(true).Encode().Bytes()))
+	if c, ok := control.(*ControlSubentries); !ok || !c.Visibility || !c.Criticality {
+		t.Errorf("unexpected decoded control %#v", control)
+	}
+}
+
+func TestControlAuthzID(t *testing.T) {
+	runControlTest(t, NewControlAuthzIDRequest(true))
+	runControlTest(t, NewControlAuthzIDRequest(false))
+	runControlTest(t, &ControlAuthzIDResponse{AuthzID: "dn:uid=jdoe,ou=people,dc=example,dc=com"})
+	runControlTest(t, &ControlAuthzIDResponse{})
+
+	result := &SimpleBindResult{Controls: []Control{DecodeControl(asn1.DecodePacket((&ControlAuthzIDResponse{AuthzID: "u:jdoe"}).Encode().Bytes()))}}
+	if authzID, ok := result.AuthzID(); !ok || authzID != "u:jdoe" {
+		t.Errorf("unexpected authorization identity %q, %t", authzID, ok)
+	}
+	if authzID, ok := (&SimpleBindResult{}).AuthzID(); ok {
+		t.Errorf("expected no authorization identity, got %q", authzID)
+	}
+}
+
+func TestControlServerSideSorting(t *testing.T) {
+	runControlTest(t, NewControlServerSideSorting([]*SortKey{{AttributeType: "cn"}}))
+	runControlTest(t, &ControlServerSideSorting{
+		Criticality: true,
+		SortKeys: []*SortKey{
+			{AttributeType: "sn", MatchingRule: "2.5.13.3", Reverse: true},
+			{AttributeType: "givenName"},
+		},
+	})
+
+	original := &ControlServerSideSorting{SortKeys: []*SortKey{
+		{AttributeType: "sn", MatchingRule: "2.5.13.3", Reverse: true},
+		{AttributeType: "givenName"},
+	}}
+	decoded := DecodeControl(asn1.DecodePacket(original.Encode().Bytes()))
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("sort control did not round-trip: %s", decoded)
+	}
+
+	testMalformedControl(t, ControlTypeServerSideSorting, nil, newSequencePacket(newSequencePacket()))
+}
+
+func TestControlServerSideSortingResult(t *testing.T) {
+	runControlTest(t, &ControlServerSideSortingResult{Result: LDAPResultSuccess})
+	runControlTest(t, &ControlServerSideSortingResult{Result: LDAPResultNoSuchAttribute, AttributeType: "sn"})
+
+	original := &ControlServerSideSortingResult{Result: LDAPResultInappropriateMatching, AttributeType: "jpegPhoto"}
+	decoded := DecodeControl(asn1.DecodePacket(original.Encode().Bytes()))
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("sort result control did not round-trip: %s", decoded)
+	}
+
+	testMalformedControl(t, ControlTypeServerSideSortingResult,
+		nil,
+		newSequencePacket(),
+		newSequencePacket(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "success", "")))
+	if FindControl([]Control{nil, original}, ControlTypeServerSideSortingResult) != original {
+		t.Errorf("expected FindControl to skip the controls which were not decoded")
+	}
+}
+
+func TestControlVLV(t *testing.T) {
+	runControlTest(t, NewControlVLVByOffset(0, 19, 1, 0))
+	runControlTest(t, NewControlVLVByValue(5, 5, "Sm"))
+
+	byOffset := NewControlVLVByOffset(2, 10, 42, 1000)
+	byOffset.Criticality = true
+	byOffset.SetContextID([]byte{0xca, 0xfe})
+	runControlTest(t, byOffset)
+
+	for _, original := range []*ControlVLV{byOffset, NewControlVLVByValue(1, 2, "Sm"), NewControlVLVByValue(0, 1, "")} {
+		decoded := DecodeControl(asn1.DecodePacket(original.Encode().Bytes()))
+		if !reflect.DeepEqual(decoded, original) {
+			t.Errorf("VLV control did not round-trip: %s", decoded)
+		}
+	}
+
+	count := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 1, "")
+	byOffsetTarget := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "")
+	byOffsetTarget.AppendChild(count)
+	testMalformedControl(t, ControlTypeVLV,
+		nil,
+		newSequencePacket(),
+		newSequencePacket(count, count),
+		newSequencePacket(count, asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "1", ""), byOffsetTarget),
+		newSequencePacket(count, count, byOffsetTarget))
+}
+
+func TestControlVLVResult(t *testing.T) {
+	original := &ControlVLVResult{TargetPosition: 42, ContentCount: 1000, Result: LDAPResultSuccess, ContextID: []byte("ctx")}
+	runControlTest(t, original)
+	runControlTest(t, &ControlVLVResult{Result: LDAPResultOther})
+
+	decoded := DecodeControl(asn1.DecodePacket(original.Encode().Bytes()))
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("VLV result control did not round-trip: %s", decoded)
+	}
+
+	position := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 42, "")
+	testMalformedControl(t, ControlTypeVLVResult,
+		nil,
+		newSequencePacket(),
+		newSequencePacket(position, position),
+		newSequencePacket(position, position, asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "success", "")))
+}
+
+func TestControlProxiedAuthorization(t *testing.T) {
+	runControlTest(t, NewControlProxiedAuthorization("dn:uid=jdoe,ou=people,dc=example,dc=com"))
+	runControlTest(t, NewControlProxiedAuthorization("u:jdoe"))
+	runControlTest(t, NewControlProxiedAuthorization(""))
+
+	encoded := NewControlProxiedAuthorization("u:jdoe").Encode()
+	if len(encoded.Children) != 3 || encoded.Children[1].Value != true {
+		t.Errorf("proxied authorization control must be critical by default")
+	}
+	decoded := DecodeControl(asn1.DecodePacket(encoded.Bytes())).(*ControlProxiedAuthorization)
+	if decoded.AuthzID != "u:jdoe" {
+		t.Errorf("unexpected authzId %q", decoded.AuthzID)
+	}
+}
+
+func TestControlPersistentSearch(t *testing.T) {
+	runControlTest(t, NewControlPersistentSearch(EntryChangeAll, true))
+	runControlTest(t, NewControlPersistentSearch(EntryChangeAdd|EntryChangeModify, false))
+	runControlTest(t, &ControlPersistentSearch{ChangeTypes: EntryChangeDelete})
+
+	changeTypes := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, EntryChangeAll, "")
+	changesOnly := asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, true, "")
+	testMalformedControl(t, ControlTypePersistentSearch,
+		nil,
+		newSequencePacket(),
+		newSequencePacket(changeTypes, changesOnly),
+		newSequencePacket(changeTypes, changesOnly, changeTypes))
+}
+
+func TestControlEntryChangeNotification(t *testing.T) {
+	runControlTest(t, &ControlEntryChangeNotification{ChangeType: EntryChangeAdd})
+	runControlTest(t, &ControlEntryChangeNotification{ChangeType: EntryChangeModify, ChangeNumber: 42})
+	runControlTest(t, &ControlEntryChangeNotification{ChangeType: EntryChangeModDN, PreviousDN: "cn=old,dc=example,dc=com", ChangeNumber: 7})
+
+	encoded := (&ControlEntryChangeNotification{ChangeType: EntryChangeModDN, PreviousDN: "cn=old,dc=example,dc=com"}).Encode()
+	decoded := DecodeControl(asn1.DecodePacket(encoded.Bytes())).(*ControlEntryChangeNotification)
+	if decoded.ChangeType != EntryChangeModDN || decoded.PreviousDN != "cn=old,dc=example,dc=com" || decoded.ChangeNumber != 0 {
+		t.Errorf("unexpected entry change notification %s", decoded)
+	}
+
+	changeType := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "")
+	changeType.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "add", ""))
+	for _, value := range []*asn1.Packet{nil, asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", ""), changeType} {
+		if c := DecodeControl(newControlPacket(ControlTypeEntryChangeNotification, value)); c != nil {
+			t.Errorf("expected malformed entry change notification not to be decoded, got %s", c)
+		}
+	}
+}
+
+// newSequencePacket returns a sequence of the given children
+func newSequencePacket(children ...*asn1.Packet) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "")
+	for _, child := range children {
+		packet.AppendChild(child)
+	}
+	return packet
+}
+
+// testMalformedControl checks that controls of the given type holding the
+// given values, or no value for nil, are not decoded
+func testMalformedControl(t *testing.T, controlType string, values ...*asn1.Packet) {
+	for _, value := range values {
+		if c := DecodeControl(newControlPacket(controlType, value)); c != nil {
+			t.Errorf("expected malformed %s control not to be decoded, got %s", ControlTypeMap[controlType], c)
+		}
+	}
+}
+
+// newControlPacket returns a control of the given type whose value holds the
+// given packet, or which has no value if it is nil
+func newControlPacket(controlType string, value *asn1.Packet) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Control")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, controlType, "Control Type"))
+	if value != nil {
+		packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(value.Bytes()), "Control Value"))
+	}
+	return asn1.DecodePacket(packet.Bytes())
+}
+
+func TestControlReadEntry(t *testing.T) {
+	entry := &Entry{
+		DN: "cn=test,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			{Name: "cn", Values: []string{"test"}},
+			{Name: "description", Values: []string{"one", "two"}},
+		},
+	}
+	runControlTest(t, NewControlPreRead())
+	runControlTest(t, NewControlPreRead("cn", "description"))
+	runControlTest(t, &ControlPreRead{Entry: entry})
+	runControlTest(t, NewControlPostRead("entryUUID"))
+	runControlTest(t, &ControlPostRead{Entry: entry})
+
+	decoded := DecodeControl(asn1.DecodePacket((&ControlPreRead{Entry: entry}).Encode().Bytes())).(*ControlPreRead)
+	if decoded.Entry == nil || decoded.Entry.DN != entry.DN || len(decoded.Entry.GetAttributeValues("description")) != 2 {
+		t.Errorf("unexpected decoded entry %v", decoded.Entry)
+	}
+
+	attribute := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 1, "")
+	dn := asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, entry.DN, "")
+	emptyEntry := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultEntry, nil, "")
+	shortEntry := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultEntry, nil, "")
+	shortEntry.AppendChild(dn)
+	invalidAttribute := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultEntry, nil, "")
+	invalidAttribute.AppendChild(dn)
+	invalidAttribute.AppendChild(newSequencePacket(newSequencePacket(dn)))
+	for _, controlType := range []string{ControlTypePreRead, ControlTypePostRead} {
+		testMalformedControl(t, controlType, nil, newSequencePacket(attribute), emptyEntry, shortEntry, invalidAttribute)
+	}
+}
+
+func TestControlSync(t *testing.T) {
+	uuid := []byte{0x59, 0x7a, 0xe2, 0xf6, 0x16, 0xa6, 0x10, 0x27, 0x98, 0xf4, 0xd2, 0x8b, 0x53, 0x65, 0xdc, 0x14}
+	runControlTest(t, NewControlSyncRequest(SyncModeRefreshOnly, nil))
+	runControlTest(t, NewControlSyncRequest(SyncModeRefreshAndPersist, []byte("rid=000,csn=20261015")))
+	runControlTest(t, &ControlSyncRequest{Mode: SyncModeRefreshOnly, Cookie: []byte("cookie"), ReloadHint: true})
+	runControlTest(t, &ControlSyncState{State: SyncStateAdd, EntryUUID: uuid})
+	runControlTest(t, &ControlSyncState{State: SyncStateDelete, EntryUUID: uuid, Cookie: []byte("cookie")})
+	runControlTest(t, &ControlSyncDone{})
+	runControlTest(t, &ControlSyncDone{Cookie: []byte("cookie"), RefreshDeletes: true})
+
+	encoded := (&ControlSyncState{State: SyncStateModify, EntryUUID: uuid, Cookie: []byte("cookie")}).Encode()
+	decoded := DecodeControl(asn1.DecodePacket(encoded.Bytes())).(*ControlSyncState)
+	if decoded.State != SyncStateModify || !bytes.Equal(decoded.EntryUUID, uuid) || string(decoded.Cookie) != "cookie" {
+		t.Errorf("unexpected sync state control %s", decoded)
+	}
+
+	state := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "")
+	state.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, SyncStateAdd, ""))
+	mode := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "")
+	mode.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "refreshOnly", ""))
+	for _, malformed := range []struct {
+		controlType string
+		value       *asn1.Packet
+	}{
+		{ControlTypeSyncRequest, nil},
+		{ControlTypeSyncRequest, mode},
+		{ControlTypeSyncState, nil},
+		{ControlTypeSyncState, state},
+		{ControlTypeSyncState, mode},
+		{ControlTypeSyncDone, nil},
+	} {
+		if c := DecodeControl(newControlPacket(malformed.controlType, malformed.value)); c != nil {
+			t.Errorf("expected malformed %s control not to be decoded, got %s", ControlTypeMap[malformed.controlType], c)
+		}
+	}
+}
+
+func TestControlSessionTracking(t *testing.T) {
+	runControlTest(t, NewControlSessionTracking("192.0.2.10", "client.example.com", SessionTrackingUsername, "jdoe"))
+	runControlTest(t, NewControlSessionTracking("2001:db8::1", "", SessionTrackingRADIUSAcctSessionID, "4f2a"))
+
+	field := asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "192.0.2.10", "")
+	testMalformedControl(t, ControlTypeSessionTracking,
+		nil,
+		newSequencePacket(),
+		newSequencePacket(field, field, field),
+		newSequencePacket(field, field, field, asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 1, "")))
+}
+
+func TestControlTransactionSpecification(t *testing.T) {
+	runControlTest(t, NewControlTransactionSpecification([]byte{0x00, 0x01, 0xff}))
+	runControlTest(t, NewControlTransactionSpecification([]byte("txn-1")))
+
+	encoded := NewControlTransactionSpecification([]byte("txn-1")).Encode()
+	if len(encoded.Children) != 3 || encoded.Children[1].Value != true {
+		t.Errorf("transaction specification control must be critical by default")
+	}
+}
+
 func TestControlString(t *testing.T) {
 	runControlTest(t, NewControlString("x", true, "y"))
 	runControlTest(t, NewControlString("x", true, ""))
@@ -95,3 +422,67 @@ func runAddControlDescriptions(t *testing.T, originalControl Control, childDescr
 	}
 
 }
+
+// newTestControls returns every built-in control, with all their fields set
+// and the given criticality when it is not fixed
+func newTestControls(criticality bool) []Control {
+	return []Control{
+		NewControlString("1.2.3.4", criticality, "value"),
+		&ControlPaging{Criticality: criticality, PagingSize: 100, Cookie: []byte("cookie")},
+		&ControlBeheraPasswordPolicy{Criticality: criticality, Expire: -1, Grace: -1, Error: -1},
+		&ControlBeheraPasswordPolicy{Criticality: criticality, Expire: 3600, Grace: -1, Error: -1},
+		&ControlBeheraPasswordPolicy{Criticality: criticality, Expire: -1, Grace: 2, Error: 2, ErrorString: BeheraPasswordPolicyErrorMap[2]},
+		&ControlVChuPasswordMustChange{Criticality: criticality, MustChange: true},
+		&ControlVChuPasswordWarning{Criticality: criticality, Expire: 86400},
+		NewControlManageDsaIT(criticality),
+		NewControlMicrosoftShowDeleted(criticality),
+		NewControlMicrosoftShowRecycled(criticality),
+		NewControlMicrosoftTreeDelete(criticality),
+		NewControlMicrosoftPermissiveModify(criticality),
+		NewControlRelaxRules(criticality),
+		NewControlNoOp(criticality),
+		NewControlDontUseCopy(criticality),
+		&ControlSubentries{Criticality: criticality, Visibility: true},
+		&ControlServerSideSorting{Criticality: criticality, SortKeys: []*SortKey{{AttributeType: "cn", MatchingRule: "2.5.13.3", Reverse: true}, {AttributeType: "uid"}}},
+		&ControlServerSideSortingResult{Criticality: criticality, Result: LDAPResultNoSuchAttribute, AttributeType: "cn"},
+		&ControlVLV{Criticality: criticality, BeforeCount: 1, AfterCount: 10, Offset: 5, ContentCount: 100, ContextID: []byte("context")},
+		&ControlVLV{Criticality: criticality, AfterCount: 10, GreaterThanOrEqual: []byte("m")},
+		&ControlVLVResult{Criticality: criticality, TargetPosition: 5, ContentCount: 100, Result: LDAPResultSuccess, ContextID: []byte("context")},
+		&ControlProxiedAuthorization{Criticality: criticality, AuthzID: "dn:uid=jdoe,ou=people,dc=example,dc=com"},
+		NewControlAuthzIDRequest(criticality),
+		&ControlAuthzIDResponse{Criticality: criticality, AuthzID: "u:jdoe"},
+		&ControlTransactionSpecification{Criticality: criticality, TransactionID: []byte("transaction")},
+		&ControlPersistentSearch{Criticality: criticality, ChangeTypes: EntryChangeAdd | EntryChangeDelete, ChangesOnly: true, ReturnECs: true},
+		&ControlEntryChangeNotification{Criticality: criticality, ChangeType: EntryChangeModDN, PreviousDN: "cn=old,dc=example,dc=com", ChangeNumber: 7},
+		&ControlPreRead{Criticality: criticality, Attributes: []string{"cn", "mail"}},
+		&ControlPostRead{Criticality: criticality, Attributes: []string{"cn"}},
+		&ControlPostRead{Criticality: criticality, Entry: NewEntry("cn=x,dc=example,dc=com", map[string][]string{"cn": {"x"}})},
+		&ControlSyncRequest{Criticality: criticality, Mode: SyncModeRefreshAndPersist, Cookie: []byte("cookie"), ReloadHint: true},
+		&ControlSyncState{Criticality: criticality, State: SyncStateModify, EntryUUID: []byte("0123456789abcdef"), Cookie: []byte("cookie")},
+		&ControlSyncDone{Criticality: criticality, Cookie: []byte("cookie"), RefreshDeletes: true},
+		&ControlSessionTracking{Criticality: criticality, SourceIP: "192.0.2.1", SourceName: "client.example.com", FormatOID: SessionTrackingUsername, Identifier: "jdoe"},
+	}
+}
+
+func TestControlSymmetry(t *testing.T) {
+	tested := make(map[string]bool)
+	for _, criticality := range []bool{false, true} {
+		for _, control := range newTestControls(criticality) {
+			tested[control.GetControlType()] = true
+			runControlTest(t, control)
+
+			decoded := DecodeControl(asn1.DecodePacket(control.Encode().Bytes()))
+			if !reflect.DeepEqual(decoded, control) {
+				t.Errorf("%T: expected %s, got %s", control, control, decoded)
+			}
+			if !strings.Contains(control.String(), fmt.Sprintf("Criticality: %t", criticality)) {
+				t.Errorf("%T: expected the criticality in %s", control, control)
+			}
+		}
+	}
+	for controlType, name := range ControlTypeMap {
+		if !tested[controlType] {
+			t.Errorf("no round-trip test of the %s control", name)
+		}
+	}
+}
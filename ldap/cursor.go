@@ -0,0 +1,166 @@
+// File contains the cursor reading the entries of a search one at a time
+
+package ldap
+
+import (
+	"context"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// SearchCursor reads the entries of a search one at a time. The entries not
+// read yet are held in memory without holding back the other operations
+// performed on the connection, see Conn, so a paging size bounds the memory
+// used by a slow consumer: the next page is only requested once the entries
+// of the previous one have been read.
+//
+//	cursor := conn.SearchCursor(searchRequest, 500)
+//	defer cursor.Close()
+//	for cursor.Next() {
+//		entry := cursor.Entry()
+//		...
+//	}
+//	if err := cursor.Err(); err != nil {
+//		...
+//	}
+type SearchCursor struct {
+	conn          *Conn
+	ctx           context.Context
+	searchRequest *SearchRequest
+	pagingControl *ControlPaging
+	// msgCtx is the message of the outstanding search, nil between pages
+	msgCtx    *messageContext
+	entry     *Entry
+	referrals []string
+	controls  []Control
+	err       error
+	done      bool
+}
+
+// SearchCursor starts the given search and returns a cursor over its entries.
+// If pagingSize is not zero, the entries are requested in pages of that size
+// as described for SearchWithPaging, the next page being requested once the
+// entries of the previous one have been read.
+func (l *Conn) SearchCursor(searchRequest *SearchRequest, pagingSize uint32) *SearchCursor {
+	return l.SearchCursorContext(context.Background(), searchRequest, pagingSize)
+}
+
+// SearchCursorContext is like SearchCursor, but the search is abandoned and
+// Err returns ctx.Err() if ctx is done before the search completes.
+func (l *Conn) SearchCursorContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) *SearchCursor {
+	cursor := &SearchCursor{
+		conn:          l,
+		ctx:           ctx,
+		searchRequest: searchRequest,
+	}
+	if pagingSize > 0 {
+		cursor.pagingControl, cursor.err = pagingControlFor(searchRequest, pagingSize)
+	}
+	if cursor.err == nil {
+		cursor.msgCtx, cursor.err = l.sendSearch(searchRequest)
+	}
+	return cursor
+}
+
+// Next reads the next entry of the search, requesting the next page if
+// needed. It returns false once all entries have been read or an error
+// occurred, which is returned by Err.
+func (c *SearchCursor) Next() bool {
+	c.entry = nil
+	for c.err == nil && !c.done {
+		if c.msgCtx == nil {
+			c.msgCtx, c.err = c.conn.sendSearch(c.searchRequest)
+			continue
+		}
+
+		c.conn.Debug.Printf("%d: waiting for response", c.msgCtx.id)
+		packet, err := c.conn.receivePacket(c.ctx, c.msgCtx)
+		c.conn.Debug.Printf("%d: got response %p", c.msgCtx.id, packet)
+		if err != nil {
+			c.finish()
+			c.err = err
+			return false
+		}
+
+		if c.conn.Debug {
+			if err := addLDAPDescriptions(packet); err != nil {
+				c.finish()
+				c.err = err
+				return false
+			}
+			asn1.PrintPacket(packet)
+		}
+
+		switch packet.Children[1].Tag {
+		case ApplicationSearchResultEntry:
+			c.entry = decodeEntry(packet.Children[1])
+			return true
+		case ApplicationSearchResultReference:
+			c.referrals = append(c.referrals, packet.Children[1].Children[0].Value.(string))
+		case ApplicationSearchResultDone:
+			c.finish()
+			c.done = true
+			resultCode, _ := getLDAPResultCode(packet)
+			if resultCode == LDAPResultReferral {
+				c.referrals = append(c.referrals, decodeReferral(packet.Children[1])...)
+			}
+			if resultCode != 0 {
+				c.err = newResultError(packet)
+				return false
+			}
+			c.controls = nil
+			if len(packet.Children) == 3 {
+				for _, child := range packet.Children[2].Children {
+					c.controls = append(c.controls, DecodeControl(child))
+				}
+			}
+			if c.pagingControl == nil {
+				return false
+			}
+			if pagingResult, ok := FindControl(c.controls, ControlTypePaging).(*ControlPaging); ok && len(pagingResult.Cookie) > 0 {
+				c.pagingControl.SetCookie(pagingResult.Cookie)
+				c.done = false
+			}
+		}
+	}
+	return false
+}
+
+// Entry returns the entry read by the last call to Next
+func (c *SearchCursor) Entry() *Entry {
+	return c.entry
+}
+
+// Referrals returns the referrals received so far
+func (c *SearchCursor) Referrals() []string {
+	return c.referrals
+}
+
+// Controls returns the controls sent with the result of the last page, or of
+// the search if it is not paged
+func (c *SearchCursor) Controls() []Control {
+	return c.controls
+}
+
+// Err returns the error which stopped Next, if any
+func (c *SearchCursor) Err() error {
+	return c.err
+}
+
+// Close abandons the search if it has not completed. Close does not need to
+// be called once Next returned false.
+func (c *SearchCursor) Close() error {
+	c.done = true
+	if c.msgCtx == nil {
+		return nil
+	}
+	id := c.msgCtx.id
+	c.finish()
+	return c.conn.abandon(id)
+}
+
+// finish releases the message of the outstanding search
+func (c *SearchCursor) finish() {
+	c.conn.finishMessage(c.msgCtx)
+	c.msgCtx = nil
+}
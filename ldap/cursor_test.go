@@ -0,0 +1,129 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestSearchCursor(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	cookies := servePages(t, ptc, [][]string{
+		{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"},
+		{"cn=c,dc=example,dc=com"},
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	cursor := conn.SearchCursor(searchRequest, 2)
+	defer cursor.Close()
+	var dns []string
+	runWithTimeout(t, 5*time.Second, func() {
+		for cursor.Next() {
+			dns = append(dns, cursor.Entry().DN)
+		}
+	})
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(dns, []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com", "cn=c,dc=example,dc=com"}) {
+		t.Errorf("unexpected entries %v", dns)
+	}
+	for i, expected := range []string{"", "page-1"} {
+		if cookie := <-cookies; string(cookie) != expected {
+			t.Errorf("request %d: expected cookie %q, got %q", i, expected, cookie)
+		}
+	}
+	if cursor.Next() {
+		t.Error("expected no more entries")
+	}
+}
+
+func TestSearchCursorError(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		messageID := p.Children[0].Value.(int64)
+		return []*asn1.Packet{
+			newEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+			newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSizeLimitExceeded, ""),
+		}
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 1, 0, false, "(objectClass=*)", nil, nil)
+	cursor := conn.SearchCursor(searchRequest, 0)
+	runWithTimeout(t, 5*time.Second, func() {
+		if !cursor.Next() || cursor.Entry().DN != "cn=a,dc=example,dc=com" {
+			t.Fatalf("expected an entry, got %v", cursor.Err())
+		}
+		if cursor.Next() {
+			t.Fatal("expected no more entries")
+		}
+	})
+	if !IsErrorWithCode(cursor.Err(), LDAPResultSizeLimitExceeded) {
+		t.Errorf("expected size limit exceeded, got %v", cursor.Err())
+	}
+}
+
+func TestSearchCursorCancel(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	abandoned := make(chan []byte, 1)
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+				newEntryPacket(messageID, "cn=b,dc=example,dc=com"),
+			}
+		})
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Errorf("unable to receive request packet: %s", err)
+			return
+		}
+		if request.Children[1].Tag == ApplicationAbandonRequest {
+			abandoned <- request.Children[1].Data.Bytes()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	cursor := conn.SearchCursorContext(ctx, searchRequest, 0)
+	runWithTimeout(t, 5*time.Second, func() {
+		if !cursor.Next() {
+			t.Fatalf("expected an entry, got %v", cursor.Err())
+		}
+		// the second entry waits in the buffer of the search
+		cancel()
+		if cursor.Next() && cursor.Next() {
+			t.Fatal("expected the search to stop")
+		}
+		if cursor.Err() != context.Canceled {
+			t.Errorf("expected context canceled, got %v", cursor.Err())
+		}
+		searchID := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(1), "MessageID")
+		if messageID := <-abandoned; !bytes.Equal(messageID, searchID.Data.Bytes()) {
+			t.Errorf("expected search 1 to be abandoned, got %x", messageID)
+		}
+	})
+}
@@ -6,8 +6,8 @@
 package ldap
 
 import (
-	"errors"
-	"log"
+	"context"
+	"sort"
 
 	"github.com/gostores/encoding/asn1"
 )
@@ -26,6 +26,20 @@ func (d DelRequest) encode() *asn1.Packet {
 	return request
 }
 
+func (d DelRequest) controls() []Control {
+	return d.Controls
+}
+
+func (d DelRequest) responseTag() asn1.Tag {
+	return ApplicationDelResponse
+}
+
+// decodeDelRequest decodes the protocol operation of a delete request received
+// by a Server
+func decodeDelRequest(packet *asn1.Packet) *DelRequest {
+	return &DelRequest{DN: decodeOctetString(packet)}
+}
+
 // NewDelRequest creates a delete request for the given DN and controls
 func NewDelRequest(DN string,
 	Controls []Control) *DelRequest {
@@ -37,48 +51,66 @@ func NewDelRequest(DN string,
 
 // Del executes the given delete request
 func (l *Conn) Del(delRequest *DelRequest) error {
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
-	packet.AppendChild(delRequest.encode())
-	if delRequest.Controls != nil {
-		packet.AppendChild(encodeControls(delRequest.Controls))
-	}
+	return l.DelContext(context.Background(), delRequest)
+}
 
-	l.Debug.PrintPacket(packet)
+// DelContext executes the given delete request. If ctx is done before the
+// server responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) DelContext(ctx context.Context, delRequest *DelRequest) error {
+	_, err := l.DelWithResultContext(ctx, delRequest)
+	return err
+}
 
-	msgCtx, err := l.sendMessage(packet)
-	if err != nil {
+// DelWithResult performs the DelRequest and returns the controls sent back by the
+// server, such as the entry requested with a pre-read or post-read control.
+func (l *Conn) DelWithResult(delRequest *DelRequest) (*UpdateResult, error) {
+	return l.DelWithResultContext(context.Background(), delRequest)
+}
+
+// DelWithResultContext is like DelWithResult, but abandons the request and
+// returns ctx.Err() if ctx is done before the server responds.
+func (l *Conn) DelWithResultContext(ctx context.Context, delRequest *DelRequest) (*UpdateResult, error) {
+	return newUpdateResult(l.DoContext(ctx, delRequest))
+}
+
+// DelSubtree deletes the entry with the given DN and all of its subordinates
+func (l *Conn) DelSubtree(dn string) error {
+	return l.DelSubtreeContext(context.Background(), dn)
+}
+
+// DelSubtreeContext deletes the entry with the given DN and all of its
+// subordinates. It first asks the server to do so with the tree delete
+// control. If the server does not support the control, the subtree is
+// searched and its entries are deleted one by one, starting with the deepest.
+// This is not atomic: if an error occurs, part of the subtree may be deleted.
+func (l *Conn) DelSubtreeContext(ctx context.Context, dn string) error {
+	err := l.DelContext(ctx, NewDelRequest(dn, []Control{NewControlMicrosoftTreeDelete(true)}))
+	if !IsErrorWithCode(err, LDAPResultUnavailableCriticalExtension) {
 		return err
 	}
-	defer l.finishMessage(msgCtx)
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	searchRequest := NewSearchRequest(dn, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"1.1"}, nil)
+	result, err := l.SearchContext(ctx, searchRequest)
 	if err != nil {
 		return err
 	}
 
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
+	depths := make(map[string]int, len(result.Entries))
+	for _, entry := range result.Entries {
+		parsed, err := ParseDN(entry.DN)
+		if err != nil {
 			return err
 		}
-		asn1.PrintPacket(packet)
+		depths[entry.DN] = len(parsed.RDNs)
 	}
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return depths[result.Entries[i].DN] > depths[result.Entries[j].DN]
+	})
 
-	if packet.Children[1].Tag == ApplicationDelResponse {
-		resultCode, resultDescription := getLDAPResultCode(packet)
-		if resultCode != 0 {
-			return NewError(resultCode, errors.New(resultDescription))
+	for _, entry := range result.Entries {
+		if err := l.DelContext(ctx, NewDelRequest(entry.DN, nil)); err != nil {
+			return err
 		}
-	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
 	}
-
-	l.Debug.Printf("%d: returning", msgCtx.id)
 	return nil
 }
@@ -0,0 +1,91 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestDelSubtreeControl(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var request *asn1.Packet
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		request = p
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.DelSubtree("ou=people,dc=example,dc=com"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	if len(request.Children) != 3 {
+		t.Fatalf("expected delete request to carry controls")
+	}
+	if _, ok := DecodeControl(request.Children[2].Children[0]).(*ControlMicrosoftTreeDelete); !ok {
+		t.Errorf("expected tree delete control, got %s", DecodeControl(request.Children[2].Children[0]))
+	}
+}
+
+func TestDelSubtreeFallback(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	deleted := make(chan string, 4)
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultUnavailableCriticalExtension, "")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "ou=people,dc=example,dc=com"),
+				newEntryPacket(messageID, "ou=staff,ou=people,dc=example,dc=com"),
+				newEntryPacket(messageID, "uid=jdoe,ou=staff,ou=people,dc=example,dc=com"),
+				newEntryPacket(messageID, "uid=asmith,ou=people,dc=example,dc=com"),
+				newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+			}
+		})
+		for i := 0; i < 4; i++ {
+			serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+				deleted <- string(p.Children[1].Data.Bytes())
+				return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+			})
+		}
+		close(deleted)
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.DelSubtree("ou=people,dc=example,dc=com"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	expected := []string{
+		"uid=jdoe,ou=staff,ou=people,dc=example,dc=com",
+		"ou=staff,ou=people,dc=example,dc=com",
+		"uid=asmith,ou=people,dc=example,dc=com",
+		"ou=people,dc=example,dc=com",
+	}
+	i := 0
+	for dn := range deleted {
+		if i >= len(expected) || dn != expected[i] {
+			t.Errorf("unexpected deletion %d of %q", i, dn)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Errorf("expected %d deletions, got %d", len(expected), i)
+	}
+}
@@ -0,0 +1,139 @@
+// File contains the computation of the changes turning an entry into another
+
+package ldap
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DiffStrategy is how the values of an attribute are compared by DiffEntries
+type DiffStrategy int
+
+// Strategies of DiffOptions
+const (
+	// DiffValues deletes the values which are not desired and adds the
+	// missing ones, replacing the attribute if none of its values is kept
+	DiffValues DiffStrategy = iota
+	// DiffReplace replaces the attribute if its values differ, or are in a
+	// different order, e.g. for attributes whose values are ordered
+	DiffReplace
+	// DiffAddOnly adds the missing values and keeps the other ones, e.g. for
+	// the members of a group also managed elsewhere
+	DiffAddOnly
+	// DiffIgnore leaves the attribute unchanged
+	DiffIgnore
+)
+
+// DiffOptions set how the attributes are compared by Diff
+type DiffOptions struct {
+	// Strategy is the strategy of the attributes without one in Strategies,
+	// DiffValues by default
+	Strategy DiffStrategy
+	// Strategies are the strategies of attributes, by case insensitive name
+	Strategies map[string]DiffStrategy
+}
+
+// strategy returns the strategy of the attribute
+func (o DiffOptions) strategy(name string) DiffStrategy {
+	for attribute, strategy := range o.Strategies {
+		if strings.EqualFold(attribute, name) {
+			return strategy
+		}
+	}
+	return o.Strategy
+}
+
+// DiffEntries returns the request modifying the current entry into the
+// desired one, with the default DiffOptions, or nil if there is nothing to
+// change. See DiffOptions.Diff.
+func DiffEntries(current, desired *Entry) *ModifyRequest {
+	return DiffOptions{}.Diff(current, desired)
+}
+
+// Diff returns the request modifying the current entry into the desired one,
+// with as few changes as possible, or nil if there is nothing to change. Only
+// the attributes of the desired entry are changed, in the order of the
+// desired entry: the other attributes of the current entry are left as is,
+// and a desired attribute without values is deleted. Values are compared
+// exactly, so that a value differing in case is replaced.
+func (o DiffOptions) Diff(current, desired *Entry) *ModifyRequest {
+	request := NewModifyRequest(current.DN)
+	for _, attribute := range desired.Attributes {
+		strategy := o.strategy(attribute.Name)
+		if strategy == DiffIgnore {
+			continue
+		}
+		var currentValues []string
+		if currentAttribute := current.getAttribute(attribute.Name); currentAttribute != nil {
+			currentValues = currentAttribute.Values
+		}
+		desiredValues := uniqueValues(attribute.Values)
+
+		if len(desiredValues) == 0 {
+			if len(currentValues) > 0 && strategy != DiffAddOnly {
+				request.Delete(attribute.Name, nil)
+			}
+			continue
+		}
+		if len(currentValues) == 0 {
+			request.Add(attribute.Name, desiredValues)
+			continue
+		}
+
+		currentValues = uniqueValues(currentValues)
+		added := missingValues(desiredValues, currentValues)
+		deleted := missingValues(currentValues, desiredValues)
+		switch {
+		case strategy == DiffReplace:
+			if !reflect.DeepEqual(currentValues, desiredValues) {
+				request.Replace(attribute.Name, desiredValues)
+			}
+		case strategy == DiffAddOnly:
+			if len(added) > 0 {
+				request.Add(attribute.Name, added)
+			}
+		case len(deleted) == len(currentValues):
+			request.Replace(attribute.Name, desiredValues)
+		default:
+			if len(deleted) > 0 {
+				request.Delete(attribute.Name, deleted)
+			}
+			if len(added) > 0 {
+				request.Add(attribute.Name, added)
+			}
+		}
+	}
+	if len(request.Changes) == 0 {
+		return nil
+	}
+	return request
+}
+
+// uniqueValues returns the values without duplicates, in order
+func uniqueValues(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// missingValues returns the values which are not in other, in order
+func missingValues(values, other []string) []string {
+	present := make(map[string]bool, len(other))
+	for _, value := range other {
+		present[value] = true
+	}
+	var missing []string
+	for _, value := range values {
+		if !present[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,65 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffEntries(t *testing.T) {
+	current := NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{
+		"cn":          {"John Doe"},
+		"mail":        {"jdoe@example.com", "john@example.com"},
+		"memberOf":    {"cn=staff,dc=example,dc=com"},
+		"description": {"temporary"},
+		"objectClass": {"inetOrgPerson"},
+	})
+	desired := &Entry{DN: "uid=jdoe,dc=example,dc=com", Attributes: []*EntryAttribute{
+		NewEntryAttribute("CN", []string{"John Doe"}),
+		NewEntryAttribute("mail", []string{"john@example.com", "j.doe@example.com"}),
+		NewEntryAttribute("telephoneNumber", []string{"+1 555 0100"}),
+		NewEntryAttribute("description", nil),
+		NewEntryAttribute("memberOf", []string{"cn=admins,dc=example,dc=com"}),
+	}}
+
+	request := DiffEntries(current, desired)
+	expected := []Change{
+		{DeleteAttribute, PartialAttribute{Type: "mail", Vals: []string{"jdoe@example.com"}}},
+		{AddAttribute, PartialAttribute{Type: "mail", Vals: []string{"j.doe@example.com"}}},
+		{AddAttribute, PartialAttribute{Type: "telephoneNumber", Vals: []string{"+1 555 0100"}}},
+		{DeleteAttribute, PartialAttribute{Type: "description"}},
+		{ReplaceAttribute, PartialAttribute{Type: "memberOf", Vals: []string{"cn=admins,dc=example,dc=com"}}},
+	}
+	if request == nil || request.DN != current.DN || !reflect.DeepEqual(request.Changes, expected) {
+		t.Errorf("unexpected request %#v", request)
+	}
+
+	options := DiffOptions{Strategies: map[string]DiffStrategy{
+		"Mail":        DiffReplace,
+		"memberof":    DiffAddOnly,
+		"description": DiffIgnore,
+	}}
+	request = options.Diff(current, desired)
+	expected = []Change{
+		{ReplaceAttribute, PartialAttribute{Type: "mail", Vals: []string{"john@example.com", "j.doe@example.com"}}},
+		{AddAttribute, PartialAttribute{Type: "telephoneNumber", Vals: []string{"+1 555 0100"}}},
+		{AddAttribute, PartialAttribute{Type: "memberOf", Vals: []string{"cn=admins,dc=example,dc=com"}}},
+	}
+	if request == nil || !reflect.DeepEqual(request.Changes, expected) {
+		t.Errorf("unexpected request with strategies %#v", request)
+	}
+
+	// values are compared regardless of their order, unless replaced
+	reordered := &Entry{DN: current.DN, Attributes: []*EntryAttribute{
+		NewEntryAttribute("mail", []string{"john@example.com", "jdoe@example.com"}),
+	}}
+	if request := DiffEntries(current, reordered); request != nil {
+		t.Errorf("expected no change for values in a different order, got %#v", request)
+	}
+	if request := (DiffOptions{Strategy: DiffReplace}).Diff(current, reordered); request == nil || len(request.Changes) != 1 || request.Changes[0].Operation != ReplaceAttribute {
+		t.Errorf("expected the values in a different order to be replaced, got %#v", request)
+	}
+
+	if request := DiffEntries(current, current); request != nil {
+		t.Errorf("expected no change between identical entries, got %#v", request)
+	}
+}
@@ -0,0 +1,206 @@
+// File contains the DIGEST-MD5 SASL mechanism
+//
+// https://tools.ietf.org/html/rfc2831
+
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	enchex "encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DigestMD5BindRequest represents a DIGEST-MD5 SASL bind
+type DigestMD5BindRequest struct {
+	// Host is the host name of the server, used in the digest-uri of the response
+	Host string
+	// Username is the authentication identity, usually a user name rather than a DN
+	Username string
+	// Password is the password of the user
+	Password string
+	// Controls are optional controls to send with the bind request
+	Controls []Control
+}
+
+// DigestMD5Bind performs the DIGEST-MD5 SASL bind defined in the given request
+func (l *Conn) DigestMD5Bind(digestMD5BindRequest *DigestMD5BindRequest) (*SASLBindResult, error) {
+	return l.DigestMD5BindContext(context.Background(), digestMD5BindRequest)
+}
+
+// DigestMD5BindContext performs the DIGEST-MD5 SASL bind defined in the given
+// request. If ctx is done before the server responds, the connection is closed
+// and ctx.Err() is returned.
+func (l *Conn) DigestMD5BindContext(ctx context.Context, digestMD5BindRequest *DigestMD5BindRequest) (*SASLBindResult, error) {
+	if digestMD5BindRequest.Password == "" {
+		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
+	}
+	mechanism := &digestMD5{
+		host:     digestMD5BindRequest.Host,
+		username: digestMD5BindRequest.Username,
+		password: digestMD5BindRequest.Password,
+	}
+	return l.SASLBindContext(ctx, mechanism, digestMD5BindRequest.Controls)
+}
+
+// MD5Bind performs a DIGEST-MD5 SASL bind with the given host name, username and password
+func (l *Conn) MD5Bind(host, username, password string) error {
+	req := &DigestMD5BindRequest{
+		Host:     host,
+		Username: username,
+		Password: password,
+	}
+	_, err := l.DigestMD5Bind(req)
+	return err
+}
+
+// digestMD5 implements the client side of the DIGEST-MD5 SASL mechanism with
+// the "auth" quality of protection
+type digestMD5 struct {
+	host     string
+	username string
+	password string
+
+	// rspauth is the server response expected in the final challenge
+	rspauth string
+	// authenticated is set once the server response has been verified
+	authenticated bool
+}
+
+func (d *digestMD5) Name() string {
+	return "DIGEST-MD5"
+}
+
+func (d *digestMD5) Start() ([]byte, error) {
+	return nil, nil
+}
+
+func (d *digestMD5) Next(challenge []byte) ([]byte, error) {
+	directives, err := parseDigestChallenge(string(challenge))
+	if err != nil {
+		return nil, err
+	}
+
+	if d.rspauth != "" {
+		if d.authenticated || directives["rspauth"] != d.rspauth {
+			return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: DIGEST-MD5 server authentication failed"))
+		}
+		d.authenticated = true
+		return []byte{}, nil
+	}
+
+	nonce := directives["nonce"]
+	if nonce == "" {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: DIGEST-MD5 challenge without nonce"))
+	}
+	if qop, ok := directives["qop"]; ok && !containsToken(qop, "auth") {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: DIGEST-MD5 quality of protection %q not supported", qop))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return nil, err
+	}
+	cnonce := enchex.EncodeToString(cnonceBytes)
+	realm := directives["realm"]
+	digestURI := "ldap/" + d.host
+	nc := "00000001"
+
+	ha1 := digestMD5HA1(d.username, realm, d.password, nonce, cnonce)
+	response := digestMD5Response(ha1, nonce, nc, cnonce, "AUTHENTICATE:"+digestURI)
+	d.rspauth = digestMD5Response(ha1, nonce, nc, cnonce, ":"+digestURI)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `username="%s"`, quoteDigestValue(d.username))
+	if realm != "" {
+		fmt.Fprintf(&b, `,realm="%s"`, quoteDigestValue(realm))
+	}
+	fmt.Fprintf(&b, `,nonce="%s",cnonce="%s",nc=%s,qop=auth,digest-uri="%s",response=%s`,
+		quoteDigestValue(nonce), cnonce, nc, quoteDigestValue(digestURI), response)
+	if directives["charset"] == "utf-8" {
+		b.WriteString(",charset=utf-8")
+	}
+	return []byte(b.String()), nil
+}
+
+// digestMD5HA1 returns H(A1) as defined in https://tools.ietf.org/html/rfc2831#section-2.1.2.1
+func digestMD5HA1(username, realm, password, nonce, cnonce string) string {
+	secret := md5.Sum([]byte(username + ":" + realm + ":" + password))
+	a1 := string(secret[:]) + ":" + nonce + ":" + cnonce
+	ha1 := md5.Sum([]byte(a1))
+	return enchex.EncodeToString(ha1[:])
+}
+
+// digestMD5Response returns the response-value for the given A2, as defined in
+// https://tools.ietf.org/html/rfc2831#section-2.1.2.1
+func digestMD5Response(ha1, nonce, nc, cnonce, a2 string) string {
+	ha2 := md5.Sum([]byte(a2))
+	kd := md5.Sum([]byte(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":auth:" + enchex.EncodeToString(ha2[:])))
+	return enchex.EncodeToString(kd[:])
+}
+
+// parseDigestChallenge parses the comma separated directives of a DIGEST-MD5
+// challenge. Only the first value of repeated directives is kept.
+func parseDigestChallenge(challenge string) (map[string]string, error) {
+	directives := make(map[string]string)
+	for i := 0; i < len(challenge); {
+		// skip separators
+		for i < len(challenge) && (challenge[i] == ',' || challenge[i] == ' ' || challenge[i] == '\t') {
+			i++
+		}
+		if i == len(challenge) {
+			break
+		}
+
+		eq := strings.IndexByte(challenge[i:], '=')
+		if eq < 0 {
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: malformed DIGEST-MD5 challenge %q", challenge))
+		}
+		key := strings.ToLower(strings.TrimSpace(challenge[i : i+eq]))
+		i += eq + 1
+
+		var value bytes.Buffer
+		if i < len(challenge) && challenge[i] == '"' {
+			i++
+			for ; i < len(challenge) && challenge[i] != '"'; i++ {
+				if challenge[i] == '\\' && i+1 < len(challenge) {
+					i++
+				}
+				value.WriteByte(challenge[i])
+			}
+			if i == len(challenge) {
+				return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: unterminated value in DIGEST-MD5 challenge %q", challenge))
+			}
+			i++
+		} else {
+			for ; i < len(challenge) && challenge[i] != ','; i++ {
+				value.WriteByte(challenge[i])
+			}
+		}
+
+		if _, ok := directives[key]; !ok {
+			directives[key] = strings.TrimSpace(value.String())
+		}
+	}
+	return directives, nil
+}
+
+// quoteDigestValue escapes the characters which are not allowed in a quoted string
+func quoteDigestValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return strings.Replace(value, `"`, `\"`, -1)
+}
+
+// containsToken reports whether the comma separated list contains the token
+func containsToken(list, token string) bool {
+	for _, t := range strings.Split(list, ",") {
+		if strings.TrimSpace(t) == token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// TestDigestMD5Response checks the digest computation against the example of
+// https://tools.ietf.org/html/rfc2831#section-4
+func TestDigestMD5Response(t *testing.T) {
+	ha1 := digestMD5HA1("chris", "elwood.innosoft.com", "secret", "OA6MG9tEQGm2hh", "OA6MHXh6VqTrRk")
+	if response := digestMD5Response(ha1, "OA6MG9tEQGm2hh", "00000001", "OA6MHXh6VqTrRk", "AUTHENTICATE:imap/elwood.innosoft.com"); response != "d388dad90d4bbd760a152321f2143af7" {
+		t.Errorf("unexpected response %s", response)
+	}
+	if rspauth := digestMD5Response(ha1, "OA6MG9tEQGm2hh", "00000001", "OA6MHXh6VqTrRk", ":imap/elwood.innosoft.com"); rspauth != "ea40f60335c427b5527b84dbabcdfffd" {
+		t.Errorf("unexpected rspauth %s", rspauth)
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	directives, err := parseDigestChallenge(`realm="elwood.innosoft.com",nonce="OA6MG9tEQGm2hh",qop="auth,auth-int",algorithm=md5-sess, charset=utf-8,realm="other",cipher="a\"b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"realm":     "elwood.innosoft.com",
+		"nonce":     "OA6MG9tEQGm2hh",
+		"qop":       "auth,auth-int",
+		"algorithm": "md5-sess",
+		"charset":   "utf-8",
+		"cipher":    `a"b`,
+	}
+	for key, value := range expected {
+		if directives[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, directives[key])
+		}
+	}
+
+	if _, err := parseDigestChallenge(`nonce="unterminated`); err == nil {
+		t.Errorf("expected error for unterminated value")
+	}
+}
+
+// newBindResponsePacket returns a bind response packet with the given result
+// code and server SASL credentials.
+func newBindResponsePacket(messageID int64, resultCode int, serverCredentials string) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+	response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, resultCode, "Result Code"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Matched DN"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Diagnostic Message"))
+	if serverCredentials != "" {
+		response.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 7, serverCredentials, "Server SASL Credentials"))
+	}
+	packet.AppendChild(response)
+	return packet
+}
+
+// saslCredentials returns the mechanism and credentials of a SASL bind request
+func saslCredentials(t *testing.T, request *asn1.Packet) (string, []byte) {
+	auth := request.Children[1].Children[2]
+	if auth.ClassType != asn1.ClassContext || auth.Tag != 3 {
+		t.Fatalf("expected SASL bind request")
+	}
+	mechanism := auth.Children[0].Value.(string)
+	if len(auth.Children) < 2 {
+		return mechanism, nil
+	}
+	return mechanism, append([]byte{}, auth.Children[1].Data.Bytes()...)
+}
+
+func TestDigestMD5Bind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if mechanism, credentials := saslCredentials(t, p); mechanism != "DIGEST-MD5" || credentials != nil {
+				t.Errorf("unexpected initial request %s %q", mechanism, credentials)
+			}
+			challenge := `realm="example.com",nonce="OA6MG9tEQGm2hh",qop="auth",charset=utf-8,algorithm=md5-sess`
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), LDAPResultSaslBindInProgress, challenge)}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			_, credentials := saslCredentials(t, p)
+			directives, err := parseDigestChallenge(string(credentials))
+			if err != nil {
+				t.Errorf("unable to parse response: %s", err)
+				return nil
+			}
+			if directives["username"] != "jdoe" || directives["digest-uri"] != "ldap/ldap.example.com" || directives["qop"] != "auth" {
+				t.Errorf("unexpected response %s", credentials)
+			}
+			ha1 := digestMD5HA1("jdoe", "example.com", "secret", "OA6MG9tEQGm2hh", directives["cnonce"])
+			if directives["response"] != digestMD5Response(ha1, "OA6MG9tEQGm2hh", directives["nc"], directives["cnonce"], "AUTHENTICATE:ldap/ldap.example.com") {
+				t.Errorf("unexpected response digest in %s", credentials)
+			}
+			rspauth := "rspauth=" + digestMD5Response(ha1, "OA6MG9tEQGm2hh", directives["nc"], directives["cnonce"], ":ldap/ldap.example.com")
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), LDAPResultSuccess, rspauth)}
+		})
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.MD5Bind("ldap.example.com", "jdoe", "secret"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestDigestMD5BindServerAuthentication(t *testing.T) {
+	mechanism := &digestMD5{host: "ldap.example.com", username: "jdoe", password: "secret"}
+	if _, err := mechanism.Next([]byte(`nonce="abc",qop="auth"`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mechanism.Next([]byte("rspauth=00000000000000000000000000000000")); err == nil {
+		t.Errorf("expected server authentication failure")
+	}
+}
@@ -175,6 +175,55 @@ func ParseDN(str string) (*DN, error) {
 	return dn, nil
 }
 
+// String returns the string representation of the DN as defined in
+// https://tools.ietf.org/html/rfc4514#section-2, escaping the attribute
+// values so that the result can be parsed back with ParseDN
+func (d *DN) String() string {
+	rdns := make([]string, len(d.RDNs))
+	for i, rdn := range d.RDNs {
+		rdns[i] = rdn.String()
+	}
+	return strings.Join(rdns, ",")
+}
+
+// String returns the string representation of the RelativeDN, joining its
+// attributes with plus signs
+func (r *RelativeDN) String() string {
+	attrs := make([]string, len(r.Attributes))
+	for i, attr := range r.Attributes {
+		attrs[i] = attr.String()
+	}
+	return strings.Join(attrs, "+")
+}
+
+// String returns the string representation of the AttributeTypeAndValue,
+// with the value escaped as defined in https://tools.ietf.org/html/rfc4514#section-2.4
+func (a *AttributeTypeAndValue) String() string {
+	return a.Type + "=" + escapeDNValue(a.Value)
+}
+
+// escapeDNValue escapes the special characters of a DN attribute value, as
+// well as the equal sign and the control characters
+func escapeDNValue(value string) string {
+	buffer := bytes.Buffer{}
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case char == '"' || char == '+' || char == ',' || char == ';' || char == '<' || char == '>' || char == '\\' || char == '=':
+			buffer.WriteByte('\\')
+			buffer.WriteByte(char)
+		case char == '#' && i == 0, char == ' ' && (i == 0 || i == len(value)-1):
+			buffer.WriteByte('\\')
+			buffer.WriteByte(char)
+		case char < ' ' || char == 0x7f:
+			fmt.Fprintf(&buffer, "\\%02X", char)
+		default:
+			buffer.WriteByte(char)
+		}
+	}
+	return buffer.String()
+}
+
 // Equal returns true if the DNs are equal as defined by rfc4517 4.2.15 (distinguishedNameMatch).
 // Returns true if they have the same number of relative distinguished names
 // and corresponding relative distinguished names (by position) are the same.
@@ -208,6 +257,55 @@ func (d *DN) AncestorOf(other *DN) bool {
 	return true
 }
 
+// EqualFold is like Equal, but compares the attribute values case-insensitively,
+// as done by servers for attributes with a caseIgnoreMatch equality rule such
+// as cn, ou, o and dc.
+func (d *DN) EqualFold(other *DN) bool {
+	if len(d.RDNs) != len(other.RDNs) {
+		return false
+	}
+	for i := range d.RDNs {
+		if !d.RDNs[i].EqualFold(other.RDNs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// AncestorOfFold is like AncestorOf, but compares the attribute values
+// case-insensitively
+func (d *DN) AncestorOfFold(other *DN) bool {
+	if len(d.RDNs) >= len(other.RDNs) {
+		return false
+	}
+	otherRDNs := other.RDNs[len(other.RDNs)-len(d.RDNs):]
+	for i := range d.RDNs {
+		if !d.RDNs[i].EqualFold(otherRDNs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// RDNAt returns the relative distinguished name at the given position,
+// starting from the leftmost one, or nil if the DN has no such RDN.
+// "cn=john,ou=people,dc=example" has "ou=people" at position 1.
+func (d *DN) RDNAt(i int) *RelativeDN {
+	if i < 0 || i >= len(d.RDNs) {
+		return nil
+	}
+	return d.RDNs[i]
+}
+
+// Parent returns the DN of the parent entry, or nil for the empty DN.
+// The parent of "cn=john,ou=people,dc=example" is "ou=people,dc=example".
+func (d *DN) Parent() *DN {
+	if len(d.RDNs) == 0 {
+		return nil
+	}
+	return &DN{RDNs: d.RDNs[1:]}
+}
+
 // Equal returns true if the RelativeDNs are equal as defined by rfc4517 4.2.15 (distinguishedNameMatch).
 // Relative distinguished names are the same if and only if they have the same number of AttributeTypeAndValues
 // and each attribute of the first RDN is the same as the attribute of the second RDN with the same attribute type.
@@ -220,6 +318,14 @@ func (r *RelativeDN) Equal(other *RelativeDN) bool {
 	return r.hasAllAttributes(other.Attributes) && other.hasAllAttributes(r.Attributes)
 }
 
+// EqualFold is like Equal, but compares the attribute values case-insensitively
+func (r *RelativeDN) EqualFold(other *RelativeDN) bool {
+	if len(r.Attributes) != len(other.Attributes) {
+		return false
+	}
+	return r.hasAllAttributesFold(other.Attributes) && other.hasAllAttributesFold(r.Attributes)
+}
+
 func (r *RelativeDN) hasAllAttributes(attrs []*AttributeTypeAndValue) bool {
 	for _, attr := range attrs {
 		found := false
@@ -241,3 +347,24 @@ func (r *RelativeDN) hasAllAttributes(attrs []*AttributeTypeAndValue) bool {
 func (a *AttributeTypeAndValue) Equal(other *AttributeTypeAndValue) bool {
 	return strings.EqualFold(a.Type, other.Type) && a.Value == other.Value
 }
+
+func (r *RelativeDN) hasAllAttributesFold(attrs []*AttributeTypeAndValue) bool {
+	for _, attr := range attrs {
+		found := false
+		for _, myattr := range r.Attributes {
+			if myattr.EqualFold(attr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualFold is like Equal, but the case of the attribute value is not significant either
+func (a *AttributeTypeAndValue) EqualFold(other *AttributeTypeAndValue) bool {
+	return strings.EqualFold(a.Type, other.Type) && strings.EqualFold(a.Value, other.Value)
+}
@@ -209,3 +209,95 @@ func TestDNAncestor(t *testing.T) {
 		}
 	}
 }
+
+func TestDNString(t *testing.T) {
+	testcases := map[string]string{
+		"":                                       "",
+		"cn=John Doe, ou=People, dc=sun.com":     "cn=John Doe,ou=People,dc=sun.com",
+		"OU=Sales+CN=J. Smith,DC=example,DC=net": "OU=Sales+CN=J. Smith,DC=example,DC=net",
+		"cn=Jim\\2C \\22Hasse Hö\\22 Hansson!,dc=dummy,dc=com": `cn=Jim\, \"Hasse Hö\" Hansson!,dc=dummy,dc=com`,
+		`cn=\#1\+2\=3\;\<\>\\,dc=com`:                          `cn=\#1\+2\=3\;\<\>\\,dc=com`,
+		`cn=\ lead and trail\ `:                                `cn=\ lead and trail\ `,
+		`cn=line\0Afeed`:                                       `cn=line\0Afeed`,
+		"1.3.6.1.4.1.1466.0=#04024869":                         "1.3.6.1.4.1.1466.0=Hi",
+	}
+
+	for test, expected := range testcases {
+		dn, err := ldap.ParseDN(test)
+		if err != nil {
+			t.Errorf("%s: %v", test, err)
+			continue
+		}
+		if actual := dn.String(); actual != expected {
+			t.Errorf("%s: expected %q, got %q", test, expected, actual)
+			continue
+		}
+		reparsed, err := ldap.ParseDN(dn.String())
+		if err != nil {
+			t.Errorf("%s: %v", test, err)
+			continue
+		}
+		if !reflect.DeepEqual(dn, reparsed) {
+			t.Errorf("%s: %q does not parse back to the same DN", test, dn.String())
+		}
+	}
+}
+
+func TestDNEqualFold(t *testing.T) {
+	testcases := []struct {
+		A        string
+		B        string
+		Equal    bool
+		Ancestor bool
+	}{
+		{"o=A", "o=a", true, false},
+		{"o=a,o=B", "o=A,O=b", true, false},
+		{"o=a+o=B", "O=b+o=A", true, false},
+		{"o=A", "o=B", false, false},
+		{"ou=People,dc=Example,dc=COM", "uid=jdoe,ou=people,dc=example,dc=com", false, true},
+		{"ou=People,dc=Example,dc=COM", "uid=jdoe,ou=groups,dc=example,dc=com", false, false},
+	}
+
+	for i, tc := range testcases {
+		a, err := ldap.ParseDN(tc.A)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		b, err := ldap.ParseDN(tc.B)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if expected, actual := tc.Equal, a.EqualFold(b); expected != actual {
+			t.Errorf("%d: when comparing '%s' and '%s' expected %v, got %v", i, tc.A, tc.B, expected, actual)
+		}
+		if expected, actual := tc.Ancestor, a.AncestorOfFold(b); expected != actual {
+			t.Errorf("%d: when checking '%s' is an ancestor of '%s' expected %v, got %v", i, tc.A, tc.B, expected, actual)
+		}
+	}
+}
+
+func TestDNParent(t *testing.T) {
+	dn, err := ldap.ParseDN("cn=john,ou=people,dc=example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rdn := dn.RDNAt(1); rdn == nil || rdn.String() != "ou=people" {
+		t.Errorf("unexpected RDN at position 1: %v", rdn)
+	}
+	if rdn := dn.RDNAt(3); rdn != nil {
+		t.Errorf("expected no RDN at position 3, got %s", rdn)
+	}
+
+	parent := dn.Parent()
+	if parent.String() != "ou=people,dc=example" || !parent.AncestorOf(dn) {
+		t.Errorf("unexpected parent %s", parent)
+	}
+	if root := parent.Parent().Parent(); root == nil || len(root.RDNs) != 0 {
+		t.Errorf("expected the empty DN, got %v", root)
+	}
+	if dn := (&ldap.DN{}).Parent(); dn != nil {
+		t.Errorf("expected no parent for the empty DN, got %s", dn)
+	}
+}
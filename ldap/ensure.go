@@ -0,0 +1,84 @@
+// File contains the declarative provisioning of entries
+
+package ldap
+
+import (
+	"context"
+)
+
+// EnsureOptions are the options of EnsureEntry
+type EnsureOptions struct {
+	// Diff sets how the attributes of an existing entry are compared with the
+	// desired ones
+	Diff DiffOptions
+	// DryRun returns the changes which would be made, without making them
+	DryRun bool
+}
+
+// EnsureResult holds the request made by EnsureEntry, or planned in dry-run
+// mode. Both requests are nil if the entry is already as desired.
+type EnsureResult struct {
+	// Add is the request creating the missing entry
+	Add *AddRequest
+	// Modify is the request changing the existing entry
+	Modify *ModifyRequest
+}
+
+// Changed reports whether the entry was, or would be, created or modified
+func (r *EnsureResult) Changed() bool {
+	return r.Add != nil || r.Modify != nil
+}
+
+// EnsureEntry creates the entry with the desired attributes if it does not
+// exist, or changes its attributes as computed by DiffOptions.Diff if they
+// differ from the desired ones. Attributes not in desired are left as is,
+// and desired attributes without values are deleted. options may be nil.
+func (l *Conn) EnsureEntry(dn string, desired map[string][]string, options *EnsureOptions) (*EnsureResult, error) {
+	return l.EnsureEntryContext(context.Background(), dn, desired, options)
+}
+
+// EnsureEntryContext is like EnsureEntry, but abandons the requests and
+// returns ctx.Err() if ctx is done before the server responds
+func (l *Conn) EnsureEntryContext(ctx context.Context, dn string, desired map[string][]string, options *EnsureOptions) (*EnsureResult, error) {
+	if options == nil {
+		options = &EnsureOptions{}
+	}
+	desiredEntry := NewEntry(dn, desired)
+	attributes := make([]string, 0, len(desiredEntry.Attributes))
+	for _, attribute := range desiredEntry.Attributes {
+		attributes = append(attributes, attribute.Name)
+	}
+	if len(attributes) == 0 {
+		attributes = []string{"1.1"}
+	}
+
+	searchRequest := NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", attributes, nil)
+	result, err := l.SearchContext(ctx, searchRequest)
+	if err != nil && !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+		return nil, err
+	}
+
+	ensured := &EnsureResult{}
+	if err != nil || len(result.Entries) == 0 {
+		ensured.Add = NewAddRequest(dn)
+		for _, attribute := range desiredEntry.Attributes {
+			if len(attribute.Values) > 0 {
+				ensured.Add.Attribute(attribute.Name, attribute.Values)
+			}
+		}
+		if !options.DryRun {
+			if err := l.AddContext(ctx, ensured.Add); err != nil {
+				return nil, err
+			}
+		}
+		return ensured, nil
+	}
+
+	ensured.Modify = options.Diff.Diff(result.Entries[0], desiredEntry)
+	if ensured.Modify != nil && !options.DryRun {
+		if err := l.ModifyContext(ctx, ensured.Modify); err != nil {
+			return nil, err
+		}
+	}
+	return ensured, nil
+}
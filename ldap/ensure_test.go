@@ -0,0 +1,50 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnsureEntry(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	dn := "uid=bwayne,ou=people,dc=example,dc=com"
+	desired := map[string][]string{"uid": {"bwayne"}, "cn": {"Bruce Wayne"}, "mail": {"bruce@example.com"}}
+	result, err := conn.EnsureEntry(dn, desired, &EnsureOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %s", err)
+	}
+	if result.Add == nil || result.Modify != nil || len(result.Add.Attributes) != 3 {
+		t.Errorf("expected an add request, got %#v", result)
+	}
+	if search, err := conn.Search(NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+		t.Errorf("expected the dry run not to create the entry, got %v, %v", search, err)
+	}
+
+	if result, err = conn.EnsureEntry(dn, desired, nil); err != nil || result.Add == nil {
+		t.Fatalf("expected the entry to be created, got %#v, %v", result, err)
+	}
+	if result, err = conn.EnsureEntry(dn, desired, nil); err != nil || result.Changed() {
+		t.Errorf("expected the entry to be unchanged, got %#v, %v", result, err)
+	}
+
+	desired["mail"] = []string{"bwayne@example.com"}
+	desired["description"] = nil
+	result, err = conn.EnsureEntry(dn, desired, nil)
+	if err != nil {
+		t.Fatalf("ensure changed entry: %s", err)
+	}
+	expected := []Change{{ReplaceAttribute, PartialAttribute{Type: "mail", Vals: []string{"bwayne@example.com"}}}}
+	if result.Add != nil || result.Modify == nil || !reflect.DeepEqual(result.Modify.Changes, expected) {
+		t.Errorf("unexpected result %#v", result)
+	}
+	search, err := conn.Search(NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"mail", "cn"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mail := search.Entries[0].GetAttributeValue("mail"); mail != "bwayne@example.com" {
+		t.Errorf("expected the mail to be replaced, got %q", mail)
+	}
+}
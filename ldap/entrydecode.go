@@ -0,0 +1,190 @@
+// File contains the decoding of search result entries from the read buffer
+
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+var errInvalidElement = errors.New("ldap: invalid BER element")
+
+// BER identifier octets of the elements decoded or encoded without packets
+const (
+	berOctetString = byte(asn1.ClassUniversal) | byte(asn1.TypePrimitive) | byte(asn1.TagOctetString)
+	berInteger     = byte(asn1.ClassUniversal) | byte(asn1.TypePrimitive) | byte(asn1.TagInteger)
+	berEnumerated  = byte(asn1.ClassUniversal) | byte(asn1.TypePrimitive) | byte(asn1.TagEnumerated)
+	berSequence    = byte(asn1.ClassUniversal) | byte(asn1.TypeConstructed) | byte(asn1.TagSequence)
+	berSet         = byte(asn1.ClassUniversal) | byte(asn1.TypeConstructed) | byte(asn1.TagSet)
+	berEntry       = byte(asn1.ClassApplication) | byte(asn1.TypeConstructed) | byte(ApplicationSearchResultEntry)
+)
+
+// readMessage reads the rest of the message starting with the given header
+// into a single buffer. A SearchResultEntry is decoded directly from the
+// buffer, with the values of its attributes referencing it rather than
+// copied into a tree of packets, and the other messages are decoded as
+// usual.
+func readMessage(r io.Reader, header []byte, length int64) (*asn1.Packet, error) {
+	data := make([]byte, int64(len(header))+length)
+	copy(data, header)
+	if _, err := io.ReadFull(r, data[len(header):]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if packet := decodeEntryMessage(data[len(header):]); packet != nil {
+		return packet, nil
+	}
+	return asn1.DecodePacketErr(data)
+}
+
+// decodeEntryMessage returns the packet of the message with the given
+// content if it holds a SearchResultEntry, or nil otherwise or if it cannot
+// be decoded this way. The entry packet has no children: the decoded entry is
+// its Value, which decodeEntry returns.
+func decodeEntryMessage(content []byte) *asn1.Packet {
+	tag, start, end, err := berElement(content, 0)
+	if err != nil || tag != berInteger {
+		return nil
+	}
+	messageID, err := asn1.DecodePacketErr(content[:end])
+	if err != nil {
+		return nil
+	}
+	tag, start, opEnd, err := berElement(content, end)
+	if err != nil || tag != berEntry {
+		return nil
+	}
+	entry, err := parseEntry(content[start:opEnd])
+	if err != nil {
+		return nil
+	}
+
+	children := []*asn1.Packet{messageID, {
+		Identifier: asn1.Identifier{ClassType: asn1.ClassApplication, TagType: asn1.TypeConstructed, Tag: ApplicationSearchResultEntry},
+		Value:      entry,
+		Data:       bytes.NewBuffer(content[start:opEnd]),
+	}}
+	if opEnd < len(content) {
+		controls, err := asn1.DecodePacketErr(content[opEnd:])
+		if err != nil {
+			return nil
+		}
+		children = append(children, controls)
+	}
+	return &asn1.Packet{
+		Identifier: asn1.Identifier{ClassType: asn1.ClassUniversal, TagType: asn1.TypeConstructed, Tag: asn1.TagSequence},
+		Data:       bytes.NewBuffer(content),
+		Children:   children,
+	}
+}
+
+// parseEntry decodes the content of a SearchResultEntry. The string values
+// share a single copy of the content, and the byte values are slices of it.
+func parseEntry(content []byte) (*Entry, error) {
+	str := string(content)
+	tag, start, end, err := berElement(content, 0)
+	if err != nil || tag != berOctetString {
+		return nil, errInvalidElement
+	}
+	entry := &Entry{DN: str[start:end]}
+
+	tag, start, attributesEnd, err := berElement(content, end)
+	if err != nil || tag != berSequence || attributesEnd != len(content) {
+		return nil, errInvalidElement
+	}
+	count, err := countElements(content, start, attributesEnd)
+	if err != nil || count == 0 {
+		return entry, err
+	}
+	attributes := make([]EntryAttribute, count)
+	entry.Attributes = make([]*EntryAttribute, count)
+	for i, offset := 0, start; offset < attributesEnd; i++ {
+		tag, start, end, err := berElement(content, offset)
+		if err != nil || tag != berSequence {
+			return nil, errInvalidElement
+		}
+		offset = end
+		if err := parseAttribute(&attributes[i], content, str, start, end); err != nil {
+			return nil, err
+		}
+		entry.Attributes[i] = &attributes[i]
+	}
+	return entry, nil
+}
+
+// parseAttribute decodes the PartialAttribute between start and end
+func parseAttribute(attribute *EntryAttribute, content []byte, str string, start, end int) error {
+	tag, nameStart, nameEnd, err := berElement(content, start)
+	if err != nil || tag != berOctetString {
+		return errInvalidElement
+	}
+	attribute.Name = str[nameStart:nameEnd]
+
+	tag, start, valuesEnd, err := berElement(content, nameEnd)
+	if err != nil || tag != berSet || valuesEnd != end {
+		return errInvalidElement
+	}
+	count, err := countElements(content, start, valuesEnd)
+	if err != nil || count == 0 {
+		return err
+	}
+	attribute.Values = make([]string, 0, count)
+	attribute.ByteValues = make([][]byte, 0, count)
+	for offset := start; offset < valuesEnd; {
+		tag, start, end, err := berElement(content, offset)
+		if err != nil || tag != berOctetString {
+			return errInvalidElement
+		}
+		offset = end
+		attribute.Values = append(attribute.Values, str[start:end])
+		attribute.ByteValues = append(attribute.ByteValues, content[start:end:end])
+	}
+	return nil
+}
+
+// countElements returns the number of BER elements between start and end
+func countElements(data []byte, start, end int) (int, error) {
+	count := 0
+	for offset := start; offset < end; count++ {
+		_, _, next, err := berElement(data, offset)
+		if err != nil || next > end {
+			return 0, errInvalidElement
+		}
+		offset = next
+	}
+	return count, nil
+}
+
+// berElement returns the identifier octet of the BER element at offset in
+// data, and the offsets of the start and the end of its content. Only the
+// low tag number form and the definite lengths are supported, which is all
+// a SearchResultEntry needs.
+func berElement(data []byte, offset int) (tag byte, start, end int, err error) {
+	if offset+2 > len(data) || data[offset]&0x1f == 0x1f {
+		return 0, 0, 0, errInvalidElement
+	}
+	tag = data[offset]
+	length := int(data[offset+1])
+	start = offset + 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || start+n > len(data) {
+			return 0, 0, 0, errInvalidElement
+		}
+		length = 0
+		for _, b := range data[start : start+n] {
+			length = length<<8 | int(b)
+		}
+		start += n
+	}
+	end = start + length
+	if length < 0 || end > len(data) {
+		return 0, 0, 0, errInvalidElement
+	}
+	return tag, start, end, nil
+}
@@ -0,0 +1,145 @@
+package ldap
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newTestEntryMessage returns the BER encoding of a SearchResultEntry message
+// for an entry with the given number of attributes of the given number of
+// values
+func newTestEntryMessage(attributes, values int, controls ...Control) []byte {
+	entry := &Entry{DN: "uid=jdoe,ou=people,dc=example,dc=com"}
+	for i := 0; i < attributes; i++ {
+		attribute := &EntryAttribute{Name: fmt.Sprintf("attribute%d", i)}
+		for j := 0; j < values; j++ {
+			attribute.ByteValues = append(attribute.ByteValues, []byte(fmt.Sprintf("value %d of attribute %d", j, i)))
+		}
+		entry.Attributes = append(entry.Attributes, attribute)
+	}
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 7, "MessageID"))
+	packet.AppendChild(encodeEntry(entry))
+	if len(controls) > 0 {
+		packet.AppendChild(encodeControls(controls))
+	}
+	return packet.Bytes()
+}
+
+func TestReadMessage(t *testing.T) {
+	for _, data := range [][]byte{
+		newTestEntryMessage(0, 0),
+		newTestEntryMessage(3, 2),
+		newTestEntryMessage(1, 0),
+		newTestEntryMessage(2, 1, NewControlManageDsaIT(true)),
+	} {
+		reader := bytes.NewReader(data)
+		header, length, err := readMessageHeader(reader, DefaultMaxMessageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet, err := readMessage(reader, header, length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := packet.Children[1].Value.(*Entry); !ok {
+			t.Error("entry not decoded from the buffer")
+		}
+
+		expected := asn1.DecodePacket(data)
+		if packet.Children[0].Value != expected.Children[0].Value {
+			t.Errorf("expected message ID %v, got %v", expected.Children[0].Value, packet.Children[0].Value)
+		}
+		entry, expectedEntry := decodeEntry(packet.Children[1]), decodeEntry(expected.Children[1])
+		if entry.DN != expectedEntry.DN || len(entry.Attributes) != len(expectedEntry.Attributes) {
+			t.Fatalf("expected entry %v, got %v", expectedEntry, entry)
+		}
+		for i, attribute := range entry.Attributes {
+			if attribute.Name != expectedEntry.Attributes[i].Name ||
+				!reflect.DeepEqual(attribute.Values, expectedEntry.Attributes[i].Values) ||
+				len(attribute.ByteValues) != len(expectedEntry.Attributes[i].ByteValues) {
+				t.Errorf("expected attribute %v, got %v", expectedEntry.Attributes[i], attribute)
+			}
+			for j, value := range attribute.ByteValues {
+				if !bytes.Equal(value, expectedEntry.Attributes[i].ByteValues[j]) {
+					t.Errorf("expected value %q, got %q", expectedEntry.Attributes[i].ByteValues[j], value)
+				}
+			}
+		}
+		if len(packet.Children) != len(expected.Children) {
+			t.Errorf("expected %d children, got %d", len(expected.Children), len(packet.Children))
+		}
+		if err := addLDAPDescriptions(packet); err != nil {
+			t.Errorf("unexpected error adding the descriptions: %s", err)
+		}
+	}
+}
+
+func TestReadMessageFallback(t *testing.T) {
+	tests := map[string][]byte{
+		"result":        newResultPacket(3, ApplicationSearchResultDone, LDAPResultSuccess, "").Bytes(),
+		"integer value": {0x30, 0x13, 0x02, 0x01, 0x01, 0x64, 0x0e, 0x04, 0x01, 'a', 0x30, 0x09, 0x30, 0x07, 0x04, 0x01, 'b', 0x31, 0x02, 0x02, 0x00},
+		"short entry":   {0x30, 0x0a, 0x02, 0x01, 0x01, 0x64, 0x05, 0x04, 0x01, 'a', 0x30, 0x05},
+	}
+	for name, data := range tests {
+		expected, _ := asn1.DecodePacketErr(data)
+		reader := bytes.NewReader(data)
+		header, length, err := readMessageHeader(reader, DefaultMaxMessageSize)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		packet, err := readMessage(reader, header, length)
+		if expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		if _, ok := packet.Children[1].Value.(*Entry); ok || !bytes.Equal(packet.Bytes(), expected.Bytes()) {
+			t.Errorf("%s: expected the packet to be decoded as usual", name)
+		}
+	}
+
+	if _, err := readMessage(bytes.NewReader([]byte{0x02, 0x01}), []byte{0x30, 0x05}, 5); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+func BenchmarkDecodeEntryPackets(b *testing.B) {
+	data := newTestEntryMessage(20, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		packet, err := asn1.ReadPacket(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		decodeEntry(packet.Children[1])
+	}
+}
+
+func BenchmarkDecodeEntryBuffer(b *testing.B) {
+	data := newTestEntryMessage(20, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader := bytes.NewReader(data)
+		header, length, err := readMessageHeader(reader, DefaultMaxMessageSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		packet, err := readMessage(reader, header, length)
+		if err != nil {
+			b.Fatal(err)
+		}
+		decodeEntry(packet.Children[1])
+	}
+}
@@ -1,12 +1,15 @@
 package ldap
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gostores/encoding/asn1"
 )
 
-// LDAP Result Codes
+// LDAP Result Codes, from RFC 4511 and the extensions defining their own:
+// LCUP (RFC 3928), Cancel (RFC 3909), Assertion (RFC 4528) and Proxied
+// Authorization (RFC 4370)
 const (
 	LDAPResultSuccess                      = 0
 	LDAPResultOperationsError              = 1
@@ -47,6 +50,17 @@ const (
 	LDAPResultObjectClassModsProhibited    = 69
 	LDAPResultAffectsMultipleDSAs          = 71
 	LDAPResultOther                        = 80
+	LDAPResultLcupResourcesExhausted       = 113
+	LDAPResultLcupSecurityViolation        = 114
+	LDAPResultLcupInvalidData              = 115
+	LDAPResultLcupUnsupportedScheme        = 116
+	LDAPResultLcupReloadRequired           = 117
+	LDAPResultCanceled                     = 118
+	LDAPResultNoSuchOperation              = 119
+	LDAPResultTooLate                      = 120
+	LDAPResultCannotCancel                 = 121
+	LDAPResultAssertionFailed              = 122
+	LDAPResultAuthorizationDenied          = 123
 
 	ErrorNetwork            = 200
 	ErrorFilterCompile      = 201
@@ -55,6 +69,7 @@ const (
 	ErrorUnexpectedMessage  = 204
 	ErrorUnexpectedResponse = 205
 	ErrorEmptyPassword      = 206
+	ErrorMessageTooLarge    = 207
 )
 
 // LDAPResultCodeMap contains string descriptions for LDAP error codes
@@ -98,6 +113,17 @@ var LDAPResultCodeMap = map[uint8]string{
 	LDAPResultObjectClassModsProhibited:    "Object Class Mods Prohibited",
 	LDAPResultAffectsMultipleDSAs:          "Affects Multiple DSAs",
 	LDAPResultOther:                        "Other",
+	LDAPResultLcupResourcesExhausted:       "LCUP Resources Exhausted",
+	LDAPResultLcupSecurityViolation:        "LCUP Security Violation",
+	LDAPResultLcupInvalidData:              "LCUP Invalid Data",
+	LDAPResultLcupUnsupportedScheme:        "LCUP Unsupported Scheme",
+	LDAPResultLcupReloadRequired:           "LCUP Reload Required",
+	LDAPResultCanceled:                     "Canceled",
+	LDAPResultNoSuchOperation:              "No Such Operation",
+	LDAPResultTooLate:                      "Too Late",
+	LDAPResultCannotCancel:                 "Cannot Cancel",
+	LDAPResultAssertionFailed:              "Assertion Failed",
+	LDAPResultAuthorizationDenied:          "Authorization Denied",
 
 	ErrorNetwork:            "Network Error",
 	ErrorFilterCompile:      "Filter Compile Error",
@@ -106,6 +132,7 @@ var LDAPResultCodeMap = map[uint8]string{
 	ErrorUnexpectedMessage:  "Unexpected Message",
 	ErrorUnexpectedResponse: "Unexpected Response",
 	ErrorEmptyPassword:      "Empty password not allowed by the client",
+	ErrorMessageTooLarge:    "Message larger than allowed by the client",
 }
 
 func getLDAPResultCode(packet *asn1.Packet) (code uint8, description string) {
@@ -131,6 +158,16 @@ type Error struct {
 	Err error
 	// ResultCode is the LDAP error code
 	ResultCode uint8
+	// MatchedDN is the last entry of the target DN which exists on the server,
+	// when the result is one of the name errors such as LDAPResultNoSuchObject
+	MatchedDN string
+	// DiagnosticMessage is the message returned by the server, which is empty
+	// for errors detected by the client
+	DiagnosticMessage string
+	// Referrals are the URLs returned with LDAPResultReferral
+	Referrals []string
+	// Controls are the controls returned with the response
+	Controls []Control
 }
 
 func (e *Error) Error() string {
@@ -142,8 +179,26 @@ func NewError(resultCode uint8, err error) error {
 	return &Error{ResultCode: resultCode, Err: err}
 }
 
-// IsErrorWithCode returns true if the given error is an LDAP error with the given result code
-func IsErrorWithCode(err error, desiredResultCode uint8) bool {
+// newResultError returns the error of the LDAPResult held by the packet
+func newResultError(packet *asn1.Packet) error {
+	_, description := getLDAPResultCode(packet)
+	response := decodeResponse(packet)
+	err := &Error{
+		Err:               errors.New(description),
+		ResultCode:        response.ResultCode,
+		MatchedDN:         response.MatchedDN,
+		DiagnosticMessage: response.DiagnosticMessage,
+		Referrals:         response.Referrals,
+	}
+	if len(response.Controls) > 0 {
+		err.Controls = response.Controls
+	}
+	return err
+}
+
+// IsErrorWithCode returns true if the given error is an LDAP error with one of
+// the given result codes
+func IsErrorWithCode(err error, desiredResultCodes ...uint8) bool {
 	if err == nil {
 		return false
 	}
@@ -153,5 +208,65 @@ func IsErrorWithCode(err error, desiredResultCode uint8) bool {
 		return false
 	}
 
-	return serverError.ResultCode == desiredResultCode
+	for _, desiredResultCode := range desiredResultCodes {
+		if serverError.ResultCode == desiredResultCode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNoSuchObject returns true if the target entry of the operation does not
+// exist
+func IsNoSuchObject(err error) bool {
+	return IsErrorWithCode(err, LDAPResultNoSuchObject)
+}
+
+// IsNoSuchAttribute returns true if the attribute or value of the operation
+// does not exist
+func IsNoSuchAttribute(err error) bool {
+	return IsErrorWithCode(err, LDAPResultNoSuchAttribute)
+}
+
+// IsEntryAlreadyExists returns true if the entry to add or rename already
+// exists
+func IsEntryAlreadyExists(err error) bool {
+	return IsErrorWithCode(err, LDAPResultEntryAlreadyExists)
+}
+
+// IsAttributeOrValueExists returns true if the attribute or value to add
+// already exists
+func IsAttributeOrValueExists(err error) bool {
+	return IsErrorWithCode(err, LDAPResultAttributeOrValueExists)
+}
+
+// IsInvalidCredentials returns true if a bind failed because of wrong
+// credentials
+func IsInvalidCredentials(err error) bool {
+	return IsErrorWithCode(err, LDAPResultInvalidCredentials)
+}
+
+// IsInsufficientAccessRights returns true if the operation is not allowed for
+// the authorization identity of the connection
+func IsInsufficientAccessRights(err error) bool {
+	return IsErrorWithCode(err, LDAPResultInsufficientAccessRights)
+}
+
+// IsSizeOrTimeLimitExceeded returns true if the operation was stopped by a
+// size, time or administrative limit. Searches return the entries found before
+// the limit along with the error.
+func IsSizeOrTimeLimitExceeded(err error) bool {
+	return IsErrorWithCode(err, LDAPResultSizeLimitExceeded, LDAPResultTimeLimitExceeded, LDAPResultAdminLimitExceeded)
+}
+
+// IsBusyOrUnavailable returns true if the server cannot process the operation
+// at the moment, so that it may be retried later or on another server
+func IsBusyOrUnavailable(err error) bool {
+	return IsErrorWithCode(err, LDAPResultBusy, LDAPResultUnavailable)
+}
+
+// IsNetworkError returns true if the connection to the server failed or was
+// closed
+func IsNetworkError(err error) bool {
+	return IsErrorWithCode(err, ErrorNetwork)
 }
@@ -31,6 +31,78 @@ func TestNilPacket(t *testing.T) {
 	}
 }
 
+// TestResultError tests that the parts of an LDAPResult are exposed by the
+// error
+func TestResultError(t *testing.T) {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(1), "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationModifyResponse, nil, "Response")
+	response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, LDAPResultReferral, "Result Code"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "dc=example,dc=com", "Matched DN"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "go away", "Diagnostic Message"))
+	referral := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 3, nil, "Referral")
+	referral.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "ldap://other.example.com/", "URI"))
+	response.AppendChild(referral)
+	packet.AppendChild(response)
+	controls := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+	controls.AppendChild(NewControlManageDsaIT(false).Encode())
+	packet.AppendChild(controls)
+
+	decoded, err := asn1.DecodePacketErr(packet.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = newResultError(decoded)
+	ldapErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got %T", err)
+	}
+	if ldapErr.ResultCode != LDAPResultReferral || !IsErrorWithCode(err, LDAPResultReferral) {
+		t.Errorf("got result code %d", ldapErr.ResultCode)
+	}
+	if ldapErr.MatchedDN != "dc=example,dc=com" {
+		t.Errorf("got matched DN %q", ldapErr.MatchedDN)
+	}
+	if ldapErr.DiagnosticMessage != "go away" {
+		t.Errorf("got diagnostic message %q", ldapErr.DiagnosticMessage)
+	}
+	if len(ldapErr.Referrals) != 1 || ldapErr.Referrals[0] != "ldap://other.example.com/" {
+		t.Errorf("got referrals %v", ldapErr.Referrals)
+	}
+	if len(ldapErr.Controls) != 1 || ldapErr.Controls[0].GetControlType() != ControlTypeManageDsaIT {
+		t.Errorf("got controls %v", ldapErr.Controls)
+	}
+	if expected := `LDAP Result Code 10 "Referral": go away`; err.Error() != expected {
+		t.Errorf("got %q, expected %q", err.Error(), expected)
+	}
+}
+
+// TestErrorPredicates tests the result code helpers
+func TestErrorPredicates(t *testing.T) {
+	noSuchObject := NewError(LDAPResultNoSuchObject, errors.New("no such object"))
+	if !IsErrorWithCode(noSuchObject, LDAPResultBusy, LDAPResultNoSuchObject) {
+		t.Error("expected a match with one of the codes")
+	}
+	if IsErrorWithCode(noSuchObject) || IsErrorWithCode(nil, LDAPResultSuccess) {
+		t.Error("expected no match without codes or error")
+	}
+	if IsErrorWithCode(errors.New("no such object"), LDAPResultNoSuchObject) {
+		t.Error("expected no match for a non LDAP error")
+	}
+	if !IsNoSuchObject(noSuchObject) || IsInvalidCredentials(noSuchObject) {
+		t.Error("unexpected predicate results for no such object")
+	}
+	if !IsBusyOrUnavailable(NewError(LDAPResultUnavailable, errors.New("unavailable"))) {
+		t.Error("expected unavailable to be busy or unavailable")
+	}
+	if !IsSizeOrTimeLimitExceeded(NewError(LDAPResultSizeLimitExceeded, errors.New("too many"))) {
+		t.Error("expected size limit exceeded to be a limit")
+	}
+	if LDAPResultCodeMap[LDAPResultCanceled] != "Canceled" {
+		t.Errorf("unexpected description %q", LDAPResultCodeMap[LDAPResultCanceled])
+	}
+}
+
 // TestConnReadErr tests that an unexpected error reading from underlying
 // connection bubbles up to the goroutine which makes a request.
 func TestConnReadErr(t *testing.T) {
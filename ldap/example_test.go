@@ -162,6 +162,40 @@ func ExampleConn_Modify() {
 	}
 }
 
+// This example shows how to update the target of a referral object. Without
+// the ManageDsaIT control, the server would return a referral instead of
+// modifying the entry itself.
+func ExampleConn_Modify_manageDsaIT() {
+	l, err := ldap.Dial("tcp", fmt.Sprintf("%s:%d", "ldap.example.com", 389))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+
+	modify := ldap.NewModifyRequest("ou=remote,dc=example,dc=com", ldap.NewControlManageDsaIT(true))
+	modify.Replace("ref", []string{"ldap://ldap2.example.com/ou=remote,dc=example,dc=com"})
+
+	err = l.Modify(modify)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// This example shows how to rename an entry and move it below another parent
+// in a single operation
+func ExampleConn_ModifyDN() {
+	l, err := ldap.Dial("tcp", fmt.Sprintf("%s:%d", "ldap.example.com", 389))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+
+	req := ldap.NewModifyDNRequest("uid=someone,ou=people,dc=example,dc=com", "uid=someone-else", true, "ou=staff,dc=example,dc=com")
+	if err = l.ModifyDN(req); err != nil {
+		log.Fatalf("Failed to move entry: %s\n", err.Error())
+	}
+}
+
 // Example User Authentication shows how a typical application can verify a login attempt
 func Example_userAuthentication() {
 	// The username and password we want to check
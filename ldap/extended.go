@@ -0,0 +1,110 @@
+// File contains the generic Extended Operation functionality
+//
+// https://tools.ietf.org/html/rfc4511
+//
+// ExtendedRequest ::= [APPLICATION 23] SEQUENCE {
+//      requestName      [0] LDAPOID,
+//      requestValue     [1] OCTET STRING OPTIONAL }
+//
+// ExtendedResponse ::= [APPLICATION 24] SEQUENCE {
+//      COMPONENTS OF LDAPResult,
+//      responseName     [10] LDAPOID OPTIONAL,
+//      responseValue    [11] OCTET STRING OPTIONAL }
+
+package ldap
+
+import (
+	"context"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// ExtendedRequest represents an extended operation identified by its OID.
+// The value, if any, is sent as is, so it has to be encoded as expected by
+// the server, usually in BER.
+type ExtendedRequest struct {
+	// Name is the OID of the extended operation
+	Name string
+	// Value is the encoded request value, or nil if the operation has none
+	Value []byte
+	// Controls hold optional controls to send with the request
+	Controls []Control
+}
+
+// ExtendedResponse holds the server response to an ExtendedRequest
+type ExtendedResponse struct {
+	// Name is the OID of the response, if the server sent one
+	Name string
+	// Value is the encoded response value, or nil if the server sent none
+	Value []byte
+	// Controls are the controls returned with the response
+	Controls []Control
+}
+
+// NewExtendedRequest returns an ExtendedRequest for the given OID and value
+func NewExtendedRequest(name string, value []byte, controls ...Control) *ExtendedRequest {
+	return &ExtendedRequest{
+		Name:     name,
+		Value:    value,
+		Controls: controls,
+	}
+}
+
+func (r *ExtendedRequest) encode() *asn1.Packet {
+	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationExtendedRequest, nil, "Extended Request")
+	request.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, r.Name, "Extended Request Name"))
+	if r.Value != nil {
+		value := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, 1, nil, "Extended Request Value")
+		value.Data.Write(r.Value)
+		request.AppendChild(value)
+	}
+	return request
+}
+
+func (r *ExtendedRequest) controls() []Control {
+	return r.Controls
+}
+
+func (r *ExtendedRequest) responseTag() asn1.Tag {
+	return ApplicationExtendedResponse
+}
+
+// Extended performs the given extended operation
+func (l *Conn) Extended(extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	return l.ExtendedContext(context.Background(), extendedRequest)
+}
+
+// ExtendedContext performs the given extended operation. If ctx is done before
+// the server responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) ExtendedContext(ctx context.Context, extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	response, err := l.DoContext(ctx, extendedRequest)
+	if response == nil {
+		return nil, err
+	}
+	return decodeExtendedResponse(response.Packet), err
+}
+
+// decodeExtendedResponse returns the name, value and controls of the
+// ExtendedResponse held by the packet
+func decodeExtendedResponse(packet *asn1.Packet) *ExtendedResponse {
+	response := &ExtendedResponse{
+		Controls: make([]Control, 0),
+	}
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType != asn1.ClassContext {
+			continue
+		}
+		switch child.Tag {
+		case 10:
+			response.Name = asn1.DecodeString(child.Data.Bytes())
+		case 11:
+			response.Value = child.Data.Bytes()
+		}
+	}
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			response.Controls = append(response.Controls, DecodeControl(child))
+		}
+	}
+	return response
+}
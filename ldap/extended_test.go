@@ -0,0 +1,81 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestExtended(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requestValue := asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "payload", "Payload").Bytes()
+	requests := make(chan *asn1.Packet, 1)
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		requests <- p
+		packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+		packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, p.Children[0].Value.(int64), "MessageID"))
+		response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+		response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, LDAPResultSuccess, "Result Code"))
+		response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Matched DN"))
+		response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Diagnostic Message"))
+		response.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 10, "1.2.3.4.5", "Response Name"))
+		response.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 11, "result", "Response Value"))
+		packet.AppendChild(response)
+		return []*asn1.Packet{packet}
+	})
+
+	var response *ExtendedResponse
+	runWithTimeout(t, time.Second, func() {
+		var err error
+		response, err = conn.Extended(NewExtendedRequest("1.2.3.4", requestValue, NewControlManageDsaIT(false)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	request := <-requests
+	if name := asn1.DecodeString(request.Children[1].Children[0].Data.Bytes()); name != "1.2.3.4" {
+		t.Errorf("unexpected request name %q", name)
+	}
+	if !bytes.Equal(request.Children[1].Children[1].Data.Bytes(), requestValue) {
+		t.Errorf("unexpected request value %x", request.Children[1].Children[1].Data.Bytes())
+	}
+	if len(request.Children) != 3 {
+		t.Errorf("expected request to carry controls")
+	}
+
+	if response.Name != "1.2.3.4.5" || string(response.Value) != "result" {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
+
+func TestExtendedError(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationExtendedResponse, LDAPResultProtocolError, "unsupported extended operation")}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		response, err := conn.Extended(NewExtendedRequest("1.2.3.4", nil))
+		if !IsErrorWithCode(err, LDAPResultProtocolError) {
+			t.Errorf("expected protocol error, got %v", err)
+		}
+		if response == nil || response.Value != nil {
+			t.Errorf("unexpected response %+v", response)
+		}
+	})
+}
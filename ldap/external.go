@@ -0,0 +1,59 @@
+// File contains the EXTERNAL SASL mechanism
+//
+// https://tools.ietf.org/html/rfc4422#appendix-A
+
+package ldap
+
+import (
+	"context"
+)
+
+// ExternalBindRequest represents an EXTERNAL SASL bind, which authenticates
+// with the identity established outside of LDAP, such as the client
+// certificate of a TLS connection or the peer credentials of a Unix socket
+type ExternalBindRequest struct {
+	// AuthzID is the optional authorization identity to assume. If empty, the
+	// server derives it from the external identity.
+	AuthzID string
+	// Controls are optional controls to send with the bind request
+	Controls []Control
+}
+
+// ExternalBind performs an EXTERNAL SASL bind with the identity derived from
+// the connection. On an ldapi connection, see DialURL, the OpenLDAP server
+// maps the uid and gid of the client process to
+// "gidNumber=<gid>+uidNumber=<uid>,cn=peercred,cn=external,cn=auth".
+func (l *Conn) ExternalBind() error {
+	_, err := l.ExternalBindRequest(&ExternalBindRequest{})
+	return err
+}
+
+// ExternalBindRequest performs the EXTERNAL SASL bind defined in the given request
+func (l *Conn) ExternalBindRequest(externalBindRequest *ExternalBindRequest) (*SASLBindResult, error) {
+	return l.ExternalBindRequestContext(context.Background(), externalBindRequest)
+}
+
+// ExternalBindRequestContext performs the EXTERNAL SASL bind defined in the
+// given request. If ctx is done before the server responds, the connection is
+// closed and ctx.Err() is returned.
+func (l *Conn) ExternalBindRequestContext(ctx context.Context, externalBindRequest *ExternalBindRequest) (*SASLBindResult, error) {
+	mechanism := &external{authzid: externalBindRequest.AuthzID}
+	return l.SASLBindContext(ctx, mechanism, externalBindRequest.Controls)
+}
+
+// external implements the client side of the EXTERNAL SASL mechanism
+type external struct {
+	authzid string
+}
+
+func (e *external) Name() string {
+	return "EXTERNAL"
+}
+
+func (e *external) Start() ([]byte, error) {
+	return []byte(e.authzid), nil
+}
+
+func (e *external) Next(challenge []byte) ([]byte, error) {
+	return []byte{}, nil
+}
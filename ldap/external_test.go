@@ -0,0 +1,41 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestExternalBind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	expect := func(expected string, resultCode int) {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			mechanism, credentials := saslCredentials(t, p)
+			if mechanism != "EXTERNAL" || credentials == nil || string(credentials) != expected {
+				t.Errorf("expected %s credentials %q, got %q", mechanism, expected, credentials)
+			}
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), resultCode, "")}
+		})
+	}
+	go func() {
+		expect("", LDAPResultSuccess)
+		expect("dn:cn=admin,dc=example,dc=com", LDAPResultInvalidCredentials)
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.ExternalBind(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err := conn.ExternalBindRequest(&ExternalBindRequest{AuthzID: "dn:cn=admin,dc=example,dc=com"})
+		if !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+			t.Errorf("expected invalid credentials error, got %v", err)
+		}
+	})
+}
@@ -171,7 +171,8 @@ func DecompileFilter(packet *asn1.Packet) (ret string, err error) {
 			case MatchingRuleAssertionMatchValue:
 				value = asn1.DecodeString(child.Data.Bytes())
 			case MatchingRuleAssertionDNAttributes:
-				dnAttributes = child.Value.(bool)
+				// decoded context-specific booleans only carry their data
+				dnAttributes = child.Data.Len() > 0 && child.Data.Bytes()[0] != 0
 			}
 		}
 
@@ -187,6 +188,8 @@ func DecompileFilter(packet *asn1.Packet) (ret string, err error) {
 		}
 		ret += ":="
 		ret += EscapeFilter(value)
+	default:
+		return "", NewError(ErrorFilterDecompile, fmt.Errorf("ldap: unknown filter choice %d", packet.Tag))
 	}
 
 	ret += ")"
@@ -209,23 +212,22 @@ func compileFilterSet(filter string, pos int, parent *asn1.Packet) (int, error)
 	return pos + 1, nil
 }
 
-func compileFilter(filter string, pos int) (*asn1.Packet, int, error) {
-	var (
-		packet *asn1.Packet
-		err    error
-	)
-
+func compileFilter(filter string, pos int) (packet *asn1.Packet, newPos int, err error) {
 	defer func() {
 		if r := recover(); r != nil {
+			packet = nil
 			err = NewError(ErrorFilterCompile, errors.New("ldap: error compiling filter"))
 		}
 	}()
-	newPos := pos
+	newPos = pos
 
 	currentRune, currentWidth := utf8.DecodeRuneInString(filter[newPos:])
 
 	switch currentRune {
 	case utf8.RuneError:
+		if newPos == len(filter) {
+			return nil, newPos, NewError(ErrorFilterCompile, errors.New("ldap: unexpected end of filter"))
+		}
 		return nil, 0, NewError(ErrorFilterCompile, fmt.Errorf("ldap: error reading rune at position %d", newPos))
 	case '(':
 		packet, newPos, err = compileFilter(filter, pos+currentWidth)
@@ -243,6 +245,9 @@ func compileFilter(filter string, pos int) (*asn1.Packet, int, error) {
 		packet = asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, FilterNot, nil, FilterMap[FilterNot])
 		var child *asn1.Packet
 		child, newPos, err = compileFilter(filter, pos+currentWidth)
+		if err != nil {
+			return nil, newPos, err
+		}
 		packet.AppendChild(child)
 		return packet, newPos, err
 	default:
@@ -343,6 +348,10 @@ func compileFilter(filter string, pos int) (*asn1.Packet, int, error) {
 				}
 
 			case stateReadingCondition:
+				// parentheses have to be escaped in values
+				if currentRune == '(' {
+					return nil, newPos, NewError(ErrorFilterCompile, fmt.Errorf("ldap: unescaped '(' in filter value at position %d", newPos))
+				}
 				// append to the condition
 				condition += fmt.Sprintf("%c", currentRune)
 				newPos += currentWidth
@@ -357,6 +366,9 @@ func compileFilter(filter string, pos int) (*asn1.Packet, int, error) {
 			err = NewError(ErrorFilterCompile, errors.New("ldap: error parsing filter"))
 			return packet, newPos, err
 		}
+		if err = validateFilterAttribute(packet.Tag, attribute, extensibleMatchingRule); err != nil {
+			return nil, newPos, err
+		}
 
 		switch {
 		case packet.Tag == FilterExtensibleMatch:
@@ -446,7 +458,7 @@ func escapedStringToEncodedBytes(escapedString string) (string, error) {
 			// http://tools.ietf.org/search/rfc4515
 			// \ (%x5C) is not a valid character unless it is followed by two HEX characters due to not
 			// being a member of UTF1SUBSET.
-			if i+2 > len(escapedString) {
+			if i+3 > len(escapedString) {
 				return "", NewError(ErrorFilterCompile, errors.New("ldap: missing characters for escape in filter"))
 			}
 			escByte, decodeErr := hexpac.DecodeString(escapedString[i+1 : i+3])
@@ -463,3 +475,70 @@ func escapedStringToEncodedBytes(escapedString string) (string, error) {
 	}
 	return buffer.String(), nil
 }
+
+// validateFilterAttribute checks the attribute description and matching rule
+// of a filter item, as defined in https://tools.ietf.org/html/rfc4515#section-3
+func validateFilterAttribute(tag asn1.Tag, attribute, matchingRule string) error {
+	if tag == FilterExtensibleMatch {
+		if attribute == "" && matchingRule == "" {
+			return NewError(ErrorFilterCompile, errors.New("ldap: extensible match filter without attribute nor matching rule"))
+		}
+		if matchingRule != "" && !isValidOID(matchingRule) {
+			return NewError(ErrorFilterCompile, fmt.Errorf("ldap: invalid matching rule %q in filter", matchingRule))
+		}
+		if attribute == "" {
+			return nil
+		}
+	}
+	if !isValidAttributeDescription(attribute) {
+		return NewError(ErrorFilterCompile, fmt.Errorf("ldap: invalid attribute description %q in filter", attribute))
+	}
+	return nil
+}
+
+// isValidAttributeDescription reports whether s is an attribute type followed
+// by options, as defined in https://tools.ietf.org/html/rfc4512#section-2.5
+func isValidAttributeDescription(s string) bool {
+	parts := strings.Split(s, ";")
+	if !isValidOID(parts[0]) {
+		return false
+	}
+	for _, option := range parts[1:] {
+		if option == "" || !isKeychars(option) {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidOID reports whether s is a descr or a numericoid
+func isValidOID(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		for _, number := range strings.Split(s, ".") {
+			if number == "" || (len(number) > 1 && number[0] == '0') {
+				return false
+			}
+			for i := 0; i < len(number); i++ {
+				if number[i] < '0' || number[i] > '9' {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return isKeychars(s) && (s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z')
+}
+
+// isKeychars reports whether s only contains letters, digits and hyphens
+func isKeychars(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
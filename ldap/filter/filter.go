@@ -0,0 +1,259 @@
+// Package filter builds LDAP search filters, as defined in
+// https://tools.ietf.org/html/rfc4515, without string formatting.
+//
+// Values are given unescaped: they are escaped when rendering the filter as
+// a string, and sent as is when encoding the filter in BER.
+//
+//	f := filter.And(filter.Eq("uid", username), filter.Present("mail"))
+//	searchRequest := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree,
+//		ldap.NeverDerefAliases, 0, 0, false, f.String(), nil, nil)
+package filter
+
+import (
+	"bytes"
+
+	"github.com/gostores/checking/ldap"
+	"github.com/gostores/encoding/asn1"
+)
+
+// Filter is a search filter
+type Filter interface {
+	// String returns the string representation of the filter
+	String() string
+	// Encode returns the BER encoding of the filter
+	Encode() *asn1.Packet
+}
+
+// set is an And or Or filter
+type set struct {
+	tag     asn1.Tag
+	filters []Filter
+}
+
+// And returns a filter matching the entries matched by all the given filters
+func And(filters ...Filter) Filter {
+	return &set{tag: ldap.FilterAnd, filters: filters}
+}
+
+// Or returns a filter matching the entries matched by any of the given filters
+func Or(filters ...Filter) Filter {
+	return &set{tag: ldap.FilterOr, filters: filters}
+}
+
+func (s *set) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteByte('(')
+	if s.tag == ldap.FilterAnd {
+		buffer.WriteByte('&')
+	} else {
+		buffer.WriteByte('|')
+	}
+	for _, f := range s.filters {
+		buffer.WriteString(f.String())
+	}
+	buffer.WriteByte(')')
+	return buffer.String()
+}
+
+func (s *set) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, s.tag, nil, ldap.FilterMap[uint64(s.tag)])
+	for _, f := range s.filters {
+		packet.AppendChild(f.Encode())
+	}
+	return packet
+}
+
+// not is a Not filter
+type not struct {
+	filter Filter
+}
+
+// Not returns a filter matching the entries not matched by the given filter
+func Not(filter Filter) Filter {
+	return &not{filter: filter}
+}
+
+func (n *not) String() string {
+	return "(!" + n.filter.String() + ")"
+}
+
+func (n *not) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, ldap.FilterNot, nil, ldap.FilterMap[ldap.FilterNot])
+	packet.AppendChild(n.filter.Encode())
+	return packet
+}
+
+// assertion is an attribute value assertion filter
+type assertion struct {
+	tag       asn1.Tag
+	attribute string
+	value     string
+}
+
+// Eq returns a filter matching the entries with an attribute value equal to value
+func Eq(attribute, value string) Filter {
+	return &assertion{tag: ldap.FilterEqualityMatch, attribute: attribute, value: value}
+}
+
+// Ge returns a filter matching the entries with an attribute value greater
+// than or equal to value
+func Ge(attribute, value string) Filter {
+	return &assertion{tag: ldap.FilterGreaterOrEqual, attribute: attribute, value: value}
+}
+
+// Le returns a filter matching the entries with an attribute value less than
+// or equal to value
+func Le(attribute, value string) Filter {
+	return &assertion{tag: ldap.FilterLessOrEqual, attribute: attribute, value: value}
+}
+
+// Approx returns a filter matching the entries with an attribute value
+// approximately equal to value
+func Approx(attribute, value string) Filter {
+	return &assertion{tag: ldap.FilterApproxMatch, attribute: attribute, value: value}
+}
+
+func (a *assertion) String() string {
+	var operator string
+	switch a.tag {
+	case ldap.FilterEqualityMatch:
+		operator = "="
+	case ldap.FilterGreaterOrEqual:
+		operator = ">="
+	case ldap.FilterLessOrEqual:
+		operator = "<="
+	case ldap.FilterApproxMatch:
+		operator = "~="
+	}
+	return "(" + a.attribute + operator + ldap.EscapeFilter(a.value) + ")"
+}
+
+func (a *assertion) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, a.tag, nil, ldap.FilterMap[uint64(a.tag)])
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, a.attribute, "Attribute"))
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, a.value, "Condition"))
+	return packet
+}
+
+// present is a Present filter
+type present struct {
+	attribute string
+}
+
+// Present returns a filter matching the entries having the attribute
+func Present(attribute string) Filter {
+	return &present{attribute: attribute}
+}
+
+func (p *present) String() string {
+	return "(" + p.attribute + "=*)"
+}
+
+func (p *present) Encode() *asn1.Packet {
+	return asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.FilterPresent, p.attribute, ldap.FilterMap[ldap.FilterPresent])
+}
+
+// substrings is a Substrings filter
+type substrings struct {
+	attribute string
+	initial   string
+	any       []string
+	final     string
+}
+
+// Substrings returns a filter matching the entries with an attribute value
+// starting with initial, containing the any values in order, and ending with
+// final. Empty initial and final values are omitted.
+func Substrings(attribute, initial string, any []string, final string) Filter {
+	return &substrings{attribute: attribute, initial: initial, any: any, final: final}
+}
+
+// Prefix returns a filter matching the entries with an attribute value starting with value
+func Prefix(attribute, value string) Filter {
+	return Substrings(attribute, value, nil, "")
+}
+
+// Suffix returns a filter matching the entries with an attribute value ending with value
+func Suffix(attribute, value string) Filter {
+	return Substrings(attribute, "", nil, value)
+}
+
+// Contains returns a filter matching the entries with an attribute value containing value
+func Contains(attribute, value string) Filter {
+	return Substrings(attribute, "", []string{value}, "")
+}
+
+func (s *substrings) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("(" + s.attribute + "=")
+	buffer.WriteString(ldap.EscapeFilter(s.initial))
+	buffer.WriteByte('*')
+	for _, value := range s.any {
+		buffer.WriteString(ldap.EscapeFilter(value))
+		buffer.WriteByte('*')
+	}
+	buffer.WriteString(ldap.EscapeFilter(s.final))
+	buffer.WriteByte(')')
+	return buffer.String()
+}
+
+func (s *substrings) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, ldap.FilterSubstrings, nil, ldap.FilterMap[ldap.FilterSubstrings])
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, s.attribute, "Attribute"))
+	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Substrings")
+	if s.initial != "" {
+		seq.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.FilterSubstringsInitial, s.initial, ldap.FilterSubstringsMap[ldap.FilterSubstringsInitial]))
+	}
+	for _, value := range s.any {
+		seq.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.FilterSubstringsAny, value, ldap.FilterSubstringsMap[ldap.FilterSubstringsAny]))
+	}
+	if s.final != "" {
+		seq.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.FilterSubstringsFinal, s.final, ldap.FilterSubstringsMap[ldap.FilterSubstringsFinal]))
+	}
+	packet.AppendChild(seq)
+	return packet
+}
+
+// extensible is an Extensible Match filter
+type extensible struct {
+	attribute    string
+	matchingRule string
+	value        string
+	dnAttributes bool
+}
+
+// Extensible returns a filter matching the entries with an attribute value
+// matching value with the given matching rule. Either attribute or
+// matchingRule may be empty. If dnAttributes is set, the attributes of the
+// entry DN are matched as well.
+func Extensible(attribute, matchingRule, value string, dnAttributes bool) Filter {
+	return &extensible{attribute: attribute, matchingRule: matchingRule, value: value, dnAttributes: dnAttributes}
+}
+
+func (e *extensible) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("(" + e.attribute)
+	if e.dnAttributes {
+		buffer.WriteString(":dn")
+	}
+	if e.matchingRule != "" {
+		buffer.WriteString(":" + e.matchingRule)
+	}
+	buffer.WriteString(":=" + ldap.EscapeFilter(e.value) + ")")
+	return buffer.String()
+}
+
+func (e *extensible) Encode() *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, ldap.FilterExtensibleMatch, nil, ldap.FilterMap[ldap.FilterExtensibleMatch])
+	if e.matchingRule != "" {
+		packet.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.MatchingRuleAssertionMatchingRule, e.matchingRule, ldap.MatchingRuleAssertionMap[ldap.MatchingRuleAssertionMatchingRule]))
+	}
+	if e.attribute != "" {
+		packet.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.MatchingRuleAssertionType, e.attribute, ldap.MatchingRuleAssertionMap[ldap.MatchingRuleAssertionType]))
+	}
+	packet.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, ldap.MatchingRuleAssertionMatchValue, e.value, ldap.MatchingRuleAssertionMap[ldap.MatchingRuleAssertionMatchValue]))
+	if e.dnAttributes {
+		packet.AppendChild(asn1.NewBoolean(asn1.ClassContext, asn1.TypePrimitive, ldap.MatchingRuleAssertionDNAttributes, e.dnAttributes, ldap.MatchingRuleAssertionMap[ldap.MatchingRuleAssertionDNAttributes]))
+	}
+	return packet
+}
@@ -0,0 +1,45 @@
+package filter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gostores/checking/ldap"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		filter   Filter
+		expected string
+	}{
+		{And(Eq("uid", "jdoe"), Present("mail")), "(&(uid=jdoe)(mail=*))"},
+		{Or(Eq("cn", "a*b"), Not(Eq("cn", "(c)"))), `(|(cn=a\2ab)(!(cn=\28c\29)))`},
+		{And(), "(&)"},
+		{Ge("uidNumber", "1000"), "(uidNumber>=1000)"},
+		{Le("uidNumber", "2000"), "(uidNumber<=2000)"},
+		{Approx("sn", "Miller"), "(sn~=Miller)"},
+		{Prefix("cn", "Jo"), "(cn=Jo*)"},
+		{Suffix("cn", "hn"), "(cn=*hn)"},
+		{Contains("cn", "oh"), "(cn=*oh*)"},
+		{Substrings("cn", "J", []string{"o", "h"}, "n"), "(cn=J*o*h*n)"},
+		{Eq("cn", "中文"), `(cn=\e4\b8\ad\e6\96\87)`},
+		{Extensible("memberOf", "1.2.840.113556.1.4.1941", "cn=admins,dc=example,dc=com", false), "(memberOf:1.2.840.113556.1.4.1941:=cn=admins,dc=example,dc=com)"},
+		{Extensible("", "2.4.6.8.10", "Dino", true), "(:dn:2.4.6.8.10:=Dino)"},
+		{Extensible("o", "", "Ace Industry", true), "(o:dn:=Ace Industry)"},
+	}
+
+	for _, test := range tests {
+		if actual := test.filter.String(); actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+			continue
+		}
+		compiled, err := ldap.CompileFilter(test.expected)
+		if err != nil {
+			t.Errorf("%s: %s", test.expected, err)
+			continue
+		}
+		if !bytes.Equal(test.filter.Encode().Bytes(), compiled.Bytes()) {
+			t.Errorf("%s: encoding differs from the compiled filter", test.expected)
+		}
+	}
+}
@@ -183,6 +183,28 @@ var testFilters = []compileTest{
 		expectedFilter: `(memberOf:1.2.840.113556.1.4.1941:=CN=User1,OU=blah,DC=mydomain,DC=net)`,
 		expectedType:   ldap.FilterExtensibleMatch,
 	},
+	// attribute options and numeric OIDs
+	compileTest{
+		filterStr:      `(cn;lang-en=Jensen)`,
+		expectedFilter: `(cn;lang-en=Jensen)`,
+		expectedType:   ldap.FilterEqualityMatch,
+	},
+	compileTest{
+		filterStr:      `(2.5.4.3=Jensen)`,
+		expectedFilter: `(2.5.4.3=Jensen)`,
+		expectedType:   ldap.FilterEqualityMatch,
+	},
+	compileTest{
+		filterStr:      `(cn=Jensen\29)`,
+		expectedFilter: `(cn=Jensen\29)`,
+		expectedType:   ldap.FilterEqualityMatch,
+	},
+	compileTest{
+		filterStr:      `(c n=Jensen)`,
+		expectedFilter: ``,
+		expectedType:   0,
+		expectedErr:    "invalid attribute description",
+	},
 
 	// compileTest{ filterStr: "()", filterType: FilterExtensibleMatch },
 }
@@ -190,6 +212,16 @@ var testFilters = []compileTest{
 var testInvalidFilters = []string{
 	`(objectGUID=\zz)`,
 	`(objectGUID=\a)`,
+	`(objectGUID=\)`,
+	`(=foo)`,
+	`(:=foo)`,
+	`(cn:1.2.x:=foo)`,
+	`(cn;=foo)`,
+	`(1cn=foo)`,
+	`(cn=a(b)`,
+	`(!(=foo))`,
+	`(&(cn=foo)(=bar))`,
+	`(`,
 }
 
 func TestFilter(t *testing.T) {
@@ -209,6 +241,14 @@ func TestFilter(t *testing.T) {
 			} else if i.expectedFilter != o {
 				t.Errorf("%q expected, got %q", i.expectedFilter, o)
 			}
+
+			// filters received from the wire only carry their encoded data
+			o, err = ldap.DecompileFilter(asn1.DecodePacket(filter.Bytes()))
+			if err != nil {
+				t.Errorf("Problem decompiling decoded %s - %s", i.filterStr, err.Error())
+			} else if i.expectedFilter != o {
+				t.Errorf("%q expected from decoded filter, got %q", i.expectedFilter, o)
+			}
 		}
 	}
 }
@@ -252,3 +292,19 @@ func BenchmarkFilterDecompile(b *testing.B) {
 		ldap.DecompileFilter(filters[i%maxIdx])
 	}
 }
+
+func TestDecompileInvalidFilter(t *testing.T) {
+	packets := []*asn1.Packet{
+		// unknown filter choice
+		asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 12, "cn", "Unknown"),
+		// not without a filter
+		asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, ldap.FilterNot, nil, "Not"),
+		// equality match without a value
+		asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, ldap.FilterEqualityMatch, nil, "Equality Match"),
+	}
+	for _, packet := range packets {
+		if filter, err := ldap.DecompileFilter(packet); err == nil {
+			t.Errorf("expected error decompiling %s, got %q", packet.Description, filter)
+		}
+	}
+}
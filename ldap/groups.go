@@ -0,0 +1,93 @@
+// File contains nested group membership resolution
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MatchingRuleInChainOID is the Active Directory matching rule following the
+// chain of DN values of an attribute, such as member, to its end
+const MatchingRuleInChainOID = "1.2.840.113556.1.4.1941"
+
+// groupsPagingSize is the page size of the searches for groups, below the
+// MaxPageSize limit of Active Directory
+const groupsPagingSize = 500
+
+// NestedGroups returns the DNs of the groups under baseDN which memberDN is a
+// member of, directly or through other groups, according to their member
+// attribute. The groups are found with the LDAP_MATCHING_RULE_IN_CHAIN
+// matching rule when the server supports it, or by expanding the groups of
+// every group otherwise, each group being searched once even when
+// memberships form a cycle.
+func NestedGroups(conn Client, baseDN, memberDN string) ([]string, error) {
+	return NestedGroupsContext(context.Background(), conn, baseDN, memberDN)
+}
+
+// NestedGroupsContext is like NestedGroups, but abandons the current search and
+// returns ctx.Err() if ctx is done before the groups are resolved.
+func NestedGroupsContext(ctx context.Context, conn Client, baseDN, memberDN string) ([]string, error) {
+	filter := fmt.Sprintf("(member:%s:=%s)", MatchingRuleInChainOID, EscapeFilter(memberDN))
+	groups, err := searchGroups(ctx, conn, baseDN, filter)
+	switch {
+	case err == nil && len(groups) > 0:
+		return groups, nil
+	case err != nil && !IsErrorWithCode(err, LDAPResultInappropriateMatching, LDAPResultProtocolError, LDAPResultUnwillingToPerform, LDAPResultUnavailableCriticalExtension):
+		return nil, err
+	}
+
+	// servers not supporting the matching rule evaluate the filter as
+	// undefined, which returns no groups as well as no membership does
+	return expandGroups(ctx, conn, baseDN, memberDN)
+}
+
+// expandGroups returns the groups of memberDN by searching the groups of
+// every group found, breadth first
+func expandGroups(ctx context.Context, conn Client, baseDN, memberDN string) ([]string, error) {
+	visited := map[string]bool{normalizeDN(memberDN): true}
+	var groups []string
+	queue := []string{memberDN}
+	for len(queue) > 0 {
+		dn := queue[0]
+		queue = queue[1:]
+		direct, err := searchGroups(ctx, conn, baseDN, fmt.Sprintf("(member=%s)", EscapeFilter(dn)))
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range direct {
+			key := normalizeDN(group)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			groups = append(groups, group)
+			queue = append(queue, group)
+		}
+	}
+	return groups, nil
+}
+
+// searchGroups returns the DNs of the entries under baseDN matching filter
+func searchGroups(ctx context.Context, conn Client, baseDN, filter string) ([]string, error) {
+	searchRequest := NewSearchRequest(baseDN, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, filter, []string{"1.1"}, nil)
+	result, err := conn.SearchWithPagingContext(ctx, searchRequest, groupsPagingSize)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// normalizeDN returns a form of the DN comparing equal for equivalent DNs,
+// ignoring case and spaces
+func normalizeDN(dn string) string {
+	if parsed, err := ParseDN(dn); err == nil {
+		return strings.ToLower(parsed.String())
+	}
+	return strings.ToLower(dn)
+}
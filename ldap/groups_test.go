@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// groupsClient answers the searches for groups from a map of the groups of
+// every member
+type groupsClient struct {
+	Client
+	memberOf map[string][]string
+	inChain  error
+	filters  []string
+}
+
+func (c *groupsClient) SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	c.filters = append(c.filters, searchRequest.Filter)
+	result := &SearchResult{}
+	if strings.Contains(searchRequest.Filter, MatchingRuleInChainOID) {
+		return result, c.inChain
+	}
+	member := strings.TrimSuffix(strings.TrimPrefix(searchRequest.Filter, "(member="), ")")
+	for _, group := range c.memberOf[member] {
+		result.Entries = append(result.Entries, &Entry{DN: group})
+	}
+	return result, nil
+}
+
+func TestNestedGroupsExpansion(t *testing.T) {
+	client := &groupsClient{
+		memberOf: map[string][]string{
+			"uid=jdoe,ou=people,dc=example,dc=com": {"cn=dev,ou=groups,dc=example,dc=com"},
+			"cn=dev,ou=groups,dc=example,dc=com":   {"cn=staff,ou=groups,dc=example,dc=com"},
+			// a cycle back to the first group, with another case
+			"cn=staff,ou=groups,dc=example,dc=com": {"CN=Dev,ou=groups,dc=example,dc=com", "cn=all,ou=groups,dc=example,dc=com"},
+		},
+		inChain: NewError(LDAPResultInappropriateMatching, errors.New("unknown matching rule")),
+	}
+	groups, err := NestedGroups(client, "dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cn=dev,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com", "cn=all,ou=groups,dc=example,dc=com"}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("got groups %v, expected %v", groups, expected)
+	}
+	if len(client.filters) != 5 {
+		t.Errorf("expected one search by member, got %v", client.filters)
+	}
+}
+
+func TestNestedGroupsInChain(t *testing.T) {
+	client := &inChainClient{groups: []string{"cn=staff,ou=groups,dc=example,dc=com", "cn=dev,ou=groups,dc=example,dc=com"}}
+	groups, err := NestedGroups(client, "dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(groups)
+	if !reflect.DeepEqual(groups, []string{"cn=dev,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"}) {
+		t.Errorf("unexpected groups %v", groups)
+	}
+	expected := "(member:1.2.840.113556.1.4.1941:=uid=jdoe,ou=people,dc=example,dc=com)"
+	if len(client.filters) != 1 || client.filters[0] != expected {
+		t.Errorf("got filters %v, expected %q", client.filters, expected)
+	}
+
+	client.err = NewError(LDAPResultBusy, errors.New("busy"))
+	if _, err := NestedGroups(client, "dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com"); !IsErrorWithCode(err, LDAPResultBusy) {
+		t.Errorf("expected busy error, got %v", err)
+	}
+}
+
+// inChainClient answers every search with the same groups
+type inChainClient struct {
+	Client
+	groups  []string
+	err     error
+	filters []string
+}
+
+func (c *inChainClient) SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	c.filters = append(c.filters, searchRequest.Filter)
+	if c.err != nil {
+		return nil, c.err
+	}
+	result := &SearchResult{}
+	for _, group := range c.groups {
+		result.Entries = append(result.Entries, &Entry{DN: group})
+	}
+	return result, nil
+}
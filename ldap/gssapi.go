@@ -0,0 +1,166 @@
+// File contains the GSSAPI SASL mechanism
+//
+// https://tools.ietf.org/html/rfc4752
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// gssapiNoSecurityLayer is the only security layer supported by the GSSAPI
+// mechanism, which relies on TLS to protect the connection
+const gssapiNoSecurityLayer = 0x01
+
+// GSSAPIClient establishes a GSSAPI security context, usually with Kerberos.
+// The library does not implement Kerberos itself: callers provide an
+// implementation backed by the Kerberos library of their choice.
+type GSSAPIClient interface {
+	// InitSecContext initiates or continues the establishment of a security
+	// context with the given target service principal. The token is nil on
+	// the first call, and the token sent by the server on subsequent calls.
+	// It returns the token to send to the server, and whether further tokens
+	// from the server are needed to complete the security context.
+	InitSecContext(target string, token []byte) (outputToken []byte, needContinue bool, err error)
+	// Unwrap verifies and returns the message wrapped in the given token
+	Unwrap(token []byte) ([]byte, error)
+	// Wrap returns a token protecting the integrity of the given message
+	Wrap(message []byte) ([]byte, error)
+	// DeleteSecContext releases the security context
+	DeleteSecContext() error
+}
+
+// GSSAPIChannelBindingClient is implemented by GSSAPI clients supporting
+// channel binding, which servers such as Active Directory may require on TLS
+// connections
+type GSSAPIChannelBindingClient interface {
+	GSSAPIClient
+	// InitSecContextWithChannelBinding is like InitSecContext, but binds the
+	// security context to the given channel. The application data of the
+	// channel bindings is the binding type, a colon and the binding data, as
+	// described in https://tools.ietf.org/html/rfc5929#section-3.
+	InitSecContextWithChannelBinding(target string, token []byte, channelBinding *ChannelBinding) (outputToken []byte, needContinue bool, err error)
+}
+
+// GSSAPIBindRequest represents a GSSAPI SASL bind
+type GSSAPIBindRequest struct {
+	// ServicePrincipalName is the principal of the LDAP server, usually
+	// "ldap/" followed by the host name of the server
+	ServicePrincipalName string
+	// AuthzID is the optional authorization identity to assume
+	AuthzID string
+	// ChannelBinding, if set, binds the security context to the TLS channel.
+	// It is usually obtained with Conn.ChannelBinding, and requires a client
+	// implementing GSSAPIChannelBindingClient.
+	ChannelBinding *ChannelBinding
+	// Controls are optional controls to send with the bind request
+	Controls []Control
+}
+
+// GSSAPIBind performs a GSSAPI SASL bind with the given service principal
+// name and authorization identity, using client to establish the security context
+func (l *Conn) GSSAPIBind(client GSSAPIClient, servicePrincipal, authzid string) error {
+	req := &GSSAPIBindRequest{
+		ServicePrincipalName: servicePrincipal,
+		AuthzID:              authzid,
+	}
+	_, err := l.GSSAPIBindRequest(client, req)
+	return err
+}
+
+// GSSAPIBindRequest performs the GSSAPI SASL bind defined in the given request
+func (l *Conn) GSSAPIBindRequest(client GSSAPIClient, gssapiBindRequest *GSSAPIBindRequest) (*SASLBindResult, error) {
+	return l.GSSAPIBindRequestContext(context.Background(), client, gssapiBindRequest)
+}
+
+// GSSAPIBindRequestContext performs the GSSAPI SASL bind defined in the given
+// request. The security context is released once the bind completes. If ctx is
+// done before the server responds, the connection is closed and ctx.Err() is
+// returned.
+func (l *Conn) GSSAPIBindRequestContext(ctx context.Context, client GSSAPIClient, gssapiBindRequest *GSSAPIBindRequest) (*SASLBindResult, error) {
+	mechanism := &gssapi{
+		client:         client,
+		target:         gssapiBindRequest.ServicePrincipalName,
+		authzid:        gssapiBindRequest.AuthzID,
+		channelBinding: gssapiBindRequest.ChannelBinding,
+	}
+	if mechanism.channelBinding != nil {
+		if _, ok := client.(GSSAPIChannelBindingClient); !ok {
+			return nil, errors.New("ldap: GSSAPI client does not support channel binding")
+		}
+	}
+	result, err := l.SASLBindContext(ctx, mechanism, gssapiBindRequest.Controls)
+	if deleteErr := client.DeleteSecContext(); err == nil && deleteErr != nil {
+		return result, deleteErr
+	}
+	return result, err
+}
+
+// gssapi implements the client side of the GSSAPI SASL mechanism
+type gssapi struct {
+	client         GSSAPIClient
+	target         string
+	authzid        string
+	channelBinding *ChannelBinding
+
+	// established is set once the security context is complete
+	established bool
+	// negotiated is set once the security layer has been negotiated
+	negotiated bool
+}
+
+func (g *gssapi) Name() string {
+	return "GSSAPI"
+}
+
+func (g *gssapi) Start() ([]byte, error) {
+	return g.initSecContext(nil)
+}
+
+func (g *gssapi) Next(challenge []byte) ([]byte, error) {
+	if !g.established {
+		return g.initSecContext(challenge)
+	}
+	if g.negotiated {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: unexpected GSSAPI challenge after security layer negotiation"))
+	}
+
+	// https://tools.ietf.org/html/rfc4752#section-3.1
+	message, err := g.client.Unwrap(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) != 4 {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid GSSAPI security layer message of length %d", len(message)))
+	}
+	if message[0]&gssapiNoSecurityLayer == 0 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: server requires a GSSAPI security layer"))
+	}
+	g.negotiated = true
+
+	response := append([]byte{gssapiNoSecurityLayer, 0, 0, 0}, g.authzid...)
+	return g.client.Wrap(response)
+}
+
+// initSecContext continues the establishment of the security context with the
+// given server token
+func (g *gssapi) initSecContext(token []byte) ([]byte, error) {
+	var outputToken []byte
+	var needContinue bool
+	var err error
+	if g.channelBinding != nil {
+		outputToken, needContinue, err = g.client.(GSSAPIChannelBindingClient).InitSecContextWithChannelBinding(g.target, token, g.channelBinding)
+	} else {
+		outputToken, needContinue, err = g.client.InitSecContext(g.target, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.established = !needContinue
+	if outputToken == nil {
+		outputToken = []byte{}
+	}
+	return outputToken, nil
+}
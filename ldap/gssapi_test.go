@@ -0,0 +1,124 @@
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// fakeGSSAPIClient establishes a security context in two rounds and wraps
+// messages by prefixing them
+type fakeGSSAPIClient struct {
+	rounds  int
+	deleted bool
+}
+
+func (c *fakeGSSAPIClient) InitSecContext(target string, token []byte) ([]byte, bool, error) {
+	if target != "ldap/ldap.example.com" {
+		return nil, false, errors.New("unexpected target " + target)
+	}
+	c.rounds++
+	switch c.rounds {
+	case 1:
+		return []byte("ap-req"), true, nil
+	case 2:
+		if string(token) != "ap-rep" {
+			return nil, false, errors.New("unexpected token " + string(token))
+		}
+		return nil, false, nil
+	}
+	return nil, false, errors.New("security context already established")
+}
+
+func (c *fakeGSSAPIClient) Unwrap(token []byte) ([]byte, error) {
+	if !bytes.HasPrefix(token, []byte("wrap:")) {
+		return nil, errors.New("invalid token")
+	}
+	return token[5:], nil
+}
+
+func (c *fakeGSSAPIClient) Wrap(message []byte) ([]byte, error) {
+	return append([]byte("wrap:"), message...), nil
+}
+
+func (c *fakeGSSAPIClient) DeleteSecContext() error {
+	c.deleted = true
+	return nil
+}
+
+func TestGSSAPIBind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	expect := func(expected string, resultCode int, serverCredentials string) {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if mechanism, credentials := saslCredentials(t, p); mechanism != "GSSAPI" || string(credentials) != expected {
+				t.Errorf("expected %s credentials %q, got %q", mechanism, expected, credentials)
+			}
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), resultCode, serverCredentials)}
+		})
+	}
+	go func() {
+		expect("ap-req", LDAPResultSaslBindInProgress, "ap-rep")
+		expect("", LDAPResultSaslBindInProgress, "wrap:\x07\x00\x10\x00")
+		expect("wrap:\x01\x00\x00\x00dn:cn=admin", LDAPResultSuccess, "")
+	}()
+
+	client := &fakeGSSAPIClient{}
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.GSSAPIBind(client, "ldap/ldap.example.com", "dn:cn=admin"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	if !client.deleted {
+		t.Errorf("expected security context to be deleted")
+	}
+}
+
+func TestGSSAPISecurityLayerRequired(t *testing.T) {
+	mechanism := &gssapi{client: &fakeGSSAPIClient{rounds: 1}, target: "ldap/ldap.example.com"}
+	if _, err := mechanism.Next([]byte("ap-rep")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mechanism.Next([]byte("wrap:\x04\x00\x10\x00")); err == nil {
+		t.Errorf("expected error when the server requires a security layer")
+	}
+}
+
+// fakeGSSAPIChannelBindingClient records the channel binding of the security context
+type fakeGSSAPIChannelBindingClient struct {
+	fakeGSSAPIClient
+	channelBinding *ChannelBinding
+}
+
+func (c *fakeGSSAPIChannelBindingClient) InitSecContextWithChannelBinding(target string, token []byte, channelBinding *ChannelBinding) ([]byte, bool, error) {
+	c.channelBinding = channelBinding
+	return c.InitSecContext(target, token)
+}
+
+func TestGSSAPIChannelBinding(t *testing.T) {
+	channelBinding := &ChannelBinding{Type: ChannelBindingTLSServerEndPoint, Data: []byte("hash")}
+
+	mechanism := &gssapi{client: &fakeGSSAPIChannelBindingClient{}, target: "ldap/ldap.example.com", channelBinding: channelBinding}
+	if _, err := mechanism.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if client := mechanism.client.(*fakeGSSAPIChannelBindingClient); client.channelBinding != channelBinding {
+		t.Errorf("expected channel binding to be passed to the client")
+	}
+
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+	conn := NewConn(ptc, false)
+	req := &GSSAPIBindRequest{ServicePrincipalName: "ldap/ldap.example.com", ChannelBinding: channelBinding}
+	if _, err := conn.GSSAPIBindRequest(&fakeGSSAPIClient{}, req); err == nil {
+		t.Errorf("expected error for a client without channel binding support")
+	}
+}
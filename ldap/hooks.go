@@ -0,0 +1,96 @@
+// File contains the hooks receiving the raw messages of the connections
+
+package ldap
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// PacketHook is called with every message sent or received on a connection,
+// as the BER encoding read from or written to the network and the decoded
+// packet. It is called from the goroutines reading and writing the connection,
+// so it must not block, and must not modify data or packet.
+type PacketHook func(data []byte, packet *asn1.Packet)
+
+// OnSend sets the hook called with every message before it is sent, e.g. to
+// audit or hexdump the requests. Messages are not passed to a hook if hook is
+// nil, which is the default.
+func (l *Conn) OnSend(hook PacketHook) {
+	l.sendHook.Store(hook)
+}
+
+// OnReceive sets the hook called with every message received, as read from
+// the network, before it is delivered to its operation. Messages are not
+// passed to a hook if hook is nil, which is the default.
+func (l *Conn) OnReceive(hook PacketHook) {
+	l.receiveHook.Store(hook)
+}
+
+func (l *Conn) loadSendHook() PacketHook {
+	hook, _ := l.sendHook.Load().(PacketHook)
+	return hook
+}
+
+func (l *Conn) loadReceiveHook() PacketHook {
+	hook, _ := l.receiveHook.Load().(PacketHook)
+	return hook
+}
+
+// OnSend sets the hook called with every message sent on the connection and
+// on the connections replacing it
+func (r *ReconnectingConn) OnSend(hook PacketHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sendHook = hook
+	if r.conn != nil {
+		r.conn.OnSend(hook)
+	}
+}
+
+// OnReceive sets the hook called with every message received on the
+// connection and on the connections replacing it
+func (r *ReconnectingConn) OnReceive(hook PacketHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.receiveHook = hook
+	if r.conn != nil {
+		r.conn.OnReceive(hook)
+	}
+}
+
+// readPacket reads the next message from the connection, passing it to the
+// receive hook if one is set, once its length is checked against the maximum
+// message size. It returns the number of bytes read, even if an
+// error occurred.
+func (l *Conn) readPacket() (*asn1.Packet, int, error) {
+	hook := l.loadReceiveHook()
+	var data bytes.Buffer
+	counter := &countingReader{Reader: l.conn}
+	maxSize := l.loadMaxMessageSize()
+	header, length, err := readMessageHeader(counter, maxSize)
+	if err != nil {
+		return nil, counter.n, err
+	}
+	var reader io.Reader = counter
+	if hook != nil {
+		data.Write(header)
+		reader = io.TeeReader(counter, &data)
+	}
+	var packet *asn1.Packet
+	switch {
+	case l.valueStreams.active():
+		packet, err = l.valueStreams.readPacket(reader, header, length, maxSize)
+	case hook == nil && !bool(l.Debug) && !packetTrees:
+		packet, err = readMessage(reader, header, length)
+	default:
+		// the hooks and the debug output get the whole tree of packets
+		packet, err = asn1.ReadPacket(io.MultiReader(bytes.NewReader(header), reader))
+	}
+	if err == nil && hook != nil {
+		hook(data.Bytes(), packet)
+	}
+	return packet, counter.n, err
+}
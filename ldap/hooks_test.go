@@ -0,0 +1,70 @@
+package ldap
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestPacketHooks(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var mu sync.Mutex
+	var sent, received [][]byte
+	conn.OnSend(func(data []byte, packet *asn1.Packet) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !bytes.Equal(data, packet.Bytes()) {
+			t.Errorf("sent data does not match the packet")
+		}
+		sent = append(sent, data)
+	})
+	conn.OnReceive(func(data []byte, packet *asn1.Packet) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !bytes.Equal(data, packet.Bytes()) {
+			t.Errorf("received data does not match the packet")
+		}
+		received = append(received, data)
+	})
+
+	var response []byte
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		if p.Children[1].Tag != ApplicationDelRequest {
+			t.Errorf("expected a delete request, got tag %d", p.Children[1].Tag)
+		}
+		packet := newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")
+		response = packet.Bytes()
+		return []*asn1.Packet{packet}
+	})
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 message sent, got %d", len(sent))
+	}
+	request, err := asn1.ReadPacket(bytes.NewReader(sent[0]))
+	if err != nil {
+		t.Fatalf("could not decode the sent message: %s", err)
+	}
+	if request.Children[1].Tag != ApplicationDelRequest {
+		t.Errorf("expected a delete request to be sent, got tag %d", request.Children[1].Tag)
+	}
+	if len(received) != 1 || !bytes.Equal(received[0], response) {
+		t.Errorf("expected the response to be received as sent, got %x", received)
+	}
+}
@@ -0,0 +1,68 @@
+// File contains the interceptors wrapping the operations of a connection
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+)
+
+// OpFunc performs an operation. The request is a *SearchRequest for a search,
+// or one of the requests performed with Do, and the result is then a
+// *SearchResult or a *Response.
+type OpFunc func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Interceptor wraps the operations of a connection, e.g. to rewrite requests,
+// add controls, retry or record operations without modifying the call sites:
+//
+//	conn.Use(func(next ldap.OpFunc) ldap.OpFunc {
+//		return func(ctx context.Context, request interface{}) (interface{}, error) {
+//			start := time.Now()
+//			result, err := next(ctx, request)
+//			log.Printf("%T took %s", request, time.Since(start))
+//			return result, err
+//		}
+//	})
+type Interceptor func(next OpFunc) OpFunc
+
+// Use adds interceptors wrapping the operations performed with Do and Search,
+// and the operations built on them. The first interceptor added is the
+// outermost one.
+func (l *Conn) Use(interceptors ...Interceptor) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	chain, _ := l.interceptors.Load().([]Interceptor)
+	l.interceptors.Store(append(chain[:len(chain):len(chain)], interceptors...))
+}
+
+// intercept performs the operation through the interceptors of the connection
+func (l *Conn) intercept(ctx context.Context, request interface{}) (interface{}, error) {
+	chain, _ := l.interceptors.Load().([]Interceptor)
+	op := l.perform
+	for i := len(chain) - 1; i >= 0; i-- {
+		op = chain[i](op)
+	}
+	return op(ctx, request)
+}
+
+// perform performs the operation once it went through the interceptors
+func (l *Conn) perform(ctx context.Context, request interface{}) (interface{}, error) {
+	switch r := request.(type) {
+	case *SearchRequest:
+		return l.searchContext(ctx, r)
+	case Request:
+		return l.doContext(ctx, r)
+	}
+	return nil, NewError(ErrorUnexpectedMessage, fmt.Errorf("ldap: unsupported request %T", request))
+}
+
+// Use adds interceptors wrapping the operations of the connection and of the
+// connections replacing it
+func (r *ReconnectingConn) Use(interceptors ...Interceptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interceptors = append(r.interceptors, interceptors...)
+	if r.conn != nil {
+		r.conn.Use(interceptors...)
+	}
+}
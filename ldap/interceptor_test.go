@@ -0,0 +1,72 @@
+package ldap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestInterceptors(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(next OpFunc) OpFunc {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				calls = append(calls, name)
+				return next(ctx, request)
+			}
+		}
+	}
+	rewrite := func(next OpFunc) OpFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			switch r := request.(type) {
+			case *DelRequest:
+				request = &DelRequest{DN: r.DN + ",dc=example,dc=com"}
+			case *ModifyDNRequest:
+				request = "invalid"
+			}
+			return next(ctx, request)
+		}
+	}
+	conn.Use(record("outer"), record("inner"))
+	conn.Use(rewrite)
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if dn := string(p.Children[1].Data.Bytes()); dn != "cn=a,dc=example,dc=com" {
+				t.Errorf("expected the rewritten DN, got %q", dn)
+			}
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationSearchResultDone, LDAPResultSuccess, "")}
+		})
+	}()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := conn.Del(NewDelRequest("cn=a", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		if result, err := conn.Search(searchRequest); err != nil || result == nil {
+			t.Fatalf("unexpected result %v, error %v", result, err)
+		}
+		if err := conn.ModifyDN(NewModifyDNRequest("cn=b,dc=example,dc=com", "cn=c", true, "")); !IsErrorWithCode(err, ErrorUnexpectedMessage) {
+			t.Fatalf("expected an unsupported request error, got %v", err)
+		}
+	})
+
+	expected := []string{"outer", "inner", "outer", "inner", "outer", "inner"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("got calls %v, expected %v", calls, expected)
+	}
+}
@@ -0,0 +1,92 @@
+// File contains connection health checks
+
+package ldap
+
+import (
+	"context"
+	"time"
+)
+
+// KeepaliveProbe checks that the server still answers on the connection
+type KeepaliveProbe func(ctx context.Context, l *Conn) error
+
+// ProbeRootDSE is a KeepaliveProbe reading the namingContexts of the rootDSE
+func ProbeRootDSE(ctx context.Context, l *Conn) error {
+	searchRequest := NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"namingContexts"}, nil)
+	_, err := l.SearchContext(ctx, searchRequest)
+	return err
+}
+
+// ProbeWhoAmI is a KeepaliveProbe sending the "Who am I?" extended operation
+func ProbeWhoAmI(ctx context.Context, l *Conn) error {
+	_, err := l.WhoAmIContext(ctx, nil)
+	return err
+}
+
+// isConnectionDead reports whether the error of a probe means that the
+// connection is unusable. Results other than success, e.g. when the probe is
+// not allowed, still prove that the server answers.
+func isConnectionDead(err error) bool {
+	return err != nil && (IsNetworkError(err) || err == context.DeadlineExceeded)
+}
+
+// StartKeepalive probes the connection every interval while it has no
+// outstanding requests, ProbeRootDSE if probe is nil, so that firewalls and
+// NAT devices do not drop it as idle. If the server does not answer within the
+// interval, the connection is closed, making the next operation fail right
+// away, or reconnect with a ReconnectingConn, rather than time out. Calling
+// StartKeepalive again replaces the previous keepalive.
+func (l *Conn) StartKeepalive(interval time.Duration, probe KeepaliveProbe) {
+	if probe == nil {
+		probe = ProbeRootDSE
+	}
+	stop := make(chan struct{})
+	l.messageMutex.Lock()
+	if l.keepaliveStop != nil {
+		close(l.keepaliveStop)
+	}
+	l.keepaliveStop = stop
+	l.messageMutex.Unlock()
+
+	go l.keepalive(interval, probe, stop)
+}
+
+// StopKeepalive stops the keepalive started by StartKeepalive
+func (l *Conn) StopKeepalive() {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	if l.keepaliveStop != nil {
+		close(l.keepaliveStop)
+		l.keepaliveStop = nil
+	}
+}
+
+func (l *Conn) keepalive(interval time.Duration, probe KeepaliveProbe, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-l.chanClose:
+			return
+		case <-ticker.C:
+		}
+
+		l.messageMutex.Lock()
+		idle := l.outstandingRequests == 0
+		l.messageMutex.Unlock()
+		if !idle {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := probe(ctx, l)
+		cancel()
+		if isConnectionDead(err) {
+			l.Debug.Printf("keepalive failed, closing connection: %s", err)
+			l.Close()
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestKeepalive(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	conn.StartKeepalive(10*time.Millisecond, nil)
+	for i := 0; i < 2; i++ {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if p.Children[1].Tag != ApplicationSearchRequest {
+				t.Errorf("got request %d, expected a search", p.Children[1].Tag)
+			}
+			if baseDN := p.Children[1].Children[0].Value.(string); baseDN != "" {
+				t.Errorf("got base DN %q, expected the rootDSE", baseDN)
+			}
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationSearchResultDone, LDAPResultInsufficientAccessRights, "")}
+		})
+	}
+	conn.StopKeepalive()
+	if conn.isClosing() {
+		t.Error("expected the connection to be kept open when the server answers")
+	}
+}
+
+func TestKeepaliveClosesDeadConnection(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	conn.StartKeepalive(10*time.Millisecond, ProbeWhoAmI)
+	request, err := ptc.ReceiveRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Children[1].Tag != ApplicationExtendedRequest {
+		t.Errorf("got request %d, expected an extended request", request.Children[1].Tag)
+	}
+
+	// the request is left unanswered
+	runWithTimeout(t, time.Second, func() {
+		for !conn.isClosing() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
@@ -30,6 +30,7 @@ const (
 	ApplicationSearchResultReference = 19
 	ApplicationExtendedRequest       = 23
 	ApplicationExtendedResponse      = 24
+	ApplicationIntermediateResponse  = 25
 )
 
 // ApplicationMap contains human readable descriptions of LDAP Application Codes
@@ -54,6 +55,7 @@ var ApplicationMap = map[uint8]string{
 	ApplicationSearchResultReference: "Search Result Reference",
 	ApplicationExtendedRequest:       "Extended Request",
 	ApplicationExtendedResponse:      "Extended Response",
+	ApplicationIntermediateResponse:  "Intermediate Response",
 }
 
 // Ldap Behera Password Policy Draft 10 (https://tools.ietf.org/html/draft-behera-ldap-password-policy-10)
@@ -82,6 +84,31 @@ var BeheraPasswordPolicyErrorMap = map[int8]string{
 	BeheraPasswordInHistory:           "New password is in list of old passwords",
 }
 
+// UpdateResult contains the response controls returned with the result of an
+// Add, Del, Modify or ModifyDN operation
+type UpdateResult struct {
+	Controls []Control
+}
+
+// newUpdateResult returns the controls of the response together with the
+// error of Do, if the server sent a response. A pre-read or post-read control
+// which cannot be decoded fails the operation, as the entry it holds is what
+// the result was requested for.
+func newUpdateResult(response *Response, err error) (*UpdateResult, error) {
+	if response == nil {
+		return nil, err
+	}
+	result := &UpdateResult{Controls: response.Controls}
+	if err == nil {
+		for _, controlType := range []string{ControlTypePreRead, ControlTypePostRead} {
+			if _, err := decodeResponseControl(response.Packet, controlType); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, err
+}
+
 // Adds descriptions to an LDAP Response packet for debugging
 func addLDAPDescriptions(packet *asn1.Packet) (err error) {
 	defer func() {
@@ -105,6 +132,10 @@ func addLDAPDescriptions(packet *asn1.Packet) (err error) {
 	case ApplicationSearchRequest:
 		addRequestDescriptions(packet)
 	case ApplicationSearchResultEntry:
+		if len(packet.Children[1].Children) == 0 {
+			// decoded by readMessage, without a tree of packets
+			break
+		}
 		packet.Children[1].Children[0].Description = "Object Name"
 		packet.Children[1].Children[1].Description = "Attributes"
 		for _, child := range packet.Children[1].Children[1].Children {
@@ -135,12 +166,14 @@ func addLDAPDescriptions(packet *asn1.Packet) (err error) {
 	case ApplicationCompareRequest:
 		addRequestDescriptions(packet)
 	case ApplicationCompareResponse:
+		addDefaultLDAPResponseDescriptions(packet)
 	case ApplicationAbandonRequest:
 		addRequestDescriptions(packet)
 	case ApplicationSearchResultReference:
 	case ApplicationExtendedRequest:
 		addRequestDescriptions(packet)
 	case ApplicationExtendedResponse:
+	case ApplicationIntermediateResponse:
 	}
 
 	return nil
@@ -216,9 +249,8 @@ func addControlDescriptions(packet *asn1.Packet) {
 				if child.Tag == 0 {
 					//Warning
 					warningPacket := child.Children[0]
-					packet := asn1.DecodePacket(warningPacket.Data.Bytes())
-					val, ok := packet.Value.(int64)
-					if ok {
+					val, err := asn1.ParseInt64(warningPacket.Data.Bytes())
+					if err == nil {
 						if warningPacket.Tag == 0 {
 							//timeBeforeExpiration
 							value.Description += " (TimeBeforeExpiration)"
@@ -231,13 +263,12 @@ func addControlDescriptions(packet *asn1.Packet) {
 					}
 				} else if child.Tag == 1 {
 					// Error
-					packet := asn1.DecodePacket(child.Data.Bytes())
-					val, ok := packet.Value.(int8)
-					if !ok {
+					val, err := asn1.ParseInt64(child.Data.Bytes())
+					if err != nil {
 						val = -1
 					}
 					child.Description = "Error"
-					child.Value = val
+					child.Value = int8(val)
 				}
 			}
 		}
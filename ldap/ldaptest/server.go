@@ -0,0 +1,75 @@
+// Package ldaptest provides an in-memory LDAP server for tests, so that code
+// using the ldap package can be tested without a live directory.
+//
+//	server := ldaptest.NewServer(
+//		ldap.NewEntry("dc=example,dc=com", map[string][]string{"objectClass": {"domain"}}),
+//		ldap.NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{"uid": {"jdoe"}, "userPassword": {"secret"}}),
+//	)
+//	defer server.Close()
+//	conn, err := ldap.DialURL(server.URL)
+//
+// The server supports the simple bind, search, add, modify and delete
+// operations. Other operations fail with LDAPResultUnwillingToPerform.
+package ldaptest
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/gostores/checking/ldap"
+)
+
+// Server is an LDAP server holding its entries in memory
+type Server struct {
+	// URL is the LDAP URL of the server, of the form ldap://127.0.0.1:port
+	URL string
+	// Backend holds the entries of the server
+	Backend *ldap.MemoryBackend
+
+	server *ldap.Server
+}
+
+// NewServer starts a server holding the given entries, listening on a port of
+// the loopback interface. It panics if the server cannot listen or an entry
+// has an invalid DN.
+func NewServer(entries ...*ldap.Entry) *Server {
+	backend, err := ldap.NewMemoryBackend(entries...)
+	if err != nil {
+		panic(fmt.Sprintf("ldaptest: %s", err))
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("ldaptest: failed to listen: %s", err))
+	}
+	s := &Server{
+		URL:     "ldap://" + listener.Addr().String(),
+		Backend: backend,
+		server:  ldap.NewBackendServer(backend),
+	}
+	go s.server.Serve(listener)
+	return s
+}
+
+// AddEntry adds the entries to the server, replacing the entries with the
+// same DN, without the checks of an add request. It panics if an entry has an
+// invalid DN.
+func (s *Server) AddEntry(entries ...*ldap.Entry) {
+	for _, entry := range entries {
+		if err := s.Backend.Put(entry); err != nil {
+			panic(fmt.Sprintf("ldaptest: %s", err))
+		}
+	}
+}
+
+// Entry returns a copy of the entry with the given DN, or nil if the server
+// does not hold it
+func (s *Server) Entry(dn string) *ldap.Entry {
+	entry, _ := s.Backend.Lookup(context.Background(), dn)
+	return entry
+}
+
+// Close closes the connections to the server and stops it
+func (s *Server) Close() {
+	s.server.Close()
+}
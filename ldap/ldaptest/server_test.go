@@ -0,0 +1,178 @@
+package ldaptest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gostores/checking/ldap"
+)
+
+func newTestServer(t *testing.T) (*Server, *ldap.Conn) {
+	server := NewServer(
+		ldap.NewEntry("dc=example,dc=com", map[string][]string{"objectClass": {"domain"}, "dc": {"example"}}),
+		ldap.NewEntry("ou=people,dc=example,dc=com", map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"people"}}),
+		ldap.NewEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{"objectClass": {"person"}, "uid": {"jdoe"}, "cn": {"John Doe"}, "uidNumber": {"1000"}, "userPassword": {"secret"}}),
+		ldap.NewEntry("uid=asmith,ou=people,dc=example,dc=com", map[string][]string{"objectClass": {"person"}, "uid": {"asmith"}, "cn": {"Alice Smith"}, "uidNumber": {"999"}}),
+	)
+	conn, err := ldap.DialURL(server.URL)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, conn
+}
+
+func TestBind(t *testing.T) {
+	server, conn := newTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := conn.Bind("UID=JDoe,ou=people,dc=example,dc=com", "wrong"); !ldap.IsInvalidCredentials(err) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	if err := conn.Bind("uid=nobody,dc=example,dc=com", "secret"); !ldap.IsInvalidCredentials(err) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	server, conn := newTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	tests := []struct {
+		baseDN   string
+		scope    int
+		filter   string
+		expected []string
+	}{
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(uid=JDOE)", []string{"uid=jdoe,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(&(objectClass=person)(!(uid=jdoe)))", []string{"uid=asmith,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(|(ou=people)(cn=*smith))", []string{"ou=people,dc=example,dc=com", "uid=asmith,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(cn=J*n D*)", []string{"uid=jdoe,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(uidNumber>=1000)", []string{"uid=jdoe,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(uidNumber<=999)", []string{"uid=asmith,ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeSingleLevel, "(objectClass=*)", []string{"ou=people,dc=example,dc=com"}},
+		{"ou=people,dc=example,dc=com", ldap.ScopeBaseObject, "(objectClass=*)", []string{"ou=people,dc=example,dc=com"}},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(cn:=alice smith)", []string{"uid=asmith,ou=people,dc=example,dc=com"}},
+	}
+	for _, test := range tests {
+		searchRequest := ldap.NewSearchRequest(test.baseDN, test.scope, ldap.NeverDerefAliases, 0, 0, false, test.filter, []string{"uid"}, nil)
+		result, err := conn.Search(searchRequest)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.filter, err)
+			continue
+		}
+		var dns []string
+		for _, entry := range result.Entries {
+			dns = append(dns, entry.DN)
+			for _, attribute := range entry.Attributes {
+				if attribute.Name != "uid" {
+					t.Errorf("%s: unexpected attribute %s", test.filter, attribute.Name)
+				}
+			}
+		}
+		if !reflect.DeepEqual(dns, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.filter, dns, test.expected)
+		}
+	}
+
+	searchRequest := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=person)", nil, nil)
+	if result, err := conn.Search(searchRequest); !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || len(result.Entries) != 1 {
+		t.Errorf("expected 1 entry and a size limit error, got %v", err)
+	}
+	searchRequest = ldap.NewSearchRequest("dc=missing,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	if _, err := conn.Search(searchRequest); !ldap.IsNoSuchObject(err) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	server, conn := newTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	addRequest := ldap.NewAddRequest("uid=bwayne,ou=people,dc=example,dc=com")
+	addRequest.Attribute("objectClass", []string{"person"})
+	addRequest.Attribute("uid", []string{"bwayne"})
+	addRequest.Attribute("uidNumber", []string{"1001"})
+	if err := conn.Add(addRequest); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := conn.Add(addRequest); !ldap.IsEntryAlreadyExists(err) {
+		t.Errorf("expected entry already exists, got %v", err)
+	}
+	if err := conn.Add(ldap.NewAddRequest("uid=x,ou=missing,dc=example,dc=com")); !ldap.IsNoSuchObject(err) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+
+	modifyRequest := ldap.NewModifyRequest("uid=bwayne,ou=people,dc=example,dc=com")
+	modifyRequest.Add("mail", []string{"bruce@example.com"})
+	modifyRequest.Replace("uid", []string{"batman"})
+	modifyRequest.Increment("uidNumber", 10)
+	if err := conn.Modify(modifyRequest); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	entry := server.Entry("uid=bwayne,ou=people,dc=example,dc=com")
+	for name, expected := range map[string]string{"mail": "bruce@example.com", "uid": "batman", "uidNumber": "1011"} {
+		if value := entry.GetAttributeValue(name); value != expected {
+			t.Errorf("%s: got %q, expected %q", name, value, expected)
+		}
+	}
+
+	// failed modifications leave the entry unchanged
+	modifyRequest = ldap.NewModifyRequest("uid=bwayne,ou=people,dc=example,dc=com")
+	modifyRequest.Delete("mail", nil)
+	modifyRequest.Delete("description", nil)
+	if err := conn.Modify(modifyRequest); !ldap.IsNoSuchAttribute(err) {
+		t.Errorf("expected no such attribute, got %v", err)
+	}
+	if entry := server.Entry("uid=bwayne,ou=people,dc=example,dc=com"); entry.GetAttributeValue("mail") == "" {
+		t.Errorf("expected the entry to be unchanged")
+	}
+
+	if err := conn.Del(ldap.NewDelRequest("ou=people,dc=example,dc=com", nil)); !ldap.IsErrorWithCode(err, ldap.LDAPResultNotAllowedOnNonLeaf) {
+		t.Errorf("expected not allowed on non leaf, got %v", err)
+	}
+	if err := conn.Del(ldap.NewDelRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if server.Entry("uid=bwayne,ou=people,dc=example,dc=com") != nil {
+		t.Errorf("expected the entry to be deleted")
+	}
+	if err := conn.Del(ldap.NewDelRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)); !ldap.IsNoSuchObject(err) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+}
+
+func ExampleNewServer() {
+	server := NewServer(
+		ldap.NewEntry("dc=example,dc=com", map[string][]string{"objectClass": {"domain"}}),
+		ldap.NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{"uid": {"jdoe"}, "userPassword": {"secret"}}),
+	)
+	defer server.Close()
+
+	conn, err := ldap.DialURL(server.URL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	searchRequest := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=jdoe)", []string{"uid"}, nil)
+	result, err := conn.Search(searchRequest)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.Entries[0].DN)
+	// Output: uid=jdoe,dc=example,dc=com
+}
@@ -0,0 +1,137 @@
+package ldif
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gostores/checking/ldap"
+)
+
+// Diff returns the change records turning the content records of before into
+// those of after, e.g. two exports of a directory, for review before
+// applying them. See DiffEntries.
+func Diff(before, after *LDIF, options ldap.DiffOptions) (*LDIF, error) {
+	beforeEntries, err := contentEntries(before)
+	if err != nil {
+		return nil, err
+	}
+	afterEntries, err := contentEntries(after)
+	if err != nil {
+		return nil, err
+	}
+	return DiffEntries(beforeEntries, afterEntries, options)
+}
+
+// DiffEntries returns the change records turning the entries of before into
+// those of after, e.g. the results of two searches. Entries are matched by
+// case insensitive DN, and the attributes of matched entries are compared
+// with options, as by ldap.DiffOptions.Diff, except that the attributes
+// missing from the entry of after are deleted.
+//
+// The records add the new entries, parents first, then modify the changed
+// entries, and finally delete the removed entries, children first. Renamed
+// entries are deleted and added again.
+func DiffEntries(before, after []*ldap.Entry, options ldap.DiffOptions) (*LDIF, error) {
+	beforeIndex, err := indexEntries(before)
+	if err != nil {
+		return nil, err
+	}
+	afterIndex, err := indexEntries(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var adds, modifies, deletes []*Entry
+	for _, entry := range after {
+		current, ok := beforeIndex[dnKey(entry.DN)]
+		if !ok {
+			add := ldap.NewAddRequest(entry.DN)
+			for _, attr := range entry.Attributes {
+				add.Attribute(attr.Name, attr.Values)
+			}
+			adds = append(adds, &Entry{Add: add})
+			continue
+		}
+		desired := &ldap.Entry{DN: entry.DN, Attributes: append([]*ldap.EntryAttribute(nil), entry.Attributes...)}
+		for _, attr := range current.Attributes {
+			if !hasAttribute(entry, attr.Name) {
+				desired.Attributes = append(desired.Attributes, ldap.NewEntryAttribute(attr.Name, nil))
+			}
+		}
+		if modify := options.Diff(current, desired); modify != nil {
+			modify.DN = entry.DN
+			modifies = append(modifies, &Entry{Modify: modify})
+		}
+	}
+	for _, entry := range before {
+		if _, ok := afterIndex[dnKey(entry.DN)]; !ok {
+			deletes = append(deletes, &Entry{Del: ldap.NewDelRequest(entry.DN, nil)})
+		}
+	}
+
+	// parents have fewer RDNs than their children
+	sort.SliceStable(adds, func(i, j int) bool {
+		return dnDepth(adds[i].DN()) < dnDepth(adds[j].DN())
+	})
+	sort.SliceStable(deletes, func(i, j int) bool {
+		return dnDepth(deletes[i].DN()) > dnDepth(deletes[j].DN())
+	})
+
+	diff := &LDIF{Version: 1}
+	diff.Entries = append(append(append(diff.Entries, adds...), modifies...), deletes...)
+	return diff, nil
+}
+
+// hasAttribute reports whether the entry has the attribute, by case
+// insensitive name
+func hasAttribute(entry *ldap.Entry, name string) bool {
+	for _, attr := range entry.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentEntries returns the entries of the content records of l
+func contentEntries(l *LDIF) ([]*ldap.Entry, error) {
+	entries := make([]*ldap.Entry, 0, len(l.Entries))
+	for _, entry := range l.Entries {
+		if entry.Entry == nil {
+			return nil, fmt.Errorf("ldif: cannot diff the change record for %s", entry.DN())
+		}
+		entries = append(entries, entry.Entry)
+	}
+	return entries, nil
+}
+
+// indexEntries returns the entries by key of their DN, checking that their
+// DNs are valid and unique
+func indexEntries(entries []*ldap.Entry) (map[string]*ldap.Entry, error) {
+	index := make(map[string]*ldap.Entry, len(entries))
+	for _, entry := range entries {
+		if _, err := ldap.ParseDN(entry.DN); err != nil {
+			return nil, fmt.Errorf("ldif: invalid DN %q: %s", entry.DN, err)
+		}
+		key := dnKey(entry.DN)
+		if _, ok := index[key]; ok {
+			return nil, fmt.Errorf("ldif: duplicate entry %s", entry.DN)
+		}
+		index[key] = entry
+	}
+	return index, nil
+}
+
+// dnKey returns the key of a valid DN, equal for DNs differing only in case
+// or formatting
+func dnKey(dn string) string {
+	parsed, _ := ldap.ParseDN(dn)
+	return strings.ToLower(parsed.String())
+}
+
+// dnDepth returns the number of RDNs of a valid DN
+func dnDepth(dn string) int {
+	parsed, _ := ldap.ParseDN(dn)
+	return len(parsed.RDNs)
+}
@@ -0,0 +1,98 @@
+package ldif
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gostores/checking/ldap"
+)
+
+func TestDiff(t *testing.T) {
+	before, err := Parse(`dn: dc=example,dc=com
+objectClass: domain
+dc: example
+
+dn: ou=old,dc=example,dc=com
+objectClass: organizationalUnit
+ou: old
+
+dn: uid=gone,ou=old,dc=example,dc=com
+uid: gone
+
+dn: uid=jdoe,dc=example,dc=com
+uid: jdoe
+mail: jdoe@example.com
+description: temporary
+modifyTimestamp: 20200101000000Z
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := Parse(`dn: DC=Example,DC=Com
+objectClass: domain
+dc: example
+
+dn: uid=jdoe,dc=example,dc=com
+uid: jdoe
+mail: john@example.com
+modifyTimestamp: 20210101000000Z
+
+dn: uid=new,ou=new,dc=example,dc=com
+uid: new
+
+dn: ou=new,dc=example,dc=com
+objectClass: organizationalUnit
+ou: new
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := ldap.DiffOptions{Strategies: map[string]ldap.DiffStrategy{"modifyTimestamp": ldap.DiffIgnore}}
+	diff, err := Diff(before, after, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []string
+	for _, entry := range diff.Entries {
+		switch {
+		case entry.Add != nil:
+			records = append(records, "add "+entry.DN())
+		case entry.Modify != nil:
+			records = append(records, "modify "+entry.DN())
+		case entry.Del != nil:
+			records = append(records, "delete "+entry.DN())
+		}
+	}
+	expected := []string{
+		"add ou=new,dc=example,dc=com",
+		"add uid=new,ou=new,dc=example,dc=com",
+		"modify uid=jdoe,dc=example,dc=com",
+		"delete uid=gone,ou=old,dc=example,dc=com",
+		"delete ou=old,dc=example,dc=com",
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("expected records %q, got %q", expected, records)
+	}
+	changes := []ldap.Change{
+		{Operation: ldap.ReplaceAttribute, Modification: ldap.PartialAttribute{Type: "mail", Vals: []string{"john@example.com"}}},
+		{Operation: ldap.DeleteAttribute, Modification: ldap.PartialAttribute{Type: "description"}},
+	}
+	if !reflect.DeepEqual(diff.Entries[2].Modify.Changes, changes) {
+		t.Errorf("unexpected changes %#v", diff.Entries[2].Modify.Changes)
+	}
+
+	if _, err := Marshal(diff); err != nil {
+		t.Errorf("marshal diff: %s", err)
+	}
+	if diff, err := Diff(after, after, ldap.DiffOptions{}); err != nil || len(diff.Entries) != 0 {
+		t.Errorf("expected no records between identical files, got %v, %v", diff, err)
+	}
+	if _, err := Diff(&LDIF{Entries: []*Entry{{Del: ldap.NewDelRequest("dc=com", nil)}}}, after, options); err == nil {
+		t.Error("expected an error for a change record")
+	}
+	duplicate := []*ldap.Entry{ldap.NewEntry("dc=com", nil), ldap.NewEntry("DC=COM", nil)}
+	if _, err := DiffEntries(duplicate, nil, options); err == nil {
+		t.Error("expected an error for duplicate entries")
+	}
+}
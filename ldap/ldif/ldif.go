@@ -0,0 +1,472 @@
+// Package ldif parses and writes LDIF files, as defined in
+// https://tools.ietf.org/html/rfc2849, and applies their records to a
+// directory.
+//
+// Both content records, which describe complete entries, and change records
+// (changetype add, delete, modify, modrdn and moddn) are supported, so
+// applying a file is the equivalent of running ldapmodify on it. Diff
+// computes the change records between two snapshots of a directory.
+package ldif
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gostores/checking/ldap"
+)
+
+// LDIF holds the records of an LDIF file, in order
+type LDIF struct {
+	// Version is the version of the file, if specified
+	Version int
+	// Entries are the records of the file
+	Entries []*Entry
+}
+
+// Entry is a single record of an LDIF file. Exactly one of its fields is set:
+// Entry for content records, and the request matching the change type for
+// change records.
+type Entry struct {
+	Entry    *ldap.Entry
+	Add      *ldap.AddRequest
+	Del      *ldap.DelRequest
+	Modify   *ldap.ModifyRequest
+	ModifyDN *ldap.ModifyDNRequest
+}
+
+// DN returns the DN of the entry the record applies to
+func (e *Entry) DN() string {
+	switch {
+	case e.Entry != nil:
+		return e.Entry.DN
+	case e.Add != nil:
+		return e.Add.DN
+	case e.Del != nil:
+		return e.Del.DN
+	case e.Modify != nil:
+		return e.Modify.DN
+	case e.ModifyDN != nil:
+		return e.ModifyDN.DN
+	}
+	return ""
+}
+
+// line is an unfolded line of an LDIF file
+type line struct {
+	number int
+	text   string
+}
+
+// Parse parses the given LDIF string
+func Parse(str string) (*LDIF, error) {
+	l := &LDIF{}
+	if err := Unmarshal(strings.NewReader(str), l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Unmarshal parses the LDIF file read from r and appends its records to l
+func Unmarshal(r io.Reader, l *LDIF) error {
+	records, err := readRecords(r)
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		if i == 0 && strings.HasPrefix(record[0].text, "version:") {
+			version, err := strconv.Atoi(strings.TrimSpace(record[0].text[len("version:"):]))
+			if err != nil || version != 1 {
+				return fmt.Errorf("ldif: line %d: unsupported version %q", record[0].number, record[0].text)
+			}
+			l.Version = version
+			record = record[1:]
+			if len(record) == 0 {
+				continue
+			}
+		}
+		entry, err := parseRecord(record)
+		if err != nil {
+			return err
+		}
+		l.Entries = append(l.Entries, entry)
+	}
+	return nil
+}
+
+// readRecords reads the unfolded lines of the records, skipping comments
+func readRecords(r io.Reader) ([][]line, error) {
+	var records [][]line
+	var record []line
+	comment := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case len(text) == 0:
+			if len(record) > 0 {
+				records = append(records, record)
+				record = nil
+			}
+			comment = false
+		case text[0] == ' ':
+			// continuation of the previous line
+			if comment {
+				continue
+			}
+			if len(record) == 0 {
+				return nil, fmt.Errorf("ldif: line %d: continuation line without a preceding line", number)
+			}
+			record[len(record)-1].text += text[1:]
+		case text[0] == '#':
+			comment = true
+		default:
+			comment = false
+			record = append(record, line{number: number, text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseRecord parses a content or change record
+func parseRecord(record []line) (*Entry, error) {
+	attr, dn, err := parseLine(record[0])
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(attr, "dn") {
+		return nil, fmt.Errorf("ldif: line %d: record does not start with a dn", record[0].number)
+	}
+	record = record[1:]
+
+	var controls []ldap.Control
+	for len(record) > 0 && strings.HasPrefix(strings.ToLower(record[0].text), "control:") {
+		control, err := parseControl(record[0])
+		if err != nil {
+			return nil, err
+		}
+		controls = append(controls, control)
+		record = record[1:]
+	}
+
+	changeType := ""
+	if len(record) > 0 {
+		attr, value, err := parseLine(record[0])
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(attr, "changetype") {
+			changeType = strings.ToLower(value)
+			record = record[1:]
+		}
+	}
+	if changeType == "" && len(controls) > 0 {
+		return nil, fmt.Errorf("ldif: controls are only allowed in change records, found in the record for %s", dn)
+	}
+
+	switch changeType {
+	case "":
+		return parseContentRecord(dn, record)
+	case "add":
+		add := ldap.NewAddRequest(dn, controls...)
+		err := parseAttributes(record, func(attr, value string) {
+			for i := range add.Attributes {
+				if strings.EqualFold(add.Attributes[i].Type, attr) {
+					add.Attributes[i].Vals = append(add.Attributes[i].Vals, value)
+					return
+				}
+			}
+			add.Attribute(attr, []string{value})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Entry{Add: add}, nil
+	case "delete":
+		if len(record) > 0 {
+			return nil, fmt.Errorf("ldif: line %d: unexpected line in delete record", record[0].number)
+		}
+		return &Entry{Del: ldap.NewDelRequest(dn, controls)}, nil
+	case "modify":
+		modify, err := parseModifyRecord(dn, record)
+		if err != nil {
+			return nil, err
+		}
+		modify.Controls = controls
+		return &Entry{Modify: modify}, nil
+	case "modrdn", "moddn":
+		modifyDN, err := parseModifyDNRecord(dn, record)
+		if err != nil {
+			return nil, err
+		}
+		modifyDN.Controls = controls
+		return &Entry{ModifyDN: modifyDN}, nil
+	}
+	return nil, fmt.Errorf("ldif: unknown changetype %q for %s", changeType, dn)
+}
+
+// parseContentRecord parses the attributes of a content record
+func parseContentRecord(dn string, record []line) (*Entry, error) {
+	entry := &ldap.Entry{DN: dn}
+	err := parseAttributes(record, func(attr, value string) {
+		for _, a := range entry.Attributes {
+			if strings.EqualFold(a.Name, attr) {
+				a.Values = append(a.Values, value)
+				a.ByteValues = append(a.ByteValues, []byte(value))
+				return
+			}
+		}
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(attr, []string{value}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Entry: entry}, nil
+}
+
+// parseAttributes calls add for every attribute value of the record
+func parseAttributes(record []line, add func(attr, value string)) error {
+	for _, l := range record {
+		attr, value, err := parseLine(l)
+		if err != nil {
+			return err
+		}
+		add(attr, value)
+	}
+	return nil
+}
+
+// parseModifyRecord parses the modifications of a modify record
+func parseModifyRecord(dn string, record []line) (*ldap.ModifyRequest, error) {
+	modify := ldap.NewModifyRequest(dn)
+	for len(record) > 0 {
+		operation, attr, err := parseLine(record[0])
+		if err != nil {
+			return nil, err
+		}
+		spec := record[0]
+		record = record[1:]
+
+		var values []string
+		for len(record) > 0 && record[0].text != "-" {
+			valueAttr, value, err := parseLine(record[0])
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(valueAttr, attr) {
+				return nil, fmt.Errorf("ldif: line %d: expected a value of %s, got %s", record[0].number, attr, valueAttr)
+			}
+			values = append(values, value)
+			record = record[1:]
+		}
+		if len(record) > 0 {
+			// skip the separator
+			record = record[1:]
+		}
+
+		switch strings.ToLower(operation) {
+		case "add":
+			modify.Add(attr, values)
+		case "delete":
+			modify.Delete(attr, values)
+		case "replace":
+			modify.Replace(attr, values)
+		case "increment":
+			if len(values) != 1 {
+				return nil, fmt.Errorf("ldif: line %d: expected one increment value, got %d", spec.number, len(values))
+			}
+			delta, err := strconv.ParseInt(values[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("ldif: line %d: invalid increment %q", spec.number, values[0])
+			}
+			modify.Increment(attr, delta)
+		default:
+			return nil, fmt.Errorf("ldif: line %d: unknown modify operation %q", spec.number, operation)
+		}
+	}
+	return modify, nil
+}
+
+// parseModifyDNRecord parses the new RDN, delete old RDN flag and new superior of a modrdn record
+func parseModifyDNRecord(dn string, record []line) (*ldap.ModifyDNRequest, error) {
+	modifyDN := &ldap.ModifyDNRequest{DN: dn}
+	hasNewRDN, hasDeleteOldRDN := false, false
+	for _, l := range record {
+		attr, value, err := parseLine(l)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(attr) {
+		case "newrdn":
+			modifyDN.NewRDN = value
+			hasNewRDN = true
+		case "deleteoldrdn":
+			switch value {
+			case "0":
+				modifyDN.DeleteOldRDN = false
+			case "1":
+				modifyDN.DeleteOldRDN = true
+			default:
+				return nil, fmt.Errorf("ldif: line %d: invalid deleteoldrdn value %q", l.number, value)
+			}
+			hasDeleteOldRDN = true
+		case "newsuperior":
+			modifyDN.NewSuperior = value
+		default:
+			return nil, fmt.Errorf("ldif: line %d: unexpected %s in modrdn record", l.number, attr)
+		}
+	}
+	if !hasNewRDN || !hasDeleteOldRDN {
+		return nil, fmt.Errorf("ldif: modrdn record for %s requires newrdn and deleteoldrdn", dn)
+	}
+	return modifyDN, nil
+}
+
+// parseControl parses a control line:
+//
+//	control: oid [true|false] [: value | :: base64 value | :< url]
+func parseControl(l line) (ldap.Control, error) {
+	spec := strings.TrimSpace(l.text[len("control:"):])
+	value := ""
+	hasValue := false
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		var err error
+		value, err = decodeValue(l, spec[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		hasValue = true
+		spec = spec[:i]
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("ldif: line %d: invalid control %q", l.number, l.text)
+	}
+	criticality := false
+	if len(fields) == 2 {
+		switch fields[1] {
+		case "true":
+			criticality = true
+		case "false":
+		default:
+			return nil, fmt.Errorf("ldif: line %d: invalid control criticality %q", l.number, fields[1])
+		}
+	}
+	if !hasValue {
+		return &ldap.ControlString{ControlType: fields[0], Criticality: criticality}, nil
+	}
+	return ldap.NewControlString(fields[0], criticality, value), nil
+}
+
+// parseLine splits an attribute line into the attribute description and its
+// decoded value
+func parseLine(l line) (string, string, error) {
+	i := strings.IndexByte(l.text, ':')
+	if i <= 0 {
+		return "", "", fmt.Errorf("ldif: line %d: invalid line %q", l.number, l.text)
+	}
+	value, err := decodeValue(l, l.text[i+1:])
+	if err != nil {
+		return "", "", err
+	}
+	return l.text[:i], value, nil
+}
+
+// decodeValue decodes the value following the colon of an attribute line,
+// which is either a safe string, a base64 string after a second colon, or a
+// URL after a less-than sign
+func decodeValue(l line, spec string) (string, error) {
+	switch {
+	case strings.HasPrefix(spec, ":"):
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(spec[1:]))
+		if err != nil {
+			return "", fmt.Errorf("ldif: line %d: invalid base64 value: %s", l.number, err)
+		}
+		return string(value), nil
+	case strings.HasPrefix(spec, "<"):
+		u, err := url.Parse(strings.TrimSpace(spec[1:]))
+		if err != nil {
+			return "", fmt.Errorf("ldif: line %d: invalid URL: %s", l.number, err)
+		}
+		if u.Scheme != "file" {
+			return "", fmt.Errorf("ldif: line %d: unsupported URL scheme %q", l.number, u.Scheme)
+		}
+		value, err := ioutil.ReadFile(u.Path)
+		if err != nil {
+			return "", fmt.Errorf("ldif: line %d: %s", l.number, err)
+		}
+		return string(value), nil
+	}
+	return strings.TrimLeft(spec, " "), nil
+}
+
+// Apply applies the records of the LDIF file to the directory, in order.
+// Content records are added as new entries. If continueOnErr is set, all
+// records are applied and the first error is returned, otherwise Apply stops
+// at the first error.
+func Apply(conn ldap.Client, l *LDIF, continueOnErr bool) error {
+	return ApplyContext(context.Background(), conn, l, continueOnErr)
+}
+
+// ApplyContext is like Apply, but abandons the current request and returns
+// ctx.Err() if ctx is done before all records are applied.
+func ApplyContext(ctx context.Context, conn ldap.Client, l *LDIF, continueOnErr bool) error {
+	var firstErr error
+	for _, entry := range l.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := applyEntry(ctx, conn, entry)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("ldif: applying record for %s: %s", entry.DN(), err)
+		if !continueOnErr {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// applyEntry performs the request of a single record
+func applyEntry(ctx context.Context, conn ldap.Client, entry *Entry) error {
+	switch {
+	case entry.Entry != nil:
+		add := ldap.NewAddRequest(entry.Entry.DN)
+		for _, attr := range entry.Entry.Attributes {
+			add.Attribute(attr.Name, attr.Values)
+		}
+		return conn.AddContext(ctx, add)
+	case entry.Add != nil:
+		return conn.AddContext(ctx, entry.Add)
+	case entry.Del != nil:
+		return conn.DelContext(ctx, entry.Del)
+	case entry.Modify != nil:
+		return conn.ModifyContext(ctx, entry.Modify)
+	case entry.ModifyDN != nil:
+		return conn.ModifyDNContext(ctx, entry.ModifyDN)
+	}
+	return errors.New("empty record")
+}
@@ -0,0 +1,218 @@
+package ldif
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gostores/checking/ldap"
+)
+
+func TestParseContentRecords(t *testing.T) {
+	l, err := Parse(`version: 1
+# the first entry
+dn: cn=Barbara Jensen,ou=Product Development,dc=airius,
+ dc=com
+objectclass: top
+objectclass: person
+cn: Barbara Jensen
+description:: V2hhdCBhIGNhcmVmdWwgcmVhZGVyIHlvdSBhcmUh
+# a folded comment
+ which continues here
+
+dn:: dWlkPXJvZ2FzYWgsb3U9SHVtYW4gUmVzb3VyY2VzLGRjPWV4YW1wbGUsZGM9Y29t
+uid: rogasawa
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Version != 1 || len(l.Entries) != 2 {
+		t.Fatalf("unexpected version %d and %d entries", l.Version, len(l.Entries))
+	}
+
+	entry := l.Entries[0].Entry
+	if entry == nil || entry.DN != "cn=Barbara Jensen,ou=Product Development,dc=airius,dc=com" {
+		t.Fatalf("unexpected first entry %v", l.Entries[0])
+	}
+	if values := entry.GetAttributeValues("objectclass"); !reflect.DeepEqual(values, []string{"top", "person"}) {
+		t.Errorf("unexpected object classes %q", values)
+	}
+	if value := entry.GetAttributeValue("description"); value != "What a careful reader you are!" {
+		t.Errorf("unexpected description %q", value)
+	}
+	if dn := l.Entries[1].DN(); dn != "uid=rogasah,ou=Human Resources,dc=example,dc=com" {
+		t.Errorf("unexpected DN %q", dn)
+	}
+}
+
+func TestParseChangeRecords(t *testing.T) {
+	l, err := Parse(`dn: cn=Fiona Jensen,ou=Marketing,dc=airius,dc=com
+changetype: add
+objectclass: top
+objectclass: person
+cn: Fiona Jensen
+
+dn: cn=Robert Jensen,ou=Marketing,dc=airius,dc=com
+control: 1.2.840.113556.1.4.805 true
+changetype: delete
+
+dn: cn=Paula Jensen,ou=Product Development,dc=airius,dc=com
+changetype: modrdn
+newrdn: cn=Paul Jensen
+deleteoldrdn: 1
+newsuperior: ou=People,dc=airius,dc=com
+
+dn: cn=Paula Jensen,ou=Product Development,dc=airius,dc=com
+changetype: modify
+add: postaladdress
+postaladdress: 123 Anystreet $ Sunnyvale, CA $ 94086
+-
+delete: description
+-
+replace: telephonenumber
+telephonenumber: +1 408 555 1234
+telephonenumber: +1 408 555 5678
+-
+increment: uidNumber
+uidNumber: -2
+-
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(l.Entries))
+	}
+
+	add := l.Entries[0].Add
+	expectedAttributes := []ldap.Attribute{
+		{Type: "objectclass", Vals: []string{"top", "person"}},
+		{Type: "cn", Vals: []string{"Fiona Jensen"}},
+	}
+	if add == nil || !reflect.DeepEqual(add.Attributes, expectedAttributes) {
+		t.Errorf("unexpected add request %v", l.Entries[0])
+	}
+
+	del := l.Entries[1].Del
+	if del == nil || len(del.Controls) != 1 || del.Controls[0].GetControlType() != ldap.ControlTypeMicrosoftTreeDelete {
+		t.Errorf("unexpected delete request %v", l.Entries[1])
+	}
+
+	modifyDN := l.Entries[2].ModifyDN
+	expectedModifyDN := &ldap.ModifyDNRequest{
+		DN:           "cn=Paula Jensen,ou=Product Development,dc=airius,dc=com",
+		NewRDN:       "cn=Paul Jensen",
+		DeleteOldRDN: true,
+		NewSuperior:  "ou=People,dc=airius,dc=com",
+	}
+	if !reflect.DeepEqual(modifyDN, expectedModifyDN) {
+		t.Errorf("unexpected modify DN request %v", modifyDN)
+	}
+
+	modify := l.Entries[3].Modify
+	if modify == nil {
+		t.Fatalf("expected modify request, got %v", l.Entries[3])
+	}
+	expectedChanges := []ldap.Change{
+		{Operation: ldap.AddAttribute, Modification: ldap.PartialAttribute{Type: "postaladdress", Vals: []string{"123 Anystreet $ Sunnyvale, CA $ 94086"}}},
+		{Operation: ldap.DeleteAttribute, Modification: ldap.PartialAttribute{Type: "description"}},
+		{Operation: ldap.ReplaceAttribute, Modification: ldap.PartialAttribute{Type: "telephonenumber", Vals: []string{"+1 408 555 1234", "+1 408 555 5678"}}},
+		{Operation: ldap.IncrementAttribute, Modification: ldap.PartialAttribute{Type: "uidNumber", Vals: []string{"-2"}}},
+	}
+	if !reflect.DeepEqual(modify.Changes, expectedChanges) {
+		t.Errorf("unexpected changes %v", modify.Changes)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"version: 2\n\ndn: cn=a\ncn: a\n",
+		"cn: a\n",
+		"dn: cn=a\nchangetype: rename\n",
+		"dn: cn=a\nchangetype: delete\ncn: a\n",
+		"dn: cn=a\nchangetype: modify\nadd: cn\nsn: a\n-\n",
+		"dn: cn=a\nchangetype: modify\nincrease: cn\n",
+		"dn: cn=a\nchangetype: modify\nincrement: uidNumber\nuidNumber: one\n",
+		"dn: cn=a\nchangetype: modify\nincrement: uidNumber\nuidNumber: 1\nuidNumber: 2\n",
+		"dn: cn=a\nchangetype: modrdn\nnewrdn: cn=b\n",
+		"dn: cn=a\ncontrol: 1.2.3 maybe\nchangetype: delete\n",
+		"dn: cn=a\ncontrol: 1.2.3\ncn: a\n",
+		"dn: cn=a\ndescription:: not base64!\n",
+		" continued\n",
+	}
+	for _, test := range tests {
+		if _, err := Parse(test); err == nil {
+			t.Errorf("expected error parsing %q", test)
+		}
+	}
+}
+
+// recordingClient records the requests it receives, failing those for the DN fail
+type recordingClient struct {
+	ldap.Client
+	fail     string
+	requests []string
+}
+
+func (c *recordingClient) record(kind, dn string) error {
+	c.requests = append(c.requests, kind+" "+dn)
+	if dn == c.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (c *recordingClient) AddContext(ctx context.Context, addRequest *ldap.AddRequest) error {
+	return c.record("add", addRequest.DN)
+}
+
+func (c *recordingClient) DelContext(ctx context.Context, delRequest *ldap.DelRequest) error {
+	return c.record("delete", delRequest.DN)
+}
+
+func (c *recordingClient) ModifyContext(ctx context.Context, modifyRequest *ldap.ModifyRequest) error {
+	return c.record("modify", modifyRequest.DN)
+}
+
+func (c *recordingClient) ModifyDNContext(ctx context.Context, modifyDNRequest *ldap.ModifyDNRequest) error {
+	return c.record("modrdn", modifyDNRequest.DN)
+}
+
+func TestApply(t *testing.T) {
+	l, err := Parse(`dn: cn=a
+cn: a
+
+dn: cn=b
+changetype: modify
+replace: sn
+sn: b
+
+dn: cn=c
+changetype: delete
+
+dn: cn=d
+changetype: modrdn
+newrdn: cn=e
+deleteoldrdn: 0
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &recordingClient{fail: "cn=b"}
+	if err := Apply(client, l, false); err == nil {
+		t.Errorf("expected error")
+	}
+	if expected := []string{"add cn=a", "modify cn=b"}; !reflect.DeepEqual(client.requests, expected) {
+		t.Errorf("expected requests %q, got %q", expected, client.requests)
+	}
+
+	client = &recordingClient{fail: "cn=b"}
+	if err := Apply(client, l, true); err == nil {
+		t.Errorf("expected error")
+	}
+	if expected := []string{"add cn=a", "modify cn=b", "delete cn=c", "modrdn cn=d"}; !reflect.DeepEqual(client.requests, expected) {
+		t.Errorf("expected requests %q, got %q", expected, client.requests)
+	}
+}
@@ -0,0 +1,191 @@
+package ldif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gostores/checking/ldap"
+)
+
+// foldWidth is the length after which lines are folded
+const foldWidth = 76
+
+// Marshal returns the LDIF file holding the records of l
+func Marshal(l *LDIF) (string, error) {
+	var buf bytes.Buffer
+	if err := Dump(&buf, l); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Dump writes the records of l to w as an LDIF file. Values which are not
+// safe strings are base64 encoded and long lines are folded. Only controls
+// of type *ldap.ControlString can be written in change records.
+func Dump(w io.Writer, l *LDIF) error {
+	bw := bufio.NewWriter(w)
+	if l.Version > 0 {
+		writeLine(bw, "version", strconv.Itoa(l.Version))
+		bw.WriteString("\n")
+	}
+	for i, entry := range l.Entries {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		if err := writeRecord(bw, entry); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeRecord writes a single content or change record
+func writeRecord(w *bufio.Writer, entry *Entry) error {
+	dn := entry.DN()
+	switch {
+	case entry.Entry != nil:
+		writeLine(w, "dn", dn)
+		for _, attr := range entry.Entry.Attributes {
+			for _, value := range attr.Values {
+				writeLine(w, attr.Name, value)
+			}
+		}
+		return nil
+	case entry.Add != nil:
+		if err := writeHeader(w, dn, entry.Add.Controls, "add"); err != nil {
+			return err
+		}
+		for _, attr := range entry.Add.Attributes {
+			writeValues(w, attr.Type, attr.Vals, attr.ByteVals)
+		}
+		return nil
+	case entry.Del != nil:
+		return writeHeader(w, dn, entry.Del.Controls, "delete")
+	case entry.Modify != nil:
+		if err := writeHeader(w, dn, entry.Modify.Controls, "modify"); err != nil {
+			return err
+		}
+		for _, change := range entry.Modify.Changes {
+			attr := change.Modification
+			switch change.Operation {
+			case ldap.AddAttribute:
+				writeLine(w, "add", attr.Type)
+			case ldap.DeleteAttribute:
+				writeLine(w, "delete", attr.Type)
+			case ldap.ReplaceAttribute:
+				writeLine(w, "replace", attr.Type)
+			case ldap.IncrementAttribute:
+				writeLine(w, "increment", attr.Type)
+			default:
+				return fmt.Errorf("ldif: unknown modify operation %d for %s", change.Operation, dn)
+			}
+			writeValues(w, attr.Type, attr.Vals, attr.ByteVals)
+			w.WriteString("-\n")
+		}
+		return nil
+	case entry.ModifyDN != nil:
+		if err := writeHeader(w, dn, entry.ModifyDN.Controls, "modrdn"); err != nil {
+			return err
+		}
+		writeLine(w, "newrdn", entry.ModifyDN.NewRDN)
+		deleteOldRDN := "0"
+		if entry.ModifyDN.DeleteOldRDN {
+			deleteOldRDN = "1"
+		}
+		writeLine(w, "deleteoldrdn", deleteOldRDN)
+		if entry.ModifyDN.NewSuperior != "" {
+			writeLine(w, "newsuperior", entry.ModifyDN.NewSuperior)
+		}
+		return nil
+	}
+	return errors.New("ldif: empty record")
+}
+
+// writeHeader writes the dn, controls and changetype lines of a change record
+func writeHeader(w *bufio.Writer, dn string, controls []ldap.Control, changeType string) error {
+	writeLine(w, "dn", dn)
+	for _, control := range controls {
+		c, ok := control.(*ldap.ControlString)
+		if !ok {
+			return fmt.Errorf("ldif: cannot write control %s of the record for %s", control.GetControlType(), dn)
+		}
+		spec := c.ControlType
+		if c.Criticality {
+			spec += " true"
+		}
+		if c.ControlValue == "" {
+			fold(w, "control: "+spec)
+		} else if isSafeString(c.ControlValue) {
+			fold(w, "control: "+spec+": "+c.ControlValue)
+		} else {
+			fold(w, "control: "+spec+":: "+base64.StdEncoding.EncodeToString([]byte(c.ControlValue)))
+		}
+	}
+	writeLine(w, "changetype", changeType)
+	return nil
+}
+
+// writeValues writes a line for each of the string and binary values of the
+// attribute
+func writeValues(w *bufio.Writer, attr string, values []string, byteValues [][]byte) {
+	for _, value := range values {
+		writeLine(w, attr, value)
+	}
+	for _, value := range byteValues {
+		writeLine(w, attr, string(value))
+	}
+}
+
+// writeLine writes an attribute line, base64 encoding the value if it is not
+// a safe string
+func writeLine(w *bufio.Writer, attr, value string) {
+	switch {
+	case value == "":
+		fold(w, attr+":")
+	case isSafeString(value):
+		fold(w, attr+": "+value)
+	default:
+		fold(w, attr+":: "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+}
+
+// fold writes the line, folded into lines of foldWidth characters
+func fold(w *bufio.Writer, text string) {
+	width := foldWidth
+	for len(text) > width {
+		w.WriteString(text[:width])
+		w.WriteString("\n ")
+		text = text[width:]
+		// the leading space of continuation lines counts
+		width = foldWidth - 1
+	}
+	w.WriteString(text)
+	w.WriteString("\n")
+}
+
+// isSafeString reports whether the value can be written as is, as defined
+// by SAFE-STRING in RFC 2849. Values ending with a space are also encoded,
+// since trailing spaces are easily lost.
+func isSafeString(value string) bool {
+	if value == "" {
+		return true
+	}
+	switch value[0] {
+	case ' ', ':', '<':
+		return false
+	}
+	if value[len(value)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == 0 || c == '\n' || c == '\r' || c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
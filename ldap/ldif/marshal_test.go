@@ -0,0 +1,79 @@
+package ldif
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gostores/checking/ldap"
+)
+
+func TestMarshal(t *testing.T) {
+	modify := ldap.NewModifyRequest("cn=Paula Jensen,dc=airius,dc=com", ldap.NewControlString("1.2.3.4", true, "value"))
+	modify.Add("postaladdress", []string{"123 Anystreet $ Sunnyvale, CA $ 94086"})
+	modify.Delete("description", nil)
+	modify.Replace("telephonenumber", []string{"+1 408 555 1234", "+1 408 555 5678"})
+	modify.Increment("uidNumber", 1)
+	l := &LDIF{Version: 1, Entries: []*Entry{
+		{Entry: ldap.NewEntry("cn=Barbara Jensen,dc=airius,dc=com", map[string][]string{
+			"description": {"What a careful reader you are! " + strings.Repeat("x", 80)},
+			"cn":          {" leading space", "Bärbel"},
+		})},
+		{Add: &ldap.AddRequest{DN: "cn=Fiona Jensen,dc=airius,dc=com", Attributes: []ldap.Attribute{
+			{Type: "cn", Vals: []string{"Fiona Jensen"}},
+			{Type: "jpegPhoto", ByteVals: [][]byte{{0xff, 0xd8, 0x00}}},
+		}}},
+		{Del: ldap.NewDelRequest("cn=Robert Jensen,dc=airius,dc=com", nil)},
+		{Modify: modify},
+		{ModifyDN: &ldap.ModifyDNRequest{DN: "cn=Paul Jensen,dc=airius,dc=com", NewRDN: "cn=Paula Jensen", DeleteOldRDN: true, NewSuperior: "ou=People,dc=airius,dc=com"}},
+	}}
+
+	data, err := Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if len(line) > foldWidth {
+			t.Errorf("line %q is not folded", line)
+		}
+	}
+	for _, expected := range []string{"cn:: IGxlYWRpbmcgc3BhY2U=\n", "cn:: QsOkcmJlbA==\n", "control: 1.2.3.4 true: value\n", "delete: description\n-\n", "increment: uidNumber\nuidNumber: 1\n-\n"} {
+		if !strings.Contains(data, expected) {
+			t.Errorf("expected %q in\n%s", expected, data)
+		}
+	}
+
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("parsing %s: %s", data, err)
+	}
+	if len(parsed.Entries) != len(l.Entries) {
+		t.Fatalf("expected %d records, got %d", len(l.Entries), len(parsed.Entries))
+	}
+	// the values of the parsed records are strings
+	l.Entries[1].Add.Attributes[1] = ldap.Attribute{Type: "jpegPhoto", Vals: []string{"\xff\xd8\x00"}}
+	for i, entry := range parsed.Entries {
+		if entry.DN() != l.Entries[i].DN() {
+			t.Errorf("record %d: expected DN %q, got %q", i, l.Entries[i].DN(), entry.DN())
+		}
+	}
+	if !reflect.DeepEqual(parsed.Entries[0].Entry.GetAttributeValues("description"), l.Entries[0].Entry.GetAttributeValues("description")) {
+		t.Errorf("unexpected folded description %q", parsed.Entries[0].Entry.GetAttributeValues("description"))
+	}
+	if !reflect.DeepEqual(parsed.Entries[0].Entry.GetAttributeValues("cn"), l.Entries[0].Entry.GetAttributeValues("cn")) {
+		t.Errorf("unexpected base64 values %q", parsed.Entries[0].Entry.GetAttributeValues("cn"))
+	}
+	if !reflect.DeepEqual(parsed.Entries[1].Add.Attributes, l.Entries[1].Add.Attributes) {
+		t.Errorf("unexpected add attributes %#v", parsed.Entries[1].Add.Attributes)
+	}
+	if !reflect.DeepEqual(parsed.Entries[3].Modify.Changes, modify.Changes) {
+		t.Errorf("unexpected changes %#v", parsed.Entries[3].Modify.Changes)
+	}
+	if !reflect.DeepEqual(parsed.Entries[4].ModifyDN, l.Entries[4].ModifyDN) {
+		t.Errorf("unexpected modrdn %#v", parsed.Entries[4].ModifyDN)
+	}
+
+	if _, err := Marshal(&LDIF{Entries: []*Entry{{}}}); err == nil {
+		t.Error("expected an error for an empty record")
+	}
+}
@@ -0,0 +1,144 @@
+// File contains the closing of idle and long-lived connections
+
+package ldap
+
+import (
+	"time"
+)
+
+// SetIdleTimeout closes the connection once it has had no outstanding request
+// for the duration, e.g. before a load balancer or firewall drops it. A
+// duration of 0, the default, keeps idle connections open.
+func (l *Conn) SetIdleTimeout(timeout time.Duration) {
+	l.messageMutex.Lock()
+	l.idleTimeout = timeout
+	l.messageMutex.Unlock()
+	l.watchLifetime()
+}
+
+// SetMaxLifetime closes the connection once it has been open for the
+// duration, as soon as its outstanding requests complete. A duration of 0,
+// the default, keeps the connection open.
+func (l *Conn) SetMaxLifetime(lifetime time.Duration) {
+	l.messageMutex.Lock()
+	l.maxLifetime = lifetime
+	l.messageMutex.Unlock()
+	l.watchLifetime()
+}
+
+// watchLifetime starts the goroutine closing the connection once it expires,
+// or wakes it up to take a new setting into account
+func (l *Conn) watchLifetime() {
+	l.lifetimeOnce.Do(func() {
+		go l.lifetimeWatcher()
+	})
+	l.wakeLifetimeWatcher()
+}
+
+// wakeLifetimeWatcher wakes up the goroutine watching the lifetime of the
+// connection, if it is not already being woken up
+func (l *Conn) wakeLifetimeWatcher() {
+	select {
+	case l.lifetimeWake <- struct{}{}:
+	default:
+	}
+}
+
+// lifetimeWatcher closes the connection once it expires, waiting for the
+// next deadline or for its requests to complete
+func (l *Conn) lifetimeWatcher() {
+	for {
+		l.messageMutex.Lock()
+		now := time.Now()
+		expired := l.expired(now)
+		wait := l.nextExpiry(now)
+		l.messageMutex.Unlock()
+		if expired {
+			l.Debug.Printf("Closing expired connection")
+			l.Close()
+			return
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-l.chanClose:
+		case <-l.lifetimeWake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if l.isClosing() {
+			return
+		}
+	}
+}
+
+// expired reports whether the connection has no outstanding request and has
+// been idle or open for too long. It is called with messageMutex held.
+func (l *Conn) expired(now time.Time) bool {
+	if l.outstandingRequests > 0 {
+		return false
+	}
+	return (l.idleTimeout > 0 && now.Sub(l.lastActive) >= l.idleTimeout) || l.pastMaxLifetime(now)
+}
+
+// pastMaxLifetime reports whether the connection has been open for longer
+// than its maximum lifetime. It is called with messageMutex held.
+func (l *Conn) pastMaxLifetime(now time.Time) bool {
+	return l.maxLifetime > 0 && now.Sub(l.createdAt) >= l.maxLifetime
+}
+
+// nextExpiry returns the time until the connection may expire, or 0 if it
+// cannot expire before a request completes or the settings change. It is
+// called with messageMutex held.
+func (l *Conn) nextExpiry(now time.Time) time.Duration {
+	var wait time.Duration
+	if l.idleTimeout > 0 && l.outstandingRequests == 0 {
+		wait = l.lastActive.Add(l.idleTimeout).Sub(now)
+	}
+	if l.maxLifetime > 0 && l.outstandingRequests == 0 {
+		if untilExpiry := l.createdAt.Add(l.maxLifetime).Sub(now); wait <= 0 || untilExpiry < wait {
+			wait = untilExpiry
+		}
+	}
+	return wait
+}
+
+// isExpiring reports whether the connection has been idle or open for too
+// long, and should no longer be used for new requests
+func (l *Conn) isExpiring() bool {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	now := time.Now()
+	return l.expired(now) || l.pastMaxLifetime(now)
+}
+
+// SetIdleTimeout closes the connection, and the connections replacing it,
+// once they have been idle for the duration. The next operation then
+// reconnects.
+func (r *ReconnectingConn) SetIdleTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.idleTimeout = timeout
+	if r.conn != nil {
+		r.conn.SetIdleTimeout(timeout)
+	}
+}
+
+// SetMaxLifetime replaces the connection once it has been open for the
+// duration. The next operation is performed on a new connection, while the
+// expired one is closed once its outstanding requests complete.
+func (r *ReconnectingConn) SetMaxLifetime(lifetime time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxLifetime = lifetime
+	if r.conn != nil {
+		r.conn.SetMaxLifetime(lifetime)
+	}
+}
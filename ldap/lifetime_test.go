@@ -0,0 +1,95 @@
+package ldap
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitClosed waits for the connection to be closed, up to a second
+func waitClosed(conn *Conn) bool {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if conn.isClosing() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConnIdleTimeout(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	conn.SetIdleTimeout(100 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if err := conn.UnauthenticatedBind(""); err != nil {
+			t.Fatalf("bind while active: %s", err)
+		}
+	}
+	if !waitClosed(conn) {
+		t.Error("expected the idle connection to be closed")
+	}
+}
+
+func TestConnMaxLifetime(t *testing.T) {
+	handler := &testHandler{abandoned: make(chan struct{})}
+	server, conn := newTestServer(t, handler)
+	defer server.Close()
+	defer conn.Close()
+
+	conn.SetMaxLifetime(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	searched := make(chan error)
+	go func() {
+		_, err := conn.SearchContext(ctx, NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(wait=*)", nil, nil))
+		searched <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if conn.isClosing() {
+		t.Fatal("expected the connection to wait for its outstanding request")
+	}
+	if err := <-searched; err != context.DeadlineExceeded {
+		t.Errorf("expected the search to time out, got %v", err)
+	}
+	if !waitClosed(conn) {
+		t.Error("expected the expired connection to be closed")
+	}
+}
+
+func TestReconnectingConnMaxLifetime(t *testing.T) {
+	server, address := startTestBackendServer(t)
+	defer server.Close()
+	r, err := NewReconnectingConn(func() (*Conn, error) {
+		return DialURL("ldap://" + address)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.SetMaxLifetime(50 * time.Millisecond)
+	if err := r.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatalf("bind: %s", err)
+	}
+	r.mu.Lock()
+	first := r.conn
+	r.mu.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := r.Search(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); err != nil {
+		t.Fatalf("search: %s", err)
+	}
+	r.mu.Lock()
+	second := r.conn
+	r.mu.Unlock()
+	if second == first {
+		t.Error("expected the expired connection to be replaced")
+	}
+	if !waitClosed(first) {
+		t.Error("expected the expired connection to be closed")
+	}
+}
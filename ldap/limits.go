@@ -0,0 +1,40 @@
+// File contains the handling of searches stopped by a limit
+
+package ldap
+
+import (
+	"sync/atomic"
+)
+
+// SetIgnoreLimitExceeded sets whether searches stopped by a size, time or
+// administrative limit succeed with the entries found before the limit, and
+// their LimitExceeded field set, instead of failing with an error matching
+// IsSizeOrTimeLimitExceeded along with the entries.
+func (l *Conn) SetIgnoreLimitExceeded(ignore bool) {
+	var value uint32
+	if ignore {
+		value = 1
+	}
+	atomic.StoreUint32(&l.ignoreLimitExceeded, value)
+}
+
+// limitExceeded returns nil instead of an error of a search stopped by a
+// limit, if set by SetIgnoreLimitExceeded
+func (l *Conn) limitExceeded(result *SearchResult, err error) error {
+	if result != nil && result.LimitExceeded && IsSizeOrTimeLimitExceeded(err) && atomic.LoadUint32(&l.ignoreLimitExceeded) == 1 {
+		return nil
+	}
+	return err
+}
+
+// SetIgnoreLimitExceeded sets whether the searches of the connection, and of
+// the connections replacing it, stopped by a limit succeed with the entries
+// found before the limit. See Conn.SetIgnoreLimitExceeded.
+func (r *ReconnectingConn) SetIgnoreLimitExceeded(ignore bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ignoreLimitExceeded = ignore
+	if r.conn != nil {
+		r.conn.SetIgnoreLimitExceeded(ignore)
+	}
+}
@@ -0,0 +1,48 @@
+package ldap
+
+import (
+	"testing"
+)
+
+func TestSearchLimitExceeded(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	request := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 1, 0, false, "(uid=*)", []string{"uid"}, nil)
+	result, err := conn.Search(request)
+	if !IsErrorWithCode(err, LDAPResultSizeLimitExceeded) {
+		t.Fatalf("expected a size limit error, got %v", err)
+	}
+	if result == nil || !result.LimitExceeded || len(result.Entries) != 1 {
+		t.Fatalf("expected the entry found before the limit, got %#v", result)
+	}
+
+	var streamed int
+	result, err = conn.SearchStream(request, func(*Entry) error {
+		streamed++
+		return nil
+	})
+	if !IsSizeOrTimeLimitExceeded(err) || result == nil || !result.LimitExceeded || streamed != 1 {
+		t.Errorf("unexpected streamed search %#v, %d entries, %v", result, streamed, err)
+	}
+
+	conn.SetIgnoreLimitExceeded(true)
+	result, err = conn.Search(request)
+	if err != nil || !result.LimitExceeded || len(result.Entries) != 1 {
+		t.Errorf("expected the limit to be ignored, got %#v, %v", result, err)
+	}
+	result, err = conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=*)", []string{"uid"}, nil))
+	if err != nil || result.LimitExceeded || len(result.Entries) != 2 {
+		t.Errorf("unexpected search without limit %#v, %v", result, err)
+	}
+	if _, err := conn.Search(NewSearchRequest("ou=missing,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 1, 0, false, "(uid=*)", nil, nil)); !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+		t.Errorf("expected other errors to be returned, got %v", err)
+	}
+
+	conn.SetIgnoreLimitExceeded(false)
+	result, err = conn.SearchWithPaging(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 1, 0, false, "(uid=*)", []string{"uid"}, nil), 10)
+	if !IsSizeOrTimeLimitExceeded(err) || result == nil || len(result.Entries) != 1 {
+		t.Errorf("expected the paged entries found before the limit, got %#v, %v", result, err)
+	}
+}
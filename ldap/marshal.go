@@ -0,0 +1,198 @@
+// File contains the mapping of tagged structs to add and modify requests
+
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// generalizedTimeLayout formats times in the Generalized Time syntax, with a
+// fraction of second only when needed
+const generalizedTimeLayout = "20060102150405.999999999Z"
+
+// FormatGeneralizedTime returns the time in the Generalized Time syntax, in UTC
+func FormatGeneralizedTime(t time.Time) string {
+	return t.UTC().Format(generalizedTimeLayout)
+}
+
+// MarshalAddRequest returns an AddRequest for the entry described by the
+// struct v, or a pointer to it, whose fields are mapped to attributes by their
+// `ldap:"attribute"` tags as for Entry.Unmarshal. The DN of the request is
+// taken from the field tagged `ldap:"dn"`.
+//
+// Empty strings and slices and zero times are left out of the request. Other
+// zero values, such as 0 and false, are sent unless the tag has the omitempty
+// option, e.g. `ldap:"uidNumber,omitempty"`.
+func MarshalAddRequest(v interface{}, controls ...Control) (*AddRequest, error) {
+	addRequest := NewAddRequest("", controls...)
+	err := marshalFields(v, func(name string, attribute *PartialAttribute) {
+		if name == dnField {
+			addRequest.DN = attribute.Vals[0]
+		} else if attribute != nil {
+			addRequest.Attributes = append(addRequest.Attributes, Attribute{Type: name, Vals: attribute.Vals, ByteVals: attribute.ByteVals})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addRequest, nil
+}
+
+// MarshalModifyRequest returns a ModifyRequest changing the entry into the one
+// described by the struct v, as for MarshalAddRequest. Attributes whose values
+// differ are replaced, attributes whose field is empty are deleted, and
+// attributes which are not mapped by a field are left unchanged. The DN of
+// the request is the DN of the entry: renaming requires a ModifyDNRequest.
+func (e *Entry) MarshalModifyRequest(v interface{}, controls ...Control) (*ModifyRequest, error) {
+	modifyRequest := NewModifyRequest(e.DN, controls...)
+	err := marshalFields(v, func(name string, attribute *PartialAttribute) {
+		if name == dnField {
+			return
+		}
+		var current [][]byte
+		if attr := e.getAttribute(name); attr != nil {
+			current = attr.ByteValues
+		}
+		switch {
+		case attribute == nil:
+			if len(current) > 0 {
+				modifyRequest.Delete(name, []string{})
+			}
+		case !sameValues(current, attribute.values()):
+			modifyRequest.appendChange(ReplaceAttribute, *attribute)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modifyRequest, nil
+}
+
+// values returns the string and binary values of the attribute as bytes
+func (p *PartialAttribute) values() [][]byte {
+	values := make([][]byte, 0, len(p.Vals)+len(p.ByteVals))
+	for _, value := range p.Vals {
+		values = append(values, []byte(value))
+	}
+	return append(values, p.ByteVals...)
+}
+
+// sameValues reports whether both sets hold the same values, in any order
+func sameValues(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	matched := make([]bool, len(b))
+	for _, value := range a {
+		found := false
+		for i, other := range b {
+			if !matched[i] && bytes.Equal(value, other) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// marshalFields calls f with the attribute of every mapped field of the
+// struct v, which is nil when the field is empty
+func marshalFields(v interface{}, f func(name string, attribute *PartialAttribute)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("ldap: Marshal requires a struct or a non-nil pointer to a struct")
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, omitEmpty := fieldAttribute(field)
+		if name == "" {
+			continue
+		}
+		if name == dnField {
+			if field.Type.Kind() != reflect.String {
+				return fmt.Errorf("ldap: field %s holding the DN must be a string", field.Name)
+			}
+			f(name, &PartialAttribute{Type: name, Vals: []string{rv.Field(i).String()}})
+			continue
+		}
+		attribute, err := marshalField(name, rv.Field(i), omitEmpty)
+		if err != nil {
+			return fmt.Errorf("ldap: cannot marshal field %s into attribute %s: %s", field.Name, name, err)
+		}
+		f(name, attribute)
+	}
+	return nil
+}
+
+// marshalField returns the attribute holding the value of the field, or nil
+// if the field is empty
+func marshalField(name string, field reflect.Value, omitEmpty bool) (*PartialAttribute, error) {
+	attribute := &PartialAttribute{Type: name}
+
+	if field.Type() == timeType {
+		t := field.Interface().(time.Time)
+		if t.IsZero() {
+			return nil, nil
+		}
+		attribute.Vals = []string{FormatGeneralizedTime(t)}
+		return attribute, nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		if field.Len() == 0 {
+			return nil, nil
+		}
+		attribute.Vals = []string{field.String()}
+	case reflect.Bool:
+		if omitEmpty && !field.Bool() {
+			return nil, nil
+		}
+		attribute.Vals = []string{"FALSE"}
+		if field.Bool() {
+			attribute.Vals[0] = "TRUE"
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if omitEmpty && field.Int() == 0 {
+			return nil, nil
+		}
+		attribute.Vals = []string{strconv.FormatInt(field.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if omitEmpty && field.Uint() == 0 {
+			return nil, nil
+		}
+		attribute.Vals = []string{strconv.FormatUint(field.Uint(), 10)}
+	case reflect.Slice:
+		if field.Len() == 0 {
+			return nil, nil
+		}
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			attribute.Vals = field.Convert(reflect.TypeOf([]string(nil))).Interface().([]string)
+		case reflect.Uint8:
+			attribute.ByteVals = [][]byte{field.Bytes()}
+		case reflect.Slice:
+			if field.Type().Elem().Elem().Kind() != reflect.Uint8 {
+				return nil, fmt.Errorf("unsupported type %s", field.Type())
+			}
+			attribute.ByteVals = field.Convert(reflect.TypeOf([][]byte(nil))).Interface().([][]byte)
+		default:
+			return nil, fmt.Errorf("unsupported type %s", field.Type())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return attribute, nil
+}
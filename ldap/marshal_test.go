@@ -0,0 +1,106 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalAddRequest(t *testing.T) {
+	user := testUser{
+		DN:        "uid=jdoe,ou=people,dc=example,dc=com",
+		CN:        "John Doe",
+		Mail:      []string{"jdoe@example.com"},
+		UIDNumber: 1000,
+		Created:   time.Date(2026, 10, 15, 14, 30, 0, 500000000, time.FixedZone("", 2*3600)),
+		Photo:     []byte{0xff, 0xd8},
+		Ignored:   "ignored",
+	}
+	addRequest, err := MarshalAddRequest(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addRequest.DN != user.DN {
+		t.Errorf("got DN %q", addRequest.DN)
+	}
+	expected := map[string]string{
+		"cn":              "John Doe",
+		"mail":            "jdoe@example.com",
+		"uidNumber":       "1000",
+		"nsAccountLock":   "FALSE",
+		"createTimestamp": "20261015123000.5Z",
+		"jpegPhoto":       "\xff\xd8",
+	}
+	if len(addRequest.Attributes) != len(expected) {
+		t.Errorf("got attributes %v", addRequest.Attributes)
+	}
+	for _, attribute := range addRequest.Attributes {
+		values := attribute.Vals
+		for _, value := range attribute.ByteVals {
+			values = append(values, string(value))
+		}
+		if len(values) != 1 || values[0] != expected[attribute.Type] {
+			t.Errorf("%s: got values %q, expected %q", attribute.Type, values, expected[attribute.Type])
+		}
+	}
+
+	// the marshaled request unmarshals back to the struct
+	entry := &Entry{DN: addRequest.DN}
+	for _, attribute := range addRequest.Attributes {
+		entry.Attributes = append(entry.Attributes, NewEntryAttribute(attribute.Type, attribute.Vals))
+		if attribute.ByteVals != nil {
+			entry.Attributes[len(entry.Attributes)-1] = NewBinaryEntryAttribute(attribute.Type, attribute.ByteVals)
+		}
+	}
+	var decoded testUser
+	if err := entry.Unmarshal(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.CN != user.CN || !decoded.Created.Equal(user.Created) || string(decoded.Photo) != string(user.Photo) {
+		t.Errorf("got %+v, expected %+v", decoded, user)
+	}
+
+	if _, err := MarshalAddRequest(42); err == nil {
+		t.Error("expected an error marshaling a non-struct")
+	}
+}
+
+func TestMarshalModifyRequest(t *testing.T) {
+	entry := NewEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{
+		"cn":            {"John Doe"},
+		"mail":          {"jdoe@example.com", "john.doe@example.com"},
+		"uidNumber":     {"1000"},
+		"nsAccountLock": {"FALSE"},
+		"jpegPhoto":     {"\xff\xd8"},
+		"description":   {"unmapped"},
+	})
+	user := struct {
+		CN        string   `ldap:"cn"`
+		Mail      []string `ldap:"mail"`
+		UIDNumber int      `ldap:"uidNumber"`
+		Disabled  bool     `ldap:"nsAccountLock"`
+		Photo     []byte   `ldap:"jpegPhoto"`
+		Title     string   `ldap:"title"`
+	}{
+		CN:        "John Doe",
+		Mail:      []string{"john.doe@example.com", "jdoe@example.com"},
+		UIDNumber: 1001,
+		Title:     "Engineer",
+	}
+
+	modifyRequest, err := entry.MarshalModifyRequest(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modifyRequest.DN != entry.DN {
+		t.Errorf("unexpected request %+v", modifyRequest)
+	}
+	expected := []Change{
+		{Operation: ReplaceAttribute, Modification: PartialAttribute{Type: "uidNumber", Vals: []string{"1001"}}},
+		{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "jpegPhoto", Vals: []string{}}},
+		{Operation: ReplaceAttribute, Modification: PartialAttribute{Type: "title", Vals: []string{"Engineer"}}},
+	}
+	if !reflect.DeepEqual(modifyRequest.Changes, expected) {
+		t.Errorf("got changes %+v, expected %+v", modifyRequest.Changes, expected)
+	}
+}
@@ -0,0 +1,110 @@
+// File contains the limit of the size of the messages received
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// DefaultMaxMessageSize is the maximum size of the messages received by a
+// connection, unless set otherwise with SetMaxMessageSize
+const DefaultMaxMessageSize = 32 << 20
+
+// SetMaxMessageSize sets the maximum size in bytes of the messages received,
+// such as a search result entry, so that a misbehaving or malicious server
+// cannot make the client allocate unbounded memory. Once a larger message is
+// announced, the connection is closed and the outstanding operations fail
+// with an error of code ErrorMessageTooLarge. A size of 0 restores
+// DefaultMaxMessageSize.
+func (l *Conn) SetMaxMessageSize(size int64) {
+	atomic.StoreInt64(&l.maxMessageSize, size)
+}
+
+func (l *Conn) loadMaxMessageSize() int64 {
+	if size := atomic.LoadInt64(&l.maxMessageSize); size > 0 {
+		return size
+	}
+	return DefaultMaxMessageSize
+}
+
+// SetMaxMessageSize sets the maximum size of the messages received by the
+// connection and by the connections replacing it
+func (r *ReconnectingConn) SetMaxMessageSize(size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxMessageSize = size
+	if r.conn != nil {
+		r.conn.SetMaxMessageSize(size)
+	}
+}
+
+// readMessageHeader reads the identifier and length octets of the next BER
+// element, checking that the length announced does not exceed maxSize, and
+// returns them along with the length
+func readMessageHeader(r io.Reader, maxSize int64) ([]byte, int64, error) {
+	header := make([]byte, 1, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	if header[0]&0x1f == 0x1f {
+		// high tag number form, in base 128 ending with an octet below 0x80
+		for {
+			b, err := readHeaderByte(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			header = append(header, b)
+			if b&0x80 == 0 {
+				break
+			}
+			if len(header) > 8 {
+				return nil, 0, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid message tag"))
+			}
+		}
+	}
+
+	b, err := readHeaderByte(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	header = append(header, b)
+	length := int64(b)
+	switch {
+	case b == 0x80:
+		// LDAP messages only use the definite form, see RFC 4511 section 5.1
+		return nil, 0, NewError(ErrorUnexpectedResponse, errors.New("ldap: message of indefinite length"))
+	case b > 0x80:
+		n := int(b & 0x7f)
+		if n > 8 {
+			return nil, 0, NewError(ErrorMessageTooLarge, fmt.Errorf("ldap: message length of %d octets exceeds the maximum of %d bytes", n, maxSize))
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := readHeaderByte(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			header = append(header, b)
+			length = length<<8 | int64(b)
+		}
+	}
+	if length > maxSize || length < 0 {
+		return nil, 0, NewError(ErrorMessageTooLarge, fmt.Errorf("ldap: message of %d bytes exceeds the maximum of %d bytes", length, maxSize))
+	}
+	return header, length, nil
+}
+
+// readHeaderByte reads a single octet of a header, following the first one
+func readHeaderByte(r io.Reader) (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	return b[0], nil
+}
@@ -0,0 +1,68 @@
+package ldap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestReadMessageHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		header int
+		code   uint8
+	}{
+		{"short form", []byte{0x30, 0x05, 0x02}, 2, 0},
+		{"long form", []byte{0x30, 0x82, 0x01, 0x00}, 4, 0},
+		{"high tag", []byte{0x7f, 0x81, 0x01, 0x03}, 4, 0},
+		{"too large", []byte{0x30, 0x82, 0x04, 0x01}, 0, ErrorMessageTooLarge},
+		{"length overflow", []byte{0x30, 0x88, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 0, ErrorMessageTooLarge},
+		{"too many length octets", []byte{0x30, 0x89}, 0, ErrorMessageTooLarge},
+		{"indefinite length", []byte{0x30, 0x80}, 0, ErrorUnexpectedResponse},
+	}
+	for _, test := range tests {
+		header, _, err := readMessageHeader(bytes.NewReader(test.data), 1024)
+		if test.code != 0 {
+			if !IsErrorWithCode(err, test.code) {
+				t.Errorf("%s: expected an error of code %d, got %v", test.name, test.code, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !bytes.Equal(header, test.data[:test.header]) {
+			t.Errorf("%s: expected header %x, got %x", test.name, test.data[:test.header], header)
+		}
+	}
+
+	if _, _, err := readMessageHeader(bytes.NewReader([]byte{0x30, 0x82, 0x01}), 1024); err == nil {
+		t.Error("expected an error for a truncated header")
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.SetMaxMessageSize(1024)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, strings.Repeat("x", 2048))}
+	})
+
+	runWithTimeout(t, 5*time.Second, func() {
+		err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil))
+		if !IsErrorWithCode(err, ErrorMessageTooLarge) {
+			t.Fatalf("expected an error of code %d, got %v", ErrorMessageTooLarge, err)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+// File contains the Backend holding its entries in memory
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemoryBackend is a Backend holding its entries in memory. Entries are added
+// below existing entries, except top entries with a single RDN, and only
+// leaf entries are deleted. Changes are applied in full or not at all. It is
+// safe for concurrent use.
+type MemoryBackend struct {
+	mu sync.Mutex
+	// entries are the entries by normalized DN
+	entries map[string]*Entry
+}
+
+var _ Backend = &MemoryBackend{}
+
+// NewMemoryBackend returns a backend holding the given entries, or an error
+// if one of them has an invalid DN
+func NewMemoryBackend(entries ...*Entry) (*MemoryBackend, error) {
+	b := &MemoryBackend{entries: make(map[string]*Entry)}
+	for _, entry := range entries {
+		if err := b.Put(entry); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// entryKey returns the key of the entry with the given DN, and the parsed DN
+func entryKey(dn string) (string, *DN, error) {
+	parsed, err := ParseDN(dn)
+	if err != nil {
+		return "", nil, NewError(LDAPResultInvalidDNSyntax, fmt.Errorf("ldap: invalid DN %q: %s", dn, err))
+	}
+	return strings.ToLower(parsed.String()), parsed, nil
+}
+
+func noSuchObject(dn string) error {
+	return NewError(LDAPResultNoSuchObject, fmt.Errorf("ldap: no entry %s", dn))
+}
+
+// Put adds the entry, or replaces the entry with the same DN, without the
+// checks of ApplyChange
+func (b *MemoryBackend) Put(entry *Entry) error {
+	key, _, err := entryKey(entry.DN)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[key] = copyEntry(entry)
+	return nil
+}
+
+// Lookup returns a copy of the entry with the given DN, or nil if there is
+// none
+func (b *MemoryBackend) Lookup(ctx context.Context, dn string) (*Entry, error) {
+	key, _, err := entryKey(dn)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if entry, ok := b.entries[key]; ok {
+		return copyEntry(entry), nil
+	}
+	return nil, nil
+}
+
+// SearchSubtree calls fn with copies of the entries of the subtree, ordered by
+// DN
+func (b *MemoryBackend) SearchSubtree(ctx context.Context, baseDN string, fn func(*Entry) error) error {
+	_, base, err := entryKey(baseDN)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	keys := make([]string, 0, len(b.entries))
+	for key := range b.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var entries []*Entry
+	for _, key := range keys {
+		entry := b.entries[key]
+		dn, _ := ParseDN(entry.DN)
+		if base.EqualFold(dn) || base.AncestorOfFold(dn) {
+			entries = append(entries, copyEntry(entry))
+		}
+	}
+	b.mu.Unlock()
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyChange adds, modifies or deletes an entry
+func (b *MemoryBackend) ApplyChange(ctx context.Context, change *BackendChange) error {
+	switch {
+	case change.Add != nil:
+		return b.add(change.Add)
+	case change.Modify != nil:
+		return b.modify(change.Modify)
+	case change.Del != nil:
+		return b.del(change.Del.DN)
+	}
+	return NewError(LDAPResultUnwillingToPerform, errors.New("ldap: empty change"))
+}
+
+// add adds a new entry, whose parent must exist unless it is a top entry
+func (b *MemoryBackend) add(request *AddRequest) error {
+	entry := &Entry{DN: request.DN}
+	for _, attribute := range request.Attributes {
+		values := append([]string(nil), attribute.Vals...)
+		for _, value := range attribute.ByteVals {
+			values = append(values, string(value))
+		}
+		entry.Attributes = append(entry.Attributes, NewEntryAttribute(attribute.Type, values))
+	}
+	key, dn, err := entryKey(entry.DN)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.entries[key]; ok {
+		return NewError(LDAPResultEntryAlreadyExists, fmt.Errorf("ldap: entry %s already exists", entry.DN))
+	}
+	if len(dn.RDNs) > 1 {
+		parent := dn.Parent()
+		if _, ok := b.entries[strings.ToLower(parent.String())]; !ok {
+			return noSuchObject(parent.String())
+		}
+	}
+	b.entries[key] = entry
+	return nil
+}
+
+// modify applies the changes to the entry, all of them or none
+func (b *MemoryBackend) modify(request *ModifyRequest) error {
+	key, _, err := entryKey(request.DN)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry, ok := b.entries[key]
+	if !ok {
+		return noSuchObject(request.DN)
+	}
+	modified := copyEntry(entry)
+	for _, change := range request.Changes {
+		if err := applyChange(modified, change); err != nil {
+			return err
+		}
+	}
+	b.entries[key] = modified
+	return nil
+}
+
+// applyChange applies one change of a modify request to the entry
+func applyChange(entry *Entry, change Change) error {
+	name := change.Modification.Type
+	values := append([]string(nil), change.Modification.Vals...)
+	for _, value := range change.Modification.ByteVals {
+		values = append(values, string(value))
+	}
+	var current []string
+	if attribute := entry.getAttribute(name); attribute != nil {
+		current = append(current, attribute.Values...)
+	}
+
+	switch change.Operation {
+	case AddAttribute:
+		for _, value := range values {
+			if indexOfValue(current, value) >= 0 {
+				return NewError(LDAPResultAttributeOrValueExists, fmt.Errorf("ldap: attribute %s already has the value %q", name, value))
+			}
+			current = append(current, value)
+		}
+	case DeleteAttribute:
+		if len(current) == 0 {
+			return NewError(LDAPResultNoSuchAttribute, fmt.Errorf("ldap: no attribute %s", name))
+		}
+		if len(values) == 0 {
+			current = nil
+		}
+		for _, value := range values {
+			i := indexOfValue(current, value)
+			if i < 0 {
+				return NewError(LDAPResultNoSuchAttribute, fmt.Errorf("ldap: attribute %s has no value %q", name, value))
+			}
+			current = append(current[:i], current[i+1:]...)
+		}
+	case ReplaceAttribute:
+		current = values
+	case IncrementAttribute:
+		if len(current) == 0 || len(values) != 1 {
+			return NewError(LDAPResultNoSuchAttribute, fmt.Errorf("ldap: no attribute %s to increment", name))
+		}
+		delta, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			return NewError(LDAPResultInvalidAttributeSyntax, fmt.Errorf("ldap: invalid increment %q", values[0]))
+		}
+		for i, value := range current {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return NewError(LDAPResultConstraintViolation, fmt.Errorf("ldap: attribute %s is not an integer", name))
+			}
+			current[i] = strconv.FormatInt(n+delta, 10)
+		}
+	default:
+		return NewError(LDAPResultProtocolError, fmt.Errorf("ldap: unknown modify operation %d", change.Operation))
+	}
+	setEntryAttribute(entry, name, current)
+	return nil
+}
+
+// del deletes the entry, which must not have children
+func (b *MemoryBackend) del(dn string) error {
+	key, parsed, err := entryKey(dn)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.entries[key]; !ok {
+		return noSuchObject(dn)
+	}
+	for _, entry := range b.entries {
+		child, _ := ParseDN(entry.DN)
+		if parsed.AncestorOfFold(child) {
+			return NewError(LDAPResultNotAllowedOnNonLeaf, fmt.Errorf("ldap: entry %s has children", dn))
+		}
+	}
+	delete(b.entries, key)
+	return nil
+}
+
+// setEntryAttribute sets the values of the attribute, removing it if there
+// are none
+func setEntryAttribute(entry *Entry, name string, values []string) {
+	for i, attribute := range entry.Attributes {
+		if strings.EqualFold(attribute.Name, name) {
+			if len(values) == 0 {
+				entry.Attributes = append(entry.Attributes[:i], entry.Attributes[i+1:]...)
+			} else {
+				entry.Attributes[i] = NewEntryAttribute(attribute.Name, values)
+			}
+			return
+		}
+	}
+	if len(values) > 0 {
+		entry.Attributes = append(entry.Attributes, NewEntryAttribute(name, values))
+	}
+}
+
+func indexOfValue(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyEntry returns a copy of the entry which does not share its attributes
+func copyEntry(entry *Entry) *Entry {
+	copied := &Entry{DN: entry.DN}
+	for _, attribute := range entry.Attributes {
+		copied.Attributes = append(copied.Attributes, NewEntryAttribute(attribute.Name, append([]string(nil), attribute.Values...)))
+	}
+	return copied
+}
@@ -0,0 +1,98 @@
+package ldap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryBackendApplyChange(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewMemoryBackend(
+		NewEntry("dc=example,dc=com", map[string][]string{"dc": {"example"}}),
+		NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{"uid": {"jdoe"}, "mail": {"jdoe@example.com"}, "logins": {"41"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	add := NewAddRequest("uid=jdoe,dc=example,dc=com")
+	if err := backend.ApplyChange(ctx, &BackendChange{Add: add}); !IsErrorWithCode(err, LDAPResultEntryAlreadyExists) {
+		t.Errorf("add of an existing entry: got %v", err)
+	}
+	add = NewAddRequest("uid=jdoe,ou=missing,dc=example,dc=com")
+	if err := backend.ApplyChange(ctx, &BackendChange{Add: add}); !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+		t.Errorf("add below a missing entry: got %v", err)
+	}
+
+	// a failing change leaves the entry unchanged
+	modify := NewModifyRequest("UID=JDOE,dc=example,dc=com")
+	modify.Replace("mail", []string{"john@example.com"})
+	modify.Delete("cn", nil)
+	if err := backend.ApplyChange(ctx, &BackendChange{Modify: modify}); !IsErrorWithCode(err, LDAPResultNoSuchAttribute) {
+		t.Errorf("modify deleting a missing attribute: got %v", err)
+	}
+	entry, err := backend.Lookup(ctx, "uid=jdoe,dc=example,dc=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mail := entry.GetAttributeValue("mail"); mail != "jdoe@example.com" {
+		t.Errorf("expected the mail to be unchanged, got %q", mail)
+	}
+
+	modify = NewModifyRequest("uid=jdoe,dc=example,dc=com")
+	modify.Add("mail", []string{"john@example.com"})
+	modify.Delete("mail", []string{"jdoe@example.com"})
+	modify.Increment("logins", 1)
+	if err := backend.ApplyChange(ctx, &BackendChange{Modify: modify}); err != nil {
+		t.Fatalf("modify: %s", err)
+	}
+	entry, _ = backend.Lookup(ctx, "uid=jdoe,dc=example,dc=com")
+	if mails := entry.GetAttributeValues("mail"); !reflect.DeepEqual(mails, []string{"john@example.com"}) {
+		t.Errorf("expected the new mail, got %v", mails)
+	}
+	if logins := entry.GetAttributeValue("logins"); logins != "42" {
+		t.Errorf("expected the incremented logins, got %q", logins)
+	}
+
+	// entries returned are copies
+	entry.Attributes = nil
+	entry, _ = backend.Lookup(ctx, "uid=jdoe,dc=example,dc=com")
+	if len(entry.Attributes) == 0 {
+		t.Error("expected the entry to be unchanged by its copy")
+	}
+
+	if err := backend.ApplyChange(ctx, &BackendChange{Del: NewDelRequest("dc=example,dc=com", nil)}); !IsErrorWithCode(err, LDAPResultNotAllowedOnNonLeaf) {
+		t.Errorf("delete of a non-leaf entry: got %v", err)
+	}
+	if err := backend.ApplyChange(ctx, &BackendChange{Del: NewDelRequest("uid=jdoe,dc=example,dc=com", nil)}); err != nil {
+		t.Errorf("delete: %s", err)
+	}
+	if entry, _ := backend.Lookup(ctx, "uid=jdoe,dc=example,dc=com"); entry != nil {
+		t.Errorf("expected the entry to be deleted, got %v", entry)
+	}
+}
+
+func TestMemoryBackendSearchSubtree(t *testing.T) {
+	backend, err := NewMemoryBackend(
+		NewEntry("dc=example,dc=com", nil),
+		NewEntry("ou=people,dc=example,dc=com", nil),
+		NewEntry("uid=jdoe,ou=people,dc=example,dc=com", nil),
+		NewEntry("dc=other,dc=com", nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dns []string
+	err = backend.SearchSubtree(context.Background(), "ou=People,dc=example,dc=com", func(entry *Entry) error {
+		dns = append(dns, entry.DN)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ou=people,dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com"}
+	if !reflect.DeepEqual(dns, expected) {
+		t.Errorf("expected %v, got %v", expected, dns)
+	}
+}
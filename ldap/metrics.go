@@ -0,0 +1,125 @@
+// File contains the instrumentation hooks of the connections
+
+package ldap
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// Instrumentation receives the events of the connections it is set on, to be
+// adapted to a metrics system such as Prometheus or statsd. Its methods are
+// called from the goroutines reading and writing the connection, so they must
+// not block.
+type Instrumentation interface {
+	// OperationStarted is called once a request has been sent, with the name
+	// of the operation, such as "Search" or "Modify DN"
+	OperationStarted(operation string)
+	// OperationFinished is called once the request is finished, with the
+	// time elapsed since it was sent and the result code of its response, or
+	// ErrorNetwork if no result was received
+	OperationFinished(operation string, duration time.Duration, resultCode uint8)
+	// BytesWritten is called with the size of each request sent
+	BytesWritten(n int)
+	// BytesRead is called with the size of each response received
+	BytesRead(n int)
+	// Reconnected is called by a ReconnectingConn once it replaced a lost
+	// connection
+	Reconnected()
+}
+
+// instrumentationValue holds the Instrumentation of a connection, as an
+// atomicValue cannot hold a nil interface
+type instrumentationValue struct {
+	Instrumentation
+}
+
+// SetInstrumentation sets the instrumentation receiving the events of the
+// connection. Events are not reported if instrumentation is nil, which is the
+// default.
+func (l *Conn) SetInstrumentation(instrumentation Instrumentation) {
+	l.instrumentation.Store(instrumentationValue{instrumentation})
+}
+
+func (l *Conn) loadInstrumentation() Instrumentation {
+	value, _ := l.instrumentation.Load().(instrumentationValue)
+	return value.Instrumentation
+}
+
+// SetInstrumentation sets the instrumentation of the connection and of the
+// connections replacing it, which also receives the reconnections
+func (r *ReconnectingConn) SetInstrumentation(instrumentation Instrumentation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instrumentation = instrumentation
+	if r.conn != nil {
+		r.conn.SetInstrumentation(instrumentation)
+	}
+}
+
+// operationName returns the name of the operation of a request tag
+func operationName(tag asn1.Tag) string {
+	return strings.TrimSuffix(ApplicationMap[uint8(tag)], " Request")
+}
+
+// isResult returns true if the application tag is the one of a response
+// holding an LDAPResult, which ends an operation
+func isResult(tag uint8) bool {
+	switch tag {
+	case ApplicationBindResponse, ApplicationSearchResultDone, ApplicationModifyResponse, ApplicationAddResponse,
+		ApplicationDelResponse, ApplicationModifyDNResponse, ApplicationCompareResponse, ApplicationExtendedResponse:
+		return true
+	}
+	return false
+}
+
+// messageStarted records the start of the operation of the message in the
+// statistics and the instrumentation of the connection. It is called by the
+// processMessages loop once the request has been sent. Abandon requests,
+// which have no response, are counted in the statistics but not reported as
+// operations to the instrumentation.
+func (l *Conn) messageStarted(msgCtx *messageContext, packet *asn1.Packet, size int) {
+	operation := ""
+	if len(packet.Children) >= 2 {
+		operation = operationName(packet.Children[1].Tag)
+	}
+	l.stats.written(operation, size)
+
+	instrumentation := l.loadInstrumentation()
+	if instrumentation == nil {
+		return
+	}
+	instrumentation.BytesWritten(size)
+	if len(packet.Children) < 2 || packet.Children[1].Tag == ApplicationAbandonRequest {
+		return
+	}
+	msgCtx.operation = operation
+	msgCtx.started = time.Now()
+	msgCtx.resultCode = ErrorNetwork
+	instrumentation.OperationStarted(msgCtx.operation)
+}
+
+// messageFinished reports the end of the operation of the message. It is
+// called by the processMessages loop once the message is removed.
+func (l *Conn) messageFinished(msgCtx *messageContext) {
+	instrumentation := l.loadInstrumentation()
+	if instrumentation == nil || msgCtx.started.IsZero() {
+		return
+	}
+	instrumentation.OperationFinished(msgCtx.operation, time.Since(msgCtx.started), msgCtx.resultCode)
+}
+
+// countingReader counts the bytes read from the connection
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.n += n
+	return n, err
+}
@@ -0,0 +1,107 @@
+package ldap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// recordingInstrumentation records the events it receives
+type recordingInstrumentation struct {
+	mu          sync.Mutex
+	events      []string
+	written     int
+	read        int
+	resultCodes []uint8
+}
+
+func (r *recordingInstrumentation) OperationStarted(operation string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "start "+operation)
+}
+
+func (r *recordingInstrumentation) OperationFinished(operation string, duration time.Duration, resultCode uint8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "finish "+operation)
+	r.resultCodes = append(r.resultCodes, resultCode)
+}
+
+func (r *recordingInstrumentation) BytesWritten(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written += n
+}
+
+func (r *recordingInstrumentation) BytesRead(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.read += n
+}
+
+func (r *recordingInstrumentation) Reconnected() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "reconnected")
+}
+
+func TestInstrumentation(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	instrumentation := &recordingInstrumentation{}
+	conn.SetInstrumentation(instrumentation)
+
+	var response *asn1.Packet
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+				newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+			}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			response = newResultPacket(p.Children[0].Value.(int64), ApplicationModifyDNResponse, LDAPResultNoSuchObject, "")
+			return []*asn1.Packet{response}
+		})
+	}()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	runWithTimeout(t, 5*time.Second, func() {
+		if _, err := conn.Search(searchRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := conn.ModifyDN(NewModifyDNRequest("cn=b,dc=example,dc=com", "cn=c", true, "")); !IsNoSuchObject(err) {
+			t.Fatalf("expected no such object, got %v", err)
+		}
+	})
+	// the operations are finished by the processMessages loop
+	time.Sleep(50 * time.Millisecond)
+
+	instrumentation.mu.Lock()
+	defer instrumentation.mu.Unlock()
+	expected := []string{"start Search", "finish Search", "start Modify DN", "finish Modify DN"}
+	if len(instrumentation.events) != len(expected) {
+		t.Fatalf("got events %v, expected %v", instrumentation.events, expected)
+	}
+	for i, event := range expected {
+		if instrumentation.events[i] != event {
+			t.Errorf("got events %v, expected %v", instrumentation.events, expected)
+			break
+		}
+	}
+	if len(instrumentation.resultCodes) != 2 || instrumentation.resultCodes[0] != LDAPResultSuccess || instrumentation.resultCodes[1] != LDAPResultNoSuchObject {
+		t.Errorf("unexpected result codes %v", instrumentation.resultCodes)
+	}
+	if instrumentation.written == 0 || instrumentation.read == 0 {
+		t.Errorf("expected bytes to be counted, got %d written and %d read", instrumentation.written, instrumentation.read)
+	}
+}
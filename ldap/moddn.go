@@ -0,0 +1,90 @@
+// File contains ModifyDN functionality
+//
+// https://tools.ietf.org/html/rfc4511
+//
+// ModifyDNRequest ::= [APPLICATION 12] SEQUENCE {
+//      entry           LDAPDN,
+//      newrdn          RelativeLDAPDN,
+//      deleteoldrdn    BOOLEAN,
+//      newSuperior     [0] LDAPDN OPTIONAL }
+//
+
+package ldap
+
+import (
+	"context"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// ModifyDNRequest holds the request to rename or move an entry
+type ModifyDNRequest struct {
+	// DN is the distinguished name of the entry to rename or move
+	DN string
+	// NewRDN is the new relative distinguished name of the entry
+	NewRDN string
+	// DeleteOldRDN controls whether the old RDN attribute values are removed from the entry
+	DeleteOldRDN bool
+	// NewSuperior, if not empty, is the DN of the new parent of the entry
+	NewSuperior string
+	// Controls hold optional controls to send with the request
+	Controls []Control
+}
+
+// NewModifyDNRequest creates a new request which can be passed to ModifyDN().
+//
+// To move an object to another parent without renaming it, pass its current
+// RDN as newRDN together with the new parent as newSuperior. To only rename
+// an object, leave newSuperior empty.
+func NewModifyDNRequest(dn string, newRDN string, deleteOldRDN bool, newSuperior string) *ModifyDNRequest {
+	return &ModifyDNRequest{
+		DN:           dn,
+		NewRDN:       newRDN,
+		DeleteOldRDN: deleteOldRDN,
+		NewSuperior:  newSuperior,
+	}
+}
+
+func (m ModifyDNRequest) encode() *asn1.Packet {
+	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationModifyDNRequest, nil, "Modify DN")
+	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, m.DN, "DN"))
+	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, m.NewRDN, "New RDN"))
+	request.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, m.DeleteOldRDN, "Delete old RDN"))
+	if m.NewSuperior != "" {
+		request.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, m.NewSuperior, "New Superior"))
+	}
+	return request
+}
+
+func (m ModifyDNRequest) controls() []Control {
+	return m.Controls
+}
+
+func (m ModifyDNRequest) responseTag() asn1.Tag {
+	return ApplicationModifyDNResponse
+}
+
+// ModifyDN renames the given DN and optionally moves it to another base
+// (when the "newSuperior" argument is not empty).
+func (l *Conn) ModifyDN(modifyDNRequest *ModifyDNRequest) error {
+	return l.ModifyDNContext(context.Background(), modifyDNRequest)
+}
+
+// ModifyDNContext performs the ModifyDNRequest. If ctx is done before the
+// server responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) ModifyDNContext(ctx context.Context, modifyDNRequest *ModifyDNRequest) error {
+	_, err := l.ModifyDNWithResultContext(ctx, modifyDNRequest)
+	return err
+}
+
+// ModifyDNWithResult performs the ModifyDNRequest and returns the controls sent back by the
+// server, such as the entry requested with a pre-read or post-read control.
+func (l *Conn) ModifyDNWithResult(modifyDNRequest *ModifyDNRequest) (*UpdateResult, error) {
+	return l.ModifyDNWithResultContext(context.Background(), modifyDNRequest)
+}
+
+// ModifyDNWithResultContext is like ModifyDNWithResult, but abandons the request and
+// returns ctx.Err() if ctx is done before the server responds.
+func (l *Conn) ModifyDNWithResultContext(ctx context.Context, modifyDNRequest *ModifyDNRequest) (*UpdateResult, error) {
+	return newUpdateResult(l.DoContext(ctx, modifyDNRequest))
+}
@@ -0,0 +1,72 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestModifyDN(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var request *asn1.Packet
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		request = p
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationModifyDNResponse, LDAPResultSuccess, "")}
+	})
+
+	modifyDNRequest := NewModifyDNRequest("uid=bob,ou=people,dc=example,dc=com", "uid=robert", true, "ou=staff,dc=example,dc=com")
+	if err := conn.ModifyDN(modifyDNRequest); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	op := request.Children[1]
+	if op.Tag != ApplicationModifyDNRequest {
+		t.Fatalf("expected modify DN request, got application tag %d", op.Tag)
+	}
+	if len(op.Children) != 4 {
+		t.Fatalf("expected 4 children, got %d", len(op.Children))
+	}
+	if got := op.Children[0].Value; got != "uid=bob,ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected entry: %v", got)
+	}
+	if got := op.Children[1].Value; got != "uid=robert" {
+		t.Errorf("unexpected new RDN: %v", got)
+	}
+	if got := op.Children[2].Value; got != true {
+		t.Errorf("unexpected delete old RDN: %v", got)
+	}
+	if got := op.Children[3]; got.ClassType != asn1.ClassContext || got.Tag != 0 || got.Data.String() != "ou=staff,dc=example,dc=com" {
+		t.Errorf("unexpected new superior: %q", got.Data.String())
+	}
+}
+
+func TestModifyDNWithoutNewSuperior(t *testing.T) {
+	request := NewModifyDNRequest("uid=bob,ou=people,dc=example,dc=com", "uid=robert", false, "").encode()
+	if len(request.Children) != 3 {
+		t.Fatalf("expected new superior to be omitted, got %d children", len(request.Children))
+	}
+}
+
+func TestModifyDNError(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationModifyDNResponse, LDAPResultEntryAlreadyExists, "entry exists")}
+	})
+
+	err := conn.ModifyDN(NewModifyDNRequest("uid=bob,dc=example,dc=com", "uid=alice", true, ""))
+	if !IsErrorWithCode(err, LDAPResultEntryAlreadyExists) {
+		t.Fatalf("expected entry already exists error, got %v", err)
+	}
+}
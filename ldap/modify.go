@@ -9,7 +9,8 @@
 //                add     (0),
 //                delete  (1),
 //                replace (2),
-//                ...  },
+//                ...,
+//                increment (3) },
 //           modification    PartialAttribute } }
 //
 // PartialAttribute ::= SEQUENCE {
@@ -26,8 +27,8 @@
 package ldap
 
 import (
-	"errors"
-	"log"
+	"context"
+	"strconv"
 
 	"github.com/gostores/encoding/asn1"
 )
@@ -37,6 +38,9 @@ const (
 	AddAttribute     = 0
 	DeleteAttribute  = 1
 	ReplaceAttribute = 2
+	// IncrementAttribute adds a delta to the values of an integer attribute,
+	// see https://tools.ietf.org/html/rfc4525
+	IncrementAttribute = 3
 )
 
 // PartialAttribute for a ModifyRequest as defined in https://tools.ietf.org/html/rfc4511
@@ -45,122 +49,182 @@ type PartialAttribute struct {
 	Type string
 	// Vals are the values of the partial attribute
 	Vals []string
+	// ByteVals are the binary values of the partial attribute, sent after Vals
+	ByteVals [][]byte
 }
 
 func (p *PartialAttribute) encode() *asn1.Packet {
 	seq := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "PartialAttribute")
 	seq.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, p.Type, "Type"))
-	set := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSet, nil, "AttributeValue")
-	for _, value := range p.Vals {
-		set.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, value, "Vals"))
-	}
-	seq.AppendChild(set)
+	seq.AppendChild(encodeAttributeValues(p.Vals, p.ByteVals))
 	return seq
 }
 
-// ModifyRequest as defined in https://tools.ietf.org/html/rfc4511
+func (p *PartialAttribute) encodeBuffer(b *berBuffer) {
+	b.begin(berSequence)
+	b.appendString(berOctetString, p.Type)
+	encodeAttributeValuesBuffer(b, p.Vals, p.ByteVals)
+	b.end()
+}
+
+// Change for a ModifyRequest as defined in https://tools.ietf.org/html/rfc4511
+type Change struct {
+	// Operation is the type of change to be made
+	Operation uint
+	// Modification is the attribute to be modified
+	Modification PartialAttribute
+}
+
+func (c *Change) encode() *asn1.Packet {
+	change := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Change")
+	change.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, uint64(c.Operation), "Operation"))
+	change.AppendChild(c.Modification.encode())
+	return change
+}
+
+func (c *Change) encodeBuffer(b *berBuffer) {
+	b.begin(berSequence)
+	b.appendInteger(berEnumerated, int64(c.Operation))
+	c.Modification.encodeBuffer(b)
+	b.end()
+}
+
+// ModifyRequest as defined in https://tools.ietf.org/html/rfc4511. The changes
+// are sent in the order they were inserted and applied by the server in that
+// order, so that a value can be deleted and another added, as required to
+// change a password in Active Directory.
 type ModifyRequest struct {
 	// DN is the distinguishedName of the directory entry to modify
 	DN string
-	// AddAttributes contain the attributes to add
-	AddAttributes []PartialAttribute
-	// DeleteAttributes contain the attributes to delete
-	DeleteAttributes []PartialAttribute
-	// ReplaceAttributes contain the attributes to replace
-	ReplaceAttributes []PartialAttribute
+	// Changes contain the attributes to modify
+	Changes []Change
+	// Controls hold optional controls to send with the request
+	Controls []Control
+}
+
+func (m *ModifyRequest) appendChange(operation uint, attribute PartialAttribute) {
+	m.Changes = append(m.Changes, Change{Operation: operation, Modification: attribute})
 }
 
-// Add inserts the given attribute to the list of attributes to add
+// Add appends the given attribute to the list of changes to be made
 func (m *ModifyRequest) Add(attrType string, attrVals []string) {
-	m.AddAttributes = append(m.AddAttributes, PartialAttribute{Type: attrType, Vals: attrVals})
+	m.appendChange(AddAttribute, PartialAttribute{Type: attrType, Vals: attrVals})
 }
 
-// Delete inserts the given attribute to the list of attributes to delete
+// Delete appends the given attribute to the list of changes to be made
 func (m *ModifyRequest) Delete(attrType string, attrVals []string) {
-	m.DeleteAttributes = append(m.DeleteAttributes, PartialAttribute{Type: attrType, Vals: attrVals})
+	m.appendChange(DeleteAttribute, PartialAttribute{Type: attrType, Vals: attrVals})
 }
 
-// Replace inserts the given attribute to the list of attributes to replace
+// Replace appends the given attribute to the list of changes to be made
 func (m *ModifyRequest) Replace(attrType string, attrVals []string) {
-	m.ReplaceAttributes = append(m.ReplaceAttributes, PartialAttribute{Type: attrType, Vals: attrVals})
+	m.appendChange(ReplaceAttribute, PartialAttribute{Type: attrType, Vals: attrVals})
+}
+
+// Increment appends the given attribute to the list of changes to be made,
+// incrementing it by delta, which may be negative. The server must support
+// the Modify-Increment extension, published as the "1.3.6.1.1.14" feature of
+// the rootDSE.
+func (m *ModifyRequest) Increment(attrType string, delta int64) {
+	m.appendChange(IncrementAttribute, PartialAttribute{Type: attrType, Vals: []string{strconv.FormatInt(delta, 10)}})
+}
+
+// AddBinary appends the given attribute with binary values to the list of
+// changes to be made
+func (m *ModifyRequest) AddBinary(attrType string, attrVals [][]byte) {
+	m.appendChange(AddAttribute, PartialAttribute{Type: attrType, ByteVals: attrVals})
+}
+
+// DeleteBinary appends the given attribute with binary values to the list of
+// changes to be made
+func (m *ModifyRequest) DeleteBinary(attrType string, attrVals [][]byte) {
+	m.appendChange(DeleteAttribute, PartialAttribute{Type: attrType, ByteVals: attrVals})
+}
+
+// ReplaceBinary appends the given attribute with binary values to the list of
+// changes to be made
+func (m *ModifyRequest) ReplaceBinary(attrType string, attrVals [][]byte) {
+	m.appendChange(ReplaceAttribute, PartialAttribute{Type: attrType, ByteVals: attrVals})
 }
 
 func (m ModifyRequest) encode() *asn1.Packet {
 	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationModifyRequest, nil, "Modify Request")
 	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, m.DN, "DN"))
 	changes := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Changes")
-	for _, attribute := range m.AddAttributes {
-		change := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Change")
-		change.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, uint64(AddAttribute), "Operation"))
-		change.AppendChild(attribute.encode())
-		changes.AppendChild(change)
-	}
-	for _, attribute := range m.DeleteAttributes {
-		change := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Change")
-		change.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, uint64(DeleteAttribute), "Operation"))
-		change.AppendChild(attribute.encode())
-		changes.AppendChild(change)
-	}
-	for _, attribute := range m.ReplaceAttributes {
-		change := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Change")
-		change.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, uint64(ReplaceAttribute), "Operation"))
-		change.AppendChild(attribute.encode())
-		changes.AppendChild(change)
+	for _, change := range m.Changes {
+		changes.AppendChild(change.encode())
 	}
 	request.AppendChild(changes)
 	return request
 }
 
+func (m ModifyRequest) encodeBuffer(b *berBuffer) {
+	b.begin(berApplication(ApplicationModifyRequest))
+	b.appendString(berOctetString, m.DN)
+	b.begin(berSequence)
+	for _, change := range m.Changes {
+		change.encodeBuffer(b)
+	}
+	b.end()
+	b.end()
+}
+
+func (m ModifyRequest) controls() []Control {
+	return m.Controls
+}
+
+func (m ModifyRequest) responseTag() asn1.Tag {
+	return ApplicationModifyResponse
+}
+
+// decodeModifyRequest decodes the protocol operation of a modify request
+// received by a Server
+func decodeModifyRequest(packet *asn1.Packet) *ModifyRequest {
+	modifyRequest := &ModifyRequest{DN: decodeOctetString(packet.Children[0])}
+	for _, child := range packet.Children[1].Children {
+		attributeType, vals := decodeAttribute(child.Children[1])
+		modifyRequest.appendChange(uint(child.Children[0].Value.(int64)), PartialAttribute{Type: attributeType, Vals: vals})
+	}
+	return modifyRequest
+}
+
 // NewModifyRequest creates a modify request for the given DN
 func NewModifyRequest(
 	dn string,
+	controls ...Control,
 ) *ModifyRequest {
 	return &ModifyRequest{
-		DN: dn,
+		DN:       dn,
+		Controls: controls,
 	}
 }
 
 // Modify performs the ModifyRequest
 func (l *Conn) Modify(modifyRequest *ModifyRequest) error {
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
-	packet.AppendChild(modifyRequest.encode())
-
-	l.Debug.PrintPacket(packet)
-
-	msgCtx, err := l.sendMessage(packet)
-	if err != nil {
-		return err
-	}
-	defer l.finishMessage(msgCtx)
+	return l.ModifyContext(context.Background(), modifyRequest)
+}
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
-	if err != nil {
-		return err
-	}
+// ModifyContext performs the ModifyRequest. If ctx is done before the server
+// responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) ModifyContext(ctx context.Context, modifyRequest *ModifyRequest) error {
+	_, err := l.ModifyWithResultContext(ctx, modifyRequest)
+	return err
+}
 
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return err
-		}
-		asn1.PrintPacket(packet)
-	}
+// ModifyWithResult performs the ModifyRequest and returns the controls sent back by the
+// server, such as the entry requested with a pre-read or post-read control.
+func (l *Conn) ModifyWithResult(modifyRequest *ModifyRequest) (*UpdateResult, error) {
+	return l.ModifyWithResultContext(context.Background(), modifyRequest)
+}
 
-	if packet.Children[1].Tag == ApplicationModifyResponse {
-		resultCode, resultDescription := getLDAPResultCode(packet)
-		if resultCode != 0 {
-			return NewError(resultCode, errors.New(resultDescription))
+// ModifyWithResultContext is like ModifyWithResult, but abandons the request and
+// returns ctx.Err() if ctx is done before the server responds.
+func (l *Conn) ModifyWithResultContext(ctx context.Context, modifyRequest *ModifyRequest) (*UpdateResult, error) {
+	if schema := l.loadSchema(); schema != nil {
+		if err := schema.ValidateModifyRequest(modifyRequest); err != nil {
+			return nil, err
 		}
-	} else {
-		log.Printf("Unexpected Response: %d", packet.Children[1].Tag)
 	}
 
-	l.Debug.Printf("%d: returning", msgCtx.id)
-	return nil
+	return newUpdateResult(l.DoContext(ctx, modifyRequest))
 }
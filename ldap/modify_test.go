@@ -0,0 +1,75 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestModifyRequestEncode(t *testing.T) {
+	modifyRequest := NewModifyRequest("cn=x,dc=example,dc=com")
+	modifyRequest.Delete("mail", []string{"old@example.com"})
+	modifyRequest.Add("mail", []string{"new@example.com"})
+	modifyRequest.Replace("sn", []string{"Doe"})
+	modifyRequest.Increment("uidNumber", -2)
+	modifyRequest.Delete("description", nil)
+
+	changes := modifyRequest.encode().Children[1].Children
+	var operations []uint64
+	var types []string
+	for _, change := range changes {
+		operations = append(operations, change.Children[0].Value.(uint64))
+		types = append(types, change.Children[1].Children[0].Value.(string))
+	}
+	if expected := []uint64{DeleteAttribute, AddAttribute, ReplaceAttribute, IncrementAttribute, DeleteAttribute}; !reflect.DeepEqual(operations, expected) {
+		t.Errorf("got operations %v, expected %v", operations, expected)
+	}
+	if expected := []string{"mail", "mail", "sn", "uidNumber", "description"}; !reflect.DeepEqual(types, expected) {
+		t.Errorf("got attributes %v, expected %v", types, expected)
+	}
+	if delta := changes[3].Children[1].Children[1].Children[0].Value.(string); delta != "-2" {
+		t.Errorf("got delta %q", delta)
+	}
+}
+
+func TestModifyWithResultPreRead(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		if len(p.Children) != 3 {
+			t.Errorf("expected request to carry controls, got %d children", len(p.Children))
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationModifyResponse, LDAPResultProtocolError, "")}
+		}
+		requested, ok := DecodeControl(p.Children[2].Children[0]).(*ControlPreRead)
+		if !ok || len(requested.Attributes) != 1 || requested.Attributes[0] != "uidNumber" {
+			t.Errorf("unexpected request control %s", DecodeControl(p.Children[2].Children[0]))
+		}
+		response := newResultPacket(p.Children[0].Value.(int64), ApplicationModifyResponse, LDAPResultSuccess, "")
+		response.AppendChild(encodeControls([]Control{&ControlPreRead{Entry: &Entry{
+			DN:         "cn=uidNext,dc=example,dc=com",
+			Attributes: []*EntryAttribute{{Name: "uidNumber", Values: []string{"1000"}}},
+		}}}))
+		return []*asn1.Packet{response}
+	})
+
+	modifyRequest := NewModifyRequest("cn=uidNext,dc=example,dc=com", NewControlPreRead("uidNumber"))
+	modifyRequest.Increment("uidNumber", 1)
+	result, err := conn.ModifyWithResult(modifyRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	preRead, ok := FindControl(result.Controls, ControlTypePreRead).(*ControlPreRead)
+	if !ok || preRead.Entry == nil {
+		t.Fatalf("expected pre-read entry in result controls, got %v", result.Controls)
+	}
+	if uidNumber := preRead.Entry.GetAttributeValue("uidNumber"); uidNumber != "1000" {
+		t.Errorf("unexpected uidNumber %q", uidNumber)
+	}
+}
@@ -0,0 +1,92 @@
+// File contains the connection to servers authenticating with a client
+// certificate
+
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// MutualTLSOptions are the options of DialMutualTLS
+type MutualTLSOptions struct {
+	// CertFile and KeyFile are the PEM files of the client certificate and
+	// of its private key
+	CertFile string
+	KeyFile  string
+	// CAFile is the PEM file of the certificates of the authorities verifying
+	// the certificate of the server, the system roots being used if empty
+	CAFile string
+	// ExternalBind performs an EXTERNAL SASL bind once connected,
+	// authenticating with the client certificate
+	ExternalBind bool
+	// AuthzID is the authorization identity of the EXTERNAL bind, derived
+	// from the certificate by the server if empty
+	AuthzID string
+}
+
+// LoadClientTLSConfig returns a TLS configuration presenting the client
+// certificate of the PEM files, and verifying the certificate of the server
+// with the authorities of the PEM file caFile, or the system roots if it is
+// empty
+func LoadClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: cannot load the client certificate: %s", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}}
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: cannot load the authorities: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("ldap: no certificate in %s", caFile)
+		}
+	}
+	return config, nil
+}
+
+// DialMutualTLS connects to the server of the LDAP URL authenticating with
+// the client certificate of the options, directly for an ldaps URL, or with
+// StartTLS for an ldap URL, and then performs an EXTERNAL bind if requested.
+// The options set by DialWithTLSConfig are replaced by those of the
+// certificates.
+func DialMutualTLS(rawURL string, options MutualTLSOptions, opts ...DialOpt) (*Conn, error) {
+	u, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, fmt.Errorf("ldap: cannot use TLS with an %s URL", u.Scheme)
+	}
+	config, err := LoadClientTLSConfig(options.CertFile, options.KeyFile, options.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	config.ServerName, _, _ = net.SplitHostPort(u.Address())
+
+	dc := newDialConfig(opts)
+	dc.tlsConfig = config
+	conn, err := dc.dial(u)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "ldap" {
+		if err := conn.StartTLS(config); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if options.ExternalBind {
+		if _, err := conn.ExternalBindRequest(&ExternalBindRequest{AuthzID: options.AuthzID}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
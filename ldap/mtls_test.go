@@ -0,0 +1,167 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// testCertificate is a certificate and its key issued for the tests
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// issueTestCertificate issues a certificate from the template, self-signed if
+// issuer is nil
+func issueTestCertificate(t *testing.T, template *x509.Certificate, issuer *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{certificate: certificate, key: key}
+}
+
+// writePEM writes the PEM files of the certificate and its key
+func (c *testCertificate) writePEM(t *testing.T, certFile, keyFile string) {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.certificate.Raw})
+	if err := ioutil.WriteFile(certFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(keyFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ldap.example.com"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "jdoe"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	options := MutualTLSOptions{
+		CertFile:     filepath.Join(dir, "client.pem"),
+		KeyFile:      filepath.Join(dir, "client.key"),
+		CAFile:       filepath.Join(dir, "ca.pem"),
+		ExternalBind: true,
+	}
+	client.writePEM(t, options.CertFile, options.KeyFile)
+	ca.writePEM(t, options.CAFile, "")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.certificate.Raw}, PrivateKey: server.key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+	}
+
+	for _, scheme := range []string{"ldaps", "ldap"} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		peers := make(chan string, 1)
+		go func(startTLS bool) {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			if startTLS {
+				request, err := asn1.ReadPacket(c)
+				if err != nil {
+					return
+				}
+				c.Write(newResultPacket(request.Children[0].Value.(int64), ApplicationExtendedResponse, LDAPResultSuccess, "").Bytes())
+			}
+			tc := tls.Server(c, serverConfig)
+			if err := tc.Handshake(); err != nil {
+				peers <- err.Error()
+				return
+			}
+			request, err := asn1.ReadPacket(tc)
+			if err != nil {
+				return
+			}
+			mechanism := string(request.Children[1].Children[2].Children[0].Data.Bytes())
+			peers <- mechanism + " " + tc.ConnectionState().PeerCertificates[0].Subject.CommonName
+			tc.Write(newResultPacket(request.Children[0].Value.(int64), ApplicationBindResponse, LDAPResultSuccess, "").Bytes())
+		}(scheme == "ldap")
+
+		conn, err := DialMutualTLS(scheme+"://"+listener.Addr().String(), options)
+		if err != nil {
+			t.Fatalf("%s: %s", scheme, err)
+		}
+		if !conn.isTLS {
+			t.Errorf("%s: expected a TLS connection", scheme)
+		}
+		conn.Close()
+		if peer := <-peers; peer != "EXTERNAL jdoe" {
+			t.Errorf("%s: expected an EXTERNAL bind with the client certificate, got %q", scheme, peer)
+		}
+	}
+
+	if _, err := DialMutualTLS("ldapi://%2Ftmp%2Fldapi", options); err == nil {
+		t.Error("expected an error for an ldapi URL")
+	}
+	options.KeyFile = filepath.Join(dir, "missing.key")
+	if _, err := DialMutualTLS("ldaps://127.0.0.1:1", options); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
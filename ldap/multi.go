@@ -0,0 +1,155 @@
+// File contains multi-server failover dialing
+
+package ldap
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFailoverCooldown is how long a MultiDialer skips a server after
+// failing to connect to it
+const DefaultFailoverCooldown = 30 * time.Second
+
+// DialInRandomOrder makes DialMulti try the servers in random order, spreading
+// the connections of many clients over the servers
+func DialInRandomOrder() DialOpt {
+	return func(dc *dialConfig) {
+		dc.randomOrder = true
+	}
+}
+
+// DialMulti connects to the first of the given servers accepting the
+// connection and returns a new Conn for it. Servers are LDAP URLs, as accepted
+// by DialURL, or host:port addresses of ldap servers. Use a MultiDialer to
+// skip the servers which recently failed when reconnecting.
+func DialMulti(servers []string, opts ...DialOpt) (*Conn, error) {
+	m := &MultiDialer{
+		Servers: servers,
+		Options: opts,
+	}
+	return m.Dial()
+}
+
+// MultiDialer connects to one of several equivalent servers, remembering
+// the servers it failed to connect to. It is safe for concurrent use.
+type MultiDialer struct {
+	// Servers are the LDAP URLs or host:port addresses of the servers, tried
+	// in order unless the DialInRandomOrder option is given
+	Servers []string
+	// Options are the options used to dial each server
+	Options []DialOpt
+	// Cooldown is how long to skip a server after failing to connect to it,
+	// DefaultFailoverCooldown if zero
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	failures map[string]time.Time
+	// now replaces time.Now in tests
+	now func() time.Time
+}
+
+// Dial connects to the first server accepting the connection, skipping the
+// servers which failed within the cooldown period. If all servers are cooling
+// down, they are all tried rather than failing right away.
+func (m *MultiDialer) Dial() (*Conn, error) {
+	if len(m.Servers) == 0 {
+		return nil, NewError(ErrorNetwork, errors.New("ldap: no servers to dial"))
+	}
+	dc := newDialConfig(m.Options)
+
+	servers := make([]string, len(m.Servers))
+	copy(servers, m.Servers)
+	if dc.randomOrder {
+		for i := len(servers) - 1; i > 0; i-- {
+			j := rand.Intn(i + 1)
+			servers[i], servers[j] = servers[j], servers[i]
+		}
+	}
+
+	// healthy servers first, then the ones cooling down in their order
+	healthy := make([]string, 0, len(servers))
+	var cooling []string
+	for _, server := range servers {
+		if m.coolingDown(server) {
+			cooling = append(cooling, server)
+		} else {
+			healthy = append(healthy, server)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = cooling
+	}
+
+	var err error
+	for _, server := range healthy {
+		var u *URL
+		u, err = parseServer(server)
+		if err != nil {
+			return nil, err
+		}
+		var conn *Conn
+		conn, err = dc.dial(u)
+		if err == nil {
+			m.markHealthy(server)
+			return conn, nil
+		}
+		m.MarkFailed(server)
+	}
+	return nil, err
+}
+
+// MarkFailed makes the dialer skip the server for the cooldown period, e.g.
+// after the connection to it was lost
+func (m *MultiDialer) MarkFailed(server string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures == nil {
+		m.failures = make(map[string]time.Time)
+	}
+	m.failures[server] = m.timeNow()
+}
+
+// markHealthy forgets the failure of the server
+func (m *MultiDialer) markHealthy(server string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.failures, server)
+}
+
+// coolingDown reports whether the server failed within the cooldown period
+func (m *MultiDialer) coolingDown(server string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failed, ok := m.failures[server]
+	if !ok {
+		return false
+	}
+	cooldown := m.Cooldown
+	if cooldown == 0 {
+		cooldown = DefaultFailoverCooldown
+	}
+	if m.timeNow().Sub(failed) >= cooldown {
+		delete(m.failures, server)
+		return false
+	}
+	return true
+}
+
+func (m *MultiDialer) timeNow() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+// parseServer returns the URL of a server given as LDAP URL or host:port address
+func parseServer(server string) (*URL, error) {
+	if strings.Contains(server, "://") {
+		return ParseURL(server)
+	}
+	return &URL{Scheme: "ldap", Host: server}, nil
+}
@@ -0,0 +1,104 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// newMultiTestServers returns the address of a port nothing listens on and a
+// listener accepting and closing connections
+func newMultiTestServers(t *testing.T) (string, net.Listener) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	return closedAddr, ln
+}
+
+func TestDialMulti(t *testing.T) {
+	closedAddr, ln := newMultiTestServers(t)
+	defer ln.Close()
+
+	conn, err := DialMulti([]string{closedAddr, "ldap://" + ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if remote := conn.conn.RemoteAddr().String(); remote != ln.Addr().String() {
+		t.Errorf("connected to %s, expected %s", remote, ln.Addr())
+	}
+
+	conn, err = DialMulti([]string{closedAddr, ln.Addr().String()}, DialInRandomOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if _, err := DialMulti([]string{closedAddr}); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected network error, got %v", err)
+	}
+	if _, err := DialMulti(nil); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected network error, got %v", err)
+	}
+	if _, err := DialMulti([]string{"http://" + ln.Addr().String()}); err == nil {
+		t.Error("expected error for an unsupported scheme")
+	}
+}
+
+func TestMultiDialerCooldown(t *testing.T) {
+	closedAddr, ln := newMultiTestServers(t)
+	defer ln.Close()
+	openAddr := ln.Addr().String()
+
+	now := time.Now()
+	m := &MultiDialer{
+		Servers:  []string{closedAddr, openAddr},
+		Cooldown: time.Minute,
+		now:      func() time.Time { return now },
+	}
+
+	conn, err := m.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if !m.coolingDown(closedAddr) {
+		t.Errorf("expected %s to cool down after failing", closedAddr)
+	}
+	if m.coolingDown(openAddr) {
+		t.Errorf("expected %s to be healthy", openAddr)
+	}
+
+	// servers cooling down are still tried when no other is left
+	m.MarkFailed(openAddr)
+	conn, err = m.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if m.coolingDown(openAddr) {
+		t.Errorf("expected %s to be healthy after connecting", openAddr)
+	}
+
+	now = now.Add(time.Minute)
+	if m.coolingDown(closedAddr) {
+		t.Errorf("expected the cooldown of %s to be over", closedAddr)
+	}
+}
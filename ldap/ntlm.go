@@ -0,0 +1,373 @@
+// File contains the NTLM bind functionality
+//
+// Active Directory accepts NTLM authentication through the Sicily bind
+// sequence, which uses the following authentication choices:
+//
+// https://msdn.microsoft.com/en-us/library/cc223499.aspx
+//
+// AuthenticationChoice ::= CHOICE {
+//      simple                  [0] OCTET STRING,
+//      sasl                    [3] SaslCredentials,
+//      sicilyPackageDiscovery  [9] OCTET STRING,
+//      sicilyNegotiate         [10] OCTET STRING,
+//      sicilyResponse          [11] OCTET STRING }
+//
+// The NTLM messages are described in https://msdn.microsoft.com/en-us/library/cc236621.aspx
+
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	enchex "encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// NTLM negotiate flags
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+
+	ntlmDefaultFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateExtendedSessionSecurity | ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+// ntlmSignature starts every NTLM message
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmAvTimestamp is the AV_PAIR identifier of the server time in the target information
+const ntlmAvTimestamp = 7
+
+// NTLMBindRequest represents an NTLM bind
+type NTLMBindRequest struct {
+	// Domain is the NetBIOS domain name of the user
+	Domain string
+	// Username is the account name of the user
+	Username string
+	// Password is the password of the user. It is ignored if Hash is set.
+	Password string
+	// Hash is the hex encoded NT hash of the password of the user
+	Hash string
+	// Controls are optional controls to send with the bind request
+	Controls []Control
+}
+
+// NTLMBindResult contains the response from the server
+type NTLMBindResult struct {
+	Controls []Control
+}
+
+// NTLMBind performs an NTLM bind with the given domain, username and password
+func (l *Conn) NTLMBind(domain, username, password string) error {
+	req := &NTLMBindRequest{
+		Domain:   domain,
+		Username: username,
+		Password: password,
+	}
+	_, err := l.NTLMBindRequest(req)
+	return err
+}
+
+// NTLMBindWithHash performs an NTLM bind with the given domain, username and
+// hex encoded NT hash of the password
+func (l *Conn) NTLMBindWithHash(domain, username, hash string) error {
+	req := &NTLMBindRequest{
+		Domain:   domain,
+		Username: username,
+		Hash:     hash,
+	}
+	_, err := l.NTLMBindRequest(req)
+	return err
+}
+
+// NTLMBindRequest performs the NTLM bind defined in the given request
+func (l *Conn) NTLMBindRequest(ntlmBindRequest *NTLMBindRequest) (*NTLMBindResult, error) {
+	return l.NTLMBindRequestContext(context.Background(), ntlmBindRequest)
+}
+
+// NTLMBindRequestContext performs the NTLM bind defined in the given request,
+// using the Sicily package discovery, negotiate and response sequence. If ctx
+// is done before the server responds, the connection is closed and ctx.Err()
+// is returned.
+func (l *Conn) NTLMBindRequestContext(ctx context.Context, ntlmBindRequest *NTLMBindRequest) (*NTLMBindResult, error) {
+	var hash []byte
+	if ntlmBindRequest.Hash != "" {
+		var err error
+		hash, err = enchex.DecodeString(ntlmBindRequest.Hash)
+		if err != nil || len(hash) != 16 {
+			return nil, fmt.Errorf("ldap: invalid NT hash %q", ntlmBindRequest.Hash)
+		}
+	} else if ntlmBindRequest.Password != "" {
+		hash = ntlmHash(ntlmBindRequest.Password)
+	} else {
+		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
+	}
+
+	// sicilyPackageDiscovery: the server lists the supported packages in the matched DN
+	response, err := l.bindRound(ctx, asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 9, "", "Sicily Package Discovery"), ntlmBindRequest.Controls)
+	if err != nil {
+		return nil, err
+	}
+	if response.resultCode != LDAPResultSuccess {
+		return &NTLMBindResult{Controls: response.controls}, response.err
+	}
+	if !containsPackage(string(response.matchedDN), "NTLM") {
+		return &NTLMBindResult{Controls: response.controls}, NewError(LDAPResultAuthMethodNotSupported, fmt.Errorf("ldap: server does not support NTLM, packages: %q", response.matchedDN))
+	}
+
+	// sicilyNegotiate: the server returns the challenge message in the matched DN
+	response, err = l.bindRound(ctx, encodeSicilyMessage(10, ntlmNegotiateMessage(), "Sicily Negotiate"), ntlmBindRequest.Controls)
+	if err != nil {
+		return nil, err
+	}
+	if response.resultCode != LDAPResultSuccess {
+		return &NTLMBindResult{Controls: response.controls}, response.err
+	}
+	challenge, err := parseNTLMChallengeMessage(response.matchedDN)
+	if err != nil {
+		return &NTLMBindResult{Controls: response.controls}, err
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	authenticate := ntlmAuthenticateMessage(challenge, ntlmBindRequest.Domain, ntlmBindRequest.Username, hash, clientChallenge, time.Now())
+
+	// sicilyResponse
+	response, err = l.bindRound(ctx, encodeSicilyMessage(11, authenticate, "Sicily Response"), ntlmBindRequest.Controls)
+	if err != nil {
+		return nil, err
+	}
+	result := &NTLMBindResult{Controls: response.controls}
+	if response.resultCode != LDAPResultSuccess {
+		return result, response.err
+	}
+	return result, nil
+}
+
+// encodeSicilyMessage returns the Sicily authentication choice with the given tag holding an NTLM message
+func encodeSicilyMessage(tag asn1.Tag, message []byte, description string) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, tag, nil, description)
+	packet.Value = message
+	packet.Data.Write(message)
+	return packet
+}
+
+// containsPackage reports whether the semicolon separated list of packages contains the package
+func containsPackage(packages, name string) bool {
+	for _, p := range strings.Split(packages, ";") {
+		if strings.EqualFold(strings.TrimSpace(p), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ntlmChallenge holds the parts of an NTLM challenge message needed to authenticate
+type ntlmChallenge struct {
+	flags           uint32
+	serverChallenge []byte
+	targetInfo      []byte
+}
+
+// ntlmNegotiateMessage returns an NTLM negotiate message without domain and workstation
+func ntlmNegotiateMessage() []byte {
+	message := make([]byte, 32)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 1)
+	binary.LittleEndian.PutUint32(message[12:], ntlmDefaultFlags)
+	return message
+}
+
+// parseNTLMChallengeMessage parses an NTLM challenge message
+func parseNTLMChallengeMessage(message []byte) (*ntlmChallenge, error) {
+	if len(message) < 48 || !bytes.Equal(message[:8], ntlmSignature) || binary.LittleEndian.Uint32(message[8:]) != 2 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid NTLM challenge message"))
+	}
+	challenge := &ntlmChallenge{
+		flags:           binary.LittleEndian.Uint32(message[20:]),
+		serverChallenge: message[24:32],
+	}
+	length := int(binary.LittleEndian.Uint16(message[40:]))
+	offset := int(binary.LittleEndian.Uint32(message[44:]))
+	if offset+length > len(message) {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid NTLM target information"))
+	}
+	challenge.targetInfo = message[offset : offset+length]
+	return challenge, nil
+}
+
+// ntlmAuthenticateMessage returns the NTLMv2 authenticate message answering the challenge
+func ntlmAuthenticateMessage(challenge *ntlmChallenge, domain, username string, hash, clientChallenge []byte, now time.Time) []byte {
+	timestamp := ntlmTimestamp(challenge.targetInfo, now)
+	lmResponse, ntResponse := ntlmV2Responses(hash, domain, username, challenge.serverChallenge, clientChallenge, timestamp, challenge.targetInfo)
+
+	payloads := [][]byte{
+		lmResponse,
+		ntResponse,
+		encodeUTF16(domain),
+		encodeUTF16(username),
+		nil, // workstation
+		nil, // encrypted random session key
+	}
+	message := make([]byte, 64)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 3)
+	offset := len(message)
+	for i, payload := range payloads {
+		binary.LittleEndian.PutUint16(message[12+8*i:], uint16(len(payload)))
+		binary.LittleEndian.PutUint16(message[14+8*i:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(message[16+8*i:], uint32(offset))
+		offset += len(payload)
+	}
+	binary.LittleEndian.PutUint32(message[60:], challenge.flags&ntlmDefaultFlags|ntlmNegotiateUnicode)
+	for _, payload := range payloads {
+		message = append(message, payload...)
+	}
+	return message
+}
+
+// ntlmTimestamp returns the server time from the target information if
+// present, or now, in Windows FILETIME format
+func ntlmTimestamp(targetInfo []byte, now time.Time) []byte {
+	for i := 0; i+4 <= len(targetInfo); {
+		id := binary.LittleEndian.Uint16(targetInfo[i:])
+		length := int(binary.LittleEndian.Uint16(targetInfo[i+2:]))
+		i += 4
+		if i+length > len(targetInfo) {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return targetInfo[i : i+8]
+		}
+		i += length
+	}
+
+	timestamp := make([]byte, 8)
+	binary.LittleEndian.PutUint64(timestamp, uint64(TimeToFileTime(now)))
+	return timestamp
+}
+
+// ntlmV2Responses returns the LMv2 and NTLMv2 challenge responses, as described
+// in https://msdn.microsoft.com/en-us/library/cc236700.aspx
+func ntlmV2Responses(hash []byte, domain, username string, serverChallenge, clientChallenge, timestamp, targetInfo []byte) ([]byte, []byte) {
+	responseKey := ntlmHMAC(hash, encodeUTF16(strings.ToUpper(username)+domain))
+
+	lmResponse := append(ntlmHMAC(responseKey, serverChallenge, clientChallenge), clientChallenge...)
+
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+	ntResponse := append(ntlmHMAC(responseKey, serverChallenge, temp), temp...)
+
+	return lmResponse, ntResponse
+}
+
+// ntlmHMAC returns the HMAC-MD5 of the concatenated data
+func ntlmHMAC(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// ntlmHash returns the NT hash of the password
+func ntlmHash(password string) []byte {
+	return md4Sum(encodeUTF16(password))
+}
+
+// encodeUTF16 returns the UTF-16LE encoding of s
+func encodeUTF16(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(codes))
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+// md4Sum returns the MD4 digest of data, as described in
+// https://tools.ietf.org/html/rfc1320. MD4 is only used to compute NT hashes.
+func md4Sum(data []byte) []byte {
+	length := uint64(len(data)) * 8
+	message := append(append([]byte{}, data...), 0x80)
+	for len(message)%64 != 56 {
+		message = append(message, 0)
+	}
+	message = append(message, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(message[len(message)-8:], length)
+
+	rounds := []struct {
+		order  [16]int
+		shifts [4]uint
+		add    uint32
+		f      func(b, c, d uint32) uint32
+	}{
+		{
+			[16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+			[4]uint{3, 7, 11, 19},
+			0,
+			func(b, c, d uint32) uint32 { return b&c | ^b&d },
+		},
+		{
+			[16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15},
+			[4]uint{3, 5, 9, 13},
+			0x5a827999,
+			func(b, c, d uint32) uint32 { return b&c | b&d | c&d },
+		},
+		{
+			[16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15},
+			[4]uint{3, 9, 11, 15},
+			0x6ed9eba1,
+			func(b, c, d uint32) uint32 { return b ^ c ^ d },
+		},
+	}
+
+	h := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	var x [16]uint32
+	for block := 0; block < len(message); block += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(message[block+4*i:])
+		}
+		a, b, c, d := h[0], h[1], h[2], h[3]
+		for _, round := range rounds {
+			for i, k := range round.order {
+				s := round.shifts[i%4]
+				a += round.f(b, c, d) + x[k] + round.add
+				a = a<<s | a>>(32-s)
+				a, b, c, d = d, a, b, c
+			}
+		}
+		h[0] += a
+		h[1] += b
+		h[2] += c
+		h[3] += d
+	}
+
+	sum := make([]byte, 16)
+	for i, v := range h {
+		binary.LittleEndian.PutUint32(sum[4*i:], v)
+	}
+	return sum
+}
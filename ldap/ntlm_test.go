@@ -0,0 +1,149 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	enchex "encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestMD4(t *testing.T) {
+	// https://tools.ietf.org/html/rfc1320#appendix-A.5
+	tests := map[string]string{
+		"":    "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc": "a448017aaf21d8525fc10ae87aa6729d",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+	for data, expected := range tests {
+		if sum := enchex.EncodeToString(md4Sum([]byte(data))); sum != expected {
+			t.Errorf("MD4(%q) = %s, expected %s", data, sum, expected)
+		}
+	}
+}
+
+// ntlmTargetInfo is the target information of the examples of
+// https://msdn.microsoft.com/en-us/library/cc236621.aspx
+var ntlmTargetInfo = []byte{
+	0x02, 0x00, 0x0c, 0x00, 'D', 0, 'o', 0, 'm', 0, 'a', 0, 'i', 0, 'n', 0,
+	0x01, 0x00, 0x0c, 0x00, 'S', 0, 'e', 0, 'r', 0, 'v', 0, 'e', 0, 'r', 0,
+	0x00, 0x00, 0x00, 0x00,
+}
+
+func TestNTLMv2Responses(t *testing.T) {
+	hash := ntlmHash("Password")
+	if enchex.EncodeToString(hash) != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("unexpected NT hash %x", hash)
+	}
+
+	serverChallenge := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	lmResponse, ntResponse := ntlmV2Responses(hash, "Domain", "User", serverChallenge, clientChallenge, make([]byte, 8), ntlmTargetInfo)
+	if enchex.EncodeToString(lmResponse) != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("unexpected LMv2 response %x", lmResponse)
+	}
+	if enchex.EncodeToString(ntResponse[:16]) != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("unexpected NTLMv2 proof %x", ntResponse[:16])
+	}
+}
+
+// newNTLMChallengeMessage returns an NTLM challenge message with the given server challenge
+func newNTLMChallengeMessage(serverChallenge []byte) []byte {
+	message := make([]byte, 48)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 2)
+	binary.LittleEndian.PutUint32(message[20:], ntlmDefaultFlags)
+	copy(message[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(message[40:], uint16(len(ntlmTargetInfo)))
+	binary.LittleEndian.PutUint16(message[42:], uint16(len(ntlmTargetInfo)))
+	binary.LittleEndian.PutUint32(message[44:], 48)
+	return append(message, ntlmTargetInfo...)
+}
+
+// newSicilyResponsePacket returns a successful bind response with the given matched DN
+func newSicilyResponsePacket(messageID int64, matchedDN []byte) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+	response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, LDAPResultSuccess, "Result Code"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(matchedDN), "Matched DN"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Diagnostic Message"))
+	packet.AppendChild(response)
+	return packet
+}
+
+func TestNTLMBind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	serverChallenge := []byte("12345678")
+	expect := func(tag asn1.Tag, check func(message []byte), response func(messageID int64) *asn1.Packet) {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			auth := p.Children[1].Children[2]
+			if auth.ClassType != asn1.ClassContext || auth.Tag != tag {
+				t.Errorf("expected authentication choice %d, got %d", tag, auth.Tag)
+			}
+			check(auth.Data.Bytes())
+			return []*asn1.Packet{response(p.Children[0].Value.(int64))}
+		})
+	}
+	go func() {
+		expect(9, func([]byte) {}, func(messageID int64) *asn1.Packet {
+			return newSicilyResponsePacket(messageID, []byte("GSS-SPNEGO;GSSAPI;NTLM"))
+		})
+		expect(10, func(message []byte) {
+			if !bytes.HasPrefix(message, ntlmSignature) || message[8] != 1 {
+				t.Errorf("expected NTLM negotiate message, got %x", message)
+			}
+		}, func(messageID int64) *asn1.Packet {
+			return newSicilyResponsePacket(messageID, newNTLMChallengeMessage(serverChallenge))
+		})
+		expect(11, func(message []byte) {
+			if !bytes.HasPrefix(message, ntlmSignature) || message[8] != 3 {
+				t.Errorf("expected NTLM authenticate message, got %x", message)
+				return
+			}
+			length := binary.LittleEndian.Uint16(message[20:])
+			offset := binary.LittleEndian.Uint32(message[24:])
+			ntResponse := message[offset : offset+uint32(length)]
+			hash := ntlmHash("Password")
+			responseKey := ntlmHMAC(hash, encodeUTF16("USERDomain"))
+			if !bytes.Equal(ntResponse[:16], ntlmHMAC(responseKey, serverChallenge, ntResponse[16:])) {
+				t.Errorf("invalid NTLMv2 response")
+			}
+		}, func(messageID int64) *asn1.Packet {
+			return newBindResponsePacket(messageID, LDAPResultSuccess, "")
+		})
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.NTLMBindWithHash("Domain", "User", "a4f49c406510bdcab6824ee7c30fd852"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestNTLMBindNotSupported(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newSicilyResponsePacket(p.Children[0].Value.(int64), []byte("GSSAPI"))}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.NTLMBind("Domain", "User", "Password"); !IsErrorWithCode(err, LDAPResultAuthMethodNotSupported) {
+			t.Errorf("expected auth method not supported error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+// File contains the selection of operational attributes
+
+package ldap
+
+import (
+	"time"
+)
+
+// Special attribute selectors of search requests, see
+// https://tools.ietf.org/html/rfc4511#section-4.5.1.8
+const (
+	// AllUserAttributes selects all the user attributes, the default when no
+	// attribute is requested
+	AllUserAttributes = "*"
+	// AllOperationalAttributes selects all the operational attributes, as
+	// defined by https://tools.ietf.org/html/rfc3673
+	AllOperationalAttributes = "+"
+	// NoAttributes selects no attribute, when requested alone
+	NoAttributes = "1.1"
+)
+
+// Common operational attributes, which are only returned when requested by
+// name or with AllOperationalAttributes
+const (
+	AttributeEntryUUID         = "entryUUID"
+	AttributeEntryDN           = "entryDN"
+	AttributeEntryCSN          = "entryCSN"
+	AttributeCreateTimestamp   = "createTimestamp"
+	AttributeModifyTimestamp   = "modifyTimestamp"
+	AttributeCreatorsName      = "creatorsName"
+	AttributeModifiersName     = "modifiersName"
+	AttributeHasSubordinates   = "hasSubordinates"
+	AttributeSubschemaSubentry = "subschemaSubentry"
+)
+
+// UserAndOperationalAttributes returns the attributes selecting all the user
+// and operational attributes
+func UserAndOperationalAttributes() []string {
+	return []string{AllUserAttributes, AllOperationalAttributes}
+}
+
+// SetOperationalAttributes sets operational attributes, such as
+// AttributeEntryUUID and AttributeModifyTimestamp, requested by every search
+// in addition to the attributes of the request. A request without attributes
+// then requests AllUserAttributes as well, so that it still returns the user
+// attributes. Calling it without attributes stops adding them.
+func (l *Conn) SetOperationalAttributes(attributes ...string) {
+	l.extraAttributes.Store(append([]string(nil), attributes...))
+}
+
+// withOperationalAttributes returns the search request, or a copy of it
+// requesting the attributes set by SetOperationalAttributes as well
+func (l *Conn) withOperationalAttributes(searchRequest *SearchRequest) *SearchRequest {
+	operational, _ := l.extraAttributes.Load().([]string)
+	if len(operational) == 0 || containsFold(searchRequest.Attributes, AllOperationalAttributes) {
+		return searchRequest
+	}
+
+	attributes := append([]string(nil), searchRequest.Attributes...)
+	if len(attributes) == 0 {
+		attributes = append(attributes, AllUserAttributes)
+	}
+	for _, attribute := range operational {
+		if !containsFold(attributes, attribute) {
+			attributes = append(attributes, attribute)
+		}
+	}
+	request := *searchRequest
+	request.Attributes = attributes
+	return &request
+}
+
+// SetOperationalAttributes sets the operational attributes requested by every
+// search of the connection, and of the connections replacing it. See
+// Conn.SetOperationalAttributes.
+func (r *ReconnectingConn) SetOperationalAttributes(attributes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operationalAttributes = append([]string(nil), attributes...)
+	if r.conn != nil {
+		r.conn.SetOperationalAttributes(attributes...)
+	}
+}
+
+// EntryUUID returns the entryUUID operational attribute of the entry, or an
+// empty string if it was not returned
+func (e *Entry) EntryUUID() string {
+	if attr := e.getAttribute(AttributeEntryUUID); attr != nil && len(attr.Values) > 0 {
+		return attr.Values[0]
+	}
+	return ""
+}
+
+// CreateTimestamp returns the createTimestamp operational attribute of the
+// entry, or the zero time if it was not returned
+func (e *Entry) CreateTimestamp() (time.Time, error) {
+	return e.timestamp(AttributeCreateTimestamp)
+}
+
+// ModifyTimestamp returns the modifyTimestamp operational attribute of the
+// entry, or the zero time if it was not returned
+func (e *Entry) ModifyTimestamp() (time.Time, error) {
+	return e.timestamp(AttributeModifyTimestamp)
+}
+
+// timestamp parses the generalized time value of the attribute
+func (e *Entry) timestamp(name string) (time.Time, error) {
+	attr := e.getAttribute(name)
+	if attr == nil || len(attr.Values) == 0 {
+		return time.Time{}, nil
+	}
+	return ParseGeneralizedTime(attr.Values[0])
+}
@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOperationalAttributes(t *testing.T) {
+	conn := &Conn{}
+	request := NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	if got := conn.withOperationalAttributes(request); got != request {
+		t.Errorf("expected the request to be unchanged, got %#v", got)
+	}
+
+	conn.SetOperationalAttributes(AttributeEntryUUID, AttributeModifyTimestamp)
+	for _, test := range []struct {
+		attributes []string
+		expected   []string
+	}{
+		{nil, []string{AllUserAttributes, AttributeEntryUUID, AttributeModifyTimestamp}},
+		{[]string{"cn", "entryuuid"}, []string{"cn", "entryuuid", AttributeModifyTimestamp}},
+		{[]string{AllUserAttributes, AllOperationalAttributes}, []string{AllUserAttributes, AllOperationalAttributes}},
+	} {
+		request.Attributes = test.attributes
+		got := conn.withOperationalAttributes(request)
+		if !reflect.DeepEqual(got.Attributes, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.attributes, test.expected, got.Attributes)
+		}
+		if !reflect.DeepEqual(request.Attributes, test.attributes) {
+			t.Errorf("%q: the request was modified", test.attributes)
+		}
+	}
+
+	conn.SetOperationalAttributes()
+	if got := conn.withOperationalAttributes(request); got != request {
+		t.Errorf("expected the request to be unchanged once reset, got %#v", got)
+	}
+}
+
+func TestEntryOperationalAttributes(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	err := conn.Add(&AddRequest{DN: "uid=bwayne,dc=example,dc=com", Attributes: []Attribute{
+		{Type: "uid", Vals: []string{"bwayne"}},
+		{Type: "entryUUID", Vals: []string{"597ae2f6-16a6-1027-98f4-d28b5365dc14"}},
+		{Type: "modifyTimestamp", Vals: []string{"20200102030405Z"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetOperationalAttributes(AttributeEntryUUID, AttributeModifyTimestamp)
+	result, err := conn.Search(NewSearchRequest("uid=bwayne,dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"uid"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := result.Entries[0]
+	if uuid := entry.EntryUUID(); uuid != "597ae2f6-16a6-1027-98f4-d28b5365dc14" {
+		t.Errorf("unexpected entryUUID %q", uuid)
+	}
+	if modified, err := entry.ModifyTimestamp(); err != nil || !modified.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected modifyTimestamp %s, %v", modified, err)
+	}
+	if created, err := entry.CreateTimestamp(); err != nil || !created.IsZero() {
+		t.Errorf("expected no createTimestamp, got %s, %v", created, err)
+	}
+}
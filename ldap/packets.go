@@ -0,0 +1,11 @@
+//go:build !ldap_packets
+// +build !ldap_packets
+
+package ldap
+
+// packetTrees is whether every message is encoded and decoded as a tree of
+// packets, as it is when built with the ldap_packets tag. Otherwise, the
+// search result entries are decoded from the read buffer, and the add and
+// modify requests are encoded into pooled buffers, unless the debug output
+// or the hooks need the trees.
+const packetTrees = false
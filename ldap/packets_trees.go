@@ -0,0 +1,8 @@
+//go:build ldap_packets
+// +build ldap_packets
+
+package ldap
+
+// packetTrees is whether every message is encoded and decoded as a tree of
+// packets, e.g. to compare the benchmarks with and without the buffers.
+const packetTrees = true
@@ -6,8 +6,7 @@
 package ldap
 
 import (
-	"errors"
-	"fmt"
+	"context"
 
 	"github.com/gostores/encoding/asn1"
 )
@@ -34,7 +33,7 @@ type PasswordModifyResult struct {
 	GeneratedPassword string
 }
 
-func (r *PasswordModifyRequest) encode() (*asn1.Packet, error) {
+func (r *PasswordModifyRequest) encode() *asn1.Packet {
 	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationExtendedRequest, nil, "Password Modify Extended Operation")
 	request.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, passwordModifyOID, "Extended Request Name: Password Modify OID"))
 	extendedRequestValue := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, 1, nil, "Extended Request Value: Password Modify Request")
@@ -52,7 +51,15 @@ func (r *PasswordModifyRequest) encode() (*asn1.Packet, error) {
 	extendedRequestValue.AppendChild(passwordModifyRequestValue)
 	request.AppendChild(extendedRequestValue)
 
-	return request, nil
+	return request
+}
+
+func (r *PasswordModifyRequest) controls() []Control {
+	return nil
+}
+
+func (r *PasswordModifyRequest) responseTag() asn1.Tag {
+	return ApplicationExtendedResponse
 }
 
 // NewPasswordModifyRequest creates a new PasswordModifyRequest
@@ -82,59 +89,24 @@ func NewPasswordModifyRequest(userIdentity string, oldPassword string, newPasswo
 
 // PasswordModify performs the modification request
 func (l *Conn) PasswordModify(passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
-
-	encodedPasswordModifyRequest, err := passwordModifyRequest.encode()
-	if err != nil {
-		return nil, err
-	}
-	packet.AppendChild(encodedPasswordModifyRequest)
-
-	l.Debug.PrintPacket(packet)
+	return l.PasswordModifyContext(context.Background(), passwordModifyRequest)
+}
 
-	msgCtx, err := l.sendMessage(packet)
+// PasswordModifyContext performs the modification request. If ctx is done
+// before the server responds, the request is abandoned and ctx.Err() is
+// returned.
+func (l *Conn) PasswordModifyContext(ctx context.Context, passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
+	response, err := l.DoContext(ctx, passwordModifyRequest)
 	if err != nil {
 		return nil, err
 	}
-	defer l.finishMessage(msgCtx)
 
 	result := &PasswordModifyResult{}
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
-	if err != nil {
-		return nil, err
-	}
-
-	if packet == nil {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: could not retrieve message"))
-	}
-
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return nil, err
-		}
-		asn1.PrintPacket(packet)
-	}
-
-	if packet.Children[1].Tag == ApplicationExtendedResponse {
-		resultCode, resultDescription := getLDAPResultCode(packet)
-		if resultCode != 0 {
-			return nil, NewError(resultCode, errors.New(resultDescription))
-		}
-	} else {
-		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("Unexpected Response: %d", packet.Children[1].Tag))
-	}
-
-	extendedResponse := packet.Children[1]
+	extendedResponse := response.Packet.Children[1]
 	for _, child := range extendedResponse.Children {
-		if child.Tag == 11 {
+		// responseValue [11]
+		if child.ClassType == asn1.ClassContext && child.Tag == 11 {
 			passwordModifyResponseValue := asn1.DecodePacket(child.Data.Bytes())
 			if len(passwordModifyResponseValue.Children) == 1 {
 				if passwordModifyResponseValue.Children[0].Tag == 0 {
@@ -0,0 +1,81 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newPasswordModifyResponse returns an extended response packet carrying the
+// given generated password, or no response value if it is empty.
+func newPasswordModifyResponse(messageID int64, generatedPassword string) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, LDAPResultSuccess, "Result Code"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Matched DN"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Diagnostic Message"))
+	if generatedPassword != "" {
+		value := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "PasswdModifyResponseValue")
+		value.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, generatedPassword, "Generated Password"))
+		responseValue := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, 11, nil, "Response Value")
+		responseValue.Data.Write(value.Bytes())
+		response.AppendChild(responseValue)
+	}
+	packet.AppendChild(response)
+	return packet
+}
+
+func TestPasswordModify(t *testing.T) {
+	tests := []struct {
+		request   *PasswordModifyRequest
+		fields    []asn1.Tag
+		generated string
+	}{
+		{NewPasswordModifyRequest("uid=jdoe,dc=example,dc=com", "old", "new"), []asn1.Tag{0, 1, 2}, ""},
+		{NewPasswordModifyRequest("", "old", "new"), []asn1.Tag{1, 2}, ""},
+		{NewPasswordModifyRequest("uid=jdoe,dc=example,dc=com", "", ""), []asn1.Tag{0}, "s3cr3t"},
+	}
+
+	for i, test := range tests {
+		ptc := newPacketTranslatorConn()
+		conn := NewConn(ptc, false)
+		conn.Start()
+
+		var fields []asn1.Tag
+		go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			request := p.Children[1]
+			if name := asn1.DecodeString(request.Children[0].Data.Bytes()); name != passwordModifyOID {
+				t.Errorf("%d: unexpected request name %q", i, name)
+			}
+			value := asn1.DecodePacket(request.Children[1].Data.Bytes())
+			for _, field := range value.Children {
+				fields = append(fields, field.Tag)
+			}
+			return []*asn1.Packet{newPasswordModifyResponse(p.Children[0].Value.(int64), test.generated)}
+		})
+
+		runWithTimeout(t, time.Second, func() {
+			result, err := conn.PasswordModify(test.request)
+			if err != nil {
+				t.Fatalf("%d: unexpected error: %s", i, err)
+			}
+			if result.GeneratedPassword != test.generated {
+				t.Errorf("%d: expected generated password %q, got %q", i, test.generated, result.GeneratedPassword)
+			}
+		})
+		if len(fields) != len(test.fields) {
+			t.Errorf("%d: expected request fields %v, got %v", i, test.fields, fields)
+		}
+		for j := range fields {
+			if j < len(test.fields) && fields[j] != test.fields[j] {
+				t.Errorf("%d: expected request fields %v, got %v", i, test.fields, fields)
+				break
+			}
+		}
+
+		conn.Close()
+		ptc.Close()
+	}
+}
@@ -0,0 +1,262 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// cryptAlphabet is the alphabet of the salts and hashes of crypt(3)
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// cryptMethod is a method of crypt(3), identified by the prefix of its hashes
+type cryptMethod struct {
+	prefix  string
+	maxSalt int
+}
+
+// cryptMethods are the methods of crypt(3) produced by Hash
+var cryptMethods = map[Scheme]cryptMethod{
+	MD5Crypt:    {"$1$", 8},
+	SHA256Crypt: {"$5$", 16},
+	SHA512Crypt: {"$6$", 16},
+}
+
+// Rounds of the SHA based crypt(3) methods
+const (
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+)
+
+// shaCryptOrders are the orders in which the bytes of the SHA-256 and SHA-512
+// digests are encoded, by groups of three
+var shaCryptOrders = map[int][]int{
+	sha256.Size: {0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14, 15, 25, 5, 6, 16, 26, 27, 7, 17, 18, 28, 8, 9, 19, 29},
+	sha512.Size: {0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4, 47, 5, 26, 6, 27, 48, 28, 49, 7, 50, 8, 29, 9, 30, 51,
+		31, 52, 10, 53, 11, 32, 12, 33, 54, 34, 55, 13, 56, 14, 35, 15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61, 19,
+		62, 20, 41},
+}
+
+// randomCryptSalt returns a random salt of n characters of the crypt(3)
+// alphabet
+func randomCryptSalt(n int) (string, error) {
+	random := make([]byte, n)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	salt := make([]byte, n)
+	for i, b := range random {
+		salt[i] = cryptAlphabet[b&0x3f]
+	}
+	return string(salt), nil
+}
+
+// crypt returns the crypt(3) hash of the password with the method, salt and
+// rounds of the setting, which is a hash or its prefix up to the salt
+func crypt(password, setting string) (string, error) {
+	switch {
+	case strings.HasPrefix(setting, "$1$"):
+		return md5Crypt(password, setting), nil
+	case strings.HasPrefix(setting, "$5$"):
+		return shaCrypt(password, setting, sha256.New)
+	case strings.HasPrefix(setting, "$6$"):
+		return shaCrypt(password, setting, sha512.New)
+	}
+	return "", ErrUnsupportedScheme
+}
+
+// encodeCrypt appends the encoding of the 24 bits of the bytes, least
+// significant first, as n characters
+func encodeCrypt(dst []byte, b2, b1, b0 byte, n int) []byte {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		dst = append(dst, cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+	return dst
+}
+
+// cryptSalt returns the salt of the setting following the prefix, up to
+// maxSalt characters
+func cryptSalt(setting string, maxSalt int) string {
+	salt := setting
+	if i := strings.IndexByte(salt, '$'); i >= 0 {
+		salt = salt[:i]
+	}
+	if len(salt) > maxSalt {
+		salt = salt[:maxSalt]
+	}
+	return salt
+}
+
+// md5Crypt returns the MD5 based crypt(3) hash of the password, as
+// implemented by FreeBSD
+func md5Crypt(password, setting string) string {
+	const prefix = "$1$"
+	salt := cryptSalt(setting[len(prefix):], 8)
+	pw := []byte(password)
+
+	alternate := md5.New()
+	alternate.Write(pw)
+	alternate.Write([]byte(salt))
+	alternate.Write(pw)
+	sum := alternate.Sum(nil)
+
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(prefix + salt))
+	for n := len(pw); n > 0; n -= md5.Size {
+		if n > md5.Size {
+			h.Write(sum)
+		} else {
+			h.Write(sum[:n])
+		}
+	}
+	for n := len(pw); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum = h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+
+	hashed := []byte(prefix + salt + "$")
+	for i := 0; i < 4; i++ {
+		hashed = encodeCrypt(hashed, sum[i], sum[i+6], sum[i+12], 4)
+	}
+	hashed = encodeCrypt(hashed, sum[4], sum[10], sum[5], 4)
+	return string(encodeCrypt(hashed, 0, 0, sum[11], 2))
+}
+
+// shaCrypt returns the SHA-256 or SHA-512 based crypt(3) hash of the
+// password, as specified by Ulrich Drepper
+func shaCrypt(password, setting string, newHash func() hash.Hash) (string, error) {
+	prefix := setting[:3]
+	setting = setting[3:]
+	rounds, customRounds := shaCryptDefaultRounds, false
+	if strings.HasPrefix(setting, "rounds=") {
+		end := strings.IndexByte(setting, '$')
+		if end < 0 {
+			return "", errors.New("password: invalid crypt rounds")
+		}
+		n, err := strconv.Atoi(setting[len("rounds="):end])
+		if err != nil {
+			return "", errors.New("password: invalid crypt rounds")
+		}
+		rounds, customRounds = n, true
+		if rounds < shaCryptMinRounds {
+			rounds = shaCryptMinRounds
+		} else if rounds > shaCryptMaxRounds {
+			rounds = shaCryptMaxRounds
+		}
+		setting = setting[end+1:]
+	}
+	salt := []byte(cryptSalt(setting, 16))
+	pw := []byte(password)
+
+	// repeat returns the digest repeated, or truncated, to n bytes
+	repeat := func(sum []byte, n int) []byte {
+		var repeated []byte
+		for ; n > len(sum); n -= len(sum) {
+			repeated = append(repeated, sum...)
+		}
+		return append(repeated, sum[:n]...)
+	}
+
+	alternate := newHash()
+	alternate.Write(pw)
+	alternate.Write(salt)
+	alternate.Write(pw)
+	sumB := alternate.Sum(nil)
+
+	h := newHash()
+	h.Write(pw)
+	h.Write(salt)
+	h.Write(repeat(sumB, len(pw)))
+	for n := len(pw); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(sumB)
+		} else {
+			h.Write(pw)
+		}
+	}
+	sumA := h.Sum(nil)
+
+	h = newHash()
+	for i := 0; i < len(pw); i++ {
+		h.Write(pw)
+	}
+	p := repeat(h.Sum(nil), len(pw))
+
+	h = newHash()
+	for i := 0; i < 16+int(sumA[0]); i++ {
+		h.Write(salt)
+	}
+	s := repeat(h.Sum(nil), len(salt))
+
+	sum := sumA
+	for i := 0; i < rounds; i++ {
+		h := newHash()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(p)
+		}
+		sum = h.Sum(nil)
+	}
+
+	hashed := []byte(prefix)
+	if customRounds {
+		hashed = append(hashed, "rounds="+strconv.Itoa(rounds)+"$"...)
+	}
+	hashed = append(append(hashed, salt...), '$')
+	order := shaCryptOrders[len(sum)]
+	for i := 0; i+2 < len(order); i += 3 {
+		hashed = encodeCrypt(hashed, sum[order[i]], sum[order[i+1]], sum[order[i+2]], 4)
+	}
+	if len(sum) == sha256.Size {
+		hashed = encodeCrypt(hashed, 0, sum[31], sum[30], 3)
+	} else {
+		hashed = encodeCrypt(hashed, 0, 0, sum[63], 2)
+	}
+	return string(hashed), nil
+}
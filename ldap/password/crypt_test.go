@@ -0,0 +1,35 @@
+package password
+
+import (
+	"testing"
+)
+
+func TestCrypt(t *testing.T) {
+	for _, test := range []struct {
+		password string
+		hashed   string
+	}{
+		{"password", "$1$xxxxxxxx$UYCIxa628.9qXjpQCjM4a."},
+		{"", "$1$ab$rn6aQS/o7141mj179E/zA."},
+		{"Hello world!", "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		{"Hello world!", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"Hello world!", "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+	} {
+		hashed, err := crypt(test.password, test.hashed)
+		if err != nil {
+			t.Errorf("%s: %s", test.hashed, err)
+		} else if hashed != test.hashed {
+			t.Errorf("expected %s, got %s", test.hashed, hashed)
+		}
+	}
+
+	// the salt is truncated to 16 characters
+	hashed, err := crypt("Hello world!", "$6$rounds=10000$saltstringsaltstring")
+	if err != nil || hashed[:33] != "$6$rounds=10000$saltstringsaltst$" {
+		t.Errorf("unexpected hash %s with a long salt: %v", hashed, err)
+	}
+
+	if _, err := crypt("password", "abJnggxhB/yWI"); err != ErrUnsupportedScheme {
+		t.Errorf("expected DES based hashes to be unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package password hashes and verifies the passwords stored in the
+// userPassword attribute, in the formats of RFC 3112 understood by OpenLDAP
+// and 389 Directory Server: salted SHA digests and crypt(3) hashes.
+//
+//	hashed, err := password.Hash(password.SSHA512, "secret")
+//	...
+//	modifyRequest.Replace("userPassword", []string{hashed})
+//
+// and, for a value read from the directory:
+//
+//	ok, err := password.Verify(entry.GetAttributeValue("userPassword"), "secret")
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Scheme is a format of hashed passwords
+type Scheme string
+
+// Schemes supported by Hash
+const (
+	// SSHA is the salted SHA-1 digest, "{SSHA}" followed by the base64
+	// encoding of the digest and the salt
+	SSHA Scheme = "SSHA"
+	// SSHA256 is the salted SHA-256 digest
+	SSHA256 Scheme = "SSHA256"
+	// SSHA512 is the salted SHA-512 digest
+	SSHA512 Scheme = "SSHA512"
+	// MD5Crypt is the MD5 based crypt(3) hash, "{CRYPT}$1$" followed by the
+	// salt and the hash
+	MD5Crypt Scheme = "MD5-CRYPT"
+	// SHA256Crypt is the SHA-256 based crypt(3) hash, "{CRYPT}$5$"
+	SHA256Crypt Scheme = "SHA256-CRYPT"
+	// SHA512Crypt is the SHA-512 based crypt(3) hash, "{CRYPT}$6$"
+	SHA512Crypt Scheme = "SHA512-CRYPT"
+)
+
+// saltSize is the size of the salts of the salted digests
+const saltSize = 8
+
+// ErrUnsupportedScheme is returned for a scheme, or a crypt(3) method, which
+// is not supported, such as the DES based crypt(3) hash
+var ErrUnsupportedScheme = errors.New("password: unsupported scheme")
+
+// saltedDigests are the hash functions of the salted digests, by scheme name
+var saltedDigests = map[string]func() hash.Hash{
+	"SSHA":    sha1.New,
+	"SSHA256": sha256.New,
+	"SSHA512": sha512.New,
+}
+
+// unsaltedDigests are the hash functions of the unsalted digests, verified
+// but not produced by Hash as they do not resist dictionary attacks
+var unsaltedDigests = map[string]func() hash.Hash{
+	"SHA":    sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// Hash returns the password hashed with the scheme and a random salt,
+// prefixed with the scheme in braces as stored in userPassword
+func Hash(scheme Scheme, password string) (string, error) {
+	switch scheme {
+	case SSHA, SSHA256, SSHA512:
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		return hashSalted(string(scheme), password, salt), nil
+	case MD5Crypt, SHA256Crypt, SHA512Crypt:
+		salt, err := randomCryptSalt(cryptMethods[scheme].maxSalt)
+		if err != nil {
+			return "", err
+		}
+		hashed, err := crypt(password, cryptMethods[scheme].prefix+salt)
+		if err != nil {
+			return "", err
+		}
+		return "{CRYPT}" + hashed, nil
+	}
+	return "", ErrUnsupportedScheme
+}
+
+// hashSalted returns the salted digest of the password
+func hashSalted(scheme, password string, salt []byte) string {
+	h := saltedDigests[scheme]()
+	h.Write([]byte(password))
+	h.Write(salt)
+	return "{" + scheme + "}" + base64.StdEncoding.EncodeToString(append(h.Sum(nil), salt...))
+}
+
+// Verify reports whether the password matches the hashed password, prefixed
+// with its scheme in braces. The salted and unsalted SHA digests, and the MD5,
+// SHA-256 and SHA-512 based crypt(3) hashes are supported, as well as
+// passwords stored in clear text, without a scheme. ErrUnsupportedScheme is
+// returned for other schemes.
+func Verify(hashed, password string) (bool, error) {
+	if !strings.HasPrefix(hashed, "{") {
+		return subtle.ConstantTimeCompare([]byte(hashed), []byte(password)) == 1, nil
+	}
+	end := strings.IndexByte(hashed, '}')
+	if end < 0 {
+		return false, errors.New("password: invalid hashed password")
+	}
+	scheme, value := strings.ToUpper(hashed[1:end]), hashed[end+1:]
+
+	if scheme == "CRYPT" {
+		computed, err := crypt(password, value)
+		if err != nil {
+			return false, err
+		}
+		return subtle.ConstantTimeCompare([]byte(computed), []byte(value)) == 1, nil
+	}
+	if newHash, ok := saltedDigests[scheme]; ok {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		size := newHash().Size()
+		if err != nil || len(decoded) <= size {
+			return false, fmt.Errorf("password: invalid %s value", scheme)
+		}
+		computed := hashSalted(scheme, password, decoded[size:])
+		return subtle.ConstantTimeCompare([]byte(computed), []byte("{"+scheme+"}"+value)) == 1, nil
+	}
+	if newHash, ok := unsaltedDigests[scheme]; ok {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return false, fmt.Errorf("password: invalid %s value", scheme)
+		}
+		h := newHash()
+		h.Write([]byte(password))
+		return subtle.ConstantTimeCompare(h.Sum(nil), decoded) == 1, nil
+	}
+	return false, ErrUnsupportedScheme
+}
@@ -0,0 +1,62 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	for _, scheme := range []Scheme{SSHA, SSHA256, SSHA512, MD5Crypt, SHA256Crypt, SHA512Crypt} {
+		hashed, err := Hash(scheme, "secret")
+		if err != nil {
+			t.Errorf("%s: %s", scheme, err)
+			continue
+		}
+		if other, _ := Hash(scheme, "secret"); other == hashed {
+			t.Errorf("%s: expected a random salt, got %s twice", scheme, hashed)
+		}
+		if ok, err := Verify(hashed, "secret"); !ok || err != nil {
+			t.Errorf("%s: expected %s to verify, got %v, %v", scheme, hashed, ok, err)
+		}
+		if ok, err := Verify(hashed, "Secret"); ok || err != nil {
+			t.Errorf("%s: expected a wrong password not to verify, got %v, %v", scheme, ok, err)
+		}
+	}
+	if hashed, _ := Hash(SHA512Crypt, "secret"); !strings.HasPrefix(hashed, "{CRYPT}$6$") {
+		t.Errorf("unexpected SHA512-CRYPT hash %s", hashed)
+	}
+	if _, err := Hash("PBKDF2", "secret"); err != ErrUnsupportedScheme {
+		t.Errorf("expected an unsupported scheme, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, test := range []struct {
+		hashed   string
+		password string
+		ok       bool
+	}{
+		{"{SSHA}tCNGqyJLk/uvKpCa4vga5GB2gWoxMjM0NTY3OA==", "secret", true},
+		{"{ssha}tCNGqyJLk/uvKpCa4vga5GB2gWoxMjM0NTY3OA==", "secret", true},
+		{"{SSHA}tCNGqyJLk/uvKpCa4vga5GB2gWoxMjM0NTY3OA==", "other", false},
+		{"{SHA256}K7gNU3sdo+OL0wNhqoVWhr3g6s1xYv72ol/pe/Unols=", "secret", true},
+		{"{CRYPT}$1$xxxxxxxx$UYCIxa628.9qXjpQCjM4a.", "password", true},
+		{"{CRYPT}$1$xxxxxxxx$UYCIxa628.9qXjpQCjM4a.", "Password", false},
+		{"secret", "secret", true},
+		{"secret", "other", false},
+	} {
+		ok, err := Verify(test.hashed, test.password)
+		if err != nil {
+			t.Errorf("%s: %s", test.hashed, err)
+		} else if ok != test.ok {
+			t.Errorf("%s with %q: expected %v, got %v", test.hashed, test.password, test.ok, ok)
+		}
+	}
+
+	if _, err := Verify("{PBKDF2}abc", "secret"); err != ErrUnsupportedScheme {
+		t.Errorf("expected an unsupported scheme, got %v", err)
+	}
+	if _, err := Verify("{SSHA}dG9vc2hvcnQ=", "secret"); err == nil {
+		t.Error("expected an error for a value shorter than the digest")
+	}
+}
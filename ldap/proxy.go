@@ -0,0 +1,326 @@
+// File contains the connection to the servers through HTTP and SOCKS5 proxies
+
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyFunc returns the URL of the proxy used to connect to the address, or
+// nil to connect to it directly
+type proxyFunc func(address string) (*url.URL, error)
+
+// DialWithProxy connects to the servers through the proxy of the URL, for
+// both ldap and ldaps connections, the TLS handshake being performed through
+// the tunnel. The "http" scheme is for an HTTP proxy tunneling the
+// connections with the CONNECT method, the "socks5" scheme for a SOCKS5
+// proxy, and the "socks5h" scheme for a SOCKS5 proxy also resolving the host
+// names of the servers. The user and password of the URL, if any,
+// authenticate with the proxy. The proxy is reached with the dialer or the
+// function set by DialWithDialContext. ldapi connections are not proxied.
+func DialWithProxy(proxyURL *url.URL) DialOpt {
+	return func(dc *dialConfig) {
+		dc.proxy = func(address string) (*url.URL, error) {
+			return proxyURL, nil
+		}
+	}
+}
+
+// DialWithProxyFromEnvironment connects to the servers through the proxy of
+// the ALL_PROXY environment variable, or all_proxy, like DialWithProxy. The
+// servers matching the NO_PROXY environment variable, or no_proxy, are
+// connected to directly, as are all the servers if ALL_PROXY is not set.
+// NO_PROXY is a comma separated list of host names, matching their
+// subdomains as well, IP addresses and CIDR ranges, each optionally with a
+// port, or "*" to match all the servers. A proxy without a scheme is an HTTP
+// proxy. The variables are read for each connection.
+func DialWithProxyFromEnvironment() DialOpt {
+	return func(dc *dialConfig) {
+		dc.proxy = func(address string) (*url.URL, error) {
+			return environmentProxy(getenv("ALL_PROXY", "all_proxy"), getenv("NO_PROXY", "no_proxy"), address)
+		}
+	}
+}
+
+// getenv returns the value of the first environment variable set
+func getenv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// environmentProxy returns the URL of the proxy used to connect to the
+// address according to the values of ALL_PROXY and NO_PROXY
+func environmentProxy(allProxy, noProxy, address string) (*url.URL, error) {
+	if allProxy == "" || matchNoProxy(noProxy, address) {
+		return nil, nil
+	}
+	if !strings.Contains(allProxy, "://") {
+		allProxy = "http://" + allProxy
+	}
+	proxyURL, err := url.Parse(allProxy)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid proxy %q: %s", allProxy, err)
+	}
+	return proxyURL, nil
+}
+
+// matchNoProxy reports whether the address matches an entry of NO_PROXY
+func matchNoProxy(noProxy, address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialProxy connects to the address through the proxy, reached with dial
+func dialProxy(ctx context.Context, proxyURL *url.URL, dial DialContextFunc, address string) (net.Conn, error) {
+	port := proxyURL.Port()
+	switch proxyURL.Scheme {
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	case "socks5", "socks5h":
+		if port == "" {
+			port = "1080"
+		}
+	default:
+		return nil, fmt.Errorf("ldap: unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	c, err := dial(ctx, "tcp", net.JoinHostPort(proxyURL.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	tunnel := c
+	if proxyURL.Scheme == "http" {
+		tunnel, err = connectHTTPProxy(c, proxyURL, address)
+	} else {
+		err = connectSOCKS5Proxy(ctx, c, proxyURL, address)
+	}
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("ldap: proxy %s: %s", proxyURL.Host, err)
+	}
+	c.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// bufferedConn is a connection whose first bytes were buffered while reading
+// the response of a proxy
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// connectHTTPProxy tunnels the connection to the address with the CONNECT
+// method
+func connectHTTPProxy(c net.Conn, proxyURL *url.URL, address string) (net.Conn, error) {
+	request := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	if _, err := io.WriteString(c, request+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(c)
+	status, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(strings.TrimSpace(status), " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+		return nil, fmt.Errorf("invalid response %q", status)
+	}
+	if code, err := strconv.Atoi(fields[1]); err != nil || code < 200 || code > 299 {
+		return nil, fmt.Errorf("CONNECT failed: %s", strings.TrimSpace(status))
+	}
+	// skip the headers
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+	}
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: c, r: r}, nil
+	}
+	return c, nil
+}
+
+// SOCKS5 constants of RFC 1928 and RFC 1929
+const (
+	socks5Version          = 5
+	socks5NoAuthentication = 0
+	socks5PasswordAuth     = 2
+	socks5Connect          = 1
+	socks5IPv4             = 1
+	socks5DomainName       = 3
+	socks5IPv6             = 4
+)
+
+// socks5Errors are the messages of the failure replies of a SOCKS5 proxy
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// connectSOCKS5Proxy requests the SOCKS5 proxy to connect to the address,
+// resolving its host name unless the scheme of the proxy is socks5h
+func connectSOCKS5Proxy(ctx context.Context, c net.Conn, proxyURL *url.URL, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	method := byte(socks5NoAuthentication)
+	if proxyURL.User != nil {
+		method = socks5PasswordAuth
+	}
+	if _, err := c.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(c, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+	if reply[1] != method {
+		return errors.New("no acceptable authentication method")
+	}
+	if method == socks5PasswordAuth {
+		username := proxyURL.User.Username()
+		password, _ := proxyURL.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("user or password too long")
+		}
+		auth := append([]byte{1, byte(len(username))}, username...)
+		auth = append(append(auth, byte(len(password))), password...)
+		if _, err := c.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("authentication failed")
+		}
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+	ip := net.ParseIP(host)
+	if ip == nil && proxyURL.Scheme == "socks5" {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		ip = addrs[0].IP
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long", host)
+		}
+		request = append(append(request, socks5DomainName, byte(len(host))), host...)
+	case ip.To4() != nil:
+		request = append(append(request, socks5IPv4), ip.To4()...)
+	default:
+		request = append(append(request, socks5IPv6), ip.To16()...)
+	}
+	request = append(request, byte(portNumber>>8), byte(portNumber))
+	if _, err := c.Write(request); err != nil {
+		return err
+	}
+
+	// the reply holds the bound address, whose length depends on its type
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		if message, ok := socks5Errors[header[1]]; ok {
+			return errors.New(message)
+		}
+		return fmt.Errorf("connection failed with reply %d", header[1])
+	}
+	var remaining int
+	switch header[3] {
+	case socks5IPv4:
+		remaining = net.IPv4len - 1 + 2
+	case socks5IPv6:
+		remaining = net.IPv6len - 1 + 2
+	case socks5DomainName:
+		remaining = int(header[4]) + 2
+	default:
+		return fmt.Errorf("unexpected address type %d", header[3])
+	}
+	_, err = io.ReadFull(c, make([]byte, remaining))
+	return err
+}
@@ -0,0 +1,122 @@
+// File contains Persistent Search functionality
+//
+// https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+//
+// A persistent search is a regular search request carrying the persistent
+// search control. Instead of terminating with a SearchResultDone, the server
+// keeps the operation open and returns a SearchResultEntry for every entry that
+// changes, until the client abandons the request.
+
+package ldap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// EntryChangeEvent is delivered for every entry returned by a persistent search
+type EntryChangeEvent struct {
+	// MessageID is the message ID of the search, which can be given to Abandon
+	MessageID int64
+	// Entry is the entry returned by the server
+	Entry *Entry
+	// Change describes the change, or is nil for entries of the initial result
+	// set and for servers which do not return entry change notifications
+	Change *ControlEntryChangeNotification
+	// Err is set on the last event if the search ended with an error
+	Err error
+}
+
+// PersistentSearch starts a persistent search for the given change types, a
+// bitmask of the EntryChange* constants. If changesOnly is false, the entries
+// matching the search are returned first, followed by the changed entries.
+//
+// Events are delivered on the returned channel until ctx is done, at which
+// point the search is abandoned and the channel is closed. If the server ends
+// the search, the channel is closed after an event carrying the error.
+// A timeout set with SetTimeout applies to persistent searches as well, so it
+// should not be used on connections running them.
+func (l *Conn) PersistentSearch(ctx context.Context, searchRequest *SearchRequest, changeTypes int, changesOnly bool) (<-chan *EntryChangeEvent, error) {
+	controls := make([]Control, 0, len(searchRequest.Controls)+1)
+	controls = append(controls, searchRequest.Controls...)
+	controls = append(controls, NewControlPersistentSearch(changeTypes, changesOnly))
+
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
+	encodedSearchRequest, err := searchRequest.encode()
+	if err != nil {
+		return nil, err
+	}
+	packet.AppendChild(encodedSearchRequest)
+	packet.AppendChild(encodeControls(controls))
+
+	l.Debug.PrintPacket(packet)
+
+	msgCtx, err := l.sendMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *EntryChangeEvent)
+	go func() {
+		defer close(events)
+		defer l.finishMessage(msgCtx)
+
+		send := func(event *EntryChangeEvent) bool {
+			event.MessageID = msgCtx.id
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				l.abandon(msgCtx.id)
+				return false
+			}
+		}
+
+		for {
+			packet, err := l.receivePacket(ctx, msgCtx)
+			if err != nil {
+				if ctx.Err() == nil {
+					send(&EntryChangeEvent{Err: err})
+				}
+				return
+			}
+
+			if l.Debug {
+				if err := addLDAPDescriptions(packet); err != nil {
+					send(&EntryChangeEvent{Err: err})
+					return
+				}
+				asn1.PrintPacket(packet)
+			}
+
+			switch packet.Children[1].Tag {
+			case ApplicationSearchResultEntry:
+				event := &EntryChangeEvent{Entry: decodeEntry(packet.Children[1])}
+				control, err := decodeResponseControl(packet, ControlTypeEntryChangeNotification)
+				if err != nil {
+					if send(&EntryChangeEvent{Err: err}) {
+						l.abandon(msgCtx.id)
+					}
+					return
+				}
+				if control != nil {
+					event.Change = control.(*ControlEntryChangeNotification)
+				}
+				if !send(event) {
+					return
+				}
+			case ApplicationSearchResultDone:
+				err := newResultError(packet)
+				if resultCode, _ := getLDAPResultCode(packet); resultCode == LDAPResultSuccess {
+					err = NewError(resultCode, errors.New("ldap: persistent search ended by server"))
+				}
+				send(&EntryChangeEvent{Err: err})
+				return
+			}
+		}
+	}()
+	return events, nil
+}
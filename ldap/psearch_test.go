@@ -0,0 +1,188 @@
+package ldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestPersistentSearch(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *asn1.Packet, 1)
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		requests <- request
+		messageID := request.Children[0].Value.(int64)
+		changed := newEntryPacket(messageID, "cn=new,dc=example,dc=com")
+		changed.AppendChild(encodeControls([]Control{&ControlEntryChangeNotification{
+			ChangeType: EntryChangeModDN,
+			PreviousDN: "cn=old,dc=example,dc=com",
+		}}))
+		return []*asn1.Packet{
+			newEntryPacket(messageID, "cn=existing,dc=example,dc=com"),
+			changed,
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.PersistentSearch(ctx, searchRequest, EntryChangeAll, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []*EntryChangeEvent
+	runWithTimeout(t, time.Second, func() {
+		for len(received) < 2 {
+			received = append(received, <-events)
+		}
+	})
+
+	request := <-requests
+	if len(request.Children) != 3 {
+		t.Fatalf("expected search request to carry controls")
+	}
+	control, ok := DecodeControl(request.Children[2].Children[0]).(*ControlPersistentSearch)
+	if !ok {
+		t.Fatalf("expected persistent search control, got %T", DecodeControl(request.Children[2].Children[0]))
+	}
+	if control.ChangeTypes != EntryChangeAll || control.ChangesOnly || !control.ReturnECs {
+		t.Errorf("unexpected persistent search control %s", control)
+	}
+
+	if received[0].Entry.DN != "cn=existing,dc=example,dc=com" || received[0].Change != nil {
+		t.Errorf("unexpected initial event %+v", received[0])
+	}
+	if received[1].Entry.DN != "cn=new,dc=example,dc=com" || received[1].Change == nil ||
+		received[1].Change.ChangeType != EntryChangeModDN || received[1].Change.PreviousDN != "cn=old,dc=example,dc=com" {
+		t.Errorf("unexpected change event %+v", received[1])
+	}
+
+	cancel()
+	runWithTimeout(t, time.Second, func() {
+		abandon, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive abandon request: %s", err)
+		}
+		if abandon.Children[1].Tag != ApplicationAbandonRequest {
+			t.Errorf("expected abandon request, got application tag %d", abandon.Children[1].Tag)
+		}
+		if _, ok := <-events; ok {
+			t.Errorf("expected events channel to be closed")
+		}
+	})
+}
+
+func TestPersistentSearchEndedByServer(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(request.Children[0].Value.(int64), ApplicationSearchResultDone, LDAPResultUnavailableCriticalExtension, "psearch not supported")}
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.PersistentSearch(context.Background(), searchRequest, EntryChangeAll, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runWithTimeout(t, time.Second, func() {
+		event := <-events
+		if !IsErrorWithCode(event.Err, LDAPResultUnavailableCriticalExtension) {
+			t.Errorf("expected unavailable critical extension error, got %v", event.Err)
+		}
+		if _, ok := <-events; ok {
+			t.Errorf("expected events channel to be closed")
+		}
+	})
+}
+
+func TestPersistentSearchInvalidControl(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		changed := newEntryPacket(request.Children[0].Value.(int64), "cn=new,dc=example,dc=com")
+		controls := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+		controls.AppendChild(newControlPacket(ControlTypeEntryChangeNotification, nil))
+		changed.AppendChild(controls)
+		return []*asn1.Packet{changed}
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.PersistentSearch(context.Background(), searchRequest, EntryChangeAll, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runWithTimeout(t, time.Second, func() {
+		event := <-events
+		if !IsErrorWithCode(event.Err, ErrorUnexpectedResponse) {
+			t.Errorf("expected unexpected response error, got %v", event.Err)
+		}
+		if _, ok := <-events; ok {
+			t.Errorf("expected events channel to be closed")
+		}
+	})
+}
+
+func TestPersistentSearchAbandon(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.PersistentSearch(context.Background(), searchRequest, EntryChangeAll, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runWithTimeout(t, time.Second, func() {
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		messageID := request.Children[0].Value.(int64)
+		ptc.SendResponse(newEntryPacket(messageID, "cn=a,dc=example,dc=com"))
+
+		event := <-events
+		if event.MessageID != messageID {
+			t.Fatalf("got message ID %d, expected %d", event.MessageID, messageID)
+		}
+		if err := conn.Abandon(event.MessageID); err != nil {
+			t.Fatal(err)
+		}
+
+		abandonRequest, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if abandonRequest.Children[1].Tag != ApplicationAbandonRequest {
+			t.Errorf("expected abandon request, got application tag %d", abandonRequest.Children[1].Tag)
+		}
+		if event := <-events; event.Err != errRequestAbandoned {
+			t.Errorf("expected abandoned error, got %v", event.Err)
+		}
+		if _, ok := <-events; ok {
+			t.Errorf("expected events channel to be closed")
+		}
+	})
+}
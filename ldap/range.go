@@ -0,0 +1,102 @@
+// File contains ranged attribute retrieval
+//
+// Active Directory returns at most MaxValRange values of a multi-valued
+// attribute, such as member, with a range option telling which values are
+// returned: member;range=0-1499. The following values are read by asking for
+// member;range=1500-* until the last range, ending with *, is returned.
+//
+// https://docs.microsoft.com/en-us/previous-versions/windows/desktop/ldap/searching-using-range-retrieval
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rangeOption is the prefix of the range option of an attribute description
+const rangeOption = "range="
+
+// parseRangeOption splits an attribute description holding a range option,
+// such as "member;range=0-1499", into the attribute description without it and
+// the bounds of the range, high being -1 for the last range
+func parseRangeOption(name string) (attribute string, low, high int, ok bool) {
+	options := strings.Split(name, ";")
+	for i, option := range options[1:] {
+		if len(option) < len(rangeOption) || !strings.EqualFold(option[:len(rangeOption)], rangeOption) {
+			continue
+		}
+		bounds := strings.SplitN(option[len(rangeOption):], "-", 2)
+		if len(bounds) != 2 {
+			return "", 0, 0, false
+		}
+		var err error
+		if low, err = strconv.Atoi(bounds[0]); err != nil {
+			return "", 0, 0, false
+		}
+		if bounds[1] == "*" {
+			high = -1
+		} else if high, err = strconv.Atoi(bounds[1]); err != nil || high < low {
+			return "", 0, 0, false
+		}
+		attribute = strings.Join(append(options[:i+1:i+1], options[i+2:]...), ";")
+		return attribute, low, high, true
+	}
+	return "", 0, 0, false
+}
+
+// searchRanged performs the search request without following referrals, then
+// reads the remaining values of the ranged attributes of the entries
+func (l *Conn) searchRanged(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	result, err := l.search(ctx, searchRequest)
+	if err != nil && !(result != nil && result.LimitExceeded) {
+		return result, err
+	}
+	// the ranges of the entries found before a limit are read as well
+	for _, entry := range result.Entries {
+		for _, attr := range entry.Attributes {
+			attribute, _, high, ok := parseRangeOption(attr.Name)
+			if !ok {
+				continue
+			}
+			if err := l.readRange(ctx, entry.DN, attr, attribute, high); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, err
+}
+
+// readRange appends the values following the high bound to the ranged
+// attribute of the entry, which is then named after the attribute
+// description without the range option
+func (l *Conn) readRange(ctx context.Context, dn string, attr *EntryAttribute, attribute string, high int) error {
+	for high >= 0 {
+		requested := fmt.Sprintf("%s;%s%d-*", attribute, rangeOption, high+1)
+		searchRequest := NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{requested}, nil)
+		result, err := l.search(ctx, searchRequest)
+		if err != nil {
+			return err
+		}
+
+		// the server ends the retrieval with no ranged attribute when
+		// the values were removed in the meantime
+		next := high
+		high = -1
+		for _, entry := range result.Entries {
+			for _, ranged := range entry.Attributes {
+				name, low, rangeHigh, ok := parseRangeOption(ranged.Name)
+				if !ok || !strings.EqualFold(name, attribute) || low != next+1 {
+					continue
+				}
+				attr.Values = append(attr.Values, ranged.Values...)
+				attr.ByteValues = append(attr.ByteValues, ranged.ByteValues...)
+				high = rangeHigh
+			}
+		}
+	}
+	attr.Name = attribute
+	return nil
+}
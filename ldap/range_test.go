@@ -0,0 +1,81 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestParseRangeOption(t *testing.T) {
+	for name, expected := range map[string]struct {
+		attribute string
+		low, high int
+	}{
+		"member;range=0-1499":          {"member", 0, 1499},
+		"member;Range=1500-*":          {"member", 1500, -1},
+		"member;binary;range=10-19":    {"member;binary", 10, 19},
+		"member;range=0-9;x-option":    {"member;x-option", 0, 9},
+		"msDS-RevealedUsers;range=0-*": {"msDS-RevealedUsers", 0, -1},
+	} {
+		attribute, low, high, ok := parseRangeOption(name)
+		if !ok || attribute != expected.attribute || low != expected.low || high != expected.high {
+			t.Errorf("%s: got %q %d-%d %t", name, attribute, low, high, ok)
+		}
+	}
+	for _, name := range []string{"member", "member;range=", "member;range=10", "member;range=9-1", "member;range=a-*"} {
+		if _, _, _, ok := parseRangeOption(name); ok {
+			t.Errorf("%s: expected no range", name)
+		}
+	}
+}
+
+func TestSearchRangedAttribute(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	dn := "cn=staff,ou=groups,dc=example,dc=com"
+	ranges := []*EntryAttribute{
+		NewEntryAttribute("member;range=0-1", []string{"cn=a", "cn=b"}),
+		NewEntryAttribute("member;range=2-3", []string{"cn=c", "cn=d"}),
+		NewEntryAttribute("member;range=4-*", []string{"cn=e"}),
+	}
+	requested := make(chan []string, len(ranges))
+	go func() {
+		for _, attribute := range ranges {
+			serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+				var attributes []string
+				for _, child := range p.Children[1].Children[7].Children {
+					attributes = append(attributes, child.Value.(string))
+				}
+				requested <- attributes
+				messageID := p.Children[0].Value.(int64)
+				return []*asn1.Packet{
+					newEntryPacket(messageID, dn, NewEntryAttribute("cn", []string{"staff"}), attribute),
+					newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+				}
+			})
+		}
+	}()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		result, err := conn.Search(NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"cn", "member"}, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"cn=a", "cn=b", "cn=c", "cn=d", "cn=e"}
+		if members := result.Entries[0].GetAttributeValues("member"); !reflect.DeepEqual(members, expected) {
+			t.Errorf("got members %v, expected %v", members, expected)
+		}
+		for _, attributes := range [][]string{{"cn", "member"}, {"member;range=2-*"}, {"member;range=4-*"}} {
+			if got := <-requested; !reflect.DeepEqual(got, attributes) {
+				t.Errorf("got requested attributes %v, expected %v", got, attributes)
+			}
+		}
+	})
+}
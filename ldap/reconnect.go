@@ -0,0 +1,445 @@
+// File contains the automatically reconnecting Client
+
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ReconnectingConn is a Client re-dialing the server when the connection is
+// lost. The new connection is set up like the lost one: the timeout is set,
+// StartTLS is replayed and the last successful bind is performed again.
+// Operations which are safe to repeat (binds, searches and compares) are
+// retried on the new connection, others return the error of the lost
+// connection and the next operation reconnects.
+type ReconnectingConn struct {
+	dial func() (*Conn, error)
+	// MaxRetries is how many times an idempotent operation is retried after
+	// the connection is lost, 1 if zero
+	MaxRetries int
+
+	mu        sync.Mutex
+	conn      *Conn
+	closed    bool
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	// bind performs the last successful bind again
+	bind            func(ctx context.Context, conn *Conn) error
+	keepaliveStop   chan struct{}
+	instrumentation Instrumentation
+	tracer          Tracer
+	sendHook        PacketHook
+	receiveHook     PacketHook
+	interceptors    []Interceptor
+	idleTimeout     time.Duration
+	maxLifetime     time.Duration
+	// ignoreLimitExceeded is set by SetIgnoreLimitExceeded
+	ignoreLimitExceeded bool
+	// operationalAttributes are set by SetOperationalAttributes
+	operationalAttributes []string
+	// reconnects is the number of connections replacing the first one
+	reconnects uint64
+	// maxMessageSize is set by SetMaxMessageSize
+	maxMessageSize int64
+}
+
+var _ Client = &ReconnectingConn{}
+
+// NewReconnectingConn dials the first connection with the given function,
+// e.g. a closure around DialURL or the Dial method of a MultiDialer, and
+// returns a ReconnectingConn using it to reconnect
+func NewReconnectingConn(dial func() (*Conn, error)) (*ReconnectingConn, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	return &ReconnectingConn{
+		dial: dial,
+		conn: conn,
+	}, nil
+}
+
+// current returns the connection, reconnecting if it is lost
+func (r *ReconnectingConn) current(ctx context.Context) (*Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
+	}
+	// an expired connection is replaced, and closes itself once its
+	// outstanding requests complete
+	if r.conn != nil && !r.conn.isClosing() && !r.conn.isExpiring() {
+		return r.conn, nil
+	}
+
+	conn, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	if r.timeout > 0 {
+		conn.SetTimeout(r.timeout)
+	}
+	if r.tracer != nil {
+		conn.SetTracer(r.tracer)
+	}
+	if r.sendHook != nil {
+		conn.OnSend(r.sendHook)
+	}
+	if r.receiveHook != nil {
+		conn.OnReceive(r.receiveHook)
+	}
+	conn.Use(r.interceptors...)
+	if r.tlsConfig != nil {
+		if err := conn.StartTLS(r.tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.bind != nil {
+		if err := r.bind(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.idleTimeout > 0 {
+		conn.SetIdleTimeout(r.idleTimeout)
+	}
+	if r.maxLifetime > 0 {
+		conn.SetMaxLifetime(r.maxLifetime)
+	}
+	if r.ignoreLimitExceeded {
+		conn.SetIgnoreLimitExceeded(true)
+	}
+	if len(r.operationalAttributes) > 0 {
+		conn.SetOperationalAttributes(r.operationalAttributes...)
+	}
+	if r.maxMessageSize > 0 {
+		conn.SetMaxMessageSize(r.maxMessageSize)
+	}
+	r.conn = conn
+	r.reconnects++
+	if r.instrumentation != nil {
+		conn.SetInstrumentation(r.instrumentation)
+		r.instrumentation.Reconnected()
+	}
+	return conn, nil
+}
+
+// do performs the operation, retrying it on a new connection if the
+// connection is lost and the operation is idempotent
+func (r *ReconnectingConn) do(ctx context.Context, idempotent bool, operation func(conn *Conn) error) error {
+	retries := r.MaxRetries
+	if retries == 0 {
+		retries = 1
+	}
+	for attempt := 0; ; attempt++ {
+		conn, err := r.current(ctx)
+		if err != nil {
+			return err
+		}
+		err = operation(conn)
+		// errors of a closed connection are network errors, or the result of
+		// a notice of disconnection
+		if err == nil || !conn.isClosing() {
+			return err
+		}
+		// the connection is lost
+		if !idempotent || attempt >= retries {
+			return err
+		}
+	}
+}
+
+// Start does nothing, the connections are started when dialed
+func (r *ReconnectingConn) Start() {}
+
+// StartTLS sends the command to start a TLS session and then creates a new TLS
+// Client. It is replayed after reconnecting.
+func (r *ReconnectingConn) StartTLS(config *tls.Config) error {
+	err := r.do(context.Background(), false, func(conn *Conn) error {
+		return conn.StartTLS(config)
+	})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.tlsConfig = config
+	r.mu.Unlock()
+	return nil
+}
+
+// Close closes the connection, no reconnection happens afterwards
+func (r *ReconnectingConn) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.keepaliveStop != nil {
+		close(r.keepaliveStop)
+		r.keepaliveStop = nil
+	}
+	if r.conn != nil {
+		r.conn.Close()
+	}
+}
+
+// StartKeepalive probes the connection every interval while it has no
+// outstanding requests, ProbeRootDSE if probe is nil. A lost connection, or
+// one where the server does not answer within the interval, is replaced right
+// away rather than by the next operation. Calling StartKeepalive again
+// replaces the previous keepalive.
+func (r *ReconnectingConn) StartKeepalive(interval time.Duration, probe KeepaliveProbe) {
+	if probe == nil {
+		probe = ProbeRootDSE
+	}
+	stop := make(chan struct{})
+	r.mu.Lock()
+	if r.keepaliveStop != nil {
+		close(r.keepaliveStop)
+	}
+	r.keepaliveStop = stop
+	r.mu.Unlock()
+
+	go r.keepalive(interval, probe, stop)
+}
+
+func (r *ReconnectingConn) keepalive(interval time.Duration, probe KeepaliveProbe, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		conn, err := r.current(ctx)
+		if err == nil {
+			conn.messageMutex.Lock()
+			idle := conn.outstandingRequests == 0
+			conn.messageMutex.Unlock()
+			if idle && isConnectionDead(probe(ctx, conn)) {
+				conn.Close()
+				reconnectCtx, reconnectCancel := context.WithTimeout(context.Background(), interval)
+				r.current(reconnectCtx)
+				reconnectCancel()
+			}
+		}
+		cancel()
+	}
+}
+
+// SetTimeout sets the time after a request is sent that a MessageTimeout
+// triggers, on the current and future connections
+func (r *ReconnectingConn) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+	if r.conn != nil {
+		r.conn.SetTimeout(timeout)
+	}
+}
+
+// setBind stores the bind to perform after reconnecting
+func (r *ReconnectingConn) setBind(bind func(ctx context.Context, conn *Conn) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bind = bind
+}
+
+// Bind performs a bind with the given username and password, which is
+// performed again after reconnecting
+func (r *ReconnectingConn) Bind(username, password string) error {
+	return r.BindContext(context.Background(), username, password)
+}
+
+// BindContext performs a bind with the given username and password, which is
+// performed again after reconnecting
+func (r *ReconnectingConn) BindContext(ctx context.Context, username, password string) error {
+	bind := func(ctx context.Context, conn *Conn) error {
+		return conn.BindContext(ctx, username, password)
+	}
+	err := r.do(ctx, true, func(conn *Conn) error {
+		return bind(ctx, conn)
+	})
+	if err == nil {
+		r.setBind(bind)
+	}
+	return err
+}
+
+// SimpleBind performs the simple bind defined in the given request, which is
+// performed again after reconnecting
+func (r *ReconnectingConn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	return r.SimpleBindContext(context.Background(), simpleBindRequest)
+}
+
+// SimpleBindContext performs the simple bind defined in the given request,
+// which is performed again after reconnecting
+func (r *ReconnectingConn) SimpleBindContext(ctx context.Context, simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
+	bind := func(ctx context.Context, conn *Conn) error {
+		_, err := conn.SimpleBindContext(ctx, simpleBindRequest)
+		return err
+	}
+	var result *SimpleBindResult
+	err := r.do(ctx, true, func(conn *Conn) (err error) {
+		result, err = conn.SimpleBindContext(ctx, simpleBindRequest)
+		return err
+	})
+	if err == nil {
+		r.setBind(bind)
+	}
+	return result, err
+}
+
+// Add performs the given add request
+func (r *ReconnectingConn) Add(addRequest *AddRequest) error {
+	return r.AddContext(context.Background(), addRequest)
+}
+
+// AddContext performs the given add request
+func (r *ReconnectingConn) AddContext(ctx context.Context, addRequest *AddRequest) error {
+	return r.do(ctx, false, func(conn *Conn) error {
+		return conn.AddContext(ctx, addRequest)
+	})
+}
+
+// Del performs the given delete request
+func (r *ReconnectingConn) Del(delRequest *DelRequest) error {
+	return r.DelContext(context.Background(), delRequest)
+}
+
+// DelContext performs the given delete request
+func (r *ReconnectingConn) DelContext(ctx context.Context, delRequest *DelRequest) error {
+	return r.do(ctx, false, func(conn *Conn) error {
+		return conn.DelContext(ctx, delRequest)
+	})
+}
+
+// Modify performs the given modify request
+func (r *ReconnectingConn) Modify(modifyRequest *ModifyRequest) error {
+	return r.ModifyContext(context.Background(), modifyRequest)
+}
+
+// ModifyContext performs the given modify request
+func (r *ReconnectingConn) ModifyContext(ctx context.Context, modifyRequest *ModifyRequest) error {
+	return r.do(ctx, false, func(conn *Conn) error {
+		return conn.ModifyContext(ctx, modifyRequest)
+	})
+}
+
+// ModifyDN performs the given modify DN request
+func (r *ReconnectingConn) ModifyDN(modifyDNRequest *ModifyDNRequest) error {
+	return r.ModifyDNContext(context.Background(), modifyDNRequest)
+}
+
+// ModifyDNContext performs the given modify DN request
+func (r *ReconnectingConn) ModifyDNContext(ctx context.Context, modifyDNRequest *ModifyDNRequest) error {
+	return r.do(ctx, false, func(conn *Conn) error {
+		return conn.ModifyDNContext(ctx, modifyDNRequest)
+	})
+}
+
+// Compare checks to see if the attribute of the dn matches value
+func (r *ReconnectingConn) Compare(dn, attribute, value string) (bool, error) {
+	return r.CompareContext(context.Background(), dn, attribute, value)
+}
+
+// CompareContext checks to see if the attribute of the dn matches value
+func (r *ReconnectingConn) CompareContext(ctx context.Context, dn, attribute, value string) (bool, error) {
+	var matches bool
+	err := r.do(ctx, true, func(conn *Conn) (err error) {
+		matches, err = conn.CompareContext(ctx, dn, attribute, value)
+		return err
+	})
+	return matches, err
+}
+
+// PasswordModify performs the modification request
+func (r *ReconnectingConn) PasswordModify(passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
+	return r.PasswordModifyContext(context.Background(), passwordModifyRequest)
+}
+
+// PasswordModifyContext performs the modification request
+func (r *ReconnectingConn) PasswordModifyContext(ctx context.Context, passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
+	var result *PasswordModifyResult
+	err := r.do(ctx, false, func(conn *Conn) (err error) {
+		result, err = conn.PasswordModifyContext(ctx, passwordModifyRequest)
+		return err
+	})
+	return result, err
+}
+
+// Extended performs the given extended request
+func (r *ReconnectingConn) Extended(extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	return r.ExtendedContext(context.Background(), extendedRequest)
+}
+
+// ExtendedContext performs the given extended request
+func (r *ReconnectingConn) ExtendedContext(ctx context.Context, extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	var response *ExtendedResponse
+	err := r.do(ctx, false, func(conn *Conn) (err error) {
+		response, err = conn.ExtendedContext(ctx, extendedRequest)
+		return err
+	})
+	return response, err
+}
+
+// Do performs the given request
+func (r *ReconnectingConn) Do(request Request) (*Response, error) {
+	return r.DoContext(context.Background(), request)
+}
+
+// DoContext performs the given request
+func (r *ReconnectingConn) DoContext(ctx context.Context, request Request) (*Response, error) {
+	var response *Response
+	err := r.do(ctx, false, func(conn *Conn) (err error) {
+		response, err = conn.DoContext(ctx, request)
+		return err
+	})
+	return response, err
+}
+
+// Search performs the given search request
+func (r *ReconnectingConn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
+	return r.SearchContext(context.Background(), searchRequest)
+}
+
+// SearchContext performs the given search request
+func (r *ReconnectingConn) SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	var result *SearchResult
+	err := r.do(ctx, true, func(conn *Conn) (err error) {
+		result, err = conn.SearchContext(ctx, searchRequest)
+		return err
+	})
+	return result, err
+}
+
+// SearchWithPaging performs the given search request with paging. After
+// reconnecting, the search is restarted from the first page.
+func (r *ReconnectingConn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	return r.SearchWithPagingContext(context.Background(), searchRequest, pagingSize)
+}
+
+// SearchWithPagingContext performs the given search request with paging.
+// After reconnecting, the search is restarted from the first page.
+func (r *ReconnectingConn) SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	pagingControl, err := pagingControlFor(searchRequest, pagingSize)
+	if err != nil {
+		return nil, err
+	}
+	// the cookie of the lost connection is not valid on the new one
+	cookie := pagingControl.Cookie
+
+	var result *SearchResult
+	err = r.do(ctx, true, func(conn *Conn) (err error) {
+		pagingControl.SetCookie(cookie)
+		result, err = conn.SearchWithPagingContext(ctx, searchRequest, pagingSize)
+		return err
+	})
+	return result, err
+}
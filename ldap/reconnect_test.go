@@ -0,0 +1,152 @@
+package ldap
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// serveDroppingConnections accepts connections answering binds, searches and
+// adds with success, but closing the connection instead of answering the first
+// search and the first add. The application tags of the requests received on
+// each connection are sent to requests.
+func serveDroppingConnections(ln net.Listener, requests chan<- []asn1.Tag) {
+	var mu sync.Mutex
+	dropped := make(map[asn1.Tag]bool)
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			var tags []asn1.Tag
+			defer func() { requests <- tags }()
+			for {
+				request, err := asn1.ReadPacket(c)
+				if err != nil {
+					return
+				}
+				messageID := request.Children[0].Value.(int64)
+				tag := request.Children[1].Tag
+				tags = append(tags, tag)
+				switch tag {
+				case ApplicationBindRequest:
+					c.Write(newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess, "").Bytes())
+				case ApplicationSearchRequest, ApplicationAddRequest:
+					mu.Lock()
+					drop := !dropped[tag]
+					dropped[tag] = true
+					mu.Unlock()
+					if drop {
+						return
+					}
+					response := ApplicationSearchResultDone
+					if tag == ApplicationAddRequest {
+						response = ApplicationAddResponse
+					}
+					c.Write(newResultPacket(messageID, asn1.Tag(response), LDAPResultSuccess, "").Bytes())
+				}
+			}
+		}(c)
+	}
+}
+
+func TestReconnectingConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	requests := make(chan []asn1.Tag, 10)
+	go serveDroppingConnections(ln, requests)
+
+	dials := 0
+	r, err := NewReconnectingConn(func() (*Conn, error) {
+		dials++
+		return DialURL("ldap://" + ln.Addr().String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetTimeout(5 * time.Second)
+
+	runWithTimeout(t, 10*time.Second, func() {
+		if err := r.Bind("cn=admin,dc=example,dc=com", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		// the search is retried on a new connection, after binding again
+		if _, err := r.Search(NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if dials != 2 {
+			t.Errorf("got %d dials, expected 2", dials)
+		}
+		expected := []asn1.Tag{ApplicationBindRequest, ApplicationSearchRequest}
+		if tags := <-requests; len(tags) != 2 || tags[0] != expected[0] || tags[1] != expected[1] {
+			t.Errorf("first connection: got requests %v, expected %v", tags, expected)
+		}
+
+		// the add is not retried, but the next operation reconnects
+		addRequest := NewAddRequest("cn=test,dc=example,dc=com")
+		addRequest.Attribute("objectClass", []string{"device"})
+		if err := r.Add(addRequest); !IsErrorWithCode(err, ErrorNetwork) {
+			t.Errorf("expected network error, got %v", err)
+		}
+		if err := r.Add(addRequest); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if dials != 3 {
+			t.Errorf("got %d dials, expected 3", dials)
+		}
+		if stats := r.Stats(); stats.Reconnects != 2 || stats.Operations["Add"] != 1 {
+			t.Errorf("unexpected statistics %+v", stats)
+		}
+	})
+
+	r.Close()
+	if _, err := r.Search(&SearchRequest{}); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected network error after closing, got %v", err)
+	}
+}
+
+func TestReconnectingConnKeepalive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	requests := make(chan []asn1.Tag, 10)
+	go serveDroppingConnections(ln, requests)
+
+	var mu sync.Mutex
+	dials := 0
+	r, err := NewReconnectingConn(func() (*Conn, error) {
+		mu.Lock()
+		dials++
+		mu.Unlock()
+		return DialURL("ldap://" + ln.Addr().String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the first probe loses the connection, which is replaced without any operation
+	r.StartKeepalive(10*time.Millisecond, nil)
+	runWithTimeout(t, 5*time.Second, func() {
+		for {
+			mu.Lock()
+			n := dials
+			mu.Unlock()
+			if n >= 2 {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
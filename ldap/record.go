@@ -0,0 +1,238 @@
+// File contains the recording and replay of the messages of connections
+
+package ldap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+)
+
+// Cassette holds the messages exchanged on a connection, as recorded by a
+// Recorder and replayed by a Replayer. It is saved as JSON, the messages
+// being encoded in base64. Cassettes hold the requests as sent, including the
+// passwords of binds, so they should only be recorded against test servers.
+type Cassette struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is a request written to a connection, as its BER encoding, and the
+// bytes read from the connection until the next request was written
+type Exchange struct {
+	Request  []byte `json:"request"`
+	Response []byte `json:"response,omitempty"`
+}
+
+// LoadCassette reads the cassette saved in the file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cassette := &Cassette{}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("ldap: invalid cassette %s: %s", path, err)
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to the file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Recorder is a net.Conn recording the messages exchanged on the underlying
+// connection, and saving them to a file when closed. It is used in place of
+// the connection of a Conn to record the exchanges of a test with a server,
+// which are then replayed without it by a Replayer:
+//
+//	c, err := net.Dial("tcp", "ldap.example.com:389")
+//	...
+//	conn := ldap.NewConn(ldap.NewRecorder(c, "testdata/search.json"), false)
+//	conn.Start()
+//	defer conn.Close()
+type Recorder struct {
+	net.Conn
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+	closed   bool
+}
+
+// NewRecorder returns a Recorder of the connection saving its cassette to the
+// file
+func NewRecorder(conn net.Conn, path string) *Recorder {
+	return &Recorder{Conn: conn, path: path}
+}
+
+// Read reads from the connection, recording the bytes read as the response
+// to the last request written
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.Conn.Read(b)
+	if n > 0 {
+		r.mu.Lock()
+		// bytes read before the first request, such as a notice of
+		// disconnection, are recorded without a request
+		if len(r.cassette.Exchanges) == 0 {
+			r.cassette.Exchanges = append(r.cassette.Exchanges, Exchange{})
+		}
+		last := &r.cassette.Exchanges[len(r.cassette.Exchanges)-1]
+		last.Response = append(last.Response, b[:n]...)
+		r.mu.Unlock()
+	}
+	return n, err
+}
+
+// Write records the request, a message being written at once by a Conn, and
+// writes it to the connection
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	r.cassette.Exchanges = append(r.cassette.Exchanges, Exchange{Request: append([]byte(nil), b...)})
+	r.mu.Unlock()
+	return r.Conn.Write(b)
+}
+
+// Close closes the connection and saves the cassette, returning the error
+// of saving it if any
+func (r *Recorder) Close() error {
+	err := r.Conn.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return err
+	}
+	r.closed = true
+	if saveErr := r.cassette.Save(r.path); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// Replayer is a net.Conn replaying the exchanges of a cassette without a
+// server. Each request written must match the next request of the cassette,
+// after which the recorded response is read. Requests written after the last
+// recorded request, or not matching the next one, fail with an error, failing
+// their operation. Deadlines are ignored.
+type Replayer struct {
+	// Match reports whether a request written matches the recorded request,
+	// bytes.Equal if nil. It is set to ignore the parts of requests which
+	// differ between runs, such as the nonces of SASL mechanisms, and must not
+	// be changed once the Replayer is in use.
+	Match func(recorded, request []byte) bool
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	cassette *Cassette
+	next     int
+	pending  []byte
+	closed   bool
+}
+
+// NewReplayer returns a Replayer of the cassette saved in the file
+func NewReplayer(path string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCassetteReplayer(cassette), nil
+}
+
+// NewCassetteReplayer returns a Replayer of the cassette
+func NewCassetteReplayer(cassette *Cassette) *Replayer {
+	r := &Replayer{cassette: cassette}
+	r.cond = sync.NewCond(&r.mu)
+	if len(cassette.Exchanges) > 0 && len(cassette.Exchanges[0].Request) == 0 {
+		r.pending = cassette.Exchanges[0].Response
+		r.next = 1
+	}
+	return r
+}
+
+// Done reports whether all the recorded requests have been written
+func (r *Replayer) Done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next == len(r.cassette.Exchanges)
+}
+
+// Read reads the responses to the requests written, blocking until there
+// are some or the Replayer is closed
+func (r *Replayer) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.pending) == 0 && !r.closed {
+		r.cond.Wait()
+	}
+	if r.closed {
+		return 0, io.EOF
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Write matches the request with the next recorded request, making its
+// response available to Read
+func (r *Replayer) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, errors.New("ldap: replayer closed")
+	}
+	if r.next == len(r.cassette.Exchanges) {
+		return 0, fmt.Errorf("ldap: request %x was not recorded", b)
+	}
+	exchange := r.cassette.Exchanges[r.next]
+	match := r.Match
+	if match == nil {
+		match = bytes.Equal
+	}
+	if !match(exchange.Request, b) {
+		return 0, fmt.Errorf("ldap: request %x does not match the recorded request %x", b, exchange.Request)
+	}
+	r.next++
+	r.pending = append(r.pending, exchange.Response...)
+	r.cond.Broadcast()
+	return len(b), nil
+}
+
+// Close closes the Replayer, failing the pending reads
+func (r *Replayer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+	return nil
+}
+
+// replayAddr is the address of both ends of a Replayer
+type replayAddr struct{}
+
+func (replayAddr) Network() string { return "replay" }
+func (replayAddr) String() string  { return "replay" }
+
+// LocalAddr returns a placeholder address
+func (r *Replayer) LocalAddr() net.Addr { return replayAddr{} }
+
+// RemoteAddr returns a placeholder address
+func (r *Replayer) RemoteAddr() net.Addr { return replayAddr{} }
+
+// SetDeadline is ignored
+func (r *Replayer) SetDeadline(t time.Time) error { return nil }
+
+// SetReadDeadline is ignored
+func (r *Replayer) SetReadDeadline(t time.Time) error { return nil }
+
+// SetWriteDeadline is ignored
+func (r *Replayer) SetWriteDeadline(t time.Time) error { return nil }
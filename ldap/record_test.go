@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordedOperations performs the operations recorded and replayed by the
+// tests, returning the DNs found
+func recordedOperations(t *testing.T, conn *Conn) []string {
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatalf("bind: %s", err)
+	}
+	add := NewAddRequest("uid=bwayne,ou=people,dc=example,dc=com")
+	add.Attribute("uid", []string{"bwayne"})
+	if err := conn.Add(add); err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	result, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=*)", []string{"uid"}, nil))
+	if err != nil {
+		t.Fatalf("search: %s", err)
+	}
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	return dns
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	server, address := startTestBackendServer(t)
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	conn := NewConn(NewRecorder(c, path), false)
+	conn.Start()
+	recorded := recordedOperations(t, conn)
+	conn.Close()
+	server.Close()
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Exchanges) != 3 {
+		t.Fatalf("expected 3 exchanges, got %d", len(cassette.Exchanges))
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = NewConn(replayer, false)
+	conn.Start()
+	defer conn.Close()
+	replayed := recordedOperations(t, conn)
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("expected the recorded entries %v, got %v", recorded, replayed)
+	}
+	if !replayer.Done() {
+		t.Error("expected all the requests to be replayed")
+	}
+
+	if err := conn.Del(NewDelRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)); err == nil || !strings.Contains(err.Error(), "was not recorded") {
+		t.Errorf("expected a request which was not recorded to fail, got %v", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	server, address := startTestBackendServer(t)
+	defer server.Close()
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := NewRecorder(c, os.DevNull)
+	conn := NewConn(recorder, false)
+	conn.Start()
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatalf("bind: %s", err)
+	}
+	conn.Close()
+
+	conn = NewConn(NewCassetteReplayer(&recorder.cassette), false)
+	conn.Start()
+	defer conn.Close()
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "other"); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a request not matching the recorded one to fail, got %v", err)
+	}
+
+	replayer := NewCassetteReplayer(&recorder.cassette)
+	replayer.Match = func(recorded, request []byte) bool {
+		return len(recorded) == len(request)
+	}
+	conn = NewConn(replayer, false)
+	conn.Start()
+	defer conn.Close()
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "123456"); err != nil {
+		t.Errorf("expected the request to match with Match, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+// File contains referral chasing functionality
+//
+// https://tools.ietf.org/html/rfc4511#section-4.1.10
+// https://tools.ietf.org/html/rfc4511#section-4.5.3
+//
+// Referral ::= SEQUENCE SIZE (1..MAX) OF uri URI
+//
+// SearchResultReference ::= [APPLICATION 19] SEQUENCE
+//                           SIZE (1..MAX) OF uri URI
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// DefaultReferralHopLimit is the number of referrals followed in a row when
+// the policy sets no limit
+const DefaultReferralHopLimit = 5
+
+// ReferralPolicy configures how the referrals returned by searches are
+// followed. Continuation references are searched and their entries added to
+// the result, and searches failing with LDAPResultReferral are performed on
+// the first referred server which succeeds. References which cannot be
+// followed are left in the Referrals of the result.
+type ReferralPolicy struct {
+	// HopLimit is the number of referrals followed in a row,
+	// DefaultReferralHopLimit if zero
+	HopLimit int
+	// Dial connects to the server of a referral URL. If nil, the server is
+	// dialed with DialURL and its default options.
+	Dial func(u *URL) (*Conn, error)
+	// Bind authenticates the connection to a referred server. If nil, the
+	// connection is anonymous: credentials are not sent to referred servers
+	// unless the policy decides to do so, e.g. depending on their host.
+	Bind func(conn *Conn, u *URL) error
+}
+
+// SetReferralPolicy makes searches follow referrals according to the given
+// policy. Referrals are not followed if policy is nil, which is the default.
+func (l *Conn) SetReferralPolicy(policy *ReferralPolicy) {
+	l.referralPolicy.Store(policy)
+}
+
+func (l *Conn) loadReferralPolicy() *ReferralPolicy {
+	policy, _ := l.referralPolicy.Load().(*ReferralPolicy)
+	return policy
+}
+
+// decodeReferral returns the URLs of the referral of an LDAPResult
+func decodeReferral(response *asn1.Packet) []string {
+	var urls []string
+	for _, child := range response.Children {
+		if child.ClassType == asn1.ClassContext && child.Tag == 3 {
+			for _, uri := range child.Children {
+				urls = append(urls, asn1.DecodeString(uri.Data.Bytes()))
+			}
+		}
+	}
+	return urls
+}
+
+// chase follows the referrals of the result of the search request, hops
+// being the number of referrals followed to get it and visited holding the
+// searches already performed
+func (p *ReferralPolicy) chase(ctx context.Context, searchRequest *SearchRequest, result *SearchResult, err error, hops int, visited map[string]bool) (*SearchResult, error) {
+	if err != nil && !IsErrorWithCode(err, LDAPResultReferral) {
+		return result, err
+	}
+	if result == nil || len(result.Referrals) == 0 {
+		return result, err
+	}
+	limit := p.HopLimit
+	if limit == 0 {
+		limit = DefaultReferralHopLimit
+	}
+	if hops >= limit {
+		return result, err
+	}
+
+	if err != nil {
+		// the whole search is referred to other servers
+		for _, referral := range result.Referrals {
+			referred, referralErr := p.follow(ctx, referral, searchRequest, false, hops+1, visited)
+			if referralErr == nil {
+				return referred, nil
+			}
+		}
+		return result, err
+	}
+
+	chased := &SearchResult{
+		Entries:   result.Entries,
+		Referrals: make([]string, 0),
+		Controls:  result.Controls,
+	}
+	for _, referral := range result.Referrals {
+		referred, referralErr := p.follow(ctx, referral, searchRequest, true, hops+1, visited)
+		if referralErr != nil {
+			chased.Referrals = append(chased.Referrals, referral)
+			continue
+		}
+		chased.Entries = append(chased.Entries, referred.Entries...)
+		chased.Referrals = append(chased.Referrals, referred.Referrals...)
+	}
+	return chased, nil
+}
+
+// follow performs the search request on the server of the referral URL. A
+// continuation reference of a single level search is searched with the base
+// object scope.
+func (p *ReferralPolicy) follow(ctx context.Context, referral string, searchRequest *SearchRequest, continuation bool, hops int, visited map[string]bool) (*SearchResult, error) {
+	u, err := ParseURL(referral)
+	if err != nil {
+		return nil, err
+	}
+	referred := *searchRequest
+	referred.Controls = nil
+	for _, control := range searchRequest.Controls {
+		// the paging cookie is only valid on the original server
+		if control.GetControlType() != ControlTypePaging {
+			referred.Controls = append(referred.Controls, control)
+		}
+	}
+	dn, _, scope, filter := referralURLParts(referral)
+	if dn != "" {
+		referred.BaseDN = u.BaseDN
+	}
+	if scope != "" {
+		referred.Scope = u.Scope
+	} else if continuation && searchRequest.Scope == ScopeSingleLevel {
+		referred.Scope = ScopeBaseObject
+	}
+	if filter != "" {
+		referred.Filter = u.Filter
+	}
+
+	key := fmt.Sprintf("%s %s %s %d %s", u.Scheme, strings.ToLower(u.Address()), strings.ToLower(referred.BaseDN), referred.Scope, referred.Filter)
+	if visited[key] {
+		return nil, NewError(LDAPResultLoopDetect, fmt.Errorf("ldap: referral loop detected at %s", referral))
+	}
+	visited[key] = true
+
+	dial := p.Dial
+	if dial == nil {
+		dial = func(u *URL) (*Conn, error) {
+			return newDialConfig(nil).dial(u)
+		}
+	}
+	conn, err := dial(u)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if p.Bind != nil {
+		if err := p.Bind(conn, u); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := conn.searchRanged(ctx, &referred)
+	return p.chase(ctx, &referred, result, err, hops, visited)
+}
+
+// referralURLParts returns the raw dn, attributes, scope and filter parts of
+// an LDAP URL, which are empty when absent
+func referralURLParts(rawURL string) (dn, attributes, scope, filter string) {
+	rest := rawURL
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	i := strings.IndexByte(rest, '/')
+	if i < 0 {
+		return
+	}
+	parts := strings.Split(rest[i+1:], "?")
+	dn = parts[0]
+	if len(parts) > 1 {
+		attributes = parts[1]
+	}
+	if len(parts) > 2 {
+		scope = parts[2]
+	}
+	if len(parts) > 3 {
+		filter = parts[3]
+	}
+	return
+}
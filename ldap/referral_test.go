@@ -0,0 +1,193 @@
+package ldap
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// serveSearches accepts connections answering binds with success and
+// searches with the packets returned by respond for the search request
+func serveSearches(ln net.Listener, respond func(messageID int64, request *asn1.Packet) []*asn1.Packet) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			for {
+				request, err := asn1.ReadPacket(c)
+				if err != nil {
+					return
+				}
+				messageID := request.Children[0].Value.(int64)
+				switch request.Children[1].Tag {
+				case ApplicationBindRequest:
+					c.Write(newResultPacket(messageID, ApplicationBindResponse, LDAPResultSuccess, "").Bytes())
+				case ApplicationSearchRequest:
+					for _, response := range respond(messageID, request.Children[1]) {
+						c.Write(response.Bytes())
+					}
+				}
+			}
+		}(c)
+	}
+}
+
+// newReferencePacket returns a SearchResultReference packet holding the URL
+func newReferencePacket(messageID int64, url string) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	reference := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultReference, nil, "Search Result Reference")
+	reference.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, url, "URI"))
+	packet.AppendChild(reference)
+	return packet
+}
+
+// newReferralPacket returns a SearchResultDone packet referring to the URL
+func newReferralPacket(messageID int64, url string) *asn1.Packet {
+	packet := newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultReferral, "")
+	response := packet.Children[1]
+	referral := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 3, nil, "Referral")
+	referral.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, url, "URI"))
+	response.AppendChild(referral)
+
+	// rebuild the envelope, as appending copies the child
+	rebuilt := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	rebuilt.AppendChild(packet.Children[0])
+	rebuilt.AppendChild(response)
+	return rebuilt
+}
+
+func newReferralListener(t *testing.T) (net.Listener, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln, "ldap://" + ln.Addr().String()
+}
+
+func entryDNs(result *SearchResult) []string {
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	sort.Strings(dns)
+	return dns
+}
+
+func TestReferralChasing(t *testing.T) {
+	lnA, urlA := newReferralListener(t)
+	defer lnA.Close()
+	lnB, urlB := newReferralListener(t)
+	defer lnB.Close()
+
+	go serveSearches(lnA, func(messageID int64, request *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{
+			newEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+			newReferencePacket(messageID, urlB+"/ou=b,dc=example,dc=com"),
+			newReferencePacket(messageID, "ldap://127.0.0.1:1/ou=c,dc=example,dc=com"),
+			newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+		}
+	})
+	bases := make(chan string, 10)
+	go serveSearches(lnB, func(messageID int64, request *asn1.Packet) []*asn1.Packet {
+		baseDN := request.Children[0].Value.(string)
+		bases <- baseDN
+		return []*asn1.Packet{
+			newEntryPacket(messageID, "cn=b,"+baseDN),
+			// refers back to a search already performed
+			newReferencePacket(messageID, urlB+"/ou=b,dc=example,dc=com"),
+			newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+		}
+	})
+
+	conn, err := DialURL(urlA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	binds := 0
+	conn.SetReferralPolicy(&ReferralPolicy{
+		Bind: func(c *Conn, u *URL) error {
+			binds++
+			return c.Bind("cn=admin,dc=example,dc=com", "secret")
+		},
+	})
+
+	runWithTimeout(t, 5*time.Second, func() {
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		result, err := conn.Search(searchRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dns := entryDNs(result); len(dns) != 2 || dns[0] != "cn=a,dc=example,dc=com" || dns[1] != "cn=b,ou=b,dc=example,dc=com" {
+			t.Errorf("unexpected entries %v", dns)
+		}
+		if len(result.Referrals) != 2 {
+			t.Errorf("expected the unreachable and looping references, got %v", result.Referrals)
+		}
+		if base := <-bases; base != "ou=b,dc=example,dc=com" {
+			t.Errorf("got base DN %q", base)
+		}
+		if binds != 1 {
+			t.Errorf("got %d binds, expected 1", binds)
+		}
+	})
+}
+
+func TestReferralResult(t *testing.T) {
+	lnA, urlA := newReferralListener(t)
+	defer lnA.Close()
+	lnB, urlB := newReferralListener(t)
+	defer lnB.Close()
+
+	go serveSearches(lnA, func(messageID int64, request *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newReferralPacket(messageID, urlB)}
+	})
+	go serveSearches(lnB, func(messageID int64, request *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{
+			newEntryPacket(messageID, "cn=b,"+request.Children[0].Value.(string)),
+			newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+		}
+	})
+
+	conn, err := DialURL(urlA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+		// referrals are not followed by default
+		result, err := conn.Search(searchRequest)
+		if !IsErrorWithCode(err, LDAPResultReferral) {
+			t.Fatalf("expected referral error, got %v", err)
+		}
+		if len(result.Referrals) != 1 || result.Referrals[0] != urlB {
+			t.Errorf("unexpected referrals %v", result.Referrals)
+		}
+
+		conn.SetReferralPolicy(&ReferralPolicy{})
+		result, err = conn.Search(searchRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dns := entryDNs(result); len(dns) != 1 || dns[0] != "cn=b,dc=example,dc=com" {
+			t.Errorf("unexpected entries %v", dns)
+		}
+
+		// the hop limit stops the chase
+		conn.SetReferralPolicy(&ReferralPolicy{HopLimit: -1})
+		if _, err := conn.Search(searchRequest); !IsErrorWithCode(err, LDAPResultReferral) {
+			t.Errorf("expected referral error, got %v", err)
+		}
+	})
+}
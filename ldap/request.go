@@ -0,0 +1,148 @@
+// File contains the envelope shared by the operations answered with a single
+// LDAPResult
+//
+// https://tools.ietf.org/html/rfc4511#section-4.1.9
+//
+// LDAPResult ::= SEQUENCE {
+//      resultCode         ENUMERATED { ... },
+//      matchedDN          LDAPDN,
+//      diagnosticMessage  LDAPString,
+//      referral           [3] Referral OPTIONAL }
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// Request is an operation which can be performed with Do. It is implemented
+// by AddRequest, DelRequest, ModifyRequest, ModifyDNRequest, SimpleBindRequest,
+// ExtendedRequest and PasswordModifyRequest.
+type Request interface {
+	// encode returns the protocol operation of the request
+	encode() *asn1.Packet
+	// controls returns the controls to send with the request
+	controls() []Control
+	// responseTag returns the application tag of the expected response
+	responseTag() asn1.Tag
+}
+
+// Response holds the LDAPResult sent back by the server for a Request
+type Response struct {
+	// ResultCode is the result code of the operation
+	ResultCode uint8
+	// MatchedDN is the DN of the last entry found while resolving the target
+	// of the operation, if it could not be found
+	MatchedDN string
+	// DiagnosticMessage is the message sent by the server, if any
+	DiagnosticMessage string
+	// Referrals are the URLs sent with a referral result code
+	Referrals []string
+	// Controls are the controls returned with the response
+	Controls []Control
+	// Packet is the response message, holding the fields specific to the
+	// operation
+	Packet *asn1.Packet
+}
+
+// Do sends the request and waits for its response
+func (l *Conn) Do(request Request) (*Response, error) {
+	return l.DoContext(context.Background(), request)
+}
+
+// DoContext sends the request and waits for its response. If the result code
+// is not a success, the response is returned together with an *Error holding
+// it. If ctx is done before the server responds, the request is abandoned, or
+// the connection closed if it is a bind, and ctx.Err() is returned.
+func (l *Conn) DoContext(ctx context.Context, request Request) (*Response, error) {
+	result, err := l.intercept(ctx, request)
+	response, _ := result.(*Response)
+	return response, err
+}
+
+// doContext performs the request once it went through the interceptors
+func (l *Conn) doContext(ctx context.Context, request Request) (*Response, error) {
+	operation := encodeRequest(request, bool(l.Debug) || l.loadSendHook() != nil)
+	ctx, span := l.startSpan(ctx, operationName(operation.Tag), requestAttributes(request))
+	response, err := l.do(ctx, request, operation)
+	resultCode := resultCodeOf(err)
+	if response != nil {
+		resultCode = response.ResultCode
+	}
+	span.End(resultCode, err)
+	return response, err
+}
+
+// do sends the encoded operation of the request and waits for its response
+func (l *Conn) do(ctx context.Context, request Request, operation *asn1.Packet) (*Response, error) {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
+	packet.AppendChild(operation)
+	if controls := request.controls(); len(controls) > 0 {
+		packet.AppendChild(encodeControls(controls))
+	}
+
+	l.Debug.PrintPacket(packet)
+
+	msgCtx, err := l.sendMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	defer l.finishMessage(msgCtx)
+
+	l.Debug.Printf("%d: waiting for response", msgCtx.id)
+	packet, err = l.receivePacket(ctx, msgCtx)
+	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	if err != nil {
+		return nil, err
+	}
+	if packet == nil {
+		return nil, NewError(ErrorNetwork, errors.New("ldap: could not retrieve message"))
+	}
+
+	if l.Debug {
+		if err := addLDAPDescriptions(packet); err != nil {
+			return nil, err
+		}
+		asn1.PrintPacket(packet)
+	}
+
+	if packet.Children[1].Tag != request.responseTag() {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("Unexpected Response: %d", packet.Children[1].Tag))
+	}
+
+	response := decodeResponse(packet)
+	switch response.ResultCode {
+	case LDAPResultSuccess, LDAPResultCompareFalse, LDAPResultCompareTrue:
+		return response, nil
+	}
+	return response, newResultError(packet)
+}
+
+// decodeResponse returns the LDAPResult and the controls held by the packet
+func decodeResponse(packet *asn1.Packet) *Response {
+	response := &Response{
+		Controls: make([]Control, 0),
+		Packet:   packet,
+	}
+	response.ResultCode, _ = getLDAPResultCode(packet)
+	if packet == nil || len(packet.Children) < 2 {
+		return response
+	}
+	result := packet.Children[1]
+	if len(result.Children) >= 3 {
+		response.MatchedDN, _ = result.Children[1].Value.(string)
+		response.DiagnosticMessage, _ = result.Children[2].Value.(string)
+	}
+	response.Referrals = decodeReferral(result)
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			response.Controls = append(response.Controls, DecodeControl(child))
+		}
+	}
+	return response
+}
@@ -0,0 +1,57 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestDo(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if p.Children[1].Tag != ApplicationDelRequest || len(p.Children) != 3 {
+				t.Errorf("unexpected request %v", p.Children[1].Tag)
+			}
+			response := newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "deleted")
+			response.AppendChild(encodeControls([]Control{NewControlManageDsaIT(false)}))
+			return []*asn1.Packet{response}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationModifyDNResponse, LDAPResultNoSuchObject, "no such entry")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationAddResponse, LDAPResultSuccess, "")}
+		})
+	}()
+
+	response, err := conn.Do(NewDelRequest("cn=x,dc=example,dc=com", []Control{NewControlManageDsaIT(false)}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response.ResultCode != LDAPResultSuccess || response.DiagnosticMessage != "deleted" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if FindControl(response.Controls, ControlTypeManageDsaIT) == nil {
+		t.Errorf("expected response controls, got %v", response.Controls)
+	}
+
+	response, err = conn.Do(NewModifyDNRequest("cn=x,dc=example,dc=com", "cn=y", true, ""))
+	if !IsNoSuchObject(err) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+	if response == nil || response.DiagnosticMessage != "no such entry" {
+		t.Errorf("expected the response with the error, got %+v", response)
+	}
+
+	// the response does not match the request
+	if _, err := conn.Do(NewDelRequest("cn=x,dc=example,dc=com", nil)); !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+		t.Errorf("expected unexpected response error, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// File contains the retry of operations failing with transient errors
+
+package ldap
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Defaults of the fields of a RetryPolicy
+const (
+	DefaultRetryAttempts   = 3
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 5 * time.Second
+)
+
+// DefaultRetryableCodes are the result codes retried by a RetryPolicy if
+// none are given, for which the server did not perform the operation
+var DefaultRetryableCodes = []uint8{LDAPResultBusy, LDAPResultUnavailable}
+
+// RetryPolicy retries the operations failing with a transient error, waiting
+// for an exponential backoff with jitter between the attempts. It is applied
+// to the operations of a connection as an interceptor:
+//
+//	conn.Use(ldap.RetryPolicy{MaxAttempts: 5}.Interceptor())
+//
+// or to any operation, e.g. on a Balancer, with Do. Only the result codes
+// for which the server did not perform the operation should be retried, as
+// the operations are not all idempotent.
+type RetryPolicy struct {
+	// MaxAttempts is how many times an operation is attempted, including the
+	// first attempt, DefaultRetryAttempts if zero
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled for each
+	// following one, DefaultRetryBackoff if zero. Each wait is chosen at
+	// random between half and all of the backoff.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the backoff, DefaultRetryMaxBackoff if zero
+	MaxBackoff time.Duration
+	// RetryableCodes are the result codes of the errors retried,
+	// DefaultRetryableCodes if nil
+	RetryableCodes []uint8
+}
+
+// Do performs the operation, retrying it while it fails with a retryable
+// error. If ctx is done while waiting before a retry, ctx.Err() is returned.
+func (p RetryPolicy) Do(ctx context.Context, operation func(ctx context.Context) error) error {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultRetryAttempts
+	}
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+	codes := p.RetryableCodes
+	if codes == nil {
+		codes = DefaultRetryableCodes
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := operation(ctx)
+		if err == nil || attempt >= attempts || !IsErrorWithCode(err, codes...) {
+			return err
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// Interceptor returns an interceptor retrying the operations of a connection
+// according to the policy
+func (p RetryPolicy) Interceptor() Interceptor {
+	return func(next OpFunc) OpFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var result interface{}
+			err := p.Do(ctx, func(ctx context.Context) error {
+				var err error
+				result, err = next(ctx, request)
+				return err
+			})
+			return result, err
+		}
+	}
+}
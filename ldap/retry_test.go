@@ -0,0 +1,86 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	busy := NewError(LDAPResultBusy, errors.New("busy"))
+	noSuchObject := NewError(LDAPResultNoSuchObject, errors.New("no such object"))
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := []struct {
+		name     string
+		errors   []error
+		attempts int
+		err      error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"retried", []error{busy, busy, nil}, 3, nil},
+		{"exhausted", []error{busy, busy, busy, nil}, 3, busy},
+		{"not retryable", []error{noSuchObject, nil}, 1, noSuchObject},
+	}
+	for _, test := range tests {
+		attempts := 0
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return test.errors[attempts-1]
+		})
+		if attempts != test.attempts {
+			t.Errorf("%s: got %d attempts, expected %d", test.name, attempts, test.attempts)
+		}
+		if err != test.err {
+			t.Errorf("%s: got error %v, expected %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestRetryPolicyContext(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	runWithTimeout(t, 5*time.Second, func() {
+		err := policy.Do(ctx, func(ctx context.Context) error {
+			attempts++
+			return NewError(LDAPResultUnavailable, errors.New("unavailable"))
+		})
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the error of the context, got %v", err)
+		}
+	})
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRetryPolicyInterceptor(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	conn.Use(RetryPolicy{InitialBackoff: time.Millisecond}.Interceptor())
+
+	go func() {
+		for _, resultCode := range []int{LDAPResultBusy, LDAPResultSuccess} {
+			code := resultCode
+			serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+				return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, code, "")}
+			})
+		}
+	}()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+}
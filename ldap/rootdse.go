@@ -0,0 +1,107 @@
+// File contains the rootDSE
+//
+// https://tools.ietf.org/html/rfc4512#section-5.1
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// RootDSE holds the information about the server published by the entry with
+// the empty DN
+type RootDSE struct {
+	// NamingContexts are the DNs of the trees held by the server
+	NamingContexts []string `ldap:"namingContexts"`
+	// DefaultNamingContext is the DN of the domain of an Active Directory
+	// server
+	DefaultNamingContext string `ldap:"defaultNamingContext"`
+	// SubschemaSubentry is the DN of the entry holding the schema
+	SubschemaSubentry string `ldap:"subschemaSubentry"`
+	// SupportedControl are the OIDs of the supported controls
+	SupportedControl []string `ldap:"supportedControl"`
+	// SupportedExtension are the OIDs of the supported extended operations
+	SupportedExtension []string `ldap:"supportedExtension"`
+	// SupportedFeatures are the OIDs of the supported features, such as
+	// the all operational attributes feature
+	SupportedFeatures []string `ldap:"supportedFeatures"`
+	// SupportedLDAPVersion are the supported protocol versions
+	SupportedLDAPVersion []string `ldap:"supportedLDAPVersion"`
+	// SupportedSASLMechanisms are the names of the supported SASL mechanisms
+	SupportedSASLMechanisms []string `ldap:"supportedSASLMechanisms"`
+	// VendorName is the name of the vendor of the server, if published
+	VendorName string `ldap:"vendorName"`
+	// VendorVersion is the version of the server, if published
+	VendorVersion string `ldap:"vendorVersion"`
+	// Entry is the entry read, holding the other attributes of the rootDSE
+	Entry *Entry
+}
+
+// rootDSEAttributes are the attributes requested from the rootDSE, which are
+// operational and not returned unless named
+var rootDSEAttributes = []string{
+	"*",
+	"namingContexts",
+	"subschemaSubentry",
+	"supportedControl",
+	"supportedExtension",
+	"supportedFeatures",
+	"supportedLDAPVersion",
+	"supportedSASLMechanisms",
+	"vendorName",
+	"vendorVersion",
+}
+
+// RootDSE reads the rootDSE of the server
+func (l *Conn) RootDSE() (*RootDSE, error) {
+	return l.RootDSEContext(context.Background())
+}
+
+// RootDSEContext reads the rootDSE of the server. If ctx is done before the
+// server responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) RootDSEContext(ctx context.Context) (*RootDSE, error) {
+	searchRequest := NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", rootDSEAttributes, nil)
+	result, err := l.SearchContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, NewError(LDAPResultNoSuchObject, errors.New("ldap: rootDSE not returned"))
+	}
+	rootDSE := &RootDSE{Entry: result.Entries[0]}
+	if err := rootDSE.Entry.Unmarshal(rootDSE); err != nil {
+		return nil, err
+	}
+	return rootDSE, nil
+}
+
+// SupportsControl returns true if the server supports the control
+func (r *RootDSE) SupportsControl(controlType string) bool {
+	return containsFold(r.SupportedControl, controlType)
+}
+
+// SupportsExtension returns true if the server supports the extended operation
+func (r *RootDSE) SupportsExtension(oid string) bool {
+	return containsFold(r.SupportedExtension, oid)
+}
+
+// SupportsFeature returns true if the server supports the feature
+func (r *RootDSE) SupportsFeature(oid string) bool {
+	return containsFold(r.SupportedFeatures, oid)
+}
+
+// SupportsSASLMechanism returns true if the server supports the SASL mechanism
+func (r *RootDSE) SupportsSASLMechanism(mechanism string) bool {
+	return containsFold(r.SupportedSASLMechanisms, mechanism)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestRootDSE(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		if baseDN := p.Children[1].Children[0].Value.(string); baseDN != "" {
+			t.Errorf("got base DN %q", baseDN)
+		}
+		messageID := p.Children[0].Value.(int64)
+		return []*asn1.Packet{
+			newEntryPacket(messageID, "",
+				NewEntryAttribute("namingContexts", []string{"dc=example,dc=com"}),
+				NewEntryAttribute("supportedControl", []string{ControlTypePaging, ControlTypeManageDsaIT}),
+				NewEntryAttribute("supportedExtension", []string{whoAmIOID}),
+				NewEntryAttribute("supportedSASLMechanisms", []string{"EXTERNAL", "SCRAM-SHA-256"}),
+				NewEntryAttribute("supportedLDAPVersion", []string{"3"}),
+				NewEntryAttribute("vendorName", []string{"Example"}),
+				NewEntryAttribute("objectClass", []string{"top"}),
+			),
+			newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+		}
+	})
+
+	runWithTimeout(t, 5*time.Second, func() {
+		rootDSE, err := conn.RootDSE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rootDSE.NamingContexts) != 1 || rootDSE.NamingContexts[0] != "dc=example,dc=com" || rootDSE.VendorName != "Example" {
+			t.Errorf("unexpected rootDSE %+v", rootDSE)
+		}
+		if !rootDSE.SupportsControl(ControlTypePaging) || rootDSE.SupportsControl(ControlTypeVChuPasswordMustChange) {
+			t.Errorf("unexpected supported controls %v", rootDSE.SupportedControl)
+		}
+		if !rootDSE.SupportsExtension(whoAmIOID) || !rootDSE.SupportsSASLMechanism("scram-sha-256") || rootDSE.SupportsFeature("1.3.6.1.4.1.4203.1.5.1") {
+			t.Errorf("unexpected supported features %+v", rootDSE)
+		}
+		if rootDSE.Entry.GetAttributeValue("objectClass") != "top" {
+			t.Errorf("expected the entry of the rootDSE, got %v", rootDSE.Entry)
+		}
+	})
+}
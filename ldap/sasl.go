@@ -0,0 +1,179 @@
+// File contains SASL bind functionality
+//
+// https://tools.ietf.org/html/rfc4513#section-5.2
+//
+// AuthenticationChoice ::= CHOICE {
+//      simple                  [0] OCTET STRING,
+//      sasl                    [3] SaslCredentials,
+//      ...  }
+//
+// SaslCredentials ::= SEQUENCE {
+//      mechanism               LDAPString,
+//      credentials             OCTET STRING OPTIONAL }
+//
+// BindResponse ::= [APPLICATION 1] SEQUENCE {
+//      COMPONENTS OF LDAPResult,
+//      serverSaslCreds    [7] OCTET STRING OPTIONAL }
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// SASLMechanism implements the client side of a SASL mechanism, as described
+// in https://tools.ietf.org/html/rfc4422
+type SASLMechanism interface {
+	// Name returns the name of the mechanism, e.g. "DIGEST-MD5"
+	Name() string
+	// Start returns the initial response sent with the first bind request, or
+	// nil if the mechanism has none
+	Start() ([]byte, error)
+	// Next returns the response to the given server challenge. If the server
+	// accepts the bind with additional data, such as a server signature, Next
+	// is called once more with that data and its response is discarded.
+	Next(challenge []byte) ([]byte, error)
+}
+
+// saslVerifier is implemented by the mechanisms which authenticate the
+// server, so that a bind accepted before the server proved its identity fails
+type saslVerifier interface {
+	verified() error
+}
+
+// SASLBindResult contains the response from the server
+type SASLBindResult struct {
+	Controls []Control
+}
+
+// bindResponse holds the parts of a bind response needed by multi-step binds
+type bindResponse struct {
+	resultCode        uint8
+	err               error
+	matchedDN         []byte
+	serverCredentials []byte
+	controls          []Control
+}
+
+// SASLBind performs a bind with the given SASL mechanism
+func (l *Conn) SASLBind(mechanism SASLMechanism, controls []Control) (*SASLBindResult, error) {
+	return l.SASLBindContext(context.Background(), mechanism, controls)
+}
+
+// SASLBindContext performs a bind with the given SASL mechanism, exchanging
+// bind requests with the server until it accepts or rejects the credentials.
+// If ctx is done before the server responds, the connection is closed and
+// ctx.Err() is returned, as a bind cannot be abandoned.
+func (l *Conn) SASLBindContext(ctx context.Context, mechanism SASLMechanism, controls []Control) (*SASLBindResult, error) {
+	credentials, err := mechanism.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		response, err := l.bindRound(ctx, encodeSASLCredentials(mechanism.Name(), credentials), controls)
+		if err != nil {
+			return nil, err
+		}
+		result := &SASLBindResult{
+			Controls: response.controls,
+		}
+
+		switch response.resultCode {
+		case LDAPResultSaslBindInProgress:
+			credentials, err = mechanism.Next(response.serverCredentials)
+			if err != nil {
+				return result, err
+			}
+		case LDAPResultSuccess:
+			if response.serverCredentials != nil {
+				if _, err := mechanism.Next(response.serverCredentials); err != nil {
+					return result, err
+				}
+			}
+			if verifier, ok := mechanism.(saslVerifier); ok {
+				if err := verifier.verified(); err != nil {
+					return result, err
+				}
+			}
+			return result, nil
+		default:
+			return result, response.err
+		}
+	}
+}
+
+// encodeSASLCredentials returns the sasl choice of the authentication of a bind request
+func encodeSASLCredentials(mechanism string, credentials []byte) *asn1.Packet {
+	auth := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 3, nil, "SASL Credentials")
+	auth.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, mechanism, "Mechanism"))
+	if credentials != nil {
+		auth.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(credentials), "Credentials"))
+	}
+	return auth
+}
+
+// bindRound sends a single anonymous bind request with the given
+// authentication choice and returns the response
+func (l *Conn) bindRound(ctx context.Context, authentication *asn1.Packet, controls []Control) (*bindResponse, error) {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
+
+	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
+	request.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 3, "Version"))
+	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "User Name"))
+	request.AppendChild(authentication)
+	packet.AppendChild(request)
+	if len(controls) > 0 {
+		packet.AppendChild(encodeControls(controls))
+	}
+
+	l.Debug.PrintPacket(packet)
+
+	msgCtx, err := l.sendMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	defer l.finishMessage(msgCtx)
+
+	l.Debug.Printf("%d: waiting for response", msgCtx.id)
+	packet, err = l.receivePacket(ctx, msgCtx)
+	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.Debug {
+		if err := addLDAPDescriptions(packet); err != nil {
+			return nil, err
+		}
+		asn1.PrintPacket(packet)
+	}
+
+	if packet.Children[1].Tag != ApplicationBindResponse {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("Unexpected Response: %d", packet.Children[1].Tag))
+	}
+
+	response := &bindResponse{
+		controls: make([]Control, 0),
+	}
+	response.resultCode, _ = getLDAPResultCode(packet)
+	response.err = newResultError(packet)
+	if len(packet.Children[1].Children) > 1 {
+		response.matchedDN = packet.Children[1].Children[1].Data.Bytes()
+	}
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType == asn1.ClassContext && child.Tag == 7 {
+			response.serverCredentials = child.Data.Bytes()
+		}
+	}
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			response.controls = append(response.controls, DecodeControl(child))
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,474 @@
+// File contains the subschema
+//
+// https://tools.ietf.org/html/rfc4512#section-4
+//
+// AttributeTypeDescription = LPAREN WSP
+//     numericoid                    ; object identifier
+//     [ SP "NAME" SP qdescrs ]      ; short names (descriptors)
+//     [ SP "DESC" SP qdstring ]     ; description
+//     [ SP "OBSOLETE" ]             ; not active
+//     [ SP "SUP" SP oid ]           ; supertype
+//     [ SP "EQUALITY" SP oid ]      ; equality matching rule
+//     [ SP "ORDERING" SP oid ]      ; ordering matching rule
+//     [ SP "SUBSTR" SP oid ]        ; substrings matching rule
+//     [ SP "SYNTAX" SP noidlen ]    ; value syntax
+//     [ SP "SINGLE-VALUE" ]         ; single-value
+//     [ SP "COLLECTIVE" ]           ; collective
+//     [ SP "NO-USER-MODIFICATION" ] ; not user modifiable
+//     [ SP "USAGE" SP usage ]       ; usage
+//     extensions WSP RPAREN         ; extensions
+//
+// ObjectClassDescription = LPAREN WSP
+//     numericoid                 ; object identifier
+//     [ SP "NAME" SP qdescrs ]   ; short names (descriptors)
+//     [ SP "DESC" SP qdstring ]  ; description
+//     [ SP "OBSOLETE" ]          ; not active
+//     [ SP "SUP" SP oids ]       ; superior object classes
+//     [ SP kind ]                ; kind of class
+//     [ SP "MUST" SP oids ]      ; attribute types
+//     [ SP "MAY" SP oids ]       ; attribute types
+//     extensions WSP RPAREN
+//
+// MatchingRuleDescription = LPAREN WSP
+//     numericoid                 ; object identifier
+//     [ SP "NAME" SP qdescrs ]   ; short names (descriptors)
+//     [ SP "DESC" SP qdstring ]  ; description
+//     [ SP "OBSOLETE" ]          ; not active
+//     SP "SYNTAX" SP numericoid  ; assertion syntax
+//     extensions WSP RPAREN      ; extensions
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Object class kinds
+const (
+	ObjectClassStructural = "STRUCTURAL"
+	ObjectClassAbstract   = "ABSTRACT"
+	ObjectClassAuxiliary  = "AUXILIARY"
+)
+
+// Attribute type usages
+const (
+	UsageUserApplications     = "userApplications"
+	UsageDirectoryOperation   = "directoryOperation"
+	UsageDistributedOperation = "distributedOperation"
+	UsageDSAOperation         = "dSAOperation"
+)
+
+// DefaultSubschemaDN is the DN of the subschema read when the rootDSE does not
+// name one
+const DefaultSubschemaDN = "cn=subschema"
+
+// AttributeType is the definition of an attribute type
+type AttributeType struct {
+	// OID is the object identifier of the attribute type
+	OID string
+	// Names are the short names of the attribute type, such as "cn" and
+	// "commonName"
+	Names []string
+	// Description is the description of the attribute type
+	Description string
+	// Obsolete is true if the attribute type is not active
+	Obsolete bool
+	// Superior is the name or OID of the supertype
+	Superior string
+	// Equality is the name or OID of the equality matching rule
+	Equality string
+	// Ordering is the name or OID of the ordering matching rule
+	Ordering string
+	// Substring is the name or OID of the substrings matching rule
+	Substring string
+	// Syntax is the OID of the syntax of the values
+	Syntax string
+	// SyntaxLength is the suggested maximum length of the values, or 0
+	SyntaxLength int
+	// SingleValue is true if the attribute holds at most one value
+	SingleValue bool
+	// Collective is true for collective attributes
+	Collective bool
+	// NoUserModification is true if the values cannot be modified by clients
+	NoUserModification bool
+	// Usage is the usage of the attribute type, UsageUserApplications if not
+	// defined
+	Usage string
+	// Extensions hold the values of the X- extensions of the definition
+	Extensions map[string][]string
+}
+
+// ObjectClass is the definition of an object class
+type ObjectClass struct {
+	// OID is the object identifier of the object class
+	OID string
+	// Names are the short names of the object class
+	Names []string
+	// Description is the description of the object class
+	Description string
+	// Obsolete is true if the object class is not active
+	Obsolete bool
+	// Superiors are the names or OIDs of the superior object classes
+	Superiors []string
+	// Kind is ObjectClassStructural, ObjectClassAbstract or
+	// ObjectClassAuxiliary
+	Kind string
+	// Must are the names or OIDs of the required attribute types
+	Must []string
+	// May are the names or OIDs of the allowed attribute types
+	May []string
+	// Extensions hold the values of the X- extensions of the definition
+	Extensions map[string][]string
+}
+
+// MatchingRule is the definition of a matching rule
+type MatchingRule struct {
+	// OID is the object identifier of the matching rule
+	OID string
+	// Names are the short names of the matching rule
+	Names []string
+	// Description is the description of the matching rule
+	Description string
+	// Obsolete is true if the matching rule is not active
+	Obsolete bool
+	// Syntax is the OID of the syntax of the assertion values
+	Syntax string
+	// Extensions hold the values of the X- extensions of the definition
+	Extensions map[string][]string
+}
+
+// Schema holds the definitions of a subschema entry
+type Schema struct {
+	// DN is the DN of the subschema entry
+	DN string
+	// AttributeTypes are the definitions of the attribute types
+	AttributeTypes []*AttributeType
+	// ObjectClasses are the definitions of the object classes
+	ObjectClasses []*ObjectClass
+	// MatchingRules are the definitions of the matching rules
+	MatchingRules []*MatchingRule
+
+	attributeTypes map[string]*AttributeType
+	objectClasses  map[string]*ObjectClass
+	matchingRules  map[string]*MatchingRule
+}
+
+// subschemaAttributes are the attributes requested from the subschema entry,
+// which are operational and not returned unless named
+var subschemaAttributes = []string{"attributeTypes", "objectClasses", "matchingRules"}
+
+// Schema reads the subschema named by the rootDSE
+func (l *Conn) Schema() (*Schema, error) {
+	return l.SchemaContext(context.Background())
+}
+
+// SchemaContext reads the subschema named by the rootDSE, or
+// DefaultSubschemaDN. If ctx is done before the server responds, the request
+// is abandoned and ctx.Err() is returned.
+func (l *Conn) SchemaContext(ctx context.Context) (*Schema, error) {
+	dn := DefaultSubschemaDN
+	if rootDSE, err := l.RootDSEContext(ctx); err == nil && rootDSE.SubschemaSubentry != "" {
+		dn = rootDSE.SubschemaSubentry
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	searchRequest := NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=subschema)", subschemaAttributes, nil)
+	result, err := l.SearchContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, NewError(LDAPResultNoSuchObject, fmt.Errorf("ldap: subschema %s not returned", dn))
+	}
+	return ParseSchema(result.Entries[0])
+}
+
+// ParseSchema parses the definitions held by a subschema entry
+func ParseSchema(entry *Entry) (*Schema, error) {
+	schema := &Schema{DN: entry.DN}
+	for _, definition := range entry.getAttributeValues("attributeTypes") {
+		attributeType, err := ParseAttributeType(definition)
+		if err != nil {
+			return nil, err
+		}
+		schema.AttributeTypes = append(schema.AttributeTypes, attributeType)
+	}
+	for _, definition := range entry.getAttributeValues("objectClasses") {
+		objectClass, err := ParseObjectClass(definition)
+		if err != nil {
+			return nil, err
+		}
+		schema.ObjectClasses = append(schema.ObjectClasses, objectClass)
+	}
+	for _, definition := range entry.getAttributeValues("matchingRules") {
+		matchingRule, err := ParseMatchingRule(definition)
+		if err != nil {
+			return nil, err
+		}
+		schema.MatchingRules = append(schema.MatchingRules, matchingRule)
+	}
+	schema.index()
+	return schema, nil
+}
+
+// getAttributeValues returns the values of the attribute, ignoring case
+func (e *Entry) getAttributeValues(name string) []string {
+	if attr := e.getAttribute(name); attr != nil {
+		return attr.Values
+	}
+	return nil
+}
+
+// index maps the names and OIDs of the definitions to them
+func (s *Schema) index() {
+	s.attributeTypes = make(map[string]*AttributeType)
+	for _, attributeType := range s.AttributeTypes {
+		s.attributeTypes[strings.ToLower(attributeType.OID)] = attributeType
+		for _, name := range attributeType.Names {
+			s.attributeTypes[strings.ToLower(name)] = attributeType
+		}
+	}
+	s.objectClasses = make(map[string]*ObjectClass)
+	for _, objectClass := range s.ObjectClasses {
+		s.objectClasses[strings.ToLower(objectClass.OID)] = objectClass
+		for _, name := range objectClass.Names {
+			s.objectClasses[strings.ToLower(name)] = objectClass
+		}
+	}
+	s.matchingRules = make(map[string]*MatchingRule)
+	for _, matchingRule := range s.MatchingRules {
+		s.matchingRules[strings.ToLower(matchingRule.OID)] = matchingRule
+		for _, name := range matchingRule.Names {
+			s.matchingRules[strings.ToLower(name)] = matchingRule
+		}
+	}
+}
+
+// AttributeType returns the attribute type with the given name or OID, ignoring
+// case and options such as ";binary", or nil
+func (s *Schema) AttributeType(name string) *AttributeType {
+	if s.attributeTypes == nil {
+		s.index()
+	}
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	return s.attributeTypes[strings.ToLower(name)]
+}
+
+// ObjectClass returns the object class with the given name or OID, ignoring
+// case, or nil
+func (s *Schema) ObjectClass(name string) *ObjectClass {
+	if s.objectClasses == nil {
+		s.index()
+	}
+	return s.objectClasses[strings.ToLower(name)]
+}
+
+// MatchingRule returns the matching rule with the given name or OID, ignoring
+// case, or nil
+func (s *Schema) MatchingRule(name string) *MatchingRule {
+	if s.matchingRules == nil {
+		s.index()
+	}
+	return s.matchingRules[strings.ToLower(name)]
+}
+
+// ParseAttributeType parses an attribute type definition
+func ParseAttributeType(definition string) (*AttributeType, error) {
+	d, err := parseSchemaDefinition(definition, "OBSOLETE", "SINGLE-VALUE", "COLLECTIVE", "NO-USER-MODIFICATION")
+	if err != nil {
+		return nil, err
+	}
+	attributeType := &AttributeType{
+		OID:                d.oid,
+		Names:              d.fields["NAME"],
+		Description:        d.first("DESC"),
+		Obsolete:           d.flags["OBSOLETE"],
+		Superior:           d.first("SUP"),
+		Equality:           d.first("EQUALITY"),
+		Ordering:           d.first("ORDERING"),
+		Substring:          d.first("SUBSTR"),
+		Syntax:             d.first("SYNTAX"),
+		SingleValue:        d.flags["SINGLE-VALUE"],
+		Collective:         d.flags["COLLECTIVE"],
+		NoUserModification: d.flags["NO-USER-MODIFICATION"],
+		Usage:              d.first("USAGE"),
+		Extensions:         d.extensions,
+	}
+	if i := strings.IndexByte(attributeType.Syntax, '{'); i >= 0 {
+		length, err := strconv.Atoi(strings.TrimSuffix(attributeType.Syntax[i+1:], "}"))
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid syntax length in %q", definition)
+		}
+		attributeType.Syntax, attributeType.SyntaxLength = attributeType.Syntax[:i], length
+	}
+	if attributeType.Usage == "" {
+		attributeType.Usage = UsageUserApplications
+	}
+	return attributeType, nil
+}
+
+// ParseObjectClass parses an object class definition
+func ParseObjectClass(definition string) (*ObjectClass, error) {
+	d, err := parseSchemaDefinition(definition, "OBSOLETE", ObjectClassStructural, ObjectClassAbstract, ObjectClassAuxiliary)
+	if err != nil {
+		return nil, err
+	}
+	objectClass := &ObjectClass{
+		OID:         d.oid,
+		Names:       d.fields["NAME"],
+		Description: d.first("DESC"),
+		Obsolete:    d.flags["OBSOLETE"],
+		Superiors:   d.fields["SUP"],
+		Kind:        ObjectClassStructural,
+		Must:        d.fields["MUST"],
+		May:         d.fields["MAY"],
+		Extensions:  d.extensions,
+	}
+	for _, kind := range []string{ObjectClassAbstract, ObjectClassAuxiliary} {
+		if d.flags[kind] {
+			objectClass.Kind = kind
+		}
+	}
+	return objectClass, nil
+}
+
+// ParseMatchingRule parses a matching rule definition
+func ParseMatchingRule(definition string) (*MatchingRule, error) {
+	d, err := parseSchemaDefinition(definition, "OBSOLETE")
+	if err != nil {
+		return nil, err
+	}
+	return &MatchingRule{
+		OID:         d.oid,
+		Names:       d.fields["NAME"],
+		Description: d.first("DESC"),
+		Obsolete:    d.flags["OBSOLETE"],
+		Syntax:      d.first("SYNTAX"),
+		Extensions:  d.extensions,
+	}, nil
+}
+
+// schemaDefinition holds the parts of a definition, the values of its fields
+// by keyword and the keywords without values
+type schemaDefinition struct {
+	oid        string
+	fields     map[string][]string
+	flags      map[string]bool
+	extensions map[string][]string
+}
+
+func (d *schemaDefinition) first(keyword string) string {
+	if values := d.fields[keyword]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parseSchemaDefinition parses a definition, flags being the keywords which
+// are not followed by a value
+func parseSchemaDefinition(definition string, flags ...string) (*schemaDefinition, error) {
+	tokens, err := tokenizeSchemaDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+	invalid := fmt.Errorf("ldap: invalid schema definition %q", definition)
+	if len(tokens) < 3 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" {
+		return nil, invalid
+	}
+	tokens = tokens[1 : len(tokens)-1]
+
+	d := &schemaDefinition{
+		oid:    tokens[0],
+		fields: make(map[string][]string),
+		flags:  make(map[string]bool),
+	}
+	isFlag := make(map[string]bool)
+	for _, flag := range flags {
+		isFlag[flag] = true
+	}
+	for i := 1; i < len(tokens); i++ {
+		keyword := tokens[i]
+		if isFlag[keyword] {
+			d.flags[keyword] = true
+			continue
+		}
+		if i+1 >= len(tokens) {
+			return nil, invalid
+		}
+		var values []string
+		i++
+		if tokens[i] == "(" {
+			for i++; i < len(tokens) && tokens[i] != ")"; i++ {
+				if tokens[i] != "$" {
+					values = append(values, unquoteSchemaValue(tokens[i]))
+				}
+			}
+			if i == len(tokens) {
+				return nil, invalid
+			}
+		} else {
+			values = []string{unquoteSchemaValue(tokens[i])}
+		}
+		if strings.HasPrefix(keyword, "X-") {
+			if d.extensions == nil {
+				d.extensions = make(map[string][]string)
+			}
+			d.extensions[keyword] = values
+		} else {
+			d.fields[keyword] = values
+		}
+	}
+	return d, nil
+}
+
+// tokenizeSchemaDefinition splits a definition into parentheses, dollar signs,
+// words and quoted strings, which keep their quotes
+func tokenizeSchemaDefinition(definition string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(definition); {
+		switch c := definition[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '$':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'':
+			end := strings.IndexByte(definition[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("ldap: unterminated string in schema definition %q", definition)
+			}
+			tokens = append(tokens, definition[i:i+end+2])
+			i += end + 2
+		default:
+			end := strings.IndexAny(definition[i:], " \t\n\r()$'")
+			if end < 0 {
+				end = len(definition) - i
+			}
+			tokens = append(tokens, definition[i:i+end])
+			i += end
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("ldap: empty schema definition")
+	}
+	return tokens, nil
+}
+
+// unquoteSchemaValue removes the quotes of a qdstring and decodes its \27 and
+// \5C escapes
+func unquoteSchemaValue(token string) string {
+	if len(token) < 2 || token[0] != '\'' {
+		return token
+	}
+	value := token[1 : len(token)-1]
+	value = strings.Replace(value, `\27`, "'", -1)
+	value = strings.Replace(value, `\5C`, `\`, -1)
+	return strings.Replace(value, `\5c`, `\`, -1)
+}
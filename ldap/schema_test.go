@@ -0,0 +1,154 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+var testSchemaAttributeTypes = []string{
+	"( 2.5.4.41 NAME 'name' EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{32768} )",
+	"( 2.5.4.3 NAME ( 'cn' 'commonName' ) DESC 'RFC4519: common name(s) for which the entity is known by' SUP name )",
+	"( 1.3.6.1.1.1.1.0 NAME 'uidNumber' DESC 'An integer uniquely identifying a user' EQUALITY integerMatch ORDERING integerOrderingMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.27 SINGLE-VALUE )",
+	"( 2.5.18.1 NAME 'createTimestamp' EQUALITY generalizedTimeMatch ORDERING generalizedTimeOrderingMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.24 SINGLE-VALUE NO-USER-MODIFICATION USAGE directoryOperation )",
+	"( 2.5.4.13 NAME 'description' DESC 'it\\27s a \\5Cdescription' EQUALITY caseIgnoreMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{1024} X-ORIGIN ( 'RFC 4519' 'user defined' ) )",
+}
+
+var testSchemaObjectClasses = []string{
+	"( 2.5.6.0 NAME 'top' ABSTRACT MUST objectClass )",
+	"( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( userPassword $ telephoneNumber $ seeAlso $ description ) X-ORIGIN 'RFC 4519' )",
+	"( 1.3.6.1.1.1.2.0 NAME 'posixAccount' DESC 'Abstraction of an account with POSIX attributes' SUP top AUXILIARY MUST ( cn $ uid $ uidNumber $ gidNumber $ homeDirectory ) MAY ( userPassword $ loginShell $ gecos $ description ) )",
+}
+
+var testSchemaMatchingRules = []string{
+	"( 2.5.13.2 NAME 'caseIgnoreMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+	"( 2.5.13.14 NAME 'integerMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.27 )",
+}
+
+func newTestSchema(t *testing.T) *Schema {
+	schema, err := ParseSchema(NewEntry("cn=Subschema", map[string][]string{
+		"attributeTypes": testSchemaAttributeTypes,
+		"objectClasses":  testSchemaObjectClasses,
+		"matchingRules":  testSchemaMatchingRules,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestParseAttributeType(t *testing.T) {
+	attributeType, err := ParseAttributeType(testSchemaAttributeTypes[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &AttributeType{
+		OID:          "2.5.4.13",
+		Names:        []string{"description"},
+		Description:  `it's a \description`,
+		Equality:     "caseIgnoreMatch",
+		Syntax:       "1.3.6.1.4.1.1466.115.121.1.15",
+		SyntaxLength: 1024,
+		Usage:        UsageUserApplications,
+		Extensions:   map[string][]string{"X-ORIGIN": {"RFC 4519", "user defined"}},
+	}
+	if !reflect.DeepEqual(attributeType, expected) {
+		t.Errorf("got %+v, expected %+v", attributeType, expected)
+	}
+
+	attributeType, err = ParseAttributeType(testSchemaAttributeTypes[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attributeType.SingleValue || !attributeType.NoUserModification || attributeType.Usage != UsageDirectoryOperation || attributeType.Ordering != "generalizedTimeOrderingMatch" {
+		t.Errorf("unexpected attribute type %+v", attributeType)
+	}
+
+	for _, definition := range []string{"", "2.5.4.3", "( 2.5.4.3 NAME 'cn )", "( 2.5.4.3 NAME ( 'cn' )", "( 2.5.4.3 SUP )", "( 2.5.4.3 SYNTAX 1.2{x} )"} {
+		if _, err := ParseAttributeType(definition); err == nil {
+			t.Errorf("%q: expected an error", definition)
+		}
+	}
+}
+
+func TestParseObjectClass(t *testing.T) {
+	objectClass, err := ParseObjectClass(testSchemaObjectClasses[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ObjectClass{
+		OID:         "1.3.6.1.1.1.2.0",
+		Names:       []string{"posixAccount"},
+		Description: "Abstraction of an account with POSIX attributes",
+		Superiors:   []string{"top"},
+		Kind:        ObjectClassAuxiliary,
+		Must:        []string{"cn", "uid", "uidNumber", "gidNumber", "homeDirectory"},
+		May:         []string{"userPassword", "loginShell", "gecos", "description"},
+	}
+	if !reflect.DeepEqual(objectClass, expected) {
+		t.Errorf("got %+v, expected %+v", objectClass, expected)
+	}
+}
+
+func TestSchemaLookup(t *testing.T) {
+	schema := newTestSchema(t)
+	if attributeType := schema.AttributeType("CommonName"); attributeType == nil || attributeType.OID != "2.5.4.3" || attributeType.Superior != "name" {
+		t.Errorf("unexpected attribute type %+v", attributeType)
+	}
+	if attributeType := schema.AttributeType("2.5.4.3;lang-en"); attributeType == nil || attributeType.Names[0] != "cn" {
+		t.Errorf("unexpected attribute type %+v", attributeType)
+	}
+	if objectClass := schema.ObjectClass("PERSON"); objectClass == nil || objectClass.Kind != ObjectClassStructural || objectClass.Extensions["X-ORIGIN"][0] != "RFC 4519" {
+		t.Errorf("unexpected object class %+v", objectClass)
+	}
+	if matchingRule := schema.MatchingRule("integerMatch"); matchingRule == nil || matchingRule.OID != "2.5.13.14" {
+		t.Errorf("unexpected matching rule %+v", matchingRule)
+	}
+	if schema.AttributeType("unknown") != nil || schema.ObjectClass("unknown") != nil {
+		t.Error("expected no definition for unknown names")
+	}
+}
+
+func TestConnSchema(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "", NewEntryAttribute("subschemaSubentry", []string{"cn=Subschema"})),
+				newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+			}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if baseDN := p.Children[1].Children[0].Value.(string); baseDN != "cn=Subschema" {
+				t.Errorf("got base DN %q", baseDN)
+			}
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "cn=Subschema",
+					NewEntryAttribute("attributeTypes", testSchemaAttributeTypes),
+					NewEntryAttribute("objectClasses", testSchemaObjectClasses),
+				),
+				newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+			}
+		})
+	}()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		schema, err := conn.Schema()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schema.DN != "cn=Subschema" || len(schema.AttributeTypes) != len(testSchemaAttributeTypes) || len(schema.ObjectClasses) != len(testSchemaObjectClasses) {
+			t.Errorf("unexpected schema %+v", schema)
+		}
+	})
+}
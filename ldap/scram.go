@@ -0,0 +1,261 @@
+// File contains the SCRAM SASL mechanisms
+//
+// https://tools.ietf.org/html/rfc5802
+// https://tools.ietf.org/html/rfc7677
+
+package ldap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SCRAM mechanisms. The channel binding variants are selected by setting the
+// ChannelBinding of a SCRAMBindRequest.
+const (
+	SCRAMSHA1   = "SCRAM-SHA-1"
+	SCRAMSHA256 = "SCRAM-SHA-256"
+)
+
+// scramHashes maps the SCRAM mechanisms to their hash function
+var scramHashes = map[string]func() hash.Hash{
+	SCRAMSHA1:   sha1.New,
+	SCRAMSHA256: sha256.New,
+}
+
+// SCRAMBindRequest represents a SCRAM SASL bind
+type SCRAMBindRequest struct {
+	// Mechanism is SCRAMSHA1 or SCRAMSHA256
+	Mechanism string
+	// Username is the authentication identity, usually a user name rather than a DN
+	Username string
+	// Password is the password of the user
+	Password string
+	// AuthzID is the optional authorization identity to assume
+	AuthzID string
+	// ChannelBinding, if set, selects the -PLUS variant of the mechanism and
+	// binds the authentication to the TLS channel. It is usually obtained
+	// with Conn.ChannelBinding.
+	ChannelBinding *ChannelBinding
+	// Controls are optional controls to send with the bind request
+	Controls []Control
+}
+
+// SCRAMBind performs a SCRAM SASL bind with the given mechanism, username and password
+func (l *Conn) SCRAMBind(mechanism, username, password string) error {
+	req := &SCRAMBindRequest{
+		Mechanism: mechanism,
+		Username:  username,
+		Password:  password,
+	}
+	_, err := l.SCRAMBindRequest(req)
+	return err
+}
+
+// SCRAMBindRequest performs the SCRAM SASL bind defined in the given request
+func (l *Conn) SCRAMBindRequest(scramBindRequest *SCRAMBindRequest) (*SASLBindResult, error) {
+	return l.SCRAMBindRequestContext(context.Background(), scramBindRequest)
+}
+
+// SCRAMBindRequestContext performs the SCRAM SASL bind defined in the given
+// request. If ctx is done before the server responds, the connection is closed
+// and ctx.Err() is returned.
+func (l *Conn) SCRAMBindRequestContext(ctx context.Context, scramBindRequest *SCRAMBindRequest) (*SASLBindResult, error) {
+	if scramBindRequest.Password == "" {
+		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
+	}
+	mechanism, err := newSCRAM(scramBindRequest)
+	if err != nil {
+		return nil, err
+	}
+	return l.SASLBindContext(ctx, mechanism, scramBindRequest.Controls)
+}
+
+// scram implements the client side of the SCRAM SASL mechanisms
+type scram struct {
+	name           string
+	hash           func() hash.Hash
+	username       string
+	password       string
+	authzid        string
+	channelBinding *ChannelBinding
+
+	// cnonce is the client nonce, generated by Start if empty
+	cnonce string
+	// step counts the challenges received from the server
+	step int
+	// gs2Header and clientFirstBare are the parts of the client first message
+	gs2Header       string
+	clientFirstBare string
+	// serverSignature is the signature expected in the server final message
+	serverSignature []byte
+	// serverVerified is set once the server signature has been verified
+	serverVerified bool
+}
+
+func newSCRAM(req *SCRAMBindRequest) (*scram, error) {
+	h, ok := scramHashes[req.Mechanism]
+	if !ok {
+		return nil, fmt.Errorf("ldap: unsupported SCRAM mechanism %q", req.Mechanism)
+	}
+	s := &scram{
+		name:           req.Mechanism,
+		hash:           h,
+		username:       req.Username,
+		password:       req.Password,
+		authzid:        req.AuthzID,
+		channelBinding: req.ChannelBinding,
+	}
+	if s.channelBinding != nil {
+		s.name += "-PLUS"
+	}
+	return s, nil
+}
+
+func (s *scram) Name() string {
+	return s.name
+}
+
+func (s *scram) Start() ([]byte, error) {
+	if s.cnonce == "" {
+		nonce := make([]byte, 18)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		s.cnonce = base64.RawStdEncoding.EncodeToString(nonce)
+	}
+
+	s.gs2Header = "n,"
+	if s.channelBinding != nil {
+		s.gs2Header = "p=" + s.channelBinding.Type + ","
+	}
+	if s.authzid != "" {
+		s.gs2Header += "a=" + escapeSCRAMName(s.authzid)
+	}
+	s.gs2Header += ","
+	s.clientFirstBare = "n=" + escapeSCRAMName(s.username) + ",r=" + s.cnonce
+	return []byte(s.gs2Header + s.clientFirstBare), nil
+}
+
+func (s *scram) Next(challenge []byte) ([]byte, error) {
+	s.step++
+	switch s.step {
+	case 1:
+		return s.clientFinal(string(challenge))
+	case 2:
+		attributes := parseSCRAMMessage(string(challenge))
+		if e, ok := attributes["e"]; ok {
+			return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: SCRAM authentication failed: %s", e))
+		}
+		signature, err := base64.StdEncoding.DecodeString(attributes["v"])
+		if err != nil || !hmac.Equal(signature, s.serverSignature) {
+			return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: SCRAM server authentication failed"))
+		}
+		s.serverVerified = true
+		return []byte{}, nil
+	}
+	return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: unexpected SCRAM challenge"))
+}
+
+// verified returns an error unless the server final message was received and
+// its signature verified, as a server accepting the bind without it has not
+// proved that it knows the password
+func (s *scram) verified() error {
+	if !s.serverVerified {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: SCRAM bind accepted without a server signature"))
+	}
+	return nil
+}
+
+// clientFinal returns the client final message answering the server first message
+func (s *scram) clientFinal(serverFirst string) ([]byte, error) {
+	attributes := parseSCRAMMessage(serverFirst)
+	if _, ok := attributes["m"]; ok {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: unsupported SCRAM extension"))
+	}
+	nonce := attributes["r"]
+	if !strings.HasPrefix(nonce, s.cnonce) || len(nonce) == len(s.cnonce) {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid SCRAM server nonce"))
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	if err != nil {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid SCRAM salt: %s", err))
+	}
+	iterations, err := strconv.Atoi(attributes["i"])
+	if err != nil || iterations < 1 {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid SCRAM iteration count %q", attributes["i"]))
+	}
+
+	channelBinding := []byte(s.gs2Header)
+	if s.channelBinding != nil {
+		channelBinding = append(channelBinding, s.channelBinding.Data...)
+	}
+	clientFinal := "c=" + base64.StdEncoding.EncodeToString(channelBinding) + ",r=" + nonce
+	authMessage := []byte(s.clientFirstBare + "," + serverFirst + "," + clientFinal)
+
+	saltedPassword := s.hi([]byte(s.password), salt, iterations)
+	clientKey := s.hmac(saltedPassword, []byte("Client Key"))
+	h := s.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+	clientSignature := s.hmac(storedKey, authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	s.serverSignature = s.hmac(s.hmac(saltedPassword, []byte("Server Key")), authMessage)
+
+	return []byte(clientFinal + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (s *scram) hmac(key, message []byte) []byte {
+	mac := hmac.New(s.hash, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// hi is the salted password function of https://tools.ietf.org/html/rfc5802#section-2.2,
+// which is PBKDF2 with a single block
+func (s *scram) hi(password, salt []byte, iterations int) []byte {
+	mac := hmac.New(s.hash, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// parseSCRAMMessage returns the attributes of a SCRAM server message
+func parseSCRAMMessage(message string) map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(message, ",") {
+		if len(attribute) >= 2 && attribute[1] == '=' {
+			attributes[attribute[:1]] = attribute[2:]
+		}
+	}
+	return attributes
+}
+
+// escapeSCRAMName escapes the characters which are not allowed in a SCRAM
+// user name or authorization identity
+func escapeSCRAMName(name string) string {
+	name = strings.Replace(name, "=", "=3D", -1)
+	return strings.Replace(name, ",", "=2C", -1)
+}
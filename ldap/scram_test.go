@@ -0,0 +1,151 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// scramExchanges are the examples of https://tools.ietf.org/html/rfc5802#section-5
+// and https://tools.ietf.org/html/rfc7677#section-3
+var scramExchanges = []struct {
+	mechanism   string
+	cnonce      string
+	clientFirst string
+	serverFirst string
+	clientFinal string
+	serverFinal string
+}{
+	{
+		mechanism:   SCRAMSHA1,
+		cnonce:      "fyko+d2lbbFgONRv9qkxdawL",
+		clientFirst: "n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL",
+		serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+		clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+		serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+	},
+	{
+		mechanism:   SCRAMSHA256,
+		cnonce:      "rOprNGfwEbeRWgbNEkqO",
+		clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+		serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+	},
+}
+
+func TestSCRAM(t *testing.T) {
+	for _, exchange := range scramExchanges {
+		mechanism, err := newSCRAM(&SCRAMBindRequest{Mechanism: exchange.mechanism, Username: "user", Password: "pencil"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mechanism.cnonce = exchange.cnonce
+
+		if clientFirst, err := mechanism.Start(); err != nil || string(clientFirst) != exchange.clientFirst {
+			t.Errorf("%s: unexpected client first message %q (%v)", exchange.mechanism, clientFirst, err)
+		}
+		if clientFinal, err := mechanism.Next([]byte(exchange.serverFirst)); err != nil || string(clientFinal) != exchange.clientFinal {
+			t.Errorf("%s: unexpected client final message %q (%v)", exchange.mechanism, clientFinal, err)
+		}
+		if _, err := mechanism.Next([]byte(exchange.serverFinal)); err != nil {
+			t.Errorf("%s: unexpected error verifying server final message: %s", exchange.mechanism, err)
+		}
+	}
+}
+
+func TestSCRAMServerAuthentication(t *testing.T) {
+	exchange := scramExchanges[1]
+	mechanism, _ := newSCRAM(&SCRAMBindRequest{Mechanism: exchange.mechanism, Username: "user", Password: "pencil"})
+	mechanism.cnonce = exchange.cnonce
+	mechanism.Start()
+
+	if _, err := mechanism.Next([]byte("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil {
+		t.Errorf("expected error for a server nonce not extending the client nonce")
+	}
+	mechanism.step = 0
+	if _, err := mechanism.Next([]byte(exchange.serverFirst)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mechanism.Next([]byte("v=rmF9pqV8S7suAoZWja4dJRkFsKQ=")); err == nil {
+		t.Errorf("expected error for an invalid server signature")
+	}
+}
+
+func TestSCRAMChannelBinding(t *testing.T) {
+	mechanism, _ := newSCRAM(&SCRAMBindRequest{
+		Mechanism:      SCRAMSHA256,
+		Username:       "user,name",
+		Password:       "pencil",
+		AuthzID:        "u:admin",
+		ChannelBinding: &ChannelBinding{Type: "tls-server-end-point", Data: []byte("cbdata")},
+	})
+	mechanism.cnonce = "abc"
+	if name := mechanism.Name(); name != "SCRAM-SHA-256-PLUS" {
+		t.Errorf("unexpected mechanism name %s", name)
+	}
+	if clientFirst, _ := mechanism.Start(); string(clientFirst) != "p=tls-server-end-point,a=u:admin,n=user=2Cname,r=abc" {
+		t.Errorf("unexpected client first message %q", clientFirst)
+	}
+	clientFinal, err := mechanism.Next([]byte("r=abcdef,s=c2FsdA==,i=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// base64 of "p=tls-server-end-point,a=u:admin,cbdata"
+	expected := "c=cD10bHMtc2VydmVyLWVuZC1wb2ludCxhPXU6YWRtaW4sY2JkYXRh,r=abcdef,"
+	if string(clientFinal[:len(expected)]) != expected {
+		t.Errorf("unexpected client final message %q", clientFinal)
+	}
+}
+
+func TestSCRAMBind(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			if mechanism, _ := saslCredentials(t, p); mechanism != SCRAMSHA256 {
+				t.Errorf("unexpected mechanism %s", mechanism)
+			}
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), LDAPResultSaslBindInProgress, "r=invalid,s=QSXCR+Q6sek8bf92,i=4096")}
+		})
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.SCRAMBind(SCRAMSHA256, "user", "pencil"); !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+			t.Errorf("expected unexpected response error, got %v", err)
+		}
+	})
+}
+
+func TestSCRAMBindWithoutServerSignature(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			_, clientFirst := saslCredentials(t, p)
+			cnonce := parseSCRAMMessage(string(clientFirst))["r"]
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), LDAPResultSaslBindInProgress, "r="+cnonce+"server,s=QSXCR+Q6sek8bf92,i=4096")}
+		})
+		// the server accepts the client proof without sending its signature
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newBindResponsePacket(p.Children[0].Value.(int64), LDAPResultSuccess, "")}
+		})
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if err := conn.SCRAMBind(SCRAMSHA256, "user", "pencil"); !IsErrorWithCode(err, ErrorUnexpectedResponse) {
+			t.Errorf("expected unexpected response error, got %v", err)
+		}
+	})
+}
@@ -56,6 +56,7 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -69,13 +70,19 @@ const (
 	ScopeBaseObject   = 0
 	ScopeSingleLevel  = 1
 	ScopeWholeSubtree = 2
+	// ScopeSubordinateSubtree selects the subordinates of the base object,
+	// but not the base object itself. It is defined by
+	// https://tools.ietf.org/html/draft-sermersheim-ldap-subordinate-scope
+	// and only supported by some servers, such as OpenLDAP.
+	ScopeSubordinateSubtree = 3
 )
 
 // ScopeMap contains human readable descriptions of scope choices
 var ScopeMap = map[int]string{
-	ScopeBaseObject:   "Base Object",
-	ScopeSingleLevel:  "Single Level",
-	ScopeWholeSubtree: "Whole Subtree",
+	ScopeBaseObject:         "Base Object",
+	ScopeSingleLevel:        "Single Level",
+	ScopeWholeSubtree:       "Whole Subtree",
+	ScopeSubordinateSubtree: "Subordinate Subtree",
 }
 
 // derefAliases
@@ -132,13 +139,21 @@ func (e *Entry) GetAttributeValues(attribute string) []string {
 	return []string{}
 }
 
-// GetRawAttributeValues returns the byte values for the named attribute, or an empty list
+// GetRawAttributeValues returns the byte values for the named attribute, or an empty list.
+// Values returned with the binary option, e.g. as "userCertificate;binary", are
+// found by the name of the attribute.
 func (e *Entry) GetRawAttributeValues(attribute string) [][]byte {
 	for _, attr := range e.Attributes {
 		if attr.Name == attribute {
 			return attr.ByteValues
 		}
 	}
+	binary := WithBinaryOption(attribute)
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, binary) {
+			return attr.ByteValues
+		}
+	}
 	return [][]byte{}
 }
 
@@ -189,6 +204,32 @@ func NewEntryAttribute(name string, values []string) *EntryAttribute {
 	}
 }
 
+// NewBinaryEntryAttribute returns a new EntryAttribute with the desired
+// binary values
+func NewBinaryEntryAttribute(name string, values [][]byte) *EntryAttribute {
+	var stringValues []string
+	for _, value := range values {
+		stringValues = append(stringValues, string(value))
+	}
+	return &EntryAttribute{
+		Name:       name,
+		Values:     stringValues,
+		ByteValues: values,
+	}
+}
+
+// WithBinaryOption returns the attribute description requesting the values of
+// the attribute in their binary transfer encoding, such as
+// "userCertificate;binary" (RFC 4522)
+func WithBinaryOption(attribute string) string {
+	for _, option := range strings.Split(attribute, ";")[1:] {
+		if strings.EqualFold(option, "binary") {
+			return attribute
+		}
+	}
+	return attribute + ";binary"
+}
+
 // EntryAttribute holds a single attribute
 type EntryAttribute struct {
 	// Name is the name of the attribute
@@ -213,10 +254,38 @@ func (e *EntryAttribute) PrettyPrint(indent int) {
 type SearchResult struct {
 	// Entries are the returned entries
 	Entries []*Entry
-	// Referrals are the returned referrals
+	// Referrals are the returned continuation references, or the referral
+	// URLs of a search failing with LDAPResultReferral
 	Referrals []string
 	// Controls are the returned controls
 	Controls []Control
+	// LimitExceeded is set if the search was stopped by a size, time or
+	// administrative limit, so that Entries only hold the entries found
+	// before the limit
+	LimitExceeded bool
+}
+
+// SortResult returns the ControlServerSideSortingResult returned with the
+// search, or nil if the server did not return one or it could not be decoded
+func (s *SearchResult) SortResult() *ControlServerSideSortingResult {
+	control, _ := FindControl(s.Controls, ControlTypeServerSideSortingResult).(*ControlServerSideSortingResult)
+	return control
+}
+
+// VLVResult returns the ControlVLVResult returned with the search, holding
+// the position of the target entry and the context ID of the next request,
+// or nil if the server did not return one or it could not be decoded
+func (s *SearchResult) VLVResult() *ControlVLVResult {
+	control, _ := FindControl(s.Controls, ControlTypeVLVResult).(*ControlVLVResult)
+	return control
+}
+
+// SyncDone returns the ControlSyncDone returned with a refreshOnly
+// synchronization, holding the new cookie, or nil if the server did not
+// return one or it could not be decoded
+func (s *SearchResult) SyncDone() *ControlSyncDone {
+	control, _ := FindControl(s.Controls, ControlTypeSyncDone).(*ControlSyncDone)
+	return control
 }
 
 // Print outputs a human-readable description
@@ -246,7 +315,24 @@ type SearchRequest struct {
 	Controls     []Control
 }
 
+// validate checks the scope, alias dereferencing and limits of the request
+func (s *SearchRequest) validate() error {
+	if _, ok := ScopeMap[s.Scope]; !ok {
+		return fmt.Errorf("ldap: invalid search scope %d", s.Scope)
+	}
+	if _, ok := DerefMap[s.DerefAliases]; !ok {
+		return fmt.Errorf("ldap: invalid search derefAliases %d", s.DerefAliases)
+	}
+	if s.SizeLimit < 0 || s.TimeLimit < 0 {
+		return fmt.Errorf("ldap: invalid search limits %d and %d", s.SizeLimit, s.TimeLimit)
+	}
+	return nil
+}
+
 func (s *SearchRequest) encode() (*asn1.Packet, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
 	request := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchRequest, nil, "Search Request")
 	request.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, s.BaseDN, "Base DN"))
 	request.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, uint64(s.Scope), "Scope"))
@@ -269,6 +355,28 @@ func (s *SearchRequest) encode() (*asn1.Packet, error) {
 	return request, nil
 }
 
+// decodeSearchRequest decodes the protocol operation of a search request
+// received by a Server
+func decodeSearchRequest(packet *asn1.Packet) (*SearchRequest, error) {
+	filter, err := DecompileFilter(packet.Children[6])
+	if err != nil {
+		return nil, err
+	}
+	searchRequest := &SearchRequest{
+		BaseDN:       decodeOctetString(packet.Children[0]),
+		Scope:        int(packet.Children[1].Value.(int64)),
+		DerefAliases: int(packet.Children[2].Value.(int64)),
+		SizeLimit:    int(packet.Children[3].Value.(int64)),
+		TimeLimit:    int(packet.Children[4].Value.(int64)),
+		TypesOnly:    packet.Children[5].Value.(bool),
+		Filter:       filter,
+	}
+	for _, attribute := range packet.Children[7].Children {
+		searchRequest.Attributes = append(searchRequest.Attributes, decodeOctetString(attribute))
+	}
+	return searchRequest, nil
+}
+
 // NewSearchRequest creates a new search request
 func NewSearchRequest(
 	BaseDN string,
@@ -300,34 +408,18 @@ func NewSearchRequest(
 //  - given SearchRequest contains a control of type ControlTypePaging with pagingSize not equal to the size requested: fail without issuing any queries
 // A requested pagingSize of 0 is interpreted as no limit by LDAP servers.
 func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
-	var pagingControl *ControlPaging
+	return l.SearchWithPagingContext(context.Background(), searchRequest, pagingSize)
+}
 
-	control := FindControl(searchRequest.Controls, ControlTypePaging)
-	if control == nil {
-		pagingControl = NewControlPaging(pagingSize)
-		searchRequest.Controls = append(searchRequest.Controls, pagingControl)
-	} else {
-		castControl, ok := control.(*ControlPaging)
-		if !ok {
-			return nil, fmt.Errorf("Expected paging control to be of type *ControlPaging, got %v", control)
-		}
-		if castControl.PagingSize != pagingSize {
-			return nil, fmt.Errorf("Paging size given in search request (%d) conflicts with size given in search call (%d)", castControl.PagingSize, pagingSize)
-		}
-		pagingControl = castControl
+// SearchWithPagingContext is like SearchWithPaging, but stops issuing further
+// page requests and abandons the outstanding one once ctx is done.
+func (l *Conn) SearchWithPagingContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	if _, err := pagingControlFor(searchRequest, pagingSize); err != nil {
+		return nil, err
 	}
 
 	searchResult := new(SearchResult)
-	for {
-		result, err := l.Search(searchRequest)
-		l.Debug.Printf("Looking for Paging Control...")
-		if err != nil {
-			return searchResult, err
-		}
-		if result == nil {
-			return searchResult, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
-		}
-
+	err := l.SearchWithPagingFuncContext(ctx, searchRequest, pagingSize, func(result *SearchResult) error {
 		for _, entry := range result.Entries {
 			searchResult.Entries = append(searchResult.Entries, entry)
 		}
@@ -337,55 +429,176 @@ func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32)
 		for _, control := range result.Controls {
 			searchResult.Controls = append(searchResult.Controls, control)
 		}
+		return nil
+	})
+	return searchResult, err
+}
+
+// SearchWithPagingFunc accepts a search request and desired page size like SearchWithPaging, but
+// instead of buffering all entries it calls handler with the result of each page as soon as the
+// page has been received. Pages are requested until the server returns an empty cookie.
+// If handler returns an error, no further pages are requested, the paged search is abandoned
+// on the server and the error is returned.
+func (l *Conn) SearchWithPagingFunc(searchRequest *SearchRequest, pagingSize uint32, handler func(*SearchResult) error) error {
+	return l.SearchWithPagingFuncContext(context.Background(), searchRequest, pagingSize, handler)
+}
+
+// SearchWithPagingFuncContext is like SearchWithPagingFunc, but stops issuing further
+// page requests and abandons the outstanding one once ctx is done.
+func (l *Conn) SearchWithPagingFuncContext(ctx context.Context, searchRequest *SearchRequest, pagingSize uint32, handler func(*SearchResult) error) error {
+	pagingControl, err := pagingControlFor(searchRequest, pagingSize)
+	if err != nil {
+		return err
+	}
+
+	for {
+		result, err := l.SearchContext(ctx, searchRequest)
+		if err != nil {
+			if IsSizeOrTimeLimitExceeded(err) && result != nil {
+				// the entries found before the limit are not discarded
+				if handlerErr := handler(result); handlerErr != nil {
+					return handlerErr
+				}
+			}
+			return err
+		}
+		if result == nil {
+			return NewError(ErrorNetwork, errors.New("ldap: packet not received"))
+		}
 
 		l.Debug.Printf("Looking for Paging Control...")
-		pagingResult := FindControl(result.Controls, ControlTypePaging)
-		if pagingResult == nil {
-			pagingControl = nil
-			l.Debug.Printf("Could not find paging control.  Breaking...")
-			break
+		var cookie []byte
+		if pagingResult := FindControl(result.Controls, ControlTypePaging); pagingResult != nil {
+			cookie = pagingResult.(*ControlPaging).Cookie
+		} else {
+			l.Debug.Printf("Could not find paging control.")
+		}
+
+		if err := handler(result); err != nil {
+			if len(cookie) > 0 {
+				l.Debug.Printf("Abandoning Paging...")
+				pagingControl.SetCookie(cookie)
+				pagingControl.PagingSize = 0
+				l.SearchContext(ctx, searchRequest)
+				pagingControl.PagingSize = pagingSize
+			}
+			return err
 		}
 
-		cookie := pagingResult.(*ControlPaging).Cookie
 		if len(cookie) == 0 {
-			pagingControl = nil
 			l.Debug.Printf("Could not find cookie.  Breaking...")
-			break
+			return nil
 		}
 		pagingControl.SetCookie(cookie)
 	}
+}
 
-	if pagingControl != nil {
-		l.Debug.Printf("Abandoning Paging...")
-		pagingControl.PagingSize = 0
-		l.Search(searchRequest)
+// pagingControlFor returns the paging control of the search request, adding one with the given
+// paging size if there is none. See SearchWithPaging for the conditions under which it fails.
+func pagingControlFor(searchRequest *SearchRequest, pagingSize uint32) (*ControlPaging, error) {
+	control := FindControl(searchRequest.Controls, ControlTypePaging)
+	if control == nil {
+		pagingControl := NewControlPaging(pagingSize)
+		searchRequest.Controls = append(searchRequest.Controls, pagingControl)
+		return pagingControl, nil
 	}
 
-	return searchResult, nil
+	castControl, ok := control.(*ControlPaging)
+	if !ok {
+		return nil, fmt.Errorf("Expected paging control to be of type *ControlPaging, got %v", control)
+	}
+	if castControl.PagingSize != pagingSize {
+		return nil, fmt.Errorf("Paging size given in search request (%d) conflicts with size given in search call (%d)", castControl.PagingSize, pagingSize)
+	}
+	return castControl, nil
 }
 
 // Search performs the given search request
 func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
-	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
-	// encode search request
-	encodedSearchRequest, err := searchRequest.encode()
-	if err != nil {
-		return nil, err
-	}
-	packet.AppendChild(encodedSearchRequest)
-	// encode search controls
-	if searchRequest.Controls != nil {
-		packet.AppendChild(encodeControls(searchRequest.Controls))
+	return l.SearchContext(context.Background(), searchRequest)
+}
+
+// SearchContext performs the given search request. If ctx is done before the
+// search completes, the request is abandoned and ctx.Err() is returned.
+// Referrals are followed according to the policy set with SetReferralPolicy.
+// Attributes returned in ranges, such as member;range=0-1499 by Active
+// Directory, are read in full and returned without the range option. If the
+// search is stopped by a limit, the entries found before the limit are
+// returned along with the error, see SetIgnoreLimitExceeded.
+func (l *Conn) SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	result, err := l.intercept(ctx, searchRequest)
+	searchResult, _ := result.(*SearchResult)
+	return searchResult, l.limitExceeded(searchResult, err)
+}
+
+// searchContext performs the search once it went through the interceptors
+func (l *Conn) searchContext(ctx context.Context, searchRequest *SearchRequest) (result *SearchResult, err error) {
+	searchRequest = l.withOperationalAttributes(searchRequest)
+	ctx, span := l.startSpan(ctx, "Search", searchAttributes(searchRequest))
+	defer func() { span.End(resultCodeOf(err), err) }()
+
+	result, err = l.searchRanged(ctx, searchRequest)
+	if policy := l.loadReferralPolicy(); policy != nil {
+		return policy.chase(ctx, searchRequest, result, err, 0, make(map[string]bool))
 	}
+	return result, err
+}
 
-	l.Debug.PrintPacket(packet)
+// SearchStream performs the given search request and calls handler with each
+// entry as soon as it is received, instead of buffering the entries. The
+// returned result holds the referrals and the controls of the search, but no
+// entries. If handler returns an error, the search is abandoned and the error
+// is returned. Referrals are not followed and attributes returned in ranges
+// are passed to handler as sent by the server.
+func (l *Conn) SearchStream(searchRequest *SearchRequest, handler func(*Entry) error) (*SearchResult, error) {
+	return l.SearchStreamContext(context.Background(), searchRequest, handler)
+}
+
+// SearchStreamContext is like SearchStream, but abandons the search and
+// returns ctx.Err() if ctx is done before the search completes.
+func (l *Conn) SearchStreamContext(ctx context.Context, searchRequest *SearchRequest, handler func(*Entry) error) (*SearchResult, error) {
+	searchRequest = l.withOperationalAttributes(searchRequest)
+	ctx, span := l.startSpan(ctx, "Search", searchAttributes(searchRequest))
+	result, err := l.searchStream(ctx, searchRequest, handler)
+	span.End(resultCodeOf(err), err)
+	return result, l.limitExceeded(result, err)
+}
+
+// search performs the given search request without following referrals
+func (l *Conn) search(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
+	entries := make([]*Entry, 0)
+	result, err := l.searchStream(ctx, searchRequest, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if result != nil {
+		result.Entries = entries
+	}
+	return result, err
+}
 
-	msgCtx, err := l.sendMessage(packet)
+// searchStream performs the given search request, calling handler with each
+// entry received
+func (l *Conn) searchStream(ctx context.Context, searchRequest *SearchRequest, handler func(*Entry) error) (*SearchResult, error) {
+	msgCtx, err := l.sendSearch(searchRequest)
 	if err != nil {
 		return nil, err
 	}
-	defer l.finishMessage(msgCtx)
+	return l.receiveSearch(ctx, msgCtx, handler)
+}
+
+// receiveSearch receives the responses of the search sent with the given
+// message, calling handler with each entry received, and finishes the message
+func (l *Conn) receiveSearch(ctx context.Context, msgCtx *messageContext, handler func(*Entry) error) (*SearchResult, error) {
+	abandon := false
+	defer func() {
+		// the message is finished first so that the responses still sent by
+		// the server do not block the abandon request
+		l.finishMessage(msgCtx)
+		if abandon {
+			l.abandon(msgCtx.id)
+		}
+	}()
 
 	result := &SearchResult{
 		Entries:   make([]*Entry, 0),
@@ -395,11 +608,7 @@ func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
 	foundSearchResultDone := false
 	for !foundSearchResultDone {
 		l.Debug.Printf("%d: waiting for response", msgCtx.id)
-		packetResponse, ok := <-msgCtx.responses
-		if !ok {
-			return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-		}
-		packet, err = packetResponse.ReadPacket()
+		packet, err := l.receivePacket(ctx, msgCtx)
 		l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 		if err != nil {
 			return nil, err
@@ -414,28 +623,26 @@ func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
 
 		switch packet.Children[1].Tag {
 		case 4:
-			entry := new(Entry)
-			entry.DN = packet.Children[1].Children[0].Value.(string)
-			for _, child := range packet.Children[1].Children[1].Children {
-				attr := new(EntryAttribute)
-				attr.Name = child.Children[0].Value.(string)
-				for _, value := range child.Children[1].Children {
-					attr.Values = append(attr.Values, value.Value.(string))
-					attr.ByteValues = append(attr.ByteValues, value.ByteValue)
-				}
-				entry.Attributes = append(entry.Attributes, attr)
+			if err := handler(decodeEntry(packet.Children[1])); err != nil {
+				l.Debug.Printf("%d: handler failed, abandoning request", msgCtx.id)
+				abandon = true
+				return nil, err
 			}
-			result.Entries = append(result.Entries, entry)
 		case 5:
-			resultCode, resultDescription := getLDAPResultCode(packet)
-			if resultCode != 0 {
-				return result, NewError(resultCode, errors.New(resultDescription))
+			resultCode, _ := getLDAPResultCode(packet)
+			if resultCode == LDAPResultReferral {
+				result.Referrals = append(result.Referrals, decodeReferral(packet.Children[1])...)
 			}
 			if len(packet.Children) == 3 {
 				for _, child := range packet.Children[2].Children {
 					result.Controls = append(result.Controls, DecodeControl(child))
 				}
 			}
+			if resultCode != 0 {
+				err := newResultError(packet)
+				result.LimitExceeded = IsSizeOrTimeLimitExceeded(err)
+				return result, err
+			}
 			foundSearchResultDone = true
 		case 19:
 			result.Referrals = append(result.Referrals, packet.Children[1].Children[0].Value.(string))
@@ -444,3 +651,70 @@ func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
 	l.Debug.Printf("%d: returning", msgCtx.id)
 	return result, nil
 }
+
+// sendSearch sends the given search request and returns the context of the
+// message receiving its responses
+func (l *Conn) sendSearch(searchRequest *SearchRequest) (*messageContext, error) {
+	return l.sendSearchWithID(l.nextMessageID(), searchRequest)
+}
+
+// sendSearchWithID is like sendSearch, with the message ID of the request
+// already taken
+func (l *Conn) sendSearchWithID(messageID int64, searchRequest *SearchRequest) (*messageContext, error) {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	// encode search request
+	encodedSearchRequest, err := searchRequest.encode()
+	if err != nil {
+		return nil, err
+	}
+	packet.AppendChild(encodedSearchRequest)
+	// encode search controls
+	if searchRequest.Controls != nil {
+		packet.AppendChild(encodeControls(searchRequest.Controls))
+	}
+
+	l.Debug.PrintPacket(packet)
+
+	return l.sendMessage(packet)
+}
+
+// decodeEntry returns the entry held by a SearchResultEntry packet, which
+// is already decoded if the packet was read by readMessage
+func decodeEntry(packet *asn1.Packet) *Entry {
+	if entry, ok := packet.Value.(*Entry); ok {
+		return entry
+	}
+	entry := new(Entry)
+	entry.DN = packet.Children[0].Value.(string)
+	for _, child := range packet.Children[1].Children {
+		attr := new(EntryAttribute)
+		attr.Name = child.Children[0].Value.(string)
+		for _, value := range child.Children[1].Children {
+			attr.Values = append(attr.Values, value.Value.(string))
+			attr.ByteValues = append(attr.ByteValues, value.Data.Bytes())
+		}
+		entry.Attributes = append(entry.Attributes, attr)
+	}
+	return entry
+}
+
+// encodeEntry returns the SearchResultEntry packet representation of the entry
+func encodeEntry(entry *Entry) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, entry.DN, "Object Name"))
+	attributes := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attributes")
+	for _, attribute := range entry.Attributes {
+		attr := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attribute")
+		attr.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, attribute.Name, "Attribute Name"))
+		if len(attribute.ByteValues) == len(attribute.Values) {
+			// the raw values are not altered by a conversion to strings
+			attr.AppendChild(encodeAttributeValues(nil, attribute.ByteValues))
+		} else {
+			attr.AppendChild(encodeAttributeValues(attribute.Values, nil))
+		}
+		attributes.AppendChild(attr)
+	}
+	packet.AppendChild(attributes)
+	return packet
+}
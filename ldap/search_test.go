@@ -1,8 +1,14 @@
 package ldap
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
 )
 
 // TestNewEntry tests that repeated calls to NewEntry return the same value with the same input
@@ -29,3 +35,324 @@ func TestNewEntry(t *testing.T) {
 		iteration = iteration + 1
 	}
 }
+
+// TestBinaryEntryAttribute tests that binary values round-trip and are found
+// without the binary option
+func TestBinaryEntryAttribute(t *testing.T) {
+	certificate := []byte{0x30, 0x82, 0x01, 0xff, 0x80}
+	entry := &Entry{
+		DN:         "uid=jdoe,ou=people,dc=example,dc=com",
+		Attributes: []*EntryAttribute{NewBinaryEntryAttribute("userCertificate;binary", [][]byte{certificate})},
+	}
+	decoded := decodeEntry(asn1.DecodePacket(encodeEntry(entry).Bytes()))
+	if value := decoded.GetRawAttributeValue("userCertificate"); !bytes.Equal(value, certificate) {
+		t.Errorf("got %x, expected %x", value, certificate)
+	}
+	if value := decoded.GetRawAttributeValue("userCertificate;binary"); !bytes.Equal(value, certificate) {
+		t.Errorf("got %x, expected %x", value, certificate)
+	}
+	if name := WithBinaryOption("userCertificate;Binary"); name != "userCertificate;Binary" {
+		t.Errorf("unexpected attribute description %q", name)
+	}
+}
+
+// servePages answers one paged search request per page. Every page holds the
+// given entries and a paging control with the next cookie; the last page
+// returns an empty cookie. The cookies received from the client are sent on
+// the returned channel.
+func servePages(t *testing.T, ptc *packetTranslatorConn, pages [][]string) <-chan []byte {
+	cookies := make(chan []byte, len(pages))
+	go func() {
+		for i, page := range pages {
+			serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+				messageID := p.Children[0].Value.(int64)
+				for _, control := range p.Children[2].Children {
+					if paging, ok := DecodeControl(control).(*ControlPaging); ok {
+						cookies <- paging.Cookie
+					}
+				}
+				var responses []*asn1.Packet
+				for _, dn := range page {
+					responses = append(responses, newEntryPacket(messageID, dn))
+				}
+				nextCookie := &ControlPaging{}
+				if i < len(pages)-1 {
+					nextCookie.SetCookie([]byte(fmt.Sprintf("page-%d", i+1)))
+				}
+				done := newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, "")
+				done.AppendChild(encodeControls([]Control{nextCookie}))
+				return append(responses, done)
+			})
+		}
+	}()
+	return cookies
+}
+
+func TestSearchWithPagingFunc(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	cookies := servePages(t, ptc, [][]string{
+		{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"},
+		{"cn=c,dc=example,dc=com", "cn=d,dc=example,dc=com"},
+		{"cn=e,dc=example,dc=com"},
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	var pageSizes []int
+	err := conn.SearchWithPagingFunc(searchRequest, 2, func(result *SearchResult) error {
+		pageSizes = append(pageSizes, len(result.Entries))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(pageSizes, []int{2, 2, 1}) {
+		t.Errorf("unexpected page sizes: %v", pageSizes)
+	}
+	for i, expected := range []string{"", "page-1", "page-2"} {
+		if cookie := <-cookies; string(cookie) != expected {
+			t.Errorf("request %d: expected cookie %q, got %q", i, expected, cookie)
+		}
+	}
+}
+
+func TestSearchWithPagingAggregates(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	servePages(t, ptc, [][]string{
+		{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"},
+		{"cn=c,dc=example,dc=com"},
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	result, err := conn.SearchWithPaging(searchRequest, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(result.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(result.Entries))
+	}
+	if result.Entries[2].DN != "cn=c,dc=example,dc=com" {
+		t.Errorf("unexpected last entry: %s", result.Entries[2].DN)
+	}
+}
+
+func TestSearchWithPagingFuncStops(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	// The second request is the one releasing the paged search on the server.
+	cookies := servePages(t, ptc, [][]string{
+		{"cn=a,dc=example,dc=com"},
+		{},
+	})
+
+	stop := errors.New("stop")
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	err := conn.SearchWithPagingFunc(searchRequest, 1, func(result *SearchResult) error {
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	<-cookies
+	if cookie := <-cookies; string(cookie) != "page-1" {
+		t.Errorf("expected paged search to be released with cookie %q, got %q", "page-1", cookie)
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	servePages(t, ptc, [][]string{
+		{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com", "cn=c,dc=example,dc=com"},
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, []Control{NewControlPaging(10)})
+	var dns []string
+	result, err := conn.SearchStream(searchRequest, func(entry *Entry) error {
+		dns = append(dns, entry.DN)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(dns, []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com", "cn=c,dc=example,dc=com"}) {
+		t.Errorf("unexpected entries %v", dns)
+	}
+	if len(result.Entries) != 0 || FindControl(result.Controls, ControlTypePaging) == nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestSearchStreamStops(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	abandoned := make(chan []byte, 1)
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			messageID := p.Children[0].Value.(int64)
+			return []*asn1.Packet{
+				newEntryPacket(messageID, "cn=a,dc=example,dc=com"),
+				newEntryPacket(messageID, "cn=b,dc=example,dc=com"),
+				newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, ""),
+			}
+		})
+		request, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Errorf("unable to receive request packet: %s", err)
+			return
+		}
+		if request.Children[1].Tag == ApplicationAbandonRequest {
+			abandoned <- request.Children[1].Data.Bytes()
+		}
+	}()
+
+	stop := errors.New("stop")
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	calls := 0
+	_, err := conn.SearchStream(searchRequest, func(entry *Entry) error {
+		calls++
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to be called once, got %d calls", calls)
+	}
+	runWithTimeout(t, 5*time.Second, func() {
+		searchID := asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, int64(1), "MessageID")
+		if messageID := <-abandoned; !bytes.Equal(messageID, searchID.Data.Bytes()) {
+			t.Errorf("expected search 1 to be abandoned, got %x", messageID)
+		}
+	})
+}
+
+func TestSearchRequestScopes(t *testing.T) {
+	for scope := range ScopeMap {
+		for deref := range DerefMap {
+			request := NewSearchRequest("dc=example,dc=com", scope, deref, 0, 0, false, "(objectClass=*)", nil, nil)
+			packet, err := request.encode()
+			if err != nil {
+				t.Fatalf("scope %d, deref %d: %s", scope, deref, err)
+			}
+			decoded, err := decodeSearchRequest(asn1.DecodePacket(packet.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Scope != scope || decoded.DerefAliases != deref {
+				t.Errorf("expected scope %d and deref %d, got %d and %d", scope, deref, decoded.Scope, decoded.DerefAliases)
+			}
+		}
+	}
+
+	for _, request := range []*SearchRequest{
+		NewSearchRequest("dc=example,dc=com", 4, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil),
+		NewSearchRequest("dc=example,dc=com", ScopeBaseObject, -1, 0, 0, false, "(objectClass=*)", nil, nil),
+		NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, -1, 0, false, "(objectClass=*)", nil, nil),
+	} {
+		if _, err := request.encode(); err == nil {
+			t.Errorf("expected an error for the request %#v", request)
+		}
+	}
+}
+
+func TestSearchResultControls(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	controls := []Control{
+		&ControlServerSideSortingResult{Result: LDAPResultNoSuchAttribute, AttributeType: "cn"},
+		&ControlVLVResult{TargetPosition: 5, ContentCount: 100, ContextID: []byte("context")},
+		&ControlSyncDone{Cookie: []byte("csn=1"), RefreshDeletes: true},
+	}
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		messageID := p.Children[0].Value.(int64)
+		done := newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, "")
+		done.AppendChild(encodeControls(controls))
+		return []*asn1.Packet{newEntryPacket(messageID, "cn=a,dc=example,dc=com"), done}
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil,
+		[]Control{NewControlServerSideSorting([]*SortKey{{AttributeType: "cn"}}), NewControlVLVByOffset(0, 9, 1, 0)})
+	result, err := conn.Search(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sortResult := result.SortResult(); !reflect.DeepEqual(sortResult, controls[0]) {
+		t.Errorf("expected sort result %v, got %v", controls[0], sortResult)
+	}
+	if vlvResult := result.VLVResult(); !reflect.DeepEqual(vlvResult, controls[1]) {
+		t.Errorf("expected VLV result %v, got %v", controls[1], vlvResult)
+	}
+	if syncDone := result.SyncDone(); !reflect.DeepEqual(syncDone, controls[2]) {
+		t.Errorf("expected sync done %v, got %v", controls[2], syncDone)
+	}
+
+	empty := &SearchResult{}
+	if empty.SortResult() != nil || empty.VLVResult() != nil || empty.SyncDone() != nil {
+		t.Error("expected no response controls")
+	}
+}
+
+func TestSearchResultMalformedControls(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	for _, value := range []*asn1.Packet{nil, newSequencePacket()} {
+		go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			done := newResultPacket(p.Children[0].Value.(int64), ApplicationSearchResultDone, LDAPResultSuccess, "")
+			controls := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+			for _, controlType := range []string{ControlTypeServerSideSortingResult, ControlTypeVLVResult, ControlTypeSyncDone} {
+				controls.AppendChild(newControlPacket(controlType, value))
+			}
+			done.AppendChild(controls)
+			return []*asn1.Packet{done}
+		})
+
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		result, err := conn.Search(searchRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.SortResult() != nil || result.VLVResult() != nil {
+			t.Errorf("expected malformed controls not to be returned, got %v", result.Controls)
+		}
+		if value == nil && result.SyncDone() != nil {
+			t.Errorf("expected malformed sync done control not to be returned, got %v", result.Controls)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+// File contains the server side of the protocol, dispatching the requests
+// received on the connections to handlers
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe once Close is called
+var ErrServerClosed = errors.New("ldap: server closed")
+
+// Binder handles the bind requests received by a Server
+type Binder interface {
+	// Bind authenticates the client, returning an *Error such as
+	// LDAPResultInvalidCredentials if it cannot. The name bound with is then
+	// returned by BoundDN for the following requests of the connection.
+	Bind(ctx context.Context, request *SimpleBindRequest) error
+}
+
+// Searcher handles the search requests received by a Server
+type Searcher interface {
+	// Search calls send with each entry matching the search, sent to the
+	// client right away, and returns the result of the search
+	Search(ctx context.Context, request *SearchRequest, send func(*Entry) error) error
+}
+
+// Modifier handles the update requests received by a Server
+type Modifier interface {
+	// Add adds the entry of the request
+	Add(ctx context.Context, request *AddRequest) error
+	// Modify applies the changes of the request
+	Modify(ctx context.Context, request *ModifyRequest) error
+	// Delete deletes the entry of the request
+	Delete(ctx context.Context, request *DelRequest) error
+}
+
+// Server accepts LDAP connections and dispatches the requests received on
+// them to its handlers, to build servers, proxies or gateways. The requests
+// are decoded into the types used by the client, such as SearchRequest. The
+// errors returned by the handlers are sent as the result of the request: the
+// result code, matched DN and controls of an *Error, and
+// LDAPResultOther for other errors.
+//
+// Requests are handled concurrently, except binds which are handled once the
+// previous requests of the connection completed. The context passed to the
+// handlers is canceled when the request is abandoned or the connection is
+// closed. Operations without a handler, as well as modify DN, compare and
+// extended operations, fail with LDAPResultUnwillingToPerform.
+type Server struct {
+	// Binder handles the bind requests. If nil, anonymous binds succeed and
+	// other binds fail with LDAPResultInvalidCredentials.
+	Binder Binder
+	// Searcher handles the search requests
+	Searcher Searcher
+	// Modifier handles the add, modify and delete requests
+	Modifier Modifier
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+type boundDNKey struct{}
+
+// BoundDN returns the name the client of the request passed to a handler
+// bound with, empty if it did not bind or bound anonymously
+func BoundDN(ctx context.Context) string {
+	dn, _ := ctx.Value(boundDNKey{}).(string)
+	return dn
+}
+
+// ListenAndServe listens on the TCP address and serves the connections
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts the connections of the listener and serves them, until the
+// listener fails or the server is closed
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]bool)
+		s.conns = make(map[net.Conn]bool)
+	}
+	s.listeners[listener] = true
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			delete(s.listeners, listener)
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes the listeners and the connections, and waits for the handlers
+// of their requests to return
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serverConn is a connection accepted by a Server
+type serverConn struct {
+	server  *Server
+	conn    net.Conn
+	ctx     context.Context
+	writeMu sync.Mutex
+	// requests are the cancel functions of the requests in progress by
+	// message ID, guarded by mu
+	mu       sync.Mutex
+	requests map[int64]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &serverConn{
+		server:   s,
+		conn:     conn,
+		ctx:      ctx,
+		requests: make(map[int64]context.CancelFunc),
+	}
+	defer s.wg.Done()
+	defer func() {
+		cancel()
+		c.wg.Wait()
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	for {
+		packet, err := asn1.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if !c.dispatch(packet) {
+			return
+		}
+	}
+}
+
+// dispatch starts the handling of a request, and returns false if the
+// connection must be closed, after an unbind request or a malformed message
+func (c *serverConn) dispatch(packet *asn1.Packet) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	messageID := packet.Children[0].Value.(int64)
+	op := packet.Children[1]
+	var controls []Control
+	if len(packet.Children) > 2 {
+		for _, child := range packet.Children[2].Children {
+			controls = append(controls, DecodeControl(child))
+		}
+	}
+
+	switch op.Tag {
+	case ApplicationUnbindRequest:
+		return false
+	case ApplicationAbandonRequest:
+		c.mu.Lock()
+		if cancel, ok := c.requests[decodeAbandonID(op)]; ok {
+			cancel()
+		}
+		c.mu.Unlock()
+		return true
+	case ApplicationBindRequest:
+		// a bind waits for the previous requests to complete, and the next
+		// ones wait for it
+		c.wg.Wait()
+		bindRequest, err := decodeSimpleBindRequest(op)
+		if err == nil {
+			bindRequest.Controls = controls
+		}
+		c.handle(messageID, func(ctx context.Context) error {
+			if err != nil {
+				return err
+			}
+			if err := c.server.bind(ctx, bindRequest); err != nil {
+				c.ctx = context.WithValue(c.ctx, boundDNKey{}, "")
+				return err
+			}
+			c.ctx = context.WithValue(c.ctx, boundDNKey{}, bindRequest.Username)
+			return nil
+		}, ApplicationBindResponse)
+		return true
+	case ApplicationSearchRequest:
+		searchRequest, err := decodeSearchRequest(op)
+		if err != nil {
+			return false
+		}
+		searchRequest.Controls = controls
+		c.start(messageID, func(ctx context.Context) error {
+			if c.server.Searcher == nil {
+				return errUnwillingToPerform
+			}
+			return c.server.Searcher.Search(ctx, searchRequest, func(entry *Entry) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				return c.write(messageID, encodeEntry(entry), nil)
+			})
+		}, ApplicationSearchResultDone)
+	case ApplicationAddRequest:
+		addRequest := decodeAddRequest(op)
+		addRequest.Controls = controls
+		c.start(messageID, func(ctx context.Context) error {
+			if c.server.Modifier == nil {
+				return errUnwillingToPerform
+			}
+			return c.server.Modifier.Add(ctx, addRequest)
+		}, ApplicationAddResponse)
+	case ApplicationModifyRequest:
+		modifyRequest := decodeModifyRequest(op)
+		modifyRequest.Controls = controls
+		c.start(messageID, func(ctx context.Context) error {
+			if c.server.Modifier == nil {
+				return errUnwillingToPerform
+			}
+			return c.server.Modifier.Modify(ctx, modifyRequest)
+		}, ApplicationModifyResponse)
+	case ApplicationDelRequest:
+		delRequest := decodeDelRequest(op)
+		delRequest.Controls = controls
+		c.start(messageID, func(ctx context.Context) error {
+			if c.server.Modifier == nil {
+				return errUnwillingToPerform
+			}
+			return c.server.Modifier.Delete(ctx, delRequest)
+		}, ApplicationDelResponse)
+	case ApplicationModifyDNRequest:
+		c.start(messageID, unwillingToPerform, ApplicationModifyDNResponse)
+	case ApplicationCompareRequest:
+		c.start(messageID, unwillingToPerform, ApplicationCompareResponse)
+	case ApplicationExtendedRequest:
+		c.start(messageID, unwillingToPerform, ApplicationExtendedResponse)
+	default:
+		return false
+	}
+	return true
+}
+
+var errUnwillingToPerform = NewError(LDAPResultUnwillingToPerform, errors.New("ldap: operation not supported by the server"))
+
+func unwillingToPerform(ctx context.Context) error {
+	return errUnwillingToPerform
+}
+
+// bind performs a bind request with the Binder of the server
+func (s *Server) bind(ctx context.Context, request *SimpleBindRequest) error {
+	if s.Binder != nil {
+		return s.Binder.Bind(ctx, request)
+	}
+	if request.Username == "" {
+		return nil
+	}
+	return NewError(LDAPResultInvalidCredentials, errors.New("ldap: binds are not supported by the server"))
+}
+
+// start handles a request in its own goroutine
+func (c *serverConn) start(messageID int64, handler func(ctx context.Context) error, responseTag asn1.Tag) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.handle(messageID, handler, responseTag)
+	}()
+}
+
+// handle calls the handler of a request and sends its result, unless the
+// request is abandoned
+func (c *serverConn) handle(messageID int64, handler func(ctx context.Context) error, responseTag asn1.Tag) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.mu.Lock()
+	c.requests[messageID] = cancel
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.requests, messageID)
+		c.mu.Unlock()
+		cancel()
+	}()
+
+	err := c.callHandler(ctx, handler)
+	if ctx.Err() != nil {
+		// abandoned requests have no response
+		return
+	}
+	c.write(messageID, encodeResult(responseTag, err), err)
+}
+
+// callHandler calls the handler, turning a panic into an error
+func (c *serverConn) callHandler(ctx context.Context, handler func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ldap: recovered panic in server handler: %v", r)
+			err = NewError(LDAPResultOperationsError, errors.New("ldap: internal server error"))
+		}
+	}()
+	return handler(ctx)
+}
+
+// write sends a response to the client, with the controls of err if it is
+// an *Error
+func (c *serverConn) write(messageID int64, op *asn1.Packet, err error) error {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(op)
+	if e, ok := err.(*Error); ok && len(e.Controls) > 0 {
+		packet.AppendChild(encodeControls(e.Controls))
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, writeErr := c.conn.Write(packet.Bytes())
+	return writeErr
+}
+
+// encodeResult returns the LDAPResult of a response holding the error, or a
+// success if err is nil
+func encodeResult(tag asn1.Tag, err error) *asn1.Packet {
+	resultCode := uint8(LDAPResultSuccess)
+	var matchedDN, message string
+	if e, ok := err.(*Error); ok {
+		resultCode, matchedDN, message = e.ResultCode, e.MatchedDN, e.DiagnosticMessage
+		if message == "" && e.Err != nil {
+			message = e.Err.Error()
+		}
+	} else if err != nil {
+		resultCode, message = LDAPResultOther, err.Error()
+	}
+	packet := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, tag, nil, ApplicationMap[uint8(tag)])
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, uint64(resultCode), "resultCode"))
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, matchedDN, "matchedDN"))
+	packet.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, message, "diagnosticMessage"))
+	return packet
+}
+
+// decodeAbandonID returns the message ID of an abandon request, whose
+// primitive packet only carries its data
+func decodeAbandonID(packet *asn1.Packet) int64 {
+	var id int64
+	for _, b := range packet.Data.Bytes() {
+		id = id<<8 | int64(b)
+	}
+	return id
+}
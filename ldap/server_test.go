@@ -0,0 +1,161 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testHandler handles the requests of a test Server
+type testHandler struct {
+	deleted   chan *DelRequest
+	abandoned chan struct{}
+}
+
+func (h *testHandler) Bind(ctx context.Context, request *SimpleBindRequest) error {
+	if request.Username != "cn=admin,dc=example,dc=com" || request.Password != "secret" {
+		return NewError(LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+func (h *testHandler) Search(ctx context.Context, request *SearchRequest, send func(*Entry) error) error {
+	if request.Filter == "(wait=*)" {
+		<-ctx.Done()
+		close(h.abandoned)
+		return ctx.Err()
+	}
+	expected := &SearchRequest{
+		BaseDN:     "dc=example,dc=com",
+		Scope:      ScopeSingleLevel,
+		SizeLimit:  10,
+		Filter:     "(&(uid=jdoe)(mail=*))",
+		Attributes: []string{"uid", "cn"},
+	}
+	if !reflect.DeepEqual(request, expected) {
+		return NewError(LDAPResultProtocolError, errors.New("unexpected request"))
+	}
+	entry := NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{"uid": {"jdoe"}, "boundDN": {BoundDN(ctx)}})
+	if err := send(entry); err != nil {
+		return err
+	}
+	return send(entry)
+}
+
+func (h *testHandler) Add(ctx context.Context, request *AddRequest) error {
+	return &Error{ResultCode: LDAPResultNoSuchObject, MatchedDN: "dc=example,dc=com", Err: errors.New("no parent")}
+}
+
+func (h *testHandler) Modify(ctx context.Context, request *ModifyRequest) error {
+	panic("modify")
+}
+
+func (h *testHandler) Delete(ctx context.Context, request *DelRequest) error {
+	h.deleted <- request
+	return nil
+}
+
+func newTestServer(t *testing.T, handler *testHandler) (*Server, *Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Binder: handler, Searcher: handler, Modifier: handler}
+	go server.Serve(listener)
+	conn, err := DialURL("ldap://" + listener.Addr().String())
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, conn
+}
+
+func TestServer(t *testing.T) {
+	handler := &testHandler{deleted: make(chan *DelRequest, 1), abandoned: make(chan struct{})}
+	server, conn := newTestServer(t, handler)
+	defer server.Close()
+	defer conn.Close()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := conn.Bind("cn=admin,dc=example,dc=com", "wrong"); !IsInvalidCredentials(err) {
+			t.Errorf("expected invalid credentials, got %v", err)
+		}
+		if err := conn.Bind("cn=admin,dc=example,dc=com", "secret"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeSingleLevel, NeverDerefAliases, 10, 0, false, "(&(uid=jdoe)(mail=*))", []string{"uid", "cn"}, nil)
+		result, err := conn.Search(searchRequest)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(result.Entries) != 2 || result.Entries[0].GetAttributeValue("boundDN") != "cn=admin,dc=example,dc=com" {
+			t.Errorf("unexpected entries %v", result.Entries)
+		}
+
+		err = conn.Add(NewAddRequest("cn=a,ou=missing,dc=example,dc=com"))
+		if e, ok := err.(*Error); !ok || e.ResultCode != LDAPResultNoSuchObject || e.MatchedDN != "dc=example,dc=com" || e.DiagnosticMessage != "no parent" {
+			t.Errorf("expected the error of the handler, got %v", err)
+		}
+
+		if err := conn.Modify(NewModifyRequest("cn=a,dc=example,dc=com")); !IsErrorWithCode(err, LDAPResultOperationsError) {
+			t.Errorf("expected an operations error, got %v", err)
+		}
+
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", []Control{NewControlManageDsaIT(true)})); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		deleted := <-handler.deleted
+		if deleted.DN != "cn=a,dc=example,dc=com" || len(deleted.Controls) != 1 || deleted.Controls[0].GetControlType() != ControlTypeManageDsaIT {
+			t.Errorf("unexpected delete request %+v", deleted)
+		}
+
+		if _, err := conn.Compare("cn=a,dc=example,dc=com", "cn", "a"); !IsErrorWithCode(err, LDAPResultUnwillingToPerform) {
+			t.Errorf("expected unwilling to perform, got %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		searchRequest = NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(wait=*)", nil, nil)
+		if _, err := conn.SearchContext(ctx, searchRequest); err != context.DeadlineExceeded {
+			t.Errorf("expected the error of the context, got %v", err)
+		}
+		<-handler.abandoned
+	})
+}
+
+func TestServerClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	conn, err := DialURL("ldap://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := conn.UnauthenticatedBind(""); err != nil {
+			t.Errorf("expected anonymous binds to succeed, got %v", err)
+		}
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)); !IsErrorWithCode(err, LDAPResultUnwillingToPerform) {
+			t.Errorf("expected unwilling to perform without a handler, got %v", err)
+		}
+		server.Close()
+		if err := <-served; err != ErrServerClosed {
+			t.Errorf("expected ErrServerClosed, got %v", err)
+		}
+		if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)); !IsNetworkError(err) {
+			t.Errorf("expected a network error once the server is closed, got %v", err)
+		}
+	})
+}
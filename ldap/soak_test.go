@@ -0,0 +1,99 @@
+package ldap
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var soakDuration = flag.Duration("ldap.soak", 0, "run TestSoak for the given duration, e.g. 10m")
+
+// TestSoak runs binds, searches, modifies and abandoned searches from
+// concurrent goroutines on a single connection for the duration given with
+// -ldap.soak, checking that no operation fails, and that the connection
+// neither leaks goroutines nor grows its heap. It is skipped by default:
+//
+//	go test -run TestSoak -args -ldap.soak=10m
+func TestSoak(t *testing.T) {
+	if *soakDuration <= 0 {
+		t.Skip("soak test not requested with -ldap.soak")
+	}
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	goroutines := runtime.NumGoroutine()
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var operations, failures uint64
+	deadline := time.Now().Add(*soakDuration)
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			dn := fmt.Sprintf("uid=soak%d,ou=people,dc=example,dc=com", worker)
+			add := NewAddRequest(dn)
+			add.Attribute("uid", []string{fmt.Sprintf("soak%d", worker)})
+			add.Attribute("counter", []string{"0"})
+			if err := conn.Add(add); err != nil {
+				t.Errorf("add %s: %s", dn, err)
+				return
+			}
+			for i := 0; time.Now().Before(deadline); i++ {
+				if err := soakOperation(conn, dn, i); err != nil {
+					atomic.AddUint64(&failures, 1)
+					t.Errorf("operation %d of %s: %s", i, dn, err)
+				}
+				atomic.AddUint64(&operations, 1)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	t.Logf("%d operations, %d failures, %.0f operations/s, heap %d -> %d bytes",
+		operations, failures, float64(operations)/soakDuration.Seconds(), before.HeapAlloc, after.HeapAlloc)
+	if stats := conn.Stats(); stats.InFlight != 0 {
+		t.Errorf("%d operations still in flight", stats.InFlight)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines+2 {
+		t.Errorf("expected at most %d goroutines, got %d", goroutines+2, n)
+	}
+	if after.HeapAlloc > 2*before.HeapAlloc+16<<20 {
+		t.Errorf("heap grew from %d to %d bytes", before.HeapAlloc, after.HeapAlloc)
+	}
+}
+
+// soakOperation runs the i-th operation of a worker of TestSoak
+func soakOperation(conn *Conn, dn string, i int) error {
+	switch i % 4 {
+	case 0:
+		return conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret")
+	case 1:
+		modify := NewModifyRequest(dn)
+		modify.Increment("counter", 1)
+		modify.Replace("description", []string{fmt.Sprintf("operation %d", i)})
+		return conn.Modify(modify)
+	case 2:
+		_, err := conn.Search(NewSearchRequest("ou=people,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil))
+		return err
+	default:
+		// a search stopped by its handler is abandoned
+		_, err := conn.SearchStream(NewSearchRequest("ou=people,dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil), func(*Entry) error {
+			return ErrSkipChildren
+		})
+		if err != ErrSkipChildren {
+			return fmt.Errorf("expected the error of the handler, got %v", err)
+		}
+		return nil
+	}
+}
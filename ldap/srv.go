@@ -0,0 +1,136 @@
+// File contains DNS SRV record discovery of LDAP servers
+//
+// https://tools.ietf.org/html/rfc2782
+// https://msdn.microsoft.com/en-us/library/cc223811.aspx
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SRVResolver looks up the LDAP servers of a domain in its SRV records
+type SRVResolver struct {
+	// Resolver is the resolver used for the lookups, net.DefaultResolver if nil
+	Resolver *net.Resolver
+	// Service is the service of the SRV records, "ldap" if empty. Use "ldaps"
+	// for servers listening for TLS connections or "gc" for the global catalog
+	// of Active Directory.
+	Service string
+	// Site is the optional Active Directory site of the client. Servers of
+	// the site are looked up first, falling back to those of the whole
+	// domain if the site has none.
+	Site string
+	// DomainControllers restricts the lookup to the domain controllers of an
+	// Active Directory domain, published under "dc._msdcs"
+	DomainControllers bool
+
+	// lookupSRV replaces Resolver.LookupSRV in tests
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DialWithSRVResolver sets the resolver used by DialDomain to look up the servers
+func DialWithSRVResolver(r *SRVResolver) DialOpt {
+	return func(dc *dialConfig) {
+		dc.srvResolver = r
+	}
+}
+
+// LookupServers returns the addresses of the LDAP servers of the domain as
+// host:port pairs, ordered by priority and, for equal priorities, randomized
+// according to their weight
+func (r *SRVResolver) LookupServers(ctx context.Context, domain string) ([]string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" {
+		return nil, errors.New("ldap: empty domain name")
+	}
+	if r.DomainControllers {
+		domain = "dc._msdcs." + domain
+	}
+
+	var addrs []*net.SRV
+	var err error
+	if r.Site != "" {
+		addrs, err = r.lookup(ctx, r.Site+"._sites."+domain)
+		if err != nil && !isNotFound(err) {
+			return nil, NewError(ErrorNetwork, err)
+		}
+	}
+	if len(addrs) == 0 {
+		addrs, err = r.lookup(ctx, domain)
+		if err != nil {
+			return nil, NewError(ErrorNetwork, err)
+		}
+	}
+
+	servers := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		// a single record with target "." means the service is not available
+		if addr.Target == "." {
+			continue
+		}
+		servers = append(servers, net.JoinHostPort(strings.TrimSuffix(addr.Target, "."), strconv.Itoa(int(addr.Port))))
+	}
+	if len(servers) == 0 {
+		return nil, NewError(ErrorNetwork, fmt.Errorf("ldap: no %s servers found for %s", r.service(), domain))
+	}
+	return servers, nil
+}
+
+// lookup returns the SRV records of the service under the given name
+func (r *SRVResolver) lookup(ctx context.Context, name string) ([]*net.SRV, error) {
+	lookupSRV := r.lookupSRV
+	if lookupSRV == nil {
+		resolver := r.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		lookupSRV = resolver.LookupSRV
+	}
+	_, addrs, err := lookupSRV(ctx, r.service(), "tcp", name)
+	return addrs, err
+}
+
+// service returns the service of the SRV records
+func (r *SRVResolver) service() string {
+	if r.Service == "" {
+		return "ldap"
+	}
+	return r.Service
+}
+
+// isNotFound reports whether the lookup failed because the name does not exist
+func isNotFound(err error) bool {
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && dnsErr.IsNotFound
+}
+
+// DialDomain looks up the LDAP servers of the domain in its SRV records and
+// returns a new Conn for the first one accepting the connection. Servers of
+// the "ldaps" service are dialed with TLS. Use DialWithSRVResolver to
+// configure the lookup.
+func DialDomain(domain string, opts ...DialOpt) (*Conn, error) {
+	dc := newDialConfig(opts)
+	servers, err := dc.srvResolver.LookupServers(context.Background(), domain)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "ldap"
+	if dc.srvResolver.service() == "ldaps" {
+		scheme = "ldaps"
+	}
+	for _, server := range servers {
+		var conn *Conn
+		conn, err = dc.dial(&URL{Scheme: scheme, Host: server})
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,123 @@
+package ldap
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeSRVLookup returns a lookup function serving the given records of the
+// "ldap" service, keyed by name
+func fakeSRVLookup(records map[string][]*net.SRV) func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		cname := "_" + service + "._" + proto + "." + name
+		addrs, ok := records[name]
+		if !ok || service != "ldap" || proto != "tcp" {
+			return "", nil, &net.DNSError{Err: "no such host", Name: cname, IsNotFound: true}
+		}
+		return cname, addrs, nil
+	}
+}
+
+func TestSRVResolverLookupServers(t *testing.T) {
+	lookup := fakeSRVLookup(map[string][]*net.SRV{
+		"example.com": {
+			{Target: "ldap1.example.com.", Port: 389, Priority: 0, Weight: 100},
+			{Target: "ldap2.example.com.", Port: 3389, Priority: 10, Weight: 100},
+		},
+		"Paris._sites.dc._msdcs.example.com": {
+			{Target: "dc-paris.example.com.", Port: 389},
+		},
+		"dc._msdcs.example.com": {
+			{Target: "dc1.example.com.", Port: 389},
+		},
+		"example.net": {
+			{Target: ".", Port: 0},
+		},
+	})
+
+	testcases := []struct {
+		resolver *SRVResolver
+		domain   string
+		expected []string
+	}{
+		{&SRVResolver{}, "example.com.", []string{"ldap1.example.com:389", "ldap2.example.com:3389"}},
+		{&SRVResolver{Site: "Paris"}, "example.com", []string{"ldap1.example.com:389", "ldap2.example.com:3389"}},
+		{&SRVResolver{DomainControllers: true}, "example.com", []string{"dc1.example.com:389"}},
+		{&SRVResolver{DomainControllers: true, Site: "Paris"}, "example.com", []string{"dc-paris.example.com:389"}},
+		{&SRVResolver{DomainControllers: true, Site: "London"}, "example.com", []string{"dc1.example.com:389"}},
+		{&SRVResolver{}, "example.net", nil},
+		{&SRVResolver{}, "example.org", nil},
+		{&SRVResolver{Service: "ldaps"}, "example.com", nil},
+		{&SRVResolver{}, "", nil},
+	}
+
+	for i, tc := range testcases {
+		tc.resolver.lookupSRV = lookup
+		servers, err := tc.resolver.LookupServers(context.Background(), tc.domain)
+		if tc.expected == nil {
+			if err == nil {
+				t.Errorf("%d: expected error, got %v", i, servers)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(servers, tc.expected) {
+			t.Errorf("%d: got %v, expected %v", i, servers, tc.expected)
+		}
+	}
+}
+
+func TestDialDomain(t *testing.T) {
+	// a port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	resolver := &SRVResolver{
+		lookupSRV: fakeSRVLookup(map[string][]*net.SRV{
+			"example.com": {
+				{Target: "127.0.0.1.", Port: uint16(closedPort)},
+				{Target: "127.0.0.1.", Port: uint16(ln.Addr().(*net.TCPAddr).Port)},
+			},
+		}),
+	}
+
+	conn, err := DialDomain("example.com", DialWithSRVResolver(resolver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if remote := conn.conn.RemoteAddr().String(); remote != ln.Addr().String() {
+		t.Errorf("connected to %s, expected %s", remote, ln.Addr())
+	}
+
+	resolver.lookupSRV = fakeSRVLookup(map[string][]*net.SRV{
+		"example.com": {{Target: "127.0.0.1.", Port: uint16(closedPort)}},
+	})
+	if _, err := DialDomain("example.com", DialWithSRVResolver(resolver)); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected network error, got %v", err)
+	}
+	if _, err := DialDomain("example.org", DialWithSRVResolver(resolver)); err == nil {
+		t.Error("expected error for an unknown domain")
+	}
+}
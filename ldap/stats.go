@@ -0,0 +1,99 @@
+// File contains the statistics of the connections
+
+package ldap
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnStats holds the statistics of a connection, as returned by Stats
+type ConnStats struct {
+	// Operations are the numbers of requests sent, by name of operation such
+	// as "Search", "Modify DN" or "Abandon"
+	Operations map[string]uint64
+	// BytesWritten is the size of the requests sent
+	BytesWritten uint64
+	// BytesRead is the size of the responses received
+	BytesRead uint64
+	// InFlight is the number of requests waiting for their response
+	InFlight int
+	// Reconnects is the number of connections which replaced a lost or
+	// expired one, for a ReconnectingConn
+	Reconnects uint64
+	// CreatedAt is the time the connection was created
+	CreatedAt time.Time
+	// LastActivity is the time a request was last sent or a response last
+	// received, or CreatedAt if none was
+	LastActivity time.Time
+}
+
+// connStats holds the counters of a connection
+type connStats struct {
+	mu           sync.Mutex
+	operations   map[string]uint64
+	bytesWritten uint64
+	bytesRead    uint64
+	lastActivity time.Time
+}
+
+// written records a request of the operation sent in n bytes
+func (s *connStats) written(operation string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.operations == nil {
+		s.operations = make(map[string]uint64)
+	}
+	s.operations[operation]++
+	s.bytesWritten += uint64(n)
+	s.lastActivity = time.Now()
+}
+
+// read records a response received in n bytes
+func (s *connStats) read(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesRead += uint64(n)
+	s.lastActivity = time.Now()
+}
+
+// Stats returns the statistics of the connection, for health dashboards. The
+// same numbers are reported as they occur to the Instrumentation set with
+// SetInstrumentation, if any.
+func (l *Conn) Stats() ConnStats {
+	l.stats.mu.Lock()
+	stats := ConnStats{
+		Operations:   make(map[string]uint64, len(l.stats.operations)),
+		BytesWritten: l.stats.bytesWritten,
+		BytesRead:    l.stats.bytesRead,
+		CreatedAt:    l.createdAt,
+		LastActivity: l.stats.lastActivity,
+	}
+	for operation, n := range l.stats.operations {
+		stats.Operations[operation] = n
+	}
+	l.stats.mu.Unlock()
+	if stats.LastActivity.IsZero() {
+		stats.LastActivity = stats.CreatedAt
+	}
+
+	l.messageMutex.Lock()
+	stats.InFlight = int(l.outstandingRequests)
+	l.messageMutex.Unlock()
+	return stats
+}
+
+// Stats returns the statistics of the current connection, along with the
+// number of reconnections. The statistics of the replaced connections are
+// not included, and are zero if the connection is closed.
+func (r *ReconnectingConn) Stats() ConnStats {
+	r.mu.Lock()
+	conn, reconnects := r.conn, r.reconnects
+	r.mu.Unlock()
+	var stats ConnStats
+	if conn != nil {
+		stats = conn.Stats()
+	}
+	stats.Reconnects = reconnects
+	return stats
+}
@@ -0,0 +1,49 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnStats(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	stats := conn.Stats()
+	if len(stats.Operations) != 0 || stats.BytesWritten != 0 || stats.BytesRead != 0 || !stats.LastActivity.Equal(stats.CreatedAt) {
+		t.Errorf("unexpected statistics of a new connection %+v", stats)
+	}
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Search(NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the responses are counted by the reader goroutine
+	deadline := time.Now().Add(5 * time.Second)
+	for stats = conn.Stats(); stats.BytesRead == 0 && time.Now().Before(deadline); stats = conn.Stats() {
+		time.Sleep(time.Millisecond)
+	}
+	if stats.Operations["Bind"] != 1 || stats.Operations["Search"] != 2 {
+		t.Errorf("unexpected operations %v", stats.Operations)
+	}
+	if stats.BytesWritten == 0 || stats.BytesRead == 0 {
+		t.Errorf("expected bytes to be counted, got %+v", stats)
+	}
+	if stats.InFlight != 0 {
+		t.Errorf("expected no request in flight, got %d", stats.InFlight)
+	}
+	if !stats.LastActivity.After(stats.CreatedAt) {
+		t.Errorf("expected the last activity to be recorded, got %s", stats.LastActivity)
+	}
+
+	stats.Operations["Bind"] = 10
+	if conn.Stats().Operations["Bind"] != 1 {
+		t.Error("expected the statistics to be a copy")
+	}
+}
@@ -0,0 +1,143 @@
+// File contains the copy and move of subtrees
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultDNAttributes are the attributes with DN syntax rewritten by
+// CopySubtree and MoveSubtree when SubtreeOptions.DNAttributes is empty
+var DefaultDNAttributes = []string{"member", "uniqueMember", "owner", "manager", "secretary", "seeAlso", "roleOccupant"}
+
+// SubtreeOptions are the options of CopySubtree and MoveSubtree
+type SubtreeOptions struct {
+	// DNAttributes are the attributes whose values are rewritten when they
+	// name an entry of the subtree, DefaultDNAttributes if empty
+	DNAttributes []string
+	// ExcludeAttributes are the attributes which are not copied, e.g. the
+	// attributes generated by the server
+	ExcludeAttributes []string
+}
+
+// CopySubtree copies the entry with the given DN and all of its
+// subordinates under newParentDN, returning the DN of the copy of the entry.
+// The values of the DN attributes of the options which name an entry of the
+// subtree are rewritten to name its copy, and the operational attributes are
+// not copied. options may be nil.
+func (l *Conn) CopySubtree(dn, newParentDN string, options *SubtreeOptions) (string, error) {
+	return l.CopySubtreeContext(context.Background(), dn, newParentDN, options)
+}
+
+// CopySubtreeContext is like CopySubtree, but abandons the current request
+// and returns ctx.Err() if ctx is done before the subtree is copied. This is
+// not atomic: if an error occurs, part of the subtree may be copied.
+func (l *Conn) CopySubtreeContext(ctx context.Context, dn, newParentDN string, options *SubtreeOptions) (string, error) {
+	newDN, _, err := l.copySubtree(ctx, dn, newParentDN, options)
+	return newDN, err
+}
+
+// copySubtree copies the subtree, returning the DN of the copy of its entry
+// and the copied entries, each entry before its children
+func (l *Conn) copySubtree(ctx context.Context, dn, newParentDN string, options *SubtreeOptions) (string, []*Entry, error) {
+	if options == nil {
+		options = &SubtreeOptions{}
+	}
+	source, err := ParseDN(dn)
+	if err != nil {
+		return "", nil, fmt.Errorf("ldap: invalid DN %q: %s", dn, err)
+	}
+	newParent, err := ParseDN(newParentDN)
+	if err != nil {
+		return "", nil, fmt.Errorf("ldap: invalid DN %q: %s", newParentDN, err)
+	}
+	if len(source.RDNs) == 0 {
+		return "", nil, errors.New("ldap: cannot copy the root DSE")
+	}
+	if source.EqualFold(newParent) || source.AncestorOfFold(newParent) {
+		return "", nil, fmt.Errorf("ldap: cannot copy %s under itself", dn)
+	}
+	target := &DN{RDNs: append([]*RelativeDN{source.RDNs[0]}, newParent.RDNs...)}
+
+	searchRequest := NewSearchRequest(dn, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"*"}, nil)
+	result, err := l.SearchContext(ctx, searchRequest)
+	if err != nil {
+		return "", nil, err
+	}
+	tree, err := result.Tree()
+	if err != nil {
+		return "", nil, err
+	}
+	root := tree.Find(dn)
+	if root == nil || root.Entry == nil {
+		return "", nil, fmt.Errorf("ldap: entry %s not found in its subtree", dn)
+	}
+
+	dnAttributes := options.DNAttributes
+	if len(dnAttributes) == 0 {
+		dnAttributes = DefaultDNAttributes
+	}
+	entries := root.Entries()
+	for _, entry := range entries {
+		add := NewAddRequest(rebaseDN(entry.DN, source, target))
+		for _, attribute := range entry.Attributes {
+			if containsFold(options.ExcludeAttributes, attribute.Name) {
+				continue
+			}
+			values := attribute.Values
+			if containsFold(dnAttributes, attribute.Name) {
+				values = make([]string, len(attribute.Values))
+				for i, value := range attribute.Values {
+					values[i] = rebaseDN(value, source, target)
+				}
+			}
+			add.Attribute(attribute.Name, values)
+		}
+		if err := l.AddContext(ctx, add); err != nil {
+			return "", nil, err
+		}
+	}
+	return target.String(), entries, nil
+}
+
+// MoveSubtree moves the entry with the given DN and all of its subordinates
+// under newParentDN, returning the new DN of the entry. It copies the
+// subtree as CopySubtree does, then deletes the copied entries, starting
+// with the deepest, for servers which cannot move entries with subordinates
+// using ModifyDN.
+// The DN values of the entries outside the subtree are not rewritten.
+// options may be nil.
+func (l *Conn) MoveSubtree(dn, newParentDN string, options *SubtreeOptions) (string, error) {
+	return l.MoveSubtreeContext(context.Background(), dn, newParentDN, options)
+}
+
+// MoveSubtreeContext is like MoveSubtree, but abandons the current request
+// and returns ctx.Err() if ctx is done before the subtree is moved. This is
+// not atomic: if an error occurs, the subtree may be partly copied, or
+// both copied and partly deleted.
+func (l *Conn) MoveSubtreeContext(ctx context.Context, dn, newParentDN string, options *SubtreeOptions) (string, error) {
+	newDN, entries, err := l.copySubtree(ctx, dn, newParentDN, options)
+	if err != nil {
+		return "", err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := l.DelContext(ctx, NewDelRequest(entries[i].DN, nil)); err != nil {
+			return "", err
+		}
+	}
+	return newDN, nil
+}
+
+// rebaseDN returns the DN with the source replaced by the target if it is
+// the source or one of its descendants, and as is otherwise
+func rebaseDN(dn string, source, target *DN) string {
+	parsed, err := ParseDN(dn)
+	if err != nil || !(source.EqualFold(parsed) || source.AncestorOfFold(parsed)) {
+		return dn
+	}
+	relative := parsed.RDNs[:len(parsed.RDNs)-len(source.RDNs)]
+	rebased := &DN{RDNs: append(append([]*RelativeDN(nil), relative...), target.RDNs...)}
+	return rebased.String()
+}
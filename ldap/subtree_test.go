@@ -0,0 +1,89 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCopyAndMoveSubtree(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	if err := conn.Add(&AddRequest{DN: "ou=archive,dc=example,dc=com", Attributes: []Attribute{{Type: "ou", Vals: []string{"archive"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	modify := NewModifyRequest("uid=jdoe,ou=people,dc=example,dc=com")
+	modify.Add("manager", []string{"uid=asmith,ou=people,dc=example,dc=com"})
+	modify.Add("seeAlso", []string{"dc=example,dc=com"})
+	if err := conn.Modify(modify); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.CopySubtree("ou=people,dc=example,dc=com", "uid=jdoe,ou=people,dc=example,dc=com", nil); err == nil {
+		t.Error("expected an error copying a subtree under itself")
+	}
+
+	newDN, err := conn.CopySubtree("ou=people,dc=example,dc=com", "ou=archive,dc=example,dc=com", &SubtreeOptions{ExcludeAttributes: []string{"userPassword"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newDN != "ou=people,ou=archive,dc=example,dc=com" {
+		t.Errorf("unexpected DN of the copy %q", newDN)
+	}
+	search := func(baseDN string) []*Entry {
+		result, err := conn.Search(NewSearchRequest(baseDN, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"*"}, nil))
+		if err != nil {
+			t.Fatalf("search %s: %s", baseDN, err)
+		}
+		return result.Entries
+	}
+	if entries := search("ou=people,dc=example,dc=com"); len(entries) != 3 {
+		t.Errorf("expected the source to be kept, got %d entries", len(entries))
+	}
+	copied := search(newDN)
+	if len(copied) != 3 {
+		t.Fatalf("expected 3 copied entries, got %d", len(copied))
+	}
+	var jdoe *Entry
+	for _, entry := range copied {
+		if entry.DN == "uid=jdoe,ou=people,ou=archive,dc=example,dc=com" {
+			jdoe = entry
+		}
+	}
+	if jdoe == nil {
+		t.Fatalf("copy of uid=jdoe not found in %v", copied)
+	}
+	if manager := jdoe.GetAttributeValue("manager"); manager != "uid=asmith,ou=people,ou=archive,dc=example,dc=com" {
+		t.Errorf("expected the manager to be rewritten, got %q", manager)
+	}
+	if seeAlso := jdoe.GetAttributeValue("seeAlso"); seeAlso != "dc=example,dc=com" {
+		t.Errorf("expected a DN outside the subtree to be kept, got %q", seeAlso)
+	}
+	if values := jdoe.GetAttributeValues("userPassword"); len(values) != 0 {
+		t.Errorf("expected the excluded attribute not to be copied, got %q", values)
+	}
+
+	if _, err := conn.MoveSubtree("ou=people,ou=archive,dc=example,dc=com", "dc=example,dc=com", nil); err == nil {
+		t.Fatal("expected an error moving the subtree onto an existing entry")
+	}
+	for _, dn := range []string{"uid=jdoe,ou=people,dc=example,dc=com", "uid=asmith,ou=people,dc=example,dc=com", "ou=people,dc=example,dc=com"} {
+		if err := conn.Del(NewDelRequest(dn, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newDN, err = conn.MoveSubtree("ou=people,ou=archive,dc=example,dc=com", "dc=example,dc=com", &SubtreeOptions{DNAttributes: []string{"manager"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newDN != "ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected new DN %q", newDN)
+	}
+	if entries := search("ou=archive,dc=example,dc=com"); len(entries) != 1 {
+		t.Errorf("expected the source to be deleted, got %d entries", len(entries))
+	}
+	moved := search("uid=jdoe,ou=people,dc=example,dc=com")
+	if len(moved) != 1 || !reflect.DeepEqual(moved[0].GetAttributeValues("manager"), []string{"uid=asmith,ou=people,dc=example,dc=com"}) {
+		t.Errorf("unexpected moved entry %v", moved)
+	}
+}
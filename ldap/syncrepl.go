@@ -0,0 +1,301 @@
+// File contains Content Synchronization (syncrepl) functionality
+//
+// https://tools.ietf.org/html/rfc4533
+//
+// syncInfoValue ::= CHOICE {
+//      newcookie      [0] syncCookie,
+//      refreshDelete  [1] SEQUENCE {
+//          cookie         syncCookie OPTIONAL,
+//          refreshDone    BOOLEAN DEFAULT TRUE
+//      },
+//      refreshPresent [2] SEQUENCE {
+//          cookie         syncCookie OPTIONAL,
+//          refreshDone    BOOLEAN DEFAULT TRUE
+//      },
+//      syncIdSet      [3] SEQUENCE {
+//          cookie         syncCookie OPTIONAL,
+//          refreshDeletes BOOLEAN DEFAULT FALSE,
+//          syncUUIDs      SET OF syncUUID
+//      }
+// }
+
+package ldap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// SyncInfoOID is the name of the Sync Info intermediate response message
+const SyncInfoOID = "1.3.6.1.4.1.4203.1.9.1.4"
+
+// Synchronization modes of the sync request control
+const (
+	SyncModeRefreshOnly       = 1
+	SyncModeRefreshAndPersist = 3
+)
+
+// Entry states of the sync state control
+const (
+	SyncStatePresent = 0
+	SyncStateAdd     = 1
+	SyncStateModify  = 2
+	SyncStateDelete  = 3
+)
+
+// SyncStateMap contains human readable descriptions of sync states
+var SyncStateMap = map[int]string{
+	SyncStatePresent: "Present",
+	SyncStateAdd:     "Add",
+	SyncStateModify:  "Modify",
+	SyncStateDelete:  "Delete",
+}
+
+// SyncEvent is delivered for every change reported by a synchronization.
+// State and EntryUUID are only set for events about an entry, other events
+// carry a new cookie or mark the end of the refresh stage.
+type SyncEvent struct {
+	// MessageID is the message ID of the search, which can be given to Abandon
+	MessageID int64
+	// State is the SyncState* constant describing the entry
+	State int
+	// EntryUUID identifies the entry
+	EntryUUID []byte
+	// Entry is the entry sent by the server. Deleted entries only carry their
+	// DN, and entries reported in a set of UUIDs have no entry at all.
+	Entry *Entry
+	// Cookie is the synchronization state to store for resuming, or nil if it
+	// did not change
+	Cookie []byte
+	// RefreshDone is set on the event ending the refresh stage
+	RefreshDone bool
+	// RefreshDeletes is set with RefreshDone if deleted entries were reported
+	// during the refresh. Otherwise, entries which were not reported as present
+	// have to be considered deleted.
+	RefreshDeletes bool
+	// Err is set on the last event if the synchronization ended with an error
+	Err error
+}
+
+// syncInfo is the decoded value of a Sync Info intermediate response message
+type syncInfo struct {
+	kind           asn1.Tag
+	cookie         []byte
+	refreshDone    bool
+	refreshDeletes bool
+	syncUUIDs      [][]byte
+}
+
+// Syncrepl synchronizes the content selected by the search request, starting
+// from the state of the given cookie, or from scratch if cookie is nil.
+//
+// In SyncModeRefreshOnly mode, the channel is closed after the event marking
+// the end of the refresh stage. In SyncModeRefreshAndPersist mode, changes are
+// delivered until ctx is done, at which point the synchronization is abandoned
+// and the channel is closed. Storing the last cookie received allows to resume
+// the synchronization later. If the server ends the synchronization with an
+// error, the channel is closed after an event carrying the error.
+func (l *Conn) Syncrepl(ctx context.Context, searchRequest *SearchRequest, mode int, cookie []byte, reloadHint bool) (<-chan *SyncEvent, error) {
+	control := NewControlSyncRequest(mode, cookie)
+	control.ReloadHint = reloadHint
+	controls := make([]Control, 0, len(searchRequest.Controls)+1)
+	controls = append(controls, searchRequest.Controls...)
+	controls = append(controls, control)
+
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, l.nextMessageID(), "MessageID"))
+	encodedSearchRequest, err := searchRequest.encode()
+	if err != nil {
+		return nil, err
+	}
+	packet.AppendChild(encodedSearchRequest)
+	packet.AppendChild(encodeControls(controls))
+
+	l.Debug.PrintPacket(packet)
+
+	msgCtx, err := l.sendMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *SyncEvent)
+	go func() {
+		defer close(events)
+		defer l.finishMessage(msgCtx)
+
+		send := func(event *SyncEvent) bool {
+			event.MessageID = msgCtx.id
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				l.abandon(msgCtx.id)
+				return false
+			}
+		}
+
+		for {
+			packet, err := l.receivePacket(ctx, msgCtx)
+			if err != nil {
+				if ctx.Err() == nil {
+					send(&SyncEvent{Err: err})
+				}
+				return
+			}
+
+			if l.Debug {
+				if err := addLDAPDescriptions(packet); err != nil {
+					send(&SyncEvent{Err: err})
+					return
+				}
+				asn1.PrintPacket(packet)
+			}
+
+			switch packet.Children[1].Tag {
+			case ApplicationSearchResultEntry:
+				event := &SyncEvent{Entry: decodeEntry(packet.Children[1])}
+				control, err := decodeResponseControl(packet, ControlTypeSyncState)
+				if err != nil {
+					if send(&SyncEvent{Err: err}) {
+						l.abandon(msgCtx.id)
+					}
+					return
+				}
+				if c, ok := control.(*ControlSyncState); ok {
+					event.State = c.State
+					event.EntryUUID = c.EntryUUID
+					event.Cookie = c.Cookie
+				}
+				if !send(event) {
+					return
+				}
+			case ApplicationIntermediateResponse:
+				info, err := decodeSyncInfo(packet.Children[1])
+				if err != nil {
+					if send(&SyncEvent{Err: err}) {
+						l.abandon(msgCtx.id)
+					}
+					return
+				}
+				if info == nil {
+					continue
+				}
+				for _, event := range info.events() {
+					if !send(event) {
+						return
+					}
+				}
+			case ApplicationSearchResultDone:
+				resultCode, _ := getLDAPResultCode(packet)
+				if resultCode != LDAPResultSuccess {
+					send(&SyncEvent{Err: newResultError(packet)})
+					return
+				}
+				control, err := decodeResponseControl(packet, ControlTypeSyncDone)
+				if err != nil {
+					send(&SyncEvent{Err: err})
+					return
+				}
+				event := &SyncEvent{RefreshDone: true}
+				if c, ok := control.(*ControlSyncDone); ok {
+					event.Cookie = c.Cookie
+					event.RefreshDeletes = c.RefreshDeletes
+				}
+				send(event)
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// events returns the events described by the Sync Info message
+func (info *syncInfo) events() []*SyncEvent {
+	switch info.kind {
+	case 0:
+		return []*SyncEvent{{Cookie: info.cookie}}
+	case 1, 2:
+		if !info.refreshDone && info.cookie == nil {
+			return nil
+		}
+		return []*SyncEvent{{
+			Cookie:         info.cookie,
+			RefreshDone:    info.refreshDone,
+			RefreshDeletes: info.refreshDone && info.kind == 1,
+		}}
+	}
+
+	state := SyncStatePresent
+	if info.refreshDeletes {
+		state = SyncStateDelete
+	}
+	events := make([]*SyncEvent, 0, len(info.syncUUIDs))
+	for _, uuid := range info.syncUUIDs {
+		events = append(events, &SyncEvent{State: state, EntryUUID: uuid})
+	}
+	if info.cookie != nil {
+		if len(events) == 0 {
+			events = append(events, &SyncEvent{})
+		}
+		events[len(events)-1].Cookie = info.cookie
+	}
+	return events
+}
+
+// decodeSyncInfo decodes the Sync Info message held by an intermediate
+// response. It returns nil if the response is not a Sync Info message.
+func decodeSyncInfo(response *asn1.Packet) (info *syncInfo, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = NewError(ErrorUnexpectedResponse, errors.New("ldap: cannot decode sync info message"))
+		}
+	}()
+
+	var name string
+	var value *asn1.Packet
+	for _, child := range response.Children {
+		switch child.Tag {
+		case 0:
+			name = asn1.DecodeString(child.Data.Bytes())
+		case 1:
+			value = asn1.DecodePacket(child.Data.Bytes())
+		}
+	}
+	if name != SyncInfoOID || value == nil {
+		return nil, nil
+	}
+
+	info = &syncInfo{kind: value.Tag}
+	switch value.Tag {
+	case 0:
+		info.cookie = value.Data.Bytes()
+	case 1, 2:
+		info.refreshDone = true
+		for _, child := range value.Children {
+			switch child.Tag {
+			case asn1.TagOctetString:
+				info.cookie = child.Data.Bytes()
+			case asn1.TagBoolean:
+				info.refreshDone = child.Value.(bool)
+			}
+		}
+	case 3:
+		for _, child := range value.Children {
+			switch child.Tag {
+			case asn1.TagOctetString:
+				info.cookie = child.Data.Bytes()
+			case asn1.TagBoolean:
+				info.refreshDeletes = child.Value.(bool)
+			case asn1.TagSet:
+				for _, uuid := range child.Children {
+					info.syncUUIDs = append(info.syncUUIDs, uuid.Data.Bytes())
+				}
+			}
+		}
+	default:
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: unknown sync info message"))
+	}
+	return info, nil
+}
@@ -0,0 +1,192 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newSyncInfoPacket returns an intermediate response packet holding the given
+// Sync Info message value.
+func newSyncInfoPacket(messageID int64, value *asn1.Packet) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationIntermediateResponse, nil, "Intermediate Response")
+	response.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, SyncInfoOID, "Response Name"))
+	responseValue := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, 1, nil, "Response Value")
+	responseValue.Data.Write(value.Bytes())
+	response.AppendChild(responseValue)
+	packet.AppendChild(response)
+	return packet
+}
+
+func newSyncEntryPacket(messageID int64, dn string, state int, uuid []byte) *asn1.Packet {
+	packet := newEntryPacket(messageID, dn)
+	packet.AppendChild(encodeControls([]Control{&ControlSyncState{State: state, EntryUUID: uuid}}))
+	return packet
+}
+
+func TestSyncreplRefreshOnly(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	uuids := [][]byte{bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16), bytes.Repeat([]byte{3}, 16)}
+	requests := make(chan *asn1.Packet, 1)
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		requests <- request
+		messageID := request.Children[0].Value.(int64)
+
+		idSet := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 3, nil, "syncIdSet")
+		idSet.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, true, "refreshDeletes"))
+		set := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSet, nil, "syncUUIDs")
+		for _, uuid := range uuids[1:] {
+			set.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, string(uuid), "syncUUID"))
+		}
+		idSet.AppendChild(set)
+
+		done := newResultPacket(messageID, ApplicationSearchResultDone, LDAPResultSuccess, "")
+		done.AppendChild(encodeControls([]Control{&ControlSyncDone{Cookie: []byte("csn=2"), RefreshDeletes: true}}))
+		return []*asn1.Packet{
+			newSyncEntryPacket(messageID, "cn=a,dc=example,dc=com", SyncStateAdd, uuids[0]),
+			newSyncInfoPacket(messageID, idSet),
+			done,
+		}
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.Syncrepl(context.Background(), searchRequest, SyncModeRefreshOnly, []byte("csn=1"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []*SyncEvent
+	runWithTimeout(t, time.Second, func() {
+		for event := range events {
+			received = append(received, event)
+		}
+	})
+
+	request := <-requests
+	control, ok := DecodeControl(request.Children[2].Children[0]).(*ControlSyncRequest)
+	if !ok || control.Mode != SyncModeRefreshOnly || string(control.Cookie) != "csn=1" {
+		t.Errorf("unexpected sync request control %v", DecodeControl(request.Children[2].Children[0]))
+	}
+
+	if len(received) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(received))
+	}
+	if e := received[0]; e.State != SyncStateAdd || !bytes.Equal(e.EntryUUID, uuids[0]) || e.Entry.DN != "cn=a,dc=example,dc=com" {
+		t.Errorf("unexpected add event %+v", e)
+	}
+	for i, e := range received[1:3] {
+		if e.State != SyncStateDelete || !bytes.Equal(e.EntryUUID, uuids[i+1]) || e.Entry != nil {
+			t.Errorf("unexpected delete event %+v", e)
+		}
+	}
+	if e := received[3]; !e.RefreshDone || !e.RefreshDeletes || string(e.Cookie) != "csn=2" || e.Err != nil {
+		t.Errorf("unexpected final event %+v", e)
+	}
+}
+
+func TestSyncreplRefreshAndPersist(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	uuid := bytes.Repeat([]byte{1}, 16)
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		messageID := request.Children[0].Value.(int64)
+		refreshPresent := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 2, nil, "refreshPresent")
+		refreshPresent.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "csn=1", "cookie"))
+		newCookie := asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 0, "csn=2", "newcookie")
+		return []*asn1.Packet{
+			newSyncEntryPacket(messageID, "cn=a,dc=example,dc=com", SyncStatePresent, uuid),
+			newSyncInfoPacket(messageID, refreshPresent),
+			newSyncEntryPacket(messageID, "cn=a,dc=example,dc=com", SyncStateModify, uuid),
+			newSyncInfoPacket(messageID, newCookie),
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.Syncrepl(ctx, searchRequest, SyncModeRefreshAndPersist, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []*SyncEvent
+	runWithTimeout(t, time.Second, func() {
+		for len(received) < 4 {
+			received = append(received, <-events)
+		}
+	})
+	if e := received[0]; e.State != SyncStatePresent || !bytes.Equal(e.EntryUUID, uuid) {
+		t.Errorf("unexpected present event %+v", e)
+	}
+	if e := received[1]; !e.RefreshDone || e.RefreshDeletes || string(e.Cookie) != "csn=1" {
+		t.Errorf("unexpected refresh done event %+v", e)
+	}
+	if e := received[2]; e.State != SyncStateModify || e.Entry == nil {
+		t.Errorf("unexpected modify event %+v", e)
+	}
+	if e := received[3]; e.EntryUUID != nil || string(e.Cookie) != "csn=2" {
+		t.Errorf("unexpected cookie event %+v", e)
+	}
+
+	cancel()
+	runWithTimeout(t, time.Second, func() {
+		abandon, err := ptc.ReceiveRequest()
+		if err != nil {
+			t.Fatalf("unable to receive abandon request: %s", err)
+		}
+		if abandon.Children[1].Tag != ApplicationAbandonRequest {
+			t.Errorf("expected abandon request, got application tag %d", abandon.Children[1].Tag)
+		}
+		if _, ok := <-events; ok {
+			t.Errorf("expected events channel to be closed")
+		}
+	})
+}
+
+func TestSyncreplInvalidSyncState(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		entry := newEntryPacket(request.Children[0].Value.(int64), "cn=a,dc=example,dc=com")
+		controls := asn1.Encode(asn1.ClassContext, asn1.TypeConstructed, 0, nil, "Controls")
+		controls.AppendChild(newControlPacket(ControlTypeSyncState, nil))
+		entry.AppendChild(controls)
+		return []*asn1.Packet{entry}
+	})
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	events, err := conn.Syncrepl(context.Background(), searchRequest, SyncModeRefreshAndPersist, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runWithTimeout(t, time.Second, func() {
+		event := <-events
+		if !IsErrorWithCode(event.Err, ErrorUnexpectedResponse) {
+			t.Errorf("expected unexpected response error, got %v", event.Err)
+		}
+		if _, ok := <-events; ok {
+			t.Errorf("expected events channel to be closed")
+		}
+	})
+}
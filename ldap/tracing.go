@@ -0,0 +1,133 @@
+// File contains the tracing hooks of the operations
+
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// Tracer starts a span for each operation performed on the connections it is
+// set on, to be adapted to a distributed tracing system such as OpenTelemetry:
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, operation string, attributes ldap.SpanAttributes) (context.Context, ldap.Span) {
+//		ctx, span := t.tracer.Start(ctx, "ldap "+operation)
+//		span.SetAttributes(attribute.String("ldap.dn", attributes.DN))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	// StartSpan starts the span of an operation, such as "Search" or "Modify
+	// DN", as a child of the span held by ctx. The returned context is the one
+	// the operation is performed with, so that the referrals it follows are
+	// traced as its children.
+	StartSpan(ctx context.Context, operation string, attributes SpanAttributes) (context.Context, Span)
+}
+
+// SpanAttributes describe the operation of a span
+type SpanAttributes struct {
+	// DN is the DN of the entry targeted by the operation, its base DN for a
+	// search or the name bound with for a simple bind
+	DN string
+	// FilterHash is a hash of the filter of a search, identifying similar
+	// searches without recording the values they hold
+	FilterHash string
+}
+
+// Span is the span of an operation started by a Tracer
+type Span interface {
+	// End ends the span with the result code of the operation, and the error
+	// it returned if any. The result code is ErrorNetwork if no result was
+	// received.
+	End(resultCode uint8, err error)
+}
+
+// SetTracer sets the tracer starting the spans of the operations performed
+// with Do, Search and SearchStream, and the operations built on them. Spans
+// are not started if tracer is nil, which is the default.
+func (l *Conn) SetTracer(tracer Tracer) {
+	l.tracer.Store(tracerValue{tracer})
+}
+
+// tracerValue holds the Tracer of a connection, as an atomicValue cannot hold
+// a nil interface
+type tracerValue struct {
+	Tracer
+}
+
+func (l *Conn) loadTracer() Tracer {
+	value, _ := l.tracer.Load().(tracerValue)
+	return value.Tracer
+}
+
+// SetTracer sets the tracer of the connection and of the connections
+// replacing it
+func (r *ReconnectingConn) SetTracer(tracer Tracer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tracer = tracer
+	if r.conn != nil {
+		r.conn.SetTracer(tracer)
+	}
+}
+
+// startSpan starts the span of an operation, which does nothing if no tracer
+// is set
+func (l *Conn) startSpan(ctx context.Context, operation string, attributes SpanAttributes) (context.Context, Span) {
+	tracer := l.loadTracer()
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.StartSpan(ctx, operation, attributes)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(uint8, error) {}
+
+// resultCodeOf returns the result code of the error returned by an operation
+func resultCodeOf(err error) uint8 {
+	if err == nil {
+		return LDAPResultSuccess
+	}
+	if e, ok := err.(*Error); ok {
+		return e.ResultCode
+	}
+	return ErrorNetwork
+}
+
+// requestAttributes returns the attributes of the span of the request
+func requestAttributes(request Request) SpanAttributes {
+	switch r := request.(type) {
+	case *AddRequest:
+		return SpanAttributes{DN: r.DN}
+	case *DelRequest:
+		return SpanAttributes{DN: r.DN}
+	case *ModifyRequest:
+		return SpanAttributes{DN: r.DN}
+	case *ModifyDNRequest:
+		return SpanAttributes{DN: r.DN}
+	case *compareRequest:
+		return SpanAttributes{DN: r.DN}
+	case *SimpleBindRequest:
+		return SpanAttributes{DN: r.Username}
+	case *PasswordModifyRequest:
+		return SpanAttributes{DN: r.UserIdentity}
+	}
+	return SpanAttributes{}
+}
+
+// searchAttributes returns the attributes of the span of the search
+func searchAttributes(searchRequest *SearchRequest) SpanAttributes {
+	return SpanAttributes{
+		DN:         searchRequest.BaseDN,
+		FilterHash: hashFilter(searchRequest.Filter),
+	}
+}
+
+// hashFilter returns the FNV-1a hash of the filter
+func hashFilter(filter string) string {
+	h := fnv.New64a()
+	h.Write([]byte(filter))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+type tracingKey struct{}
+
+// recordingTracer records the spans it starts
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	operation  string
+	attributes SpanAttributes
+	parent     interface{}
+	ended      bool
+	resultCode uint8
+	err        error
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, operation string, attributes SpanAttributes) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &recordingSpan{operation: operation, attributes: attributes, parent: ctx.Value(tracingKey{})}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, tracingKey{}, span), span
+}
+
+func (s *recordingSpan) End(resultCode uint8, err error) {
+	s.ended = true
+	s.resultCode = resultCode
+	s.err = err
+}
+
+func TestTracer(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	tracer := &recordingTracer{}
+	conn.SetTracer(tracer)
+
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationSearchResultDone, LDAPResultSuccess, "")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultNoSuchObject, "")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationCompareResponse, LDAPResultCompareFalse, "")}
+		})
+	}()
+
+	ctx := context.WithValue(context.Background(), tracingKey{}, "caller")
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=jdoe)", nil, nil)
+	runWithTimeout(t, 5*time.Second, func() {
+		if _, err := conn.SearchContext(ctx, searchRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := conn.DelContext(ctx, NewDelRequest("cn=a,dc=example,dc=com", nil)); !IsNoSuchObject(err) {
+			t.Fatalf("expected no such object, got %v", err)
+		}
+		if _, err := conn.CompareContext(ctx, "cn=b,dc=example,dc=com", "cn", "c"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	expected := []recordingSpan{
+		{operation: "Search", attributes: SpanAttributes{DN: "dc=example,dc=com", FilterHash: hashFilter("(uid=jdoe)")}, resultCode: LDAPResultSuccess},
+		{operation: "Del", attributes: SpanAttributes{DN: "cn=a,dc=example,dc=com"}, resultCode: LDAPResultNoSuchObject},
+		{operation: "Compare", attributes: SpanAttributes{DN: "cn=b,dc=example,dc=com"}, resultCode: LDAPResultCompareFalse},
+	}
+	if len(tracer.spans) != len(expected) {
+		t.Fatalf("got %d spans, expected %d", len(tracer.spans), len(expected))
+	}
+	for i, span := range tracer.spans {
+		if span.operation != expected[i].operation || span.attributes != expected[i].attributes {
+			t.Errorf("got span %s %+v, expected %s %+v", span.operation, span.attributes, expected[i].operation, expected[i].attributes)
+		}
+		if !span.ended || span.resultCode != expected[i].resultCode {
+			t.Errorf("span %s: got result code %d (ended %t), expected %d", span.operation, span.resultCode, span.ended, expected[i].resultCode)
+		}
+		if span.parent != "caller" {
+			t.Errorf("span %s: expected the context of the caller, got %v", span.operation, span.parent)
+		}
+	}
+	if hashFilter("(uid=jdoe)") == hashFilter("(uid=jsmith)") {
+		t.Errorf("expected different filters to have different hashes")
+	}
+}
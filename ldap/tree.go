@@ -0,0 +1,160 @@
+// File contains the arrangement of entries into a tree of the DIT
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSkipChildren is returned by the function of Walk to skip the children
+// of the current node
+var ErrSkipChildren = errors.New("ldap: skip children")
+
+// TreeNode is a node of a Tree
+type TreeNode struct {
+	// DN is the DN of the node
+	DN *DN
+	// Entry is the entry of the node, or nil if the entry is not in the
+	// results but has descendants in them
+	Entry *Entry
+	// Parent is the parent node, or nil for roots
+	Parent *TreeNode
+	// Children are the child nodes, in the order of the entries
+	Children []*TreeNode
+}
+
+// Tree holds entries, such as search results, arranged by DN
+type Tree struct {
+	// Roots are the nodes without an ancestor in the entries, in the order
+	// of the entries
+	Roots []*TreeNode
+
+	nodes map[string]*TreeNode
+}
+
+// NewTree arranges the entries into a tree. Each entry is the child of its
+// closest ancestor in the entries, with nodes without Entry for the missing
+// entries in between, and the entries without ancestors are the roots of the
+// tree, e.g. the base entry of a subtree search. DNs are compared regardless
+// of case.
+func NewTree(entries []*Entry) (*Tree, error) {
+	t := &Tree{nodes: make(map[string]*TreeNode, len(entries))}
+	nodes := make([]*TreeNode, 0, len(entries))
+	for _, entry := range entries {
+		key, dn, err := entryKey(entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := t.nodes[key]; ok {
+			return nil, fmt.Errorf("ldap: duplicate entry %s", entry.DN)
+		}
+		node := &TreeNode{DN: dn, Entry: entry}
+		t.nodes[key] = node
+		nodes = append(nodes, node)
+	}
+
+	for _, node := range nodes {
+		if node.Parent == nil {
+			t.attach(node)
+		}
+	}
+	return t, nil
+}
+
+// Tree arranges the entries of the search result into a tree, see NewTree
+func (s *SearchResult) Tree() (*Tree, error) {
+	return NewTree(s.Entries)
+}
+
+// attach makes the node a child of its closest ancestor, adding the missing
+// nodes in between, or a root if it has none
+func (t *Tree) attach(node *TreeNode) {
+	var missing []*DN
+	var ancestor *TreeNode
+	for dn := node.DN.Parent(); dn != nil && len(dn.RDNs) > 0; dn = dn.Parent() {
+		if ancestor = t.nodes[dnKey(dn)]; ancestor != nil {
+			break
+		}
+		missing = append(missing, dn)
+	}
+	if ancestor == nil {
+		t.Roots = append(t.Roots, node)
+		return
+	}
+
+	// link the missing nodes from the ancestor down
+	for i := len(missing) - 1; i >= 0; i-- {
+		between := &TreeNode{DN: missing[i], Parent: ancestor}
+		ancestor.Children = append(ancestor.Children, between)
+		t.nodes[dnKey(missing[i])] = between
+		ancestor = between
+	}
+	node.Parent = ancestor
+	ancestor.Children = append(ancestor.Children, node)
+}
+
+// dnKey returns the key of the DN in the nodes of a tree
+func dnKey(dn *DN) string {
+	key, _, _ := entryKey(dn.String())
+	return key
+}
+
+// Find returns the node of the DN, or nil if the tree has none
+func (t *Tree) Find(dn string) *TreeNode {
+	key, _, err := entryKey(dn)
+	if err != nil {
+		return nil
+	}
+	return t.nodes[key]
+}
+
+// Walk calls fn for every node of the tree, depth first, each node before
+// its children. If fn returns ErrSkipChildren, the children of the node are
+// skipped, and if it returns another error, Walk stops and returns it.
+func (t *Tree) Walk(fn func(node *TreeNode) error) error {
+	for _, root := range t.Roots {
+		if err := root.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for the node and its descendants, as Tree.Walk does
+func (n *TreeNode) Walk(fn func(node *TreeNode) error) error {
+	if err := fn(n); err != nil {
+		if err == ErrSkipChildren {
+			return nil
+		}
+		return err
+	}
+	for _, child := range n.Children {
+		if err := child.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Depth returns the number of ancestors of the node in the tree, 0 for roots
+func (n *TreeNode) Depth() int {
+	depth := 0
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		depth++
+	}
+	return depth
+}
+
+// Entries returns the entries of the node and its descendants, each entry
+// before the entries of its children
+func (n *TreeNode) Entries() []*Entry {
+	var entries []*Entry
+	n.Walk(func(node *TreeNode) error {
+		if node.Entry != nil {
+			entries = append(entries, node.Entry)
+		}
+		return nil
+	})
+	return entries
+}
@@ -0,0 +1,81 @@
+package ldap
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	entries := []*Entry{
+		NewEntry("uid=jdoe,ou=people,dc=example,dc=com", nil),
+		NewEntry("dc=example,dc=com", nil),
+		NewEntry("cn=admins,ou=groups,dc=example,dc=com", nil),
+		NewEntry("OU=People,DC=Example,DC=Com", nil),
+		NewEntry("uid=asmith,ou=people,dc=example,dc=com", nil),
+		NewEntry("dc=other,dc=org", nil),
+	}
+	tree, err := (&SearchResult{Entries: entries}).Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	err = tree.Walk(func(node *TreeNode) error {
+		walked = append(walked, strings.Repeat("  ", node.Depth())+node.DN.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"dc=example,dc=com",
+		"  ou=groups,dc=example,dc=com",
+		"    cn=admins,ou=groups,dc=example,dc=com",
+		"  OU=People,DC=Example,DC=Com",
+		"    uid=jdoe,ou=people,dc=example,dc=com",
+		"    uid=asmith,ou=people,dc=example,dc=com",
+		"dc=other,dc=org",
+	}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("expected the tree\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(walked, "\n"))
+	}
+
+	if node := tree.Find("ou=groups,dc=example,dc=com"); node == nil || node.Entry != nil || len(node.Children) != 1 {
+		t.Errorf("expected a node without entry for the missing parent, got %#v", node)
+	}
+	people := tree.Find("ou=people, dc=example, dc=com")
+	if people == nil || people.Entry != entries[3] || people.Parent != tree.Roots[0] {
+		t.Fatalf("unexpected node %#v", people)
+	}
+	if found := people.Entries(); !reflect.DeepEqual(found, []*Entry{entries[3], entries[0], entries[4]}) {
+		t.Errorf("unexpected entries of the subtree %v", found)
+	}
+	if node := tree.Find("dc=missing"); node != nil {
+		t.Errorf("expected no node, got %#v", node)
+	}
+
+	walked = nil
+	tree.Walk(func(node *TreeNode) error {
+		walked = append(walked, node.DN.String())
+		if node == people {
+			return ErrSkipChildren
+		}
+		return nil
+	})
+	if len(walked) != 5 {
+		t.Errorf("expected the children of %s to be skipped, got %q", people.DN, walked)
+	}
+	stop := errors.New("stop")
+	if err := tree.Walk(func(node *TreeNode) error { return stop }); err != stop {
+		t.Errorf("expected the walk to stop, got %v", err)
+	}
+
+	if _, err := NewTree([]*Entry{NewEntry("dc=com", nil), NewEntry("DC=COM", nil)}); err == nil {
+		t.Error("expected an error for duplicate entries")
+	}
+	if _, err := NewTree([]*Entry{NewEntry("invalid", nil)}); err == nil {
+		t.Error("expected an error for an invalid DN")
+	}
+}
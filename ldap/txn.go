@@ -0,0 +1,182 @@
+// File contains LDAP Transactions functionality
+//
+// https://tools.ietf.org/html/rfc5805
+//
+// txnEndReq ::= SEQUENCE {
+//      commit         BOOLEAN DEFAULT TRUE,
+//      identifier     OCTET STRING }
+//
+// txnEndRes ::= SEQUENCE {
+//      messageID MessageID OPTIONAL,
+//           -- msgid associated with non-success resultCode
+//      updatesControls SEQUENCE OF updateControls SEQUENCE {
+//           messageID MessageID,
+//                -- msgid associated with controls
+//           controls  Controls
+//      } OPTIONAL
+// }
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// Transaction extended operations
+const (
+	StartTransactionOID = "1.3.6.1.1.21.1"
+	EndTransactionOID   = "1.3.6.1.1.21.3"
+)
+
+// Txn is a transaction started with StartTransaction. The updates performed
+// through it are only applied when the transaction is committed, and they
+// are applied atomically.
+type Txn struct {
+	conn *Conn
+	// ID is the transaction identifier assigned by the server
+	ID []byte
+}
+
+// StartTransaction starts a transaction
+func (l *Conn) StartTransaction() (*Txn, error) {
+	return l.StartTransactionContext(context.Background())
+}
+
+// StartTransactionContext starts a transaction. If ctx is done before the
+// server responds, the request is abandoned and ctx.Err() is returned.
+func (l *Conn) StartTransactionContext(ctx context.Context) (*Txn, error) {
+	response, err := l.ExtendedContext(ctx, NewExtendedRequest(StartTransactionOID, nil))
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Value) == 0 {
+		return nil, NewError(ErrorUnexpectedResponse, errors.New("ldap: server did not return a transaction identifier"))
+	}
+	return &Txn{conn: l, ID: response.Value}, nil
+}
+
+// EndTransaction commits or aborts the transaction with the given identifier
+func (l *Conn) EndTransaction(id []byte, commit bool) error {
+	return l.EndTransactionContext(context.Background(), id, commit)
+}
+
+// EndTransactionContext commits or aborts the transaction with the given
+// identifier. If ctx is done before the server responds, the request is
+// abandoned and ctx.Err() is returned.
+func (l *Conn) EndTransactionContext(ctx context.Context, id []byte, commit bool) error {
+	value := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Transaction End Request")
+	if !commit {
+		value.AppendChild(asn1.NewBoolean(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagBoolean, commit, "Commit"))
+	}
+	identifier := asn1.Encode(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, nil, "Identifier")
+	identifier.Value = id
+	identifier.Data.Write(id)
+	value.AppendChild(identifier)
+
+	response, err := l.ExtendedContext(ctx, NewExtendedRequest(EndTransactionOID, value.Bytes()))
+	if err == nil || response == nil || len(response.Value) == 0 {
+		return err
+	}
+
+	// report the update which made the transaction fail
+	if result := asn1.DecodePacket(response.Value); len(result.Children) > 0 {
+		if messageID, ok := result.Children[0].Value.(int64); ok {
+			if e, ok := err.(*Error); ok {
+				e.Err = fmt.Errorf("update with message ID %d failed: %s", messageID, e.Err)
+			}
+		}
+	}
+	return err
+}
+
+// control returns the transaction specification control of the transaction
+func (t *Txn) control() Control {
+	return NewControlTransactionSpecification(t.ID)
+}
+
+// controls returns the given controls with the transaction specification control
+func (t *Txn) controls(controls []Control) []Control {
+	return append(append(make([]Control, 0, len(controls)+1), controls...), t.control())
+}
+
+// Add performs the given AddRequest as part of the transaction
+func (t *Txn) Add(addRequest *AddRequest) error {
+	return t.AddContext(context.Background(), addRequest)
+}
+
+// AddContext performs the given AddRequest as part of the transaction. If ctx
+// is done before the server responds, the request is abandoned and ctx.Err()
+// is returned.
+func (t *Txn) AddContext(ctx context.Context, addRequest *AddRequest) error {
+	req := *addRequest
+	req.Controls = t.controls(addRequest.Controls)
+	return t.conn.AddContext(ctx, &req)
+}
+
+// Del performs the given DelRequest as part of the transaction
+func (t *Txn) Del(delRequest *DelRequest) error {
+	return t.DelContext(context.Background(), delRequest)
+}
+
+// DelContext performs the given DelRequest as part of the transaction. If ctx
+// is done before the server responds, the request is abandoned and ctx.Err()
+// is returned.
+func (t *Txn) DelContext(ctx context.Context, delRequest *DelRequest) error {
+	req := *delRequest
+	req.Controls = t.controls(delRequest.Controls)
+	return t.conn.DelContext(ctx, &req)
+}
+
+// Modify performs the given ModifyRequest as part of the transaction
+func (t *Txn) Modify(modifyRequest *ModifyRequest) error {
+	return t.ModifyContext(context.Background(), modifyRequest)
+}
+
+// ModifyContext performs the given ModifyRequest as part of the transaction.
+// If ctx is done before the server responds, the request is abandoned and
+// ctx.Err() is returned.
+func (t *Txn) ModifyContext(ctx context.Context, modifyRequest *ModifyRequest) error {
+	req := *modifyRequest
+	req.Controls = t.controls(modifyRequest.Controls)
+	return t.conn.ModifyContext(ctx, &req)
+}
+
+// ModifyDN performs the given ModifyDNRequest as part of the transaction
+func (t *Txn) ModifyDN(modifyDNRequest *ModifyDNRequest) error {
+	return t.ModifyDNContext(context.Background(), modifyDNRequest)
+}
+
+// ModifyDNContext performs the given ModifyDNRequest as part of the
+// transaction. If ctx is done before the server responds, the request is
+// abandoned and ctx.Err() is returned.
+func (t *Txn) ModifyDNContext(ctx context.Context, modifyDNRequest *ModifyDNRequest) error {
+	req := *modifyDNRequest
+	req.Controls = t.controls(modifyDNRequest.Controls)
+	return t.conn.ModifyDNContext(ctx, &req)
+}
+
+// Commit applies all updates of the transaction
+func (t *Txn) Commit() error {
+	return t.conn.EndTransaction(t.ID, true)
+}
+
+// CommitContext is like Commit, but abandons the request and returns
+// ctx.Err() if ctx is done before the server responds.
+func (t *Txn) CommitContext(ctx context.Context) error {
+	return t.conn.EndTransactionContext(ctx, t.ID, true)
+}
+
+// Rollback discards all updates of the transaction
+func (t *Txn) Rollback() error {
+	return t.conn.EndTransaction(t.ID, false)
+}
+
+// RollbackContext is like Rollback, but abandons the request and returns
+// ctx.Err() if ctx is done before the server responds.
+func (t *Txn) RollbackContext(ctx context.Context) error {
+	return t.conn.EndTransactionContext(ctx, t.ID, false)
+}
@@ -0,0 +1,128 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newExtendedResponsePacket returns an extended response packet with the given
+// result code and response value.
+func newExtendedResponsePacket(messageID int64, resultCode int, value []byte) *asn1.Packet {
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, messageID, "MessageID"))
+	response := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagEnumerated, resultCode, "Result Code"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Matched DN"))
+	response.AppendChild(asn1.NewString(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagOctetString, "", "Diagnostic Message"))
+	if value != nil {
+		responseValue := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, 11, nil, "Response Value")
+		responseValue.Data.Write(value)
+		response.AppendChild(responseValue)
+	}
+	packet.AppendChild(response)
+	return packet
+}
+
+func TestTransaction(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *asn1.Packet, 4)
+	go func() {
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			requests <- p
+			return []*asn1.Packet{newExtendedResponsePacket(p.Children[0].Value.(int64), LDAPResultSuccess, []byte("txn-1"))}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			requests <- p
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationAddResponse, LDAPResultSuccess, "")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			requests <- p
+			return []*asn1.Packet{newResultPacket(p.Children[0].Value.(int64), ApplicationDelResponse, LDAPResultSuccess, "")}
+		})
+		serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+			requests <- p
+			return []*asn1.Packet{newExtendedResponsePacket(p.Children[0].Value.(int64), LDAPResultSuccess, nil)}
+		})
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		txn, err := conn.StartTransaction()
+		if err != nil {
+			t.Fatalf("unable to start transaction: %s", err)
+		}
+		if string(txn.ID) != "txn-1" {
+			t.Fatalf("unexpected transaction identifier %q", txn.ID)
+		}
+		addRequest := NewAddRequest("cn=new,dc=example,dc=com")
+		addRequest.Attribute("objectClass", []string{"person"})
+		if err := txn.Add(addRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		delRequest := NewDelRequest("cn=old,dc=example,dc=com", nil)
+		if err := txn.Del(delRequest); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if delRequest.Controls != nil {
+			t.Errorf("transaction must not modify the request")
+		}
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unable to commit transaction: %s", err)
+		}
+	})
+
+	start := <-requests
+	if name := asn1.DecodeString(start.Children[1].Children[0].Data.Bytes()); name != StartTransactionOID {
+		t.Errorf("unexpected start transaction request name %q", name)
+	}
+	for i := 0; i < 2; i++ {
+		update := <-requests
+		if len(update.Children) != 3 {
+			t.Fatalf("expected update %d to carry controls", i)
+		}
+		c, ok := DecodeControl(update.Children[2].Children[0]).(*ControlTransactionSpecification)
+		if !ok || string(c.TransactionID) != "txn-1" {
+			t.Errorf("expected transaction specification control on update %d", i)
+		}
+	}
+	end := <-requests
+	if name := asn1.DecodeString(end.Children[1].Children[0].Data.Bytes()); name != EndTransactionOID {
+		t.Errorf("unexpected end transaction request name %q", name)
+	}
+	value := asn1.DecodePacket(end.Children[1].Children[1].Data.Bytes())
+	if len(value.Children) != 1 || string(value.Children[0].Data.Bytes()) != "txn-1" {
+		t.Errorf("expected commit of transaction txn-1 with default commit flag")
+	}
+}
+
+func TestTransactionFailure(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		value := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "txnEndRes")
+		value.AppendChild(asn1.NewInteger(asn1.ClassUniversal, asn1.TypePrimitive, asn1.TagInteger, 7, "MessageID"))
+		return []*asn1.Packet{newExtendedResponsePacket(p.Children[0].Value.(int64), LDAPResultEntryAlreadyExists, value.Bytes())}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		err := conn.EndTransaction([]byte("txn-1"), true)
+		if !IsErrorWithCode(err, LDAPResultEntryAlreadyExists) {
+			t.Fatalf("expected entry already exists error, got %v", err)
+		}
+		if expected := `LDAP Result Code 68 "Entry Already Exists": update with message ID 7 failed: `; err.Error() != expected {
+			t.Errorf("expected error %q, got %q", expected, err)
+		}
+	})
+}
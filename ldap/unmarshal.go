@@ -0,0 +1,240 @@
+// File contains the mapping of entries to tagged structs
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// structTag is the key of the struct tags naming the attributes of fields
+const structTag = "ldap"
+
+// dnField is the tag of the field holding the DN of the entry
+const dnField = "dn"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Unmarshal stores the attributes of the entry in the fields of the struct
+// pointed to by v, according to their `ldap:"attribute"` tags. The field
+// tagged `ldap:"dn"` receives the DN of the entry. Fields without a tag, or
+// whose attribute is not in the entry, are left unchanged.
+//
+// Fields may be of type string, []string, []byte, [][]byte, bool, time.Time,
+// and any integer type. Single valued fields receive the first value of the
+// attribute. Booleans are parsed from the "TRUE" and "FALSE" LDAP syntax and
+// times from the Generalized Time syntax.
+func (e *Entry) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("ldap: Unmarshal requires a non-nil pointer to a struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, _ := fieldAttribute(field)
+		if name == "" {
+			continue
+		}
+		if name == dnField {
+			if field.Type.Kind() != reflect.String {
+				return fmt.Errorf("ldap: field %s holding the DN must be a string", field.Name)
+			}
+			rv.Field(i).SetString(e.DN)
+			continue
+		}
+		attr := e.getAttribute(name)
+		if attr == nil {
+			continue
+		}
+		if err := setField(rv.Field(i), attr); err != nil {
+			return fmt.Errorf("ldap: cannot unmarshal attribute %s into field %s: %s", name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+// fieldAttribute returns the attribute named by the tag of an exported field,
+// or "" if the field is not mapped, and whether the tag has the omitempty
+// option
+func fieldAttribute(field reflect.StructField) (name string, omitEmpty bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	parts := strings.Split(field.Tag.Get(structTag), ",")
+	if parts[0] == "-" {
+		return "", false
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty
+}
+
+// getAttribute returns the attribute with the given name, ignoring case and
+// the binary option, or nil
+func (e *Entry) getAttribute(name string) *EntryAttribute {
+	binary := WithBinaryOption(name)
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, name) || strings.EqualFold(attr.Name, binary) {
+			return attr
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, attr *EntryAttribute) error {
+	if len(attr.ByteValues) == 0 {
+		return nil
+	}
+	value := string(attr.ByteValues[0])
+
+	if field.Type() == timeType {
+		t, err := ParseGeneralizedTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := parseBoolean(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			values := make([]string, len(attr.ByteValues))
+			for i, value := range attr.ByteValues {
+				values[i] = string(value)
+			}
+			field.Set(reflect.ValueOf(values))
+		case reflect.Uint8:
+			field.SetBytes(append([]byte(nil), attr.ByteValues[0]...))
+		case reflect.Slice:
+			if field.Type().Elem().Elem().Kind() != reflect.Uint8 {
+				return fmt.Errorf("unsupported type %s", field.Type())
+			}
+			values := make([][]byte, len(attr.ByteValues))
+			for i, value := range attr.ByteValues {
+				values[i] = append([]byte(nil), value...)
+			}
+			field.Set(reflect.ValueOf(values))
+		default:
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// parseBoolean parses a value of the Boolean syntax, also accepting the
+// values of strconv.ParseBool
+func parseBoolean(value string) (bool, error) {
+	switch value {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// ParseGeneralizedTime parses a value of the Generalized Time syntax, such as
+// "20261015123000Z" or "20261015123000.5+0200" (RFC 4517, section 3.3.13)
+func ParseGeneralizedTime(value string) (time.Time, error) {
+	invalid := fmt.Errorf("ldap: invalid generalized time %q", value)
+	if len(value) < 11 {
+		return time.Time{}, invalid
+	}
+
+	// the time zone is Z or a +hh[mm] or -hh[mm] offset
+	var location *time.Location
+	rest := value
+	switch i := strings.IndexAny(value, "Z+-"); {
+	case i < 0:
+		return time.Time{}, invalid
+	case value[i] == 'Z':
+		if i != len(value)-1 {
+			return time.Time{}, invalid
+		}
+		location = time.UTC
+		rest = value[:i]
+	default:
+		offset := value[i+1:]
+		if len(offset) != 2 && len(offset) != 4 {
+			return time.Time{}, invalid
+		}
+		hours, err := strconv.Atoi(offset[:2])
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		minutes := 0
+		if len(offset) == 4 {
+			if minutes, err = strconv.Atoi(offset[2:]); err != nil {
+				return time.Time{}, invalid
+			}
+		}
+		seconds := hours*3600 + minutes*60
+		if value[i] == '-' {
+			seconds = -seconds
+		}
+		location = time.FixedZone("", seconds)
+		rest = value[:i]
+	}
+
+	// the fraction applies to the last of the hour, minute and second
+	fraction := 0.0
+	if i := strings.IndexAny(rest, ".,"); i >= 0 {
+		f, err := strconv.ParseFloat("0."+rest[i+1:], 64)
+		if err != nil || i+1 == len(rest) {
+			return time.Time{}, invalid
+		}
+		fraction = f
+		rest = rest[:i]
+	}
+
+	var layout string
+	var unit time.Duration
+	switch len(rest) {
+	case 10:
+		layout, unit = "2006010215", time.Hour
+	case 12:
+		layout, unit = "200601021504", time.Minute
+	case 14:
+		layout, unit = "20060102150405", time.Second
+	default:
+		return time.Time{}, invalid
+	}
+	t, err := time.ParseInLocation(layout, rest, location)
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	return t.Add(time.Duration(fraction * float64(unit))), nil
+}
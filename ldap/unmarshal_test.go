@@ -0,0 +1,88 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	DN          string    `ldap:"dn"`
+	CN          string    `ldap:"cn"`
+	Mail        []string  `ldap:"mail"`
+	UIDNumber   int       `ldap:"uidNumber"`
+	Disabled    bool      `ldap:"nsAccountLock"`
+	Created     time.Time `ldap:"createTimestamp"`
+	Photo       []byte    `ldap:"jpegPhoto"`
+	Certificate [][]byte  `ldap:"userCertificate"`
+	Ignored     string    `ldap:"-"`
+	Untagged    string
+	missing     string `ldap:"description"`
+}
+
+func TestEntryUnmarshal(t *testing.T) {
+	photo := []byte{0xff, 0xd8, 0xff}
+	entry := NewEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{
+		"CN":              {"John Doe"},
+		"mail":            {"jdoe@example.com", "john.doe@example.com"},
+		"uidNumber":       {"1000"},
+		"nsAccountLock":   {"TRUE"},
+		"createTimestamp": {"20261015123000Z"},
+		"description":     {"not exported"},
+	})
+	entry.Attributes = append(entry.Attributes,
+		NewBinaryEntryAttribute("jpegPhoto", [][]byte{photo}),
+		NewBinaryEntryAttribute("userCertificate;binary", [][]byte{{0x30, 0x01}, {0x30, 0x02}}),
+	)
+
+	user := testUser{Untagged: "unchanged"}
+	if err := entry.Unmarshal(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.DN != entry.DN || user.CN != "John Doe" || user.UIDNumber != 1000 || !user.Disabled {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if len(user.Mail) != 2 || user.Mail[1] != "john.doe@example.com" {
+		t.Errorf("unexpected mail %v", user.Mail)
+	}
+	if !user.Created.Equal(time.Date(2026, 10, 15, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected creation time %s", user.Created)
+	}
+	if !bytes.Equal(user.Photo, photo) || len(user.Certificate) != 2 {
+		t.Errorf("unexpected binary values %x %x", user.Photo, user.Certificate)
+	}
+	if user.Untagged != "unchanged" || user.missing != "" {
+		t.Errorf("unexpected unmapped fields %+v", user)
+	}
+
+	if err := entry.Unmarshal(user); err == nil {
+		t.Error("expected an error unmarshaling into a non-pointer")
+	}
+	invalid := NewEntry("cn=test", map[string][]string{"uidNumber": {"many"}})
+	if err := invalid.Unmarshal(&user); err == nil {
+		t.Error("expected an error unmarshaling an invalid integer")
+	}
+}
+
+func TestParseGeneralizedTime(t *testing.T) {
+	for value, expected := range map[string]time.Time{
+		"20261015123000Z":     time.Date(2026, 10, 15, 12, 30, 0, 0, time.UTC),
+		"2026101512Z":         time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		"202610151230.5Z":     time.Date(2026, 10, 15, 12, 30, 30, 0, time.UTC),
+		"20261015123000.25Z":  time.Date(2026, 10, 15, 12, 30, 0, 250000000, time.UTC),
+		"20261015143000+0200": time.Date(2026, 10, 15, 12, 30, 0, 0, time.UTC),
+		"20261015073000,0-05": time.Date(2026, 10, 15, 12, 30, 0, 0, time.UTC),
+	} {
+		parsed, err := ParseGeneralizedTime(value)
+		if err != nil {
+			t.Errorf("%s: %s", value, err)
+		} else if !parsed.Equal(expected) {
+			t.Errorf("%s: got %s, expected %s", value, parsed, expected)
+		}
+	}
+	for _, value := range []string{"", "2026101512", "20261015123000", "20261015123000Zx", "202610151230001Z", "20261015123000.Z", "20261015123000+2"} {
+		if _, err := ParseGeneralizedTime(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
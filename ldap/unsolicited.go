@@ -0,0 +1,73 @@
+// File contains unsolicited notification functionality
+//
+// https://tools.ietf.org/html/rfc4511#section-4.4
+//
+// An unsolicited notification is an ExtendedResponse with message ID 0, sent
+// by the server without a corresponding request.
+
+package ldap
+
+import (
+	"errors"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// NoticeOfDisconnectionOID is the name of the unsolicited notification sent by
+// the server before closing the connection
+const NoticeOfDisconnectionOID = "1.3.6.1.4.1.1466.20036"
+
+// UnsolicitedNotification is a notification sent by the server without a
+// corresponding request
+type UnsolicitedNotification struct {
+	// Name is the OID of the notification, such as NoticeOfDisconnectionOID
+	Name string
+	// Value is the encoded notification value, or nil if the server sent none
+	Value []byte
+	// ResultCode is the result code of the notification, e.g.
+	// LDAPResultUnavailable when the server is shutting down
+	ResultCode uint8
+	// DiagnosticMessage is the message of the notification
+	DiagnosticMessage string
+	// Controls are the controls returned with the notification
+	Controls []Control
+}
+
+// SetUnsolicitedNotificationHandler sets the function called with every
+// unsolicited notification received on the connection, in its own goroutine.
+// After a notice of disconnection, the connection is closed and the pending
+// operations return the error of the notice, whether a handler is set or not.
+func (l *Conn) SetUnsolicitedNotificationHandler(handler func(*UnsolicitedNotification)) {
+	l.unsolicitedHandler.Store(handler)
+}
+
+// handleUnsolicitedNotification handles a message with ID 0. It is called by
+// the processMessages loop.
+func (l *Conn) handleUnsolicitedNotification(packet *asn1.Packet) {
+	if len(packet.Children) < 2 || packet.Children[1].Tag != ApplicationExtendedResponse {
+		l.Debug.Printf("Received unexpected message with ID 0")
+		return
+	}
+	response := decodeExtendedResponse(packet)
+	notification := &UnsolicitedNotification{
+		Name:     response.Name,
+		Value:    response.Value,
+		Controls: response.Controls,
+	}
+	notification.ResultCode, notification.DiagnosticMessage = getLDAPResultCode(packet)
+	l.Debug.Printf("Received unsolicited notification %q", notification.Name)
+
+	if handler, _ := l.unsolicitedHandler.Load().(func(*UnsolicitedNotification)); handler != nil {
+		go handler(notification)
+	}
+
+	if notification.Name == NoticeOfDisconnectionOID {
+		description := notification.DiagnosticMessage
+		if description == "" {
+			description = "ldap: notice of disconnection"
+		}
+		l.closeErr.Store(NewError(notification.ResultCode, errors.New(description)))
+		// Close waits for this loop to exit
+		go l.Close()
+	}
+}
@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newNoticeOfDisconnection returns a notice of disconnection packet with the
+// given result code
+func newNoticeOfDisconnection(resultCode int, diagnosticMessage string) *asn1.Packet {
+	packet := newResultPacket(0, ApplicationExtendedResponse, resultCode, diagnosticMessage)
+	response := packet.Children[1]
+	response.AppendChild(asn1.NewString(asn1.ClassContext, asn1.TypePrimitive, 10, NoticeOfDisconnectionOID, "Response Name"))
+
+	// rebuild the envelope, as appending copies the child
+	rebuilt := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	rebuilt.AppendChild(packet.Children[0])
+	rebuilt.AppendChild(response)
+	return rebuilt
+}
+
+func TestNoticeOfDisconnection(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	notifications := make(chan *UnsolicitedNotification, 1)
+	conn.SetUnsolicitedNotificationHandler(func(n *UnsolicitedNotification) {
+		notifications <- n
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := conn.Compare("uid=jdoe,dc=example,dc=com", "uid", "jdoe")
+		errs <- err
+	}()
+
+	runWithTimeout(t, time.Second, func() {
+		if _, err := ptc.ReceiveRequest(); err != nil {
+			t.Fatal(err)
+		}
+		ptc.SendResponse(newNoticeOfDisconnection(LDAPResultUnavailable, "server shutting down"))
+
+		n := <-notifications
+		if n.Name != NoticeOfDisconnectionOID || n.ResultCode != LDAPResultUnavailable || n.DiagnosticMessage != "server shutting down" {
+			t.Errorf("unexpected notification %+v", n)
+		}
+		if err := <-errs; !IsErrorWithCode(err, LDAPResultUnavailable) {
+			t.Errorf("expected unavailable error, got %v", err)
+		}
+		for !conn.isClosing() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestUnsolicitedNotification(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	notifications := make(chan *UnsolicitedNotification, 1)
+	conn.SetUnsolicitedNotificationHandler(func(n *UnsolicitedNotification) {
+		notifications <- n
+	})
+
+	// a notification other than a notice of disconnection leaves the
+	// connection usable
+	ptc.SendResponse(newExtendedResponse(0, "value"))
+	go serveRequest(t, ptc, func(request *asn1.Packet) []*asn1.Packet {
+		return []*asn1.Packet{newResultPacket(request.Children[0].Value.(int64), ApplicationCompareResponse, LDAPResultCompareTrue, "")}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		if n := <-notifications; string(n.Value) != "value" {
+			t.Errorf("unexpected notification %+v", n)
+		}
+		if matches, err := conn.Compare("uid=jdoe,dc=example,dc=com", "uid", "jdoe"); err != nil || !matches {
+			t.Errorf("unexpected compare result %v, %v", matches, err)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+// File contains LDAP URL functionality
+//
+// https://tools.ietf.org/html/rfc4516
+//
+// The ldapi scheme, used by OpenLDAP for connections over Unix domain sockets,
+// has the percent-encoded path of the socket as host, e.g.
+// "ldapi://%2Fvar%2Frun%2Fslapd%2Fldapi".
+//
+//   ldapurl     = scheme COLON SLASH SLASH [host [COLON port]]
+//                    [SLASH dn [QUESTION [attributes]
+//                    [QUESTION [scope] [QUESTION [filter]
+//                    [QUESTION extensions]]]]]
+//   scope       = "base" / "one" / "sub"
+//   extensions  = extension *(COMMA extension)
+//   extension   = [EXCLAMATION] extype [EQUALS exvalue]
+
+package ldap
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Default ports of the LDAP URL schemes
+const (
+	DefaultLdapPort  = "389"
+	DefaultLdapsPort = "636"
+)
+
+// DefaultLdapiSocket is the socket path of ldapi URLs without host
+const DefaultLdapiSocket = "/var/run/ldapi"
+
+// URL is a parsed LDAP URL
+type URL struct {
+	// Scheme is "ldap", "ldaps" or "ldapi"
+	Scheme string
+	// Host is the host and optional port of the server, or the socket path
+	// for the ldapi scheme
+	Host string
+	// BaseDN is the DN of the search base
+	BaseDN string
+	// Attributes are the attributes to return
+	Attributes []string
+	// Scope is the search scope, ScopeBaseObject if not specified
+	Scope int
+	// Filter is the search filter, "(objectClass=*)" if not specified
+	Filter string
+	// Extensions are the URL extensions, with a leading "!" for critical ones
+	Extensions []string
+}
+
+// urlScopes maps the scopes of LDAP URLs to the search scopes
+var urlScopes = map[string]int{
+	"base": ScopeBaseObject,
+	"one":  ScopeSingleLevel,
+	"sub":  ScopeWholeSubtree,
+}
+
+// ParseURL parses an LDAP URL such as
+// "ldaps://ldap.example.com/ou=people,dc=example,dc=com?cn,mail?sub?(uid=jdoe)"
+func ParseURL(rawURL string) (*URL, error) {
+	i := strings.Index(rawURL, "://")
+	if i < 0 {
+		return nil, fmt.Errorf("ldap: invalid URL %q", rawURL)
+	}
+	u := &URL{
+		Scheme: strings.ToLower(rawURL[:i]),
+		Scope:  ScopeBaseObject,
+		Filter: "(objectClass=*)",
+	}
+	switch u.Scheme {
+	case "ldap", "ldaps", "ldapi":
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+
+	rest := rawURL[i+3:]
+	hostEnd := strings.IndexAny(rest, "/?")
+	if hostEnd < 0 {
+		hostEnd = len(rest)
+	}
+	host, err := url.PathUnescape(rest[:hostEnd])
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid host in URL %q: %s", rawURL, err)
+	}
+	u.Host = host
+	rest = rest[hostEnd:]
+	if rest == "" {
+		return u, nil
+	}
+	if rest[0] == '/' {
+		rest = rest[1:]
+	}
+
+	// dn ? attributes ? scope ? filter ? extensions
+	parts := strings.Split(rest, "?")
+	if len(parts) > 5 {
+		return nil, fmt.Errorf("ldap: too many parts in URL %q", rawURL)
+	}
+	for i, part := range parts {
+		parts[i], err = url.PathUnescape(part)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid URL %q: %s", rawURL, err)
+		}
+	}
+	u.BaseDN = parts[0]
+	if len(parts) > 1 && parts[1] != "" {
+		u.Attributes = strings.Split(parts[1], ",")
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		scope, ok := urlScopes[strings.ToLower(parts[2])]
+		if !ok {
+			return nil, fmt.Errorf("ldap: invalid scope %q in URL %q", parts[2], rawURL)
+		}
+		u.Scope = scope
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		u.Filter = parts[3]
+	}
+	if len(parts) > 4 && parts[4] != "" {
+		u.Extensions = strings.Split(parts[4], ",")
+		for _, extension := range u.Extensions {
+			// no extension is supported, so critical ones cannot be honored
+			if strings.HasPrefix(extension, "!") {
+				return nil, fmt.Errorf("ldap: unsupported critical extension %q in URL %q", extension, rawURL)
+			}
+		}
+	}
+	return u, nil
+}
+
+// Network returns the network of the server, "unix" for the ldapi scheme and
+// "tcp" otherwise
+func (u *URL) Network() string {
+	if u.Scheme == "ldapi" {
+		return "unix"
+	}
+	return "tcp"
+}
+
+// Address returns the host and port to connect to, using the default port of
+// the scheme if the URL has none. For the ldapi scheme, it returns the socket
+// path, DefaultLdapiSocket if the URL has none.
+func (u *URL) Address() string {
+	if u.Scheme == "ldapi" {
+		if u.Host == "" {
+			return DefaultLdapiSocket
+		}
+		return u.Host
+	}
+	host := u.Host
+	if host == "" {
+		host = "localhost"
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	port := DefaultLdapPort
+	if u.Scheme == "ldaps" {
+		port = DefaultLdapsPort
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// SearchRequest returns the search described by the URL
+func (u *URL) SearchRequest(controls ...Control) *SearchRequest {
+	return NewSearchRequest(u.BaseDN, u.Scope, NeverDerefAliases, 0, 0, false, u.Filter, u.Attributes, controls)
+}
+
+// String returns the URL, escaping the characters which are not allowed in its parts
+func (u *URL) String() string {
+	s := u.Scheme + "://" + escapeURLPart(u.Host, "/?") + "/" + escapeURLPart(u.BaseDN, "?")
+
+	scope := ""
+	for name, value := range urlScopes {
+		if value == u.Scope && u.Scope != ScopeBaseObject {
+			scope = name
+		}
+	}
+	filter := u.Filter
+	if filter == "(objectClass=*)" {
+		filter = ""
+	}
+	parts := []string{
+		escapeURLPart(strings.Join(u.Attributes, ","), "?"),
+		scope,
+		escapeURLPart(filter, "?"),
+		escapeURLPart(strings.Join(u.Extensions, ","), "?"),
+	}
+	// omit the trailing empty parts
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	for _, part := range parts {
+		s += "?" + part
+	}
+	return s
+}
+
+// escapeURLPart percent-encodes the given characters, the percent sign and
+// the characters not allowed in URLs
+func escapeURLPart(part string, special string) string {
+	var escaped []byte
+	for i := 0; i < len(part); i++ {
+		c := part[i]
+		if c == '%' || c <= ' ' || c >= 0x7f || c == '"' || c == '<' || c == '>' || strings.IndexByte(special, c) >= 0 {
+			escaped = append(escaped, fmt.Sprintf("%%%02X", c)...)
+		} else {
+			escaped = append(escaped, c)
+		}
+	}
+	return string(escaped)
+}
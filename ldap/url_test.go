@@ -0,0 +1,245 @@
+package ldap
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+func TestParseURL(t *testing.T) {
+	testcases := map[string]*URL{
+		"ldap://":                        &URL{Scheme: "ldap", Scope: ScopeBaseObject, Filter: "(objectClass=*)"},
+		"LDAPS://ldap.example.com:10636": &URL{Scheme: "ldaps", Host: "ldap.example.com:10636", Scope: ScopeBaseObject, Filter: "(objectClass=*)"},
+		"ldap://ldap.example.com/dc=example,dc=com?cn,mail?sub?(uid=jdoe)": &URL{
+			Scheme:     "ldap",
+			Host:       "ldap.example.com",
+			BaseDN:     "dc=example,dc=com",
+			Attributes: []string{"cn", "mail"},
+			Scope:      ScopeWholeSubtree,
+			Filter:     "(uid=jdoe)",
+		},
+		"ldap://[::1]/o=University%20of%20Michigan,c=US??one": &URL{
+			Scheme: "ldap",
+			Host:   "[::1]",
+			BaseDN: "o=University of Michigan,c=US",
+			Scope:  ScopeSingleLevel,
+			Filter: "(objectClass=*)",
+		},
+		"ldap://ldap.example.com/o=Question%3f,c=US????e-bindname=cn=Manager": &URL{
+			Scheme:     "ldap",
+			Host:       "ldap.example.com",
+			BaseDN:     "o=Question?,c=US",
+			Scope:      ScopeBaseObject,
+			Filter:     "(objectClass=*)",
+			Extensions: []string{"e-bindname=cn=Manager"},
+		},
+		"ldapi://%2Fvar%2Frun%2Fslapd%2Fldapi/cn=config": &URL{
+			Scheme: "ldapi",
+			Host:   "/var/run/slapd/ldapi",
+			BaseDN: "cn=config",
+			Scope:  ScopeBaseObject,
+			Filter: "(objectClass=*)",
+		},
+	}
+
+	for rawURL, expected := range testcases {
+		u, err := ParseURL(rawURL)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", rawURL, err)
+			continue
+		}
+		if !reflect.DeepEqual(u, expected) {
+			t.Errorf("%q: got %#v, expected %#v", rawURL, u, expected)
+		}
+	}
+}
+
+func TestParseInvalidURL(t *testing.T) {
+	testcases := []string{
+		"ldap.example.com",
+		"http://ldap.example.com/",
+		"ldap://ldap.example.com/dc=example,dc=com??subtree",
+		"ldap://ldap.example.com/dc=example,dc=com?????",
+		"ldap://ldap.example.com/dc=example%2,dc=com",
+		"ldap://ldap.example.com/????!e-bindname=cn=Manager",
+	}
+
+	for _, rawURL := range testcases {
+		if _, err := ParseURL(rawURL); err == nil {
+			t.Errorf("%q: expected error", rawURL)
+		}
+	}
+}
+
+func TestURLString(t *testing.T) {
+	testcases := []string{
+		"ldap:///",
+		"ldaps://ldap.example.com:10636/dc=example,dc=com",
+		"ldap://ldap.example.com/dc=example,dc=com?cn,mail?sub?(uid=jdoe)",
+		"ldap://ldap.example.com/o=Question%3F,c=US???(cn=a%20b)",
+		"ldapi://%2Fvar%2Frun%2Fslapd%2Fldapi/cn=config",
+	}
+
+	for _, rawURL := range testcases {
+		u, err := ParseURL(rawURL)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", rawURL, err)
+			continue
+		}
+		if u.String() != rawURL {
+			t.Errorf("got %q, expected %q", u.String(), rawURL)
+		}
+	}
+}
+
+func TestURLAddress(t *testing.T) {
+	testcases := map[string]string{
+		"ldap://":                     "localhost:389",
+		"ldaps://ldap.example.com":    "ldap.example.com:636",
+		"ldap://ldap.example.com:123": "ldap.example.com:123",
+		"ldaps://[::1]/":              "[::1]:636",
+		"ldapi://":                    DefaultLdapiSocket,
+		"ldapi://%2Ftmp%2Fldapi":      "/tmp/ldapi",
+	}
+
+	for rawURL, expected := range testcases {
+		u, err := ParseURL(rawURL)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", rawURL, err)
+			continue
+		}
+		if u.Address() != expected {
+			t.Errorf("%q: got %q, expected %q", rawURL, u.Address(), expected)
+		}
+	}
+}
+
+func TestURLSearchRequest(t *testing.T) {
+	u, err := ParseURL("ldap://ldap.example.com/dc=example,dc=com?cn?one?(uid=jdoe)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := u.SearchRequest()
+	if req.BaseDN != "dc=example,dc=com" || req.Scope != ScopeSingleLevel || req.Filter != "(uid=jdoe)" || !reflect.DeepEqual(req.Attributes, []string{"cn"}) {
+		t.Errorf("unexpected search request: %#v", req)
+	}
+}
+
+func TestDialURL(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan struct{})
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+		close(accepted)
+	}()
+
+	conn, err := DialURL("ldap://" + ln.Addr().String() + "/dc=example,dc=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-accepted
+	if conn.isTLS {
+		t.Error("expected a plain connection")
+	}
+
+	if _, err := DialURL("http://" + ln.Addr().String()); err == nil {
+		t.Error("expected error for an unsupported scheme")
+	}
+}
+
+func TestDialWithDialContext(t *testing.T) {
+	var dialed string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = network + " " + address
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			request, err := asn1.ReadPacket(server)
+			if err != nil {
+				return
+			}
+			server.Write(newResultPacket(request.Children[0].Value.(int64), ApplicationBindResponse, LDAPResultSuccess, "").Bytes())
+		}()
+		return client, nil
+	}
+
+	conn, err := DialURL("ldap://ldap.example.com", DialWithDialContext(dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if dialed != "tcp ldap.example.com:389" {
+		t.Errorf("expected the dial function to connect to the server, got %q", dialed)
+	}
+	if err := conn.UnauthenticatedBind(""); err != nil {
+		t.Errorf("bind through the dialed connection: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DialURLContext(ctx, "ldap://127.0.0.1:1"); !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("expected a network error dialing with a canceled context, got %v", err)
+	}
+}
+
+func TestDialURLUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "ldapi")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %s", err)
+	}
+	defer ln.Close()
+
+	mechanisms := make(chan string, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		request, err := asn1.ReadPacket(c)
+		if err != nil {
+			return
+		}
+		mechanisms <- string(request.Children[1].Children[2].Children[0].Data.Bytes())
+		c.Write(newResultPacket(request.Children[0].Value.(int64), ApplicationBindResponse, LDAPResultSuccess, "").Bytes())
+	}()
+
+	conn, err := DialURL("ldapi://" + url.PathEscape(socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := conn.ExternalBind(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if mechanism := <-mechanisms; mechanism != "EXTERNAL" {
+		t.Errorf("got mechanism %q, expected EXTERNAL", mechanism)
+	}
+}
@@ -0,0 +1,246 @@
+// File contains the validation of add and modify requests against a schema
+
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Syntaxes checked by the validation of values
+// https://tools.ietf.org/html/rfc4517#section-3.3
+const (
+	SyntaxBoolean         = "1.3.6.1.4.1.1466.115.121.1.7"
+	SyntaxDN              = "1.3.6.1.4.1.1466.115.121.1.12"
+	SyntaxDirectoryString = "1.3.6.1.4.1.1466.115.121.1.15"
+	SyntaxGeneralizedTime = "1.3.6.1.4.1.1466.115.121.1.24"
+	SyntaxIA5String       = "1.3.6.1.4.1.1466.115.121.1.26"
+	SyntaxInteger         = "1.3.6.1.4.1.1466.115.121.1.27"
+	SyntaxNumericString   = "1.3.6.1.4.1.1466.115.121.1.36"
+	SyntaxOID             = "1.3.6.1.4.1.1466.115.121.1.38"
+	SyntaxPrintableString = "1.3.6.1.4.1.1466.115.121.1.44"
+	SyntaxTelephoneNumber = "1.3.6.1.4.1.1466.115.121.1.50"
+)
+
+const (
+	extensibleObjectOID    = "1.3.6.1.4.1.1466.101.120.111"
+	extensibleObjectName   = "extensibleObject"
+	objectClassAttribute   = "objectClass"
+	printableStringSymbols = "'()+,-./:? "
+)
+
+// SetSchema makes Add and Modify validate their requests against the schema
+// before sending them, returning an *Error describing the first problem found
+// rather than the bare result code of the server. Requests are not validated
+// if schema is nil, which is the default.
+func (l *Conn) SetSchema(schema *Schema) {
+	l.schema.Store(schema)
+}
+
+func (l *Conn) loadSchema() *Schema {
+	schema, _ := l.schema.Load().(*Schema)
+	return schema
+}
+
+// ValidateAddRequest checks that the attributes of the request are defined,
+// that single valued attributes have one value and that values match their
+// syntax. If the request has an objectClass attribute, the object classes must
+// be defined, their required attributes present and the other attributes
+// allowed by them.
+func (s *Schema) ValidateAddRequest(addRequest *AddRequest) error {
+	present := make(map[*AttributeType]bool)
+	var objectClasses []string
+	for _, attribute := range addRequest.Attributes {
+		attributeType, err := s.validateValues(attribute.Type, attribute.Vals, attribute.ByteVals)
+		if err != nil {
+			return err
+		}
+		if attributeType.NoUserModification {
+			return NewError(LDAPResultConstraintViolation, fmt.Errorf("ldap: attribute %s cannot be set by clients", attribute.Type))
+		}
+		present[attributeType] = true
+		if s.AttributeType(objectClassAttribute) == attributeType {
+			objectClasses = append(objectClasses, attribute.Vals...)
+		}
+	}
+	if objectClasses == nil {
+		return nil
+	}
+
+	allowed := make(map[*AttributeType]bool)
+	extensible := false
+	for _, name := range s.objectClassChain(objectClasses) {
+		objectClass := s.ObjectClass(name)
+		if objectClass == nil {
+			return NewError(LDAPResultObjectClassViolation, fmt.Errorf("ldap: undefined object class %s", name))
+		}
+		if objectClass.OID == extensibleObjectOID || strings.EqualFold(name, extensibleObjectName) {
+			extensible = true
+		}
+		for _, must := range objectClass.Must {
+			attributeType := s.AttributeType(must)
+			if attributeType != nil && !present[attributeType] {
+				return NewError(LDAPResultObjectClassViolation, fmt.Errorf("ldap: attribute %s required by object class %s is missing", must, name))
+			}
+			allowed[attributeType] = true
+		}
+		for _, may := range objectClass.May {
+			allowed[s.AttributeType(may)] = true
+		}
+	}
+	if extensible {
+		return nil
+	}
+	for _, attribute := range addRequest.Attributes {
+		attributeType := s.AttributeType(attribute.Type)
+		if !allowed[attributeType] && attributeType.Usage == UsageUserApplications {
+			return NewError(LDAPResultObjectClassViolation, fmt.Errorf("ldap: attribute %s is not allowed by the object classes %s", attribute.Type, strings.Join(objectClasses, ", ")))
+		}
+	}
+	return nil
+}
+
+// ValidateModifyRequest checks that the modified attributes are defined and
+// modifiable, that no more than one value is added or replaced for single
+// valued attributes and that values match their syntax
+func (s *Schema) ValidateModifyRequest(modifyRequest *ModifyRequest) error {
+	for _, change := range modifyRequest.Changes {
+		attribute := change.Modification
+		attributeType, err := s.validateValues(attribute.Type, attribute.Vals, attribute.ByteVals)
+		if err != nil {
+			return err
+		}
+		if attributeType.NoUserModification {
+			return NewError(LDAPResultConstraintViolation, fmt.Errorf("ldap: attribute %s cannot be modified by clients", attribute.Type))
+		}
+	}
+	return nil
+}
+
+// validateValues returns the type of the attribute after checking the number
+// and syntax of its values
+func (s *Schema) validateValues(name string, vals []string, byteVals [][]byte) (*AttributeType, error) {
+	attributeType := s.AttributeType(name)
+	if attributeType == nil {
+		return nil, NewError(LDAPResultUndefinedAttributeType, fmt.Errorf("ldap: undefined attribute type %s", name))
+	}
+	if attributeType.SingleValue && len(vals)+len(byteVals) > 1 {
+		return nil, NewError(LDAPResultConstraintViolation, fmt.Errorf("ldap: attribute %s is single valued, got %d values", name, len(vals)+len(byteVals)))
+	}
+	syntax := s.syntaxOf(attributeType)
+	for _, value := range vals {
+		if err := validateSyntax(syntax, value); err != nil {
+			return nil, NewError(LDAPResultInvalidAttributeSyntax, fmt.Errorf("ldap: invalid value %q for attribute %s: %s", value, name, err))
+		}
+	}
+	for _, value := range byteVals {
+		if err := validateSyntax(syntax, string(value)); err != nil {
+			return nil, NewError(LDAPResultInvalidAttributeSyntax, fmt.Errorf("ldap: invalid value for attribute %s: %s", name, err))
+		}
+	}
+	return attributeType, nil
+}
+
+// syntaxOf returns the syntax of the attribute type, which may be inherited
+// from its supertypes
+func (s *Schema) syntaxOf(attributeType *AttributeType) string {
+	seen := make(map[*AttributeType]bool)
+	for attributeType != nil && !seen[attributeType] {
+		if attributeType.Syntax != "" {
+			return attributeType.Syntax
+		}
+		seen[attributeType] = true
+		attributeType = s.AttributeType(attributeType.Superior)
+	}
+	return ""
+}
+
+// objectClassChain returns the names of the object classes and of all their
+// superiors
+func (s *Schema) objectClassChain(names []string) []string {
+	seen := make(map[string]bool)
+	var chain []string
+	for len(names) > 0 {
+		name := names[0]
+		names = names[1:]
+		objectClass := s.ObjectClass(name)
+		key := strings.ToLower(name)
+		if objectClass != nil {
+			key = objectClass.OID
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		chain = append(chain, name)
+		if objectClass != nil {
+			names = append(names, objectClass.Superiors...)
+		}
+	}
+	return chain
+}
+
+// validateSyntax checks the value against the syntaxes with a simple textual
+// form. Values of other syntaxes are accepted.
+func validateSyntax(syntax, value string) error {
+	switch syntax {
+	case SyntaxBoolean:
+		if value != "TRUE" && value != "FALSE" {
+			return errors.New("expected TRUE or FALSE")
+		}
+	case SyntaxDN:
+		if _, err := ParseDN(value); err != nil {
+			return err
+		}
+	case SyntaxDirectoryString:
+		if value == "" || !utf8.ValidString(value) {
+			return errors.New("expected a non-empty UTF-8 string")
+		}
+	case SyntaxGeneralizedTime:
+		if _, err := ParseGeneralizedTime(value); err != nil {
+			return err
+		}
+	case SyntaxIA5String:
+		for i := 0; i < len(value); i++ {
+			if value[i] > 0x7f {
+				return errors.New("expected an ASCII string")
+			}
+		}
+	case SyntaxInteger:
+		if !isDigits(strings.TrimPrefix(value, "-")) {
+			return errors.New("expected an integer")
+		}
+	case SyntaxNumericString:
+		if !isDigits(strings.Replace(value, " ", "", -1)) {
+			return errors.New("expected digits and spaces")
+		}
+	case SyntaxOID:
+		if value == "" || strings.ContainsAny(value, " ,;=") {
+			return errors.New("expected an object identifier or descriptor")
+		}
+	case SyntaxPrintableString, SyntaxTelephoneNumber:
+		if value == "" {
+			return errors.New("expected a non-empty string")
+		}
+		for _, c := range value {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune(printableStringSymbols, c)) {
+				return fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package ldap
+
+import (
+	"testing"
+)
+
+func newValidationSchema(t *testing.T) *Schema {
+	schema, err := ParseSchema(NewEntry("cn=Subschema", map[string][]string{
+		"attributeTypes": append([]string{
+			"( 2.5.4.0 NAME 'objectClass' EQUALITY objectIdentifierMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.38 )",
+			"( 2.5.4.4 NAME ( 'sn' 'surname' ) SUP name )",
+			"( 2.5.4.20 NAME 'telephoneNumber' EQUALITY telephoneNumberMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.50 )",
+			"( 0.9.2342.19200300.100.1.10 NAME 'manager' EQUALITY distinguishedNameMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.12 )",
+		}, testSchemaAttributeTypes...),
+		"objectClasses": append([]string{
+			"( 1.3.6.1.4.1.1466.101.120.111 NAME 'extensibleObject' SUP top AUXILIARY )",
+		}, testSchemaObjectClasses...),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestValidateAddRequest(t *testing.T) {
+	schema := newValidationSchema(t)
+
+	valid := NewAddRequest("cn=John Doe,dc=example,dc=com")
+	valid.Attribute("objectClass", []string{"person"})
+	valid.Attribute("cn", []string{"John Doe"})
+	valid.Attribute("SN", []string{"Doe"})
+	valid.Attribute("telephoneNumber", []string{"+1 408 555 1234"})
+	if err := schema.ValidateAddRequest(valid); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for _, test := range []struct {
+		attributes map[string][]string
+		code       uint8
+	}{
+		{map[string][]string{"objectClass": {"person"}, "cn": {"x"}, "sn": {"x"}, "shoeSize": {"42"}}, LDAPResultUndefinedAttributeType},
+		{map[string][]string{"objectClass": {"person"}, "cn": {"x"}}, LDAPResultObjectClassViolation},
+		{map[string][]string{"objectClass": {"person"}, "cn": {"x"}, "sn": {"x"}, "manager": {"not a dn"}}, LDAPResultInvalidAttributeSyntax},
+		{map[string][]string{"objectClass": {"person"}, "cn": {"x"}, "sn": {"x"}, "manager": {"cn=boss"}}, LDAPResultObjectClassViolation},
+		{map[string][]string{"objectClass": {"unknown"}}, LDAPResultObjectClassViolation},
+		{map[string][]string{"uidNumber": {"1000", "1001"}}, LDAPResultConstraintViolation},
+		{map[string][]string{"uidNumber": {"many"}}, LDAPResultInvalidAttributeSyntax},
+		{map[string][]string{"createTimestamp": {"20261015123000Z"}}, LDAPResultConstraintViolation},
+		{map[string][]string{"telephoneNumber": {"+1 408 555 1234 ext. 5 #2"}}, LDAPResultInvalidAttributeSyntax},
+	} {
+		addRequest := NewAddRequest("cn=x,dc=example,dc=com")
+		for name, values := range test.attributes {
+			addRequest.Attribute(name, values)
+		}
+		if err := schema.ValidateAddRequest(addRequest); !IsErrorWithCode(err, test.code) {
+			t.Errorf("%v: expected result code %d, got %v", test.attributes, test.code, err)
+		}
+	}
+
+	// extensibleObject allows any attribute
+	extensible := NewAddRequest("cn=x,dc=example,dc=com")
+	extensible.Attribute("objectClass", []string{"person", "extensibleObject"})
+	extensible.Attribute("cn", []string{"x"})
+	extensible.Attribute("sn", []string{"x"})
+	extensible.Attribute("manager", []string{"cn=boss"})
+	if err := schema.ValidateAddRequest(extensible); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateModifyRequest(t *testing.T) {
+	schema := newValidationSchema(t)
+
+	modifyRequest := NewModifyRequest("cn=x,dc=example,dc=com")
+	modifyRequest.Replace("uidNumber", []string{"1001"})
+	modifyRequest.Delete("description", nil)
+	if err := schema.ValidateModifyRequest(modifyRequest); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	modifyRequest.Add("createTimestamp", []string{"20261015123000Z"})
+	if err := schema.ValidateModifyRequest(modifyRequest); !IsErrorWithCode(err, LDAPResultConstraintViolation) {
+		t.Errorf("expected constraint violation, got %v", err)
+	}
+}
+
+func TestConnValidatesRequests(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	conn.SetSchema(newValidationSchema(t))
+
+	// the requests fail before being sent
+	addRequest := NewAddRequest("cn=x,dc=example,dc=com")
+	addRequest.Attribute("shoeSize", []string{"42"})
+	if err := conn.Add(addRequest); !IsErrorWithCode(err, LDAPResultUndefinedAttributeType) {
+		t.Errorf("expected undefined attribute type, got %v", err)
+	}
+	modifyRequest := NewModifyRequest("cn=x,dc=example,dc=com")
+	modifyRequest.Replace("uidNumber", []string{"1", "2"})
+	if err := conn.Modify(modifyRequest); !IsErrorWithCode(err, LDAPResultConstraintViolation) {
+		t.Errorf("expected constraint violation, got %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+// File contains the streaming of attribute values as they are received
+
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// StreamAttributeValue reads the entry with the given DN and writes the first
+// value of the attribute to w as it is received, rather than holding it in
+// memory, e.g. for photos, certificates or backups stored in the directory.
+// It returns the number of bytes written. If the entry has no value of the
+// attribute, the error has the code LDAPResultNoSuchAttribute.
+//
+// w is called from the goroutine reading the connection, so the responses of
+// the other requests wait for it, and it must not use the connection. If w
+// fails, the rest of the value is discarded and the error is returned. The
+// message holding the value is still limited by SetMaxMessageSize, and a hook
+// set with OnReceive still gets the whole message.
+func (l *Conn) StreamAttributeValue(dn, attribute string, w io.Writer) (int64, error) {
+	return l.StreamAttributeValueContext(context.Background(), dn, attribute, w)
+}
+
+// StreamAttributeValueContext is like StreamAttributeValue, but abandons the
+// search and returns ctx.Err() if ctx is done before the value is received.
+func (l *Conn) StreamAttributeValueContext(ctx context.Context, dn, attribute string, w io.Writer) (int64, error) {
+	searchRequest := NewSearchRequest(dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{attribute}, nil)
+
+	// the stream is registered before the request is sent, as the response
+	// may be read as soon as it is
+	messageID := l.nextMessageID()
+	stream := &valueStream{attribute: attribute, w: w}
+	l.valueStreams.add(messageID, stream)
+	defer l.valueStreams.remove(messageID)
+
+	msgCtx, err := l.sendSearchWithID(messageID, searchRequest)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := l.receiveSearch(ctx, msgCtx, func(*Entry) error { return nil }); err != nil {
+		// the stream may still be written to if the search is abandoned
+		return 0, err
+	}
+	if stream.err != nil {
+		return stream.written, stream.err
+	}
+	if !stream.found {
+		return 0, NewError(LDAPResultNoSuchAttribute, fmt.Errorf("ldap: entry %s has no value of %s", dn, attribute))
+	}
+	return stream.written, nil
+}
+
+// valueStream is the attribute value of a search streamed to a writer. Its
+// fields are set by the reader of the connection before the entry is
+// delivered to the search.
+type valueStream struct {
+	attribute string
+	w         io.Writer
+	found     bool
+	written   int64
+	err       error
+}
+
+// valueStreams holds the value streams of a connection by message ID
+type valueStreams struct {
+	mu      sync.Mutex
+	streams map[int64]*valueStream
+	count   int32
+}
+
+func (s *valueStreams) add(messageID int64, stream *valueStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams == nil {
+		s.streams = make(map[int64]*valueStream)
+	}
+	s.streams[messageID] = stream
+	atomic.StoreInt32(&s.count, int32(len(s.streams)))
+}
+
+func (s *valueStreams) remove(messageID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams, messageID)
+	atomic.StoreInt32(&s.count, int32(len(s.streams)))
+}
+
+func (s *valueStreams) get(messageID int64) *valueStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[messageID]
+}
+
+// active returns whether any value is streamed, so that the messages are
+// only decoded element by element when needed
+func (s *valueStreams) active() bool {
+	return atomic.LoadInt32(&s.count) > 0
+}
+
+// readPacket reads the rest of the message starting with the given header,
+// writing the value of a SearchResultEntry to its stream instead of adding it
+// to the packet if the message has one
+func (s *valueStreams) readPacket(r io.Reader, header []byte, length, maxSize int64) (*asn1.Packet, error) {
+	messageIDData, err := readElement(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	messageID, err := asn1.DecodePacketErr(messageIDData)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := messageID.Value.(int64)
+	stream := s.get(id)
+	if stream == nil {
+		return readRemaining(r, length-int64(len(messageIDData)), header, messageIDData)
+	}
+
+	opHeader, opLength, err := readMessageHeader(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if opHeader[0] != byte(asn1.ClassApplication)|byte(asn1.TypeConstructed)|byte(ApplicationSearchResultEntry) {
+		return readRemaining(r, length-int64(len(messageIDData)+len(opHeader)), header, messageIDData, opHeader)
+	}
+	entry, err := stream.readEntry(r, opLength, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(messageID)
+	packet.AppendChild(entry)
+	if remaining := length - int64(len(messageIDData)+len(opHeader)) - opLength; remaining > 0 {
+		controls, err := readRemaining(r, remaining)
+		if err != nil {
+			return nil, err
+		}
+		packet.AppendChild(controls)
+	}
+	return packet, nil
+}
+
+var errInvalidEntry = errors.New("ldap: invalid search result entry")
+
+// readEntry reads the content of a SearchResultEntry of the given length,
+// writing the first value of the attribute to the writer of the stream
+func (v *valueStream) readEntry(r io.Reader, length, maxSize int64) (*asn1.Packet, error) {
+	objectName, err := readElement(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	entry := asn1.Encode(asn1.ClassApplication, asn1.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(asn1.DecodePacket(objectName))
+
+	attributesHeader, attributesLength, err := readMessageHeader(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(objectName)+len(attributesHeader))+attributesLength != length {
+		return nil, NewError(ErrorUnexpectedResponse, errInvalidEntry)
+	}
+	attributes := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attributes")
+	entry.AppendChild(attributes)
+	for read := int64(0); read < attributesLength; {
+		attributeHeader, attributeLength, err := readMessageHeader(r, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		read += int64(len(attributeHeader)) + attributeLength
+
+		attributeType, err := readElement(r, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		name := asn1.DecodePacket(attributeType)
+		attribute := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "Attribute")
+		attribute.AppendChild(name)
+		attributes.AppendChild(attribute)
+
+		valuesHeader, valuesLength, err := readMessageHeader(r, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(attributeType)+len(valuesHeader))+valuesLength != attributeLength {
+			return nil, NewError(ErrorUnexpectedResponse, errInvalidEntry)
+		}
+		values := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSet, nil, "Values")
+		attribute.AppendChild(values)
+		streamed, _ := name.Value.(string)
+		for read := int64(0); read < valuesLength; {
+			valueHeader, valueLength, err := readMessageHeader(r, maxSize)
+			if err != nil {
+				return nil, err
+			}
+			read += int64(len(valueHeader)) + valueLength
+			if v.found || !strings.EqualFold(streamed, v.attribute) {
+				value, err := readRemaining(r, valueLength, valueHeader)
+				if err != nil {
+					return nil, err
+				}
+				values.AppendChild(value)
+				continue
+			}
+			v.found = true
+			if err := v.write(r, valueLength); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entry, nil
+}
+
+// write copies the value of the given length to the writer of the stream. An
+// error of the writer is kept in the stream and the rest of the value is
+// discarded, so that only read errors are returned.
+func (v *valueStream) write(r io.Reader, length int64) error {
+	value := &io.LimitedReader{R: r, N: length}
+	w := &streamWriter{w: v.w}
+	written, err := io.Copy(w, value)
+	v.written += written
+	if w.err != nil {
+		v.err = w.err
+		_, err = io.Copy(ioutil.Discard, value)
+	}
+	if err != nil {
+		return err
+	}
+	if value.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// streamWriter keeps the error of the writer, to tell it apart from the
+// errors reading the value
+type streamWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if err != nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// readElement reads a whole BER element
+func readElement(r io.Reader, maxSize int64) ([]byte, error) {
+	header, length, err := readMessageHeader(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	element := make([]byte, int64(len(header))+length)
+	copy(element, header)
+	if _, err := io.ReadFull(r, element[len(header):]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return element, nil
+}
+
+// readRemaining reads the given number of bytes following the data already
+// read, and decodes them as a single packet
+func readRemaining(r io.Reader, length int64, read ...[]byte) (*asn1.Packet, error) {
+	var data bytes.Buffer
+	for _, b := range read {
+		data.Write(b)
+	}
+	if _, err := io.CopyN(&data, r, length); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return asn1.DecodePacketErr(data.Bytes())
+}
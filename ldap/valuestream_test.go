@@ -0,0 +1,66 @@
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n+len(p) > 1000 {
+		return 0, errors.New("disk full")
+	}
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestStreamAttributeValue(t *testing.T) {
+	server, conn := newTestBackendServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	photo := make([]byte, 300000)
+	rand.New(rand.NewSource(1)).Read(photo)
+	modify := NewModifyRequest("uid=jdoe,ou=people,dc=example,dc=com")
+	modify.Add("jpegPhoto", []string{string(photo)})
+	if err := conn.Modify(modify); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := conn.StreamAttributeValue("uid=jdoe,ou=people,dc=example,dc=com", "JPEGPhoto", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(photo)) || !bytes.Equal(buf.Bytes(), photo) {
+		t.Errorf("expected the %d bytes of the photo, got %d bytes", len(photo), n)
+	}
+
+	if _, err := conn.StreamAttributeValue("uid=asmith,ou=people,dc=example,dc=com", "jpegPhoto", &buf); !IsErrorWithCode(err, LDAPResultNoSuchAttribute) {
+		t.Errorf("expected an error of code %d, got %v", LDAPResultNoSuchAttribute, err)
+	}
+	if _, err := conn.StreamAttributeValue("uid=nobody,dc=example,dc=com", "jpegPhoto", &buf); !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+		t.Errorf("expected an error of code %d, got %v", LDAPResultNoSuchObject, err)
+	}
+
+	if _, err := conn.StreamAttributeValue("uid=jdoe,ou=people,dc=example,dc=com", "jpegPhoto", &failingWriter{}); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the error of the writer, got %v", err)
+	}
+
+	// the connection is still usable, and the other entries are not streamed
+	result, err := conn.Search(NewSearchRequest("uid=jdoe,ou=people,dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"jpegPhoto"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 || !bytes.Equal(result.Entries[0].GetRawAttributeValue("jpegPhoto"), photo) {
+		t.Error("unexpected photo in the search result")
+	}
+	if conn.valueStreams.active() {
+		t.Error("value stream still registered")
+	}
+}
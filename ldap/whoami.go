@@ -0,0 +1,41 @@
+// File contains the "Who am I?" extended operation
+//
+// https://tools.ietf.org/html/rfc4532
+
+package ldap
+
+import (
+	"context"
+)
+
+const (
+	whoAmIOID = "1.3.6.1.4.1.4203.1.11.3"
+)
+
+// WhoAmIResult contains the response from the server
+type WhoAmIResult struct {
+	// AuthzID is the authorization identity of the connection, such as
+	// "dn:cn=admin,dc=example,dc=com", or empty if it is anonymous
+	AuthzID string
+	// Controls are the controls returned with the response
+	Controls []Control
+}
+
+// WhoAmI returns the authorization identity of the connection
+func (l *Conn) WhoAmI(controls []Control) (*WhoAmIResult, error) {
+	return l.WhoAmIContext(context.Background(), controls)
+}
+
+// WhoAmIContext returns the authorization identity of the connection. If ctx
+// is done before the server responds, the request is abandoned and ctx.Err()
+// is returned.
+func (l *Conn) WhoAmIContext(ctx context.Context, controls []Control) (*WhoAmIResult, error) {
+	response, err := l.ExtendedContext(ctx, NewExtendedRequest(whoAmIOID, nil, controls...))
+	if err != nil {
+		return nil, err
+	}
+	return &WhoAmIResult{
+		AuthzID:  string(response.Value),
+		Controls: response.Controls,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostores/encoding/asn1"
+)
+
+// newExtendedResponse returns an extended response packet with the given
+// response value
+func newExtendedResponse(messageID int64, value string) *asn1.Packet {
+	packet := newResultPacket(messageID, ApplicationExtendedResponse, LDAPResultSuccess, "")
+	response := packet.Children[1]
+	responseValue := asn1.Encode(asn1.ClassContext, asn1.TypePrimitive, 11, nil, "Response Value")
+	responseValue.Data.Write([]byte(value))
+	response.AppendChild(responseValue)
+
+	// rebuild the envelope, as appending copies the child
+	rebuilt := asn1.Encode(asn1.ClassUniversal, asn1.TypeConstructed, asn1.TagSequence, nil, "LDAP Response")
+	rebuilt.AppendChild(packet.Children[0])
+	rebuilt.AppendChild(response)
+	return rebuilt
+}
+
+func TestWhoAmI(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go serveRequest(t, ptc, func(p *asn1.Packet) []*asn1.Packet {
+		if name := asn1.DecodeString(p.Children[1].Children[0].Data.Bytes()); name != whoAmIOID {
+			t.Errorf("unexpected request name %q", name)
+		}
+		if len(p.Children[1].Children) != 1 {
+			t.Error("unexpected request value")
+		}
+		return []*asn1.Packet{newExtendedResponse(p.Children[0].Value.(int64), "dn:cn=admin,dc=example,dc=com")}
+	})
+
+	runWithTimeout(t, time.Second, func() {
+		result, err := conn.WhoAmI(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.AuthzID != "dn:cn=admin,dc=example,dc=com" {
+			t.Errorf("got authzid %q", result.AuthzID)
+		}
+	})
+}